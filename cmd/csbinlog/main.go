@@ -0,0 +1,101 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command csbinlog reads MySQL/MariaDB binary log and relay log files from the
+// local file system and prints the events human readable or as JSON lines. No
+// running database server is required.
+//
+// Example: Who changed the price of product 3?
+//
+//		csbinlog -json -database shop -table catalog_product_entity_decimal \
+//			-start-datetime '2018-03-01 00:00:00' /archive/mysql-bin.0000*
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/pkg/sql/myreplicator"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation(timeLayout, s, time.Local)
+}
+
+func main() {
+	var (
+		asJSON        = flag.Bool("json", false, "Print each event as a JSON object in its own line")
+		startDatetime = flag.String("start-datetime", "", "Skip events before this time, format: "+timeLayout)
+		stopDatetime  = flag.String("stop-datetime", "", "Skip events after this time, format: "+timeLayout)
+		startPosition = flag.Uint("start-position", 0, "Start reading the first file at this position")
+		stopPosition  = flag.Uint("stop-position", 0, "Stop reading the last file at this position")
+		databases     = flag.String("database", "", "Comma separated list of database names")
+		tables        = flag.String("table", "", "Comma separated list of table names")
+	)
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] binlog-file [binlog-file...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ef := myreplicator.EventFilter{
+		StartPosition: uint32(*startPosition),
+		StopPosition:  uint32(*stopPosition),
+		Schemas:       splitList(*databases),
+		Tables:        splitList(*tables),
+	}
+	var err error
+	if ef.StartTime, err = parseTime(*startDatetime); err != nil {
+		fatal(err)
+	}
+	if ef.StopTime, err = parseTime(*stopDatetime); err != nil {
+		fatal(err)
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	ew := myreplicator.EventWriter{W: w, JSON: *asJSON}
+	if err := myreplicator.NewFileReader(ef).ReadFiles(ew.Write, flag.Args()...); err != nil {
+		w.Flush()
+		fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "%+v\n", err)
+	os.Exit(1)
+}
@@ -0,0 +1,307 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgdump"
+	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	pathBaseURL     = cfgpath.MustNewByParts("web/unsecure/base_url")
+	pathLocaleCode  = cfgpath.MustNewByParts("general/locale/code")
+	pathCountryDflt = cfgpath.MustNewByParts("general/country/default")
+)
+
+func newTestSections() element.SectionSlice {
+	return element.NewSectionSlice(
+		element.Section{
+			ID: cfgpath.NewRoute("web"),
+			Groups: element.NewGroupSlice(element.Group{
+				ID: cfgpath.NewRoute("unsecure"),
+				Fields: element.NewFieldSlice(
+					element.Field{ID: cfgpath.NewRoute("base_url"), Scopes: scope.PermStore},
+				),
+			}),
+		},
+		element.Section{
+			ID: cfgpath.NewRoute("general"),
+			Groups: element.NewGroupSlice(
+				element.Group{
+					ID: cfgpath.NewRoute("locale"),
+					Fields: element.NewFieldSlice(
+						element.Field{ID: cfgpath.NewRoute("code"), Scopes: scope.PermStore},
+					),
+				},
+				element.Group{
+					ID: cfgpath.NewRoute("country"),
+					Fields: element.NewFieldSlice(
+						element.Field{ID: cfgpath.NewRoute("default"), Scopes: scope.PermWebsite},
+					),
+				},
+			),
+		},
+	)
+}
+
+func newTestStoreService() *store.Service {
+	return store.MustNewService(cfgmock.NewService(),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
+		store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 1}),
+		store.WithTableStores(
+			&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
+			&store.TableStore{StoreID: 2, Code: dml.MakeNullString("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20, IsActive: true},
+		),
+	)
+}
+
+func newTestStorage(t *testing.T) config.Storager {
+	s := config.NewInMemoryStore()
+	require.NoError(t, s.Set(pathBaseURL, "https://corestore.io/"))
+	require.NoError(t, s.Set(pathCountryDflt.BindWebsite(1), []byte("DE")))
+	require.NoError(t, s.Set(pathLocaleCode.BindStore(1), "de_DE"))
+	require.NoError(t, s.Set(pathLocaleCode.BindStore(2), "de_AT"))
+	return s
+}
+
+const testDumpYAML = `default:
+  web:
+    unsecure:
+      base_url: https://corestore.io/
+websites:
+  euro:
+    general:
+      country:
+        default: DE
+stores:
+  at:
+    general:
+      locale:
+        code: de_AT
+  de:
+    general:
+      locale:
+        code: de_DE
+`
+
+// nullStorage returns a NotFound error for the path null, like the ccd
+// storage for a row with a NULL value.
+type nullStorage struct {
+	config.Storager
+	null cfgpath.Path
+}
+
+func (ns nullStorage) Get(p cfgpath.Path) (interface{}, error) {
+	if p.String() == ns.null.String() {
+		return nil, errors.NotFound.Newf("[cfgdump_test] Path %q has a NULL value", p)
+	}
+	return ns.Storager.Get(p)
+}
+
+func TestService_Export(t *testing.T) {
+	t.Parallel()
+
+	srv := cfgdump.MustNewService(newTestStorage(t), newTestStoreService(), newTestSections())
+	doc, err := srv.Export()
+	require.NoError(t, err)
+
+	t.Run("YAML", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, doc.Encode(&buf, cfgdump.FormatYAML))
+		assert.Exactly(t, testDumpYAML, buf.String())
+	})
+
+	t.Run("JSON round trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, doc.Encode(&buf, cfgdump.FormatJSON))
+		assert.Contains(t, buf.String(), `"euro": {`)
+
+		doc2, err := cfgdump.Decode(&buf, cfgdump.FormatJSON)
+		require.NoError(t, err)
+		assert.Exactly(t, doc, doc2)
+	})
+
+	t.Run("NULL value skipped", func(t *testing.T) {
+		s := nullStorage{Storager: newTestStorage(t), null: pathLocaleCode.BindStore(2)}
+		doc, err := cfgdump.MustNewService(s, newTestStoreService(), newTestSections()).Export()
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, doc.Encode(&buf, cfgdump.FormatYAML))
+		assert.NotContains(t, buf.String(), "de_AT")
+		assert.Contains(t, buf.String(), "de_DE")
+	})
+
+	t.Run("unknown store ID", func(t *testing.T) {
+		s := newTestStorage(t)
+		require.NoError(t, s.Set(pathLocaleCode.BindStore(99), "fr_FR"))
+		doc, err := cfgdump.MustNewService(s, newTestStoreService(), newTestSections()).Export()
+		assert.Nil(t, doc)
+		assert.True(t, errors.IsNotFound(err), "%+v", err)
+	})
+}
+
+const testImportYAML = `default:
+  web:
+    unsecure:
+      base_url: https://corestore.io/
+websites:
+  euro:
+    general:
+      country:
+        default: AT
+stores:
+  at:
+    web:
+      unsecure:
+        base_url: https://corestore.at/
+`
+
+func TestService_Import(t *testing.T) {
+	t.Parallel()
+
+	doc, err := cfgdump.Decode(strings.NewReader(testImportYAML), cfgdump.FormatYAML)
+	require.NoError(t, err)
+
+	t.Run("dry run", func(t *testing.T) {
+		s := newTestStorage(t)
+		srv := cfgdump.MustNewService(s, newTestStoreService(), newTestSections())
+
+		res, err := srv.Import(doc, true)
+		require.NoError(t, err)
+		require.Len(t, res.Changes, 2)
+		assert.Exactly(t, cfgdump.Change{Path: pathBaseURL.BindStore(2), New: "https://corestore.at/"}, res.Changes[0])
+		assert.Exactly(t, cfgdump.Change{Path: pathCountryDflt.BindWebsite(1), Old: "DE", HasOld: true, New: "AT"}, res.Changes[1])
+		assert.Contains(t, res.Diff, "-        default: DE\n+        default: AT\n")
+		assert.Contains(t, res.Diff, "+    web:\n+      unsecure:\n+        base_url: https://corestore.at/\n")
+
+		v, err := s.Get(pathCountryDflt.BindWebsite(1))
+		require.NoError(t, err)
+		assert.Exactly(t, []byte("DE"), v, "dry run must not write")
+	})
+
+	t.Run("apply", func(t *testing.T) {
+		s := newTestStorage(t)
+		srv := cfgdump.MustNewService(s, newTestStoreService(), newTestSections())
+
+		res, err := srv.Import(doc, false)
+		require.NoError(t, err)
+		assert.Len(t, res.Changes, 2)
+
+		v, err := s.Get(pathCountryDflt.BindWebsite(1))
+		require.NoError(t, err)
+		assert.Exactly(t, "AT", v)
+		v, err = s.Get(pathBaseURL.BindStore(2))
+		require.NoError(t, err)
+		assert.Exactly(t, "https://corestore.at/", v)
+
+		// second import does not change anything
+		res, err = srv.Import(doc, false)
+		require.NoError(t, err)
+		assert.Empty(t, res.Changes)
+		assert.Empty(t, res.Diff)
+	})
+}
+
+func TestService_Import_Errors(t *testing.T) {
+	t.Parallel()
+
+	runner := func(yml string, errBhf func(error) bool) func(*testing.T) {
+		return func(t *testing.T) {
+			doc, err := cfgdump.Decode(strings.NewReader(yml), cfgdump.FormatYAML)
+			require.NoError(t, err)
+			s := newTestStorage(t)
+			srv := cfgdump.MustNewService(s, newTestStoreService(), newTestSections())
+
+			res, err := srv.Import(doc, false)
+			assert.Nil(t, res)
+			assert.True(t, errBhf(err), "%+v", err)
+
+			v, err := s.Get(pathBaseURL)
+			require.NoError(t, err)
+			assert.Exactly(t, "https://corestore.io/", v, "nothing must be written")
+		}
+	}
+	t.Run("unknown website code", runner("default:\n  web:\n    unsecure:\n      base_url: https://x.io/\nwebsites:\n  asia:\n    general:\n      country:\n        default: CN\n", errors.NotFound.Match))
+	t.Run("unknown store code", runner("default:\n  web:\n    unsecure:\n      base_url: https://x.io/\nstores:\n  fr:\n    general:\n      locale:\n        code: fr_FR\n", errors.NotFound.Match))
+	t.Run("unknown path", runner("default:\n  web:\n    unsecure:\n      base_url: https://x.io/\n    secure:\n      base_url: https://x.io/\n", errors.NotFound.Match))
+	t.Run("scope not allowed", runner("default:\n  web:\n    unsecure:\n      base_url: https://x.io/\nstores:\n  de:\n    general:\n      country:\n        default: DE\n", errors.NotAllowed.Match))
+}
+
+type failingWriter struct {
+	config.Storager
+	failAt cfgpath.Path
+}
+
+func (fw failingWriter) Write(p cfgpath.Path, v interface{}) error {
+	if p.String() == fw.failAt.String() {
+		return errors.ConnectionFailed.Newf("DB away")
+	}
+	return fw.Set(p, v)
+}
+
+func TestService_Import_Rollback(t *testing.T) {
+	t.Parallel()
+
+	doc, err := cfgdump.Decode(strings.NewReader(testImportYAML), cfgdump.FormatYAML)
+	require.NoError(t, err)
+
+	s := newTestStorage(t)
+	srv := cfgdump.MustNewService(s, newTestStoreService(), newTestSections(),
+		cfgdump.WithWriter(failingWriter{Storager: s, failAt: pathCountryDflt.BindWebsite(1)}),
+	)
+
+	res, err := srv.Import(doc, false)
+	assert.Nil(t, res)
+	assert.True(t, errors.WriteFailed.Match(err), "%+v", err)
+
+	// The first change gets reverted by setting it to nil.
+	v, err := s.Get(pathBaseURL.BindStore(2))
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	doc, err = srv.Export()
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, doc.Encode(&buf, cfgdump.FormatYAML))
+	assert.Exactly(t, testDumpYAML, buf.String())
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	t.Parallel()
+
+	f, err := cfgdump.FormatFromFilename("config/production.YML")
+	require.NoError(t, err)
+	assert.Exactly(t, cfgdump.FormatYAML, f)
+
+	f, err = cfgdump.FormatFromFilename("production.json")
+	require.NoError(t, err)
+	assert.Exactly(t, cfgdump.FormatJSON, f)
+
+	_, err = cfgdump.FormatFromFilename("production.xml")
+	assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+}
@@ -0,0 +1,41 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgdump exports and imports configuration values as YAML or JSON
+// documents, similar to Magento's app:config:dump command.
+//
+// The document groups the values by scope. Websites and stores are referenced
+// by their code instead of their ID, so the document can be kept in a version
+// control system and deployed to environments with different IDs.
+//
+//		default:
+//		  web:
+//		    unsecure:
+//		      base_url: https://corestore.io/
+//		websites:
+//		  euro:
+//		    general:
+//		      locale:
+//		        code: de_DE
+//		stores:
+//		  at:
+//		    general:
+//		      locale:
+//		        code: de_AT
+//
+// An import validates each path against the element.SectionSlice and the
+// allowed scopes of a field before any value gets written. A dry run reports
+// only the changes and a unified diff between the current and the new
+// configuration.
+package cfgdump
@@ -0,0 +1,179 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Format defines the encoding of a Document.
+type Format uint8
+
+// Supported formats of a Document.
+const (
+	FormatYAML Format = iota
+	FormatJSON
+)
+
+// FormatFromFilename returns the format depending on the file extension.
+// Error behaviour: NotSupported.
+func FormatFromFilename(name string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".json":
+		return FormatJSON, nil
+	}
+	return 0, errors.NotSupported.Newf("[cfgdump] Unknown file extension of %q", name)
+}
+
+// Sections contains the values of one scope, nested by the section, group and
+// field IDs of a path.
+type Sections map[string]map[string]map[string]string
+
+// set sets the value of route a/b/c.
+func (s Sections) set(route, value string) error {
+	spl := strings.Split(route, "/")
+	if len(spl) != 3 {
+		return errors.NotSupported.Newf("[cfgdump] Path %q must have exactly three levels", route)
+	}
+	g, ok := s[spl[0]]
+	if !ok {
+		g = make(map[string]map[string]string)
+		s[spl[0]] = g
+	}
+	f, ok := g[spl[1]]
+	if !ok {
+		f = make(map[string]string)
+		g[spl[1]] = f
+	}
+	f[spl[2]] = value
+	return nil
+}
+
+// get returns the value of route a/b/c. Safe to call on a nil Sections.
+func (s Sections) get(route string) (string, bool) {
+	spl := strings.Split(route, "/")
+	if len(spl) != 3 {
+		return "", false
+	}
+	v, ok := s[spl[0]][spl[1]][spl[2]]
+	return v, ok
+}
+
+// each calls fn for each value with its route a/b/c.
+func (s Sections) each(fn func(route, value string) error) error {
+	for sec, groups := range s {
+		for grp, fields := range groups {
+			for fld, v := range fields {
+				if err := fn(sec+"/"+grp+"/"+fld, v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Document represents the exported configuration. Websites and stores are
+// identified by their code.
+type Document struct {
+	Default  Sections            `json:"default,omitempty" yaml:"default,omitempty"`
+	Websites map[string]Sections `json:"websites,omitempty" yaml:"websites,omitempty"`
+	Stores   map[string]Sections `json:"stores,omitempty" yaml:"stores,omitempty"`
+}
+
+// NewDocument creates a new empty Document.
+func NewDocument() *Document {
+	return &Document{
+		Default:  make(Sections),
+		Websites: make(map[string]Sections),
+		Stores:   make(map[string]Sections),
+	}
+}
+
+func scopeSections(m map[string]Sections, code string) Sections {
+	s, ok := m[code]
+	if !ok {
+		s = make(Sections)
+		m[code] = s
+	}
+	return s
+}
+
+// clone returns a deep copy of the Document.
+func (d *Document) clone() *Document {
+	c := NewDocument()
+	_ = d.Default.each(func(r, v string) error { return c.Default.set(r, v) })
+	for code, s := range d.Websites {
+		cs := scopeSections(c.Websites, code)
+		_ = s.each(func(r, v string) error { return cs.set(r, v) })
+	}
+	for code, s := range d.Stores {
+		cs := scopeSections(c.Stores, code)
+		_ = s.each(func(r, v string) error { return cs.set(r, v) })
+	}
+	return c
+}
+
+// Encode writes the Document in the requested format to w. The keys are
+// sorted, so the same configuration produces always the same output.
+func (d *Document) Encode(w io.Writer, f Format) error {
+	var data []byte
+	var err error
+	switch f {
+	case FormatYAML:
+		data, err = yaml.Marshal(d)
+	case FormatJSON:
+		data, err = json.MarshalIndent(d, "", "  ")
+		data = append(data, '\n')
+	default:
+		return errors.NotSupported.Newf("[cfgdump] Unknown Format %d", f)
+	}
+	if err != nil {
+		return errors.Fatal.New(err, "[cfgdump] Document.Encode Format %d", f)
+	}
+	_, err = w.Write(data)
+	return errors.WithStack(err)
+}
+
+// Decode reads a Document in the requested format from r.
+// Error behaviour: NotSupported or NotValid.
+func Decode(r io.Reader, f Format) (*Document, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	d := NewDocument()
+	switch f {
+	case FormatYAML:
+		err = yaml.Unmarshal(data, d)
+	case FormatJSON:
+		err = json.Unmarshal(data, d)
+	default:
+		return nil, errors.NotSupported.Newf("[cfgdump] Unknown Format %d", f)
+	}
+	if err != nil {
+		return nil, errors.NotValid.New(err, "[cfgdump] Decode Format %d", f)
+	}
+	return d, nil
+}
@@ -0,0 +1,291 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgdump
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/corestoreio/pkg/util/conv"
+	"github.com/corestoreio/pkg/util/diff"
+)
+
+// Option applies options to the Service.
+type Option func(*Service) error
+
+// WithWriter sets a custom writer for the imported values. For example the
+// *config.Service, so all subscribers of the pub/sub system get notified about
+// an import. Default writer is the config.Storager.
+func WithWriter(w config.Writer) Option {
+	return func(s *Service) error {
+		s.writer = w
+		return nil
+	}
+}
+
+// storageWriter adapts a config.Storager to the config.Writer interface.
+type storageWriter struct {
+	config.Storager
+}
+
+func (sw storageWriter) Write(p cfgpath.Path, value interface{}) error {
+	return sw.Set(p, value)
+}
+
+// Service exports the values of a config.Storager into a Document and imports
+// a Document back into the config.Storager.
+type Service struct {
+	storage  config.Storager
+	stores   *store.Service
+	sections element.SectionSlice
+	writer   config.Writer
+}
+
+// NewService creates a new dump service. Argument stores resolves the IDs of
+// websites and stores to their codes and vice versa. Argument sections
+// validates the paths of an import.
+func NewService(s config.Storager, stores *store.Service, sections element.SectionSlice, opts ...Option) (*Service, error) {
+	srv := &Service{
+		storage:  s,
+		stores:   stores,
+		sections: sections,
+		writer:   storageWriter{Storager: s},
+	}
+	for _, opt := range opts {
+		if err := opt(srv); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return srv, nil
+}
+
+// MustNewService same as NewService but panics on error.
+func MustNewService(s config.Storager, stores *store.Service, sections element.SectionSlice, opts ...Option) *Service {
+	srv, err := NewService(s, stores, sections, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return srv
+}
+
+// Export reads all values of the config.Storager and groups them by scope and
+// the code of the website or store. Paths with a nil value or a NotFound error,
+// like a NULL value in the database, get skipped.
+func (s *Service) Export() (*Document, error) {
+	keys, err := s.storage.AllKeys()
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgdump] Export.AllKeys")
+	}
+	doc := NewDocument()
+	for _, p := range keys {
+		v, err := s.storage.Get(p)
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgdump] Export.Get Path %q", p)
+		}
+		if v == nil {
+			continue
+		}
+		str, err := conv.ToStringE(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgdump] Export.ToStringE Path %q", p)
+		}
+		secs, err := s.exportSections(doc, p.ScopeID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgdump] Export Path %q", p)
+		}
+		if err := secs.set(p.Route.String(), str); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return doc, nil
+}
+
+func (s *Service) exportSections(doc *Document, scpID scope.TypeID) (Sections, error) {
+	scp, id := scpID.Unpack()
+	switch scp {
+	case scope.Default:
+		return doc.Default, nil
+	case scope.Website:
+		w, err := s.stores.Website(id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgdump] Website ID %d", id)
+		}
+		return scopeSections(doc.Websites, w.Code()), nil
+	case scope.Store:
+		st, err := s.stores.Store(id)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgdump] Store ID %d", id)
+		}
+		return scopeSections(doc.Stores, st.Code()), nil
+	}
+	return nil, errors.NotSupported.Newf("[cfgdump] Scope %s", scpID)
+}
+
+// Change describes a value which differs between the imported Document and the
+// current configuration.
+type Change struct {
+	Path cfgpath.Path
+	// Old contains the current value. HasOld reports whether the path has been
+	// set before the import.
+	Old    string
+	HasOld bool
+	New    string
+}
+
+// ImportResult reports the outcome of an import.
+type ImportResult struct {
+	// Changes contains all changed values sorted by their fully qualified
+	// path.
+	Changes []Change
+	// Diff contains a unified diff between the YAML encoded current and new
+	// configuration. Empty if nothing changes.
+	Diff string
+}
+
+// Import validates all values of doc and writes the changed values. Values of
+// the current configuration which are missing in doc stay untouched. Nothing
+// gets written if a code, path or scope is invalid. The writes are not atomic:
+// If a write fails, the already written values get restored on a best-effort
+// basis and a failing restore gets reported in the error. Concurrent readers
+// might see a partial import. A dry run only calculates the ImportResult.
+// Error behaviour: NotFound, NotAllowed, NotSupported or WriteFailed.
+func (s *Service) Import(doc *Document, dryRun bool) (*ImportResult, error) {
+	current, err := s.Export()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	merged := current.clone()
+
+	websiteIDs := make(map[string]int64, len(s.stores.Websites()))
+	for _, w := range s.stores.Websites() {
+		websiteIDs[w.Code()] = w.ID()
+	}
+	storeIDs := make(map[string]int64, len(s.stores.Stores()))
+	for _, st := range s.stores.Stores() {
+		storeIDs[st.Code()] = st.ID()
+	}
+
+	res := new(ImportResult)
+	collect := func(scpID scope.TypeID, in, cur, dst Sections) error {
+		return in.each(func(route, value string) error {
+			p, err := s.validate(scpID, route)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c := Change{Path: p, New: value}
+			c.Old, c.HasOld = cur.get(route)
+			if c.HasOld && c.Old == value {
+				return nil
+			}
+			res.Changes = append(res.Changes, c)
+			return dst.set(route, value)
+		})
+	}
+
+	if err := collect(scope.DefaultTypeID, doc.Default, current.Default, merged.Default); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for code, secs := range doc.Websites {
+		id, ok := websiteIDs[code]
+		if !ok {
+			return nil, errors.NotFound.Newf("[cfgdump] Website code %q not found", code)
+		}
+		if err := collect(scope.MakeTypeID(scope.Website, id), secs, current.Websites[code], scopeSections(merged.Websites, code)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	for code, secs := range doc.Stores {
+		id, ok := storeIDs[code]
+		if !ok {
+			return nil, errors.NotFound.Newf("[cfgdump] Store code %q not found", code)
+		}
+		if err := collect(scope.MakeTypeID(scope.Store, id), secs, current.Stores[code], scopeSections(merged.Stores, code)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if len(res.Changes) == 0 {
+		return res, nil
+	}
+	sort.Slice(res.Changes, func(i, j int) bool {
+		return res.Changes[i].Path.String() < res.Changes[j].Path.String()
+	})
+
+	var bufCur, bufNew bytes.Buffer
+	if err := current.Encode(&bufCur, FormatYAML); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := merged.Encode(&bufNew, FormatYAML); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if res.Diff, err = diff.Unified(bufCur.String(), bufNew.String()); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if dryRun {
+		return res, nil
+	}
+	for i, c := range res.Changes {
+		if err := s.writer.Write(c.Path, c.New); err != nil {
+			if rbErr := s.rollback(res.Changes[:i]); rbErr != nil {
+				return nil, errors.WriteFailed.New(err, "[cfgdump] Import.Write Path %q failed and rollback failed: %s", c.Path, rbErr)
+			}
+			return nil, errors.WriteFailed.New(err, "[cfgdump] Import.Write Path %q", c.Path)
+		}
+	}
+	return res, nil
+}
+
+// validate checks the scope, the route and the allowed scopes of the field.
+func (s *Service) validate(scpID scope.TypeID, route string) (cfgpath.Path, error) {
+	p, err := cfgpath.NewByParts(route)
+	if err != nil {
+		return cfgpath.Path{}, errors.Wrapf(err, "[cfgdump] Route %q", route)
+	}
+	p = p.Bind(scpID)
+	f, _, err := s.sections.FindField(p.Route)
+	if err != nil {
+		return cfgpath.Path{}, errors.NotFound.New(err, "[cfgdump] Path %q not defined in the sections", p)
+	}
+	if scp, _ := scpID.Unpack(); !f.Scopes.Has(scp) {
+		return cfgpath.Path{}, errors.NotAllowed.Newf("[cfgdump] Path %q not allowed in scope %s. Allowed: %s", p, scp, f.Scopes)
+	}
+	return p, nil
+}
+
+// rollback restores the previous values in reverse order. Paths which did not
+// exist before get set to nil, which deletes them. See config.Storager.
+func (s *Service) rollback(changes []Change) error {
+	var firstErr error
+	for i := len(changes) - 1; i >= 0; i-- {
+		c := changes[i]
+		var v interface{}
+		if c.HasOld {
+			v = c.Old
+		}
+		if err := s.writer.Write(c.Path, v); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "[cfgdump] Rollback Path %q", c.Path)
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,53 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgfeature
+
+import "context"
+
+type ctxKeyKey struct{}
+type ctxEvaluatedKey struct{}
+
+// Evaluated contains the resolved flags of a request. The key is the flag
+// name.
+type Evaluated map[string]bool
+
+// IsEnabled reports whether the flag has been resolved and is on.
+func (ev Evaluated) IsEnabled(name string) bool {
+	return ev[name]
+}
+
+// WithContextKey adds the key, e.g. a customer ID, a session ID or an IP
+// address, to the context. The key selects the bucket of a percentage rollout
+// and gets checked against the allow and deny lists.
+func WithContextKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, ctxKeyKey{}, key)
+}
+
+// FromContextKey returns the key set by WithContextKey.
+func FromContextKey(ctx context.Context) (string, bool) {
+	k, ok := ctx.Value(ctxKeyKey{}).(string)
+	return k, ok
+}
+
+// WithContextEvaluated adds the resolved flags to the context.
+func WithContextEvaluated(ctx context.Context, ev Evaluated) context.Context {
+	return context.WithValue(ctx, ctxEvaluatedKey{}, ev)
+}
+
+// FromContextEvaluated returns the flags resolved by the middleware WithFlags.
+func FromContextEvaluated(ctx context.Context) (Evaluated, bool) {
+	ev, ok := ctx.Value(ctxEvaluatedKey{}).(Evaluated)
+	return ev, ok
+}
@@ -0,0 +1,37 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgfeature provides feature flags and percentage rollouts backed by
+// configuration paths.
+//
+// A flag with the name "checkout_v2" reads its settings from the following
+// routes, which can be set in the default, website or store scope:
+//
+//		feature/checkout_v2/enabled    bool, turns the flag on or off
+//		feature/checkout_v2/percentage int 0-100, rollout to a share of keys
+//		feature/checkout_v2/allow      CSV of keys which always get the flag
+//		feature/checkout_v2/deny       CSV of keys which never get the flag
+//
+// A key identifies the user, e.g. a customer ID, a session ID or an IP
+// address. The percentage rollout puts a key into a bucket by hashing the
+// flag name and the key with a hash from package util/hashpool. The same key
+// always lands in the same bucket, so a user does not flip between on and
+// off with each request, and raising the percentage only adds new users.
+//
+// Service.IsEnabled evaluates a flag for the scope and key found in a
+// context. The middleware Service.WithFlags resolves a list of flags once per
+// request and stores the result in the request context. Service implements
+// config.MessageReceiver to drop its cached flags and to notify listeners
+// after a flag has been changed.
+package cfgfeature
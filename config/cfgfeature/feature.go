@@ -0,0 +1,334 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgfeature
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/fnv"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/net/mw"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/corestoreio/pkg/util/hashpool"
+)
+
+// RouteSection defines the first level of all feature flag routes.
+const RouteSection = "feature"
+
+// Route fields of a flag, the third level of a route.
+const (
+	FieldEnabled    = "enabled"
+	FieldPercentage = "percentage"
+	FieldAllow      = "allow"
+	FieldDeny       = "deny"
+)
+
+// Flag contains the settings of a feature flag for one scope.
+type Flag struct {
+	Name    string
+	Enabled bool
+	// Percentage of keys, 0-100, which get the enabled flag. Defaults to 100
+	// if not set in the configuration.
+	Percentage int
+	// Allow contains keys which always get the flag, even if it is disabled.
+	Allow []string
+	// Deny contains keys which never get the flag. Deny has precedence over
+	// Allow.
+	Deny []string
+}
+
+// Evaluate reports whether the flag is on for the key. The order of the
+// checks: Deny, Allow, Enabled and then Percentage. An empty key cannot be
+// bucketed and gets the flag only with a Percentage of 100.
+func (f Flag) Evaluate(tnk hashpool.Tank, key string) bool {
+	if key != "" {
+		if containsKey(f.Deny, key) {
+			return false
+		}
+		if containsKey(f.Allow, key) {
+			return true
+		}
+	}
+	switch {
+	case !f.Enabled || f.Percentage <= 0:
+		return false
+	case f.Percentage >= 100:
+		return true
+	case key == "":
+		return false
+	}
+	return Bucket(tnk, f.Name, key) < uint64(f.Percentage)
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Bucket hashes the flag name and the key and returns a stable bucket between
+// 0 and 99. The name is part of the hash, so that each flag rolls out to a
+// different group of keys.
+func Bucket(tnk hashpool.Tank, name, key string) uint64 {
+	var buf [64]byte
+	data := append(append(append(buf[:0], name...), ':'), key...)
+	var sumBuf [64]byte
+	sum := tnk.Sum(data, sumBuf[:0])
+	var s uint64
+	if len(sum) >= 8 {
+		s = binary.BigEndian.Uint64(sum)
+	} else {
+		for _, b := range sum {
+			s = s<<8 | uint64(b)
+		}
+	}
+	return s % 100
+}
+
+// ChangeFunc gets called after a flag has been changed in any scope. Name is
+// empty if all flags have been changed.
+type ChangeFunc func(name string)
+
+// Option applies options to the Service.
+type Option func(*Service) error
+
+// WithHash sets the hash to bucket keys for a percentage rollout. Default
+// hash: FNV-1a 64-bit.
+func WithHash(tnk hashpool.Tank) Option {
+	return func(s *Service) error {
+		s.hash = tnk
+		return nil
+	}
+}
+
+// WithHashName sets a hash previously registered in package hashpool.
+// Error behaviour: NotFound.
+func WithHashName(name string) Option {
+	return func(s *Service) error {
+		tnk, err := hashpool.FromRegistry(name)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		s.hash = tnk
+		return nil
+	}
+}
+
+// WithChangeListener adds functions which get called after a flag has been
+// changed. See Service.MessageConfig.
+func WithChangeListener(fns ...ChangeFunc) Option {
+	return func(s *Service) error {
+		s.listeners = append(s.listeners, fns...)
+		return nil
+	}
+}
+
+// WithErrorHandler sets the error handler of the middleware WithFlags.
+// Default: HTTP status code 500.
+func WithErrorHandler(eh mw.ErrorHandler) Option {
+	return func(s *Service) error {
+		s.errorHandler = eh
+		return nil
+	}
+}
+
+type flagKey struct {
+	name    string
+	scopeID scope.TypeID
+}
+
+// Service evaluates feature flags. The flags get loaded from the
+// configuration and cached per scope until a change message arrives. Safe for
+// concurrent use.
+type Service struct {
+	cfg          config.Getter
+	hash         hashpool.Tank
+	listeners    []ChangeFunc
+	errorHandler mw.ErrorHandler
+
+	mu    sync.RWMutex
+	flags map[flagKey]Flag
+	// generation gets incremented by MessageConfig. Flag caches a loaded flag
+	// only if no change message arrived during the load.
+	generation uint64
+}
+
+// NewService creates a new feature flag service which reads the flags from
+// cfg.
+func NewService(cfg config.Getter, opts ...Option) (*Service, error) {
+	s := &Service{
+		cfg:          cfg,
+		hash:         hashpool.New64(fnv.New64a),
+		errorHandler: mw.ErrorWithStatusCode(http.StatusInternalServerError),
+		flags:        make(map[flagKey]Flag),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return s, nil
+}
+
+// MustNewService same as NewService but panics on error.
+func MustNewService(cfg config.Getter, opts ...Option) *Service {
+	s, err := NewService(cfg, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Flag returns the flag settings for the scope. Routes not found in the
+// configuration fall back to the parent scope and finally to the zero
+// values, except Percentage which defaults to 100.
+func (s *Service) Flag(sg config.Scoped, name string) (Flag, error) {
+	fk := flagKey{name: name, scopeID: sg.ScopeID()}
+	s.mu.RLock()
+	f, ok := s.flags[fk]
+	gen := s.generation
+	s.mu.RUnlock()
+	if ok {
+		return f, nil
+	}
+
+	f, err := loadFlag(sg, name)
+	if err != nil {
+		return Flag{}, errors.Wrapf(err, "[cfgfeature] Service.Flag %q Scope %s", name, fk.scopeID)
+	}
+	s.mu.Lock()
+	if s.generation == gen {
+		s.flags[fk] = f
+	}
+	s.mu.Unlock()
+	return f, nil
+}
+
+func loadFlag(sg config.Scoped, name string) (Flag, error) {
+	f := Flag{Name: name, Percentage: 100}
+	if err := cfgpath.NewRoute(RouteSection, name, FieldEnabled).Validate(); err != nil {
+		return Flag{}, errors.WithStack(err)
+	}
+
+	var err error
+	if f.Enabled, err = sg.Bool(cfgpath.NewRoute(RouteSection, name, FieldEnabled)); err != nil && !errors.IsNotFound(err) {
+		return Flag{}, errors.WithStack(err)
+	}
+	switch p, err := sg.Int(cfgpath.NewRoute(RouteSection, name, FieldPercentage)); {
+	case err == nil:
+		f.Percentage = p
+	case !errors.IsNotFound(err):
+		return Flag{}, errors.WithStack(err)
+	}
+	if f.Allow, err = loadKeys(sg, cfgpath.NewRoute(RouteSection, name, FieldAllow)); err != nil {
+		return Flag{}, errors.WithStack(err)
+	}
+	if f.Deny, err = loadKeys(sg, cfgpath.NewRoute(RouteSection, name, FieldDeny)); err != nil {
+		return Flag{}, errors.WithStack(err)
+	}
+	return f, nil
+}
+
+func loadKeys(sg config.Scoped, r cfgpath.Route) ([]string, error) {
+	csv, err := sg.String(r)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var keys []string
+	for _, k := range strings.Split(csv, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+// IsEnabled reports whether the flag is on for the scope and the key found in
+// the context. If the middleware WithFlags has already resolved the flag, the
+// stored result gets returned. Without a scope in the context the default
+// scope applies. See scope.WithContext and WithContextKey.
+func (s *Service) IsEnabled(ctx context.Context, name string) (bool, error) {
+	if ev, ok := FromContextEvaluated(ctx); ok {
+		if on, ok := ev[name]; ok {
+			return on, nil
+		}
+	}
+	websiteID, storeID, _ := scope.FromContext(ctx)
+	f, err := s.Flag(s.cfg.NewScoped(websiteID, storeID), name)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	key, _ := FromContextKey(ctx)
+	return f.Evaluate(s.hash, key), nil
+}
+
+// Evaluate resolves all flags for the scope and the key found in the context.
+func (s *Service) Evaluate(ctx context.Context, names ...string) (Evaluated, error) {
+	ev := make(Evaluated, len(names))
+	for _, n := range names {
+		on, err := s.IsEnabled(ctx, n)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ev[n] = on
+	}
+	return ev, nil
+}
+
+// Subscribe registers the Service to receive change messages for all routes
+// below RouteSection. Returns the subscription ID.
+func (s *Service) Subscribe(sub config.Subscriber) (int, error) {
+	id, err := sub.Subscribe(cfgpath.NewRoute(RouteSection), s)
+	return id, errors.Wrap(err, "[cfgfeature] Service.Subscribe")
+}
+
+// MessageConfig implements config.MessageReceiver. It removes the cached
+// settings of the changed flag in all scopes and calls the change listeners.
+// A path without the flag name removes all cached flags. It never returns an
+// error, so the Service stays subscribed.
+func (s *Service) MessageConfig(p cfgpath.Path) error {
+	var name string
+	if p.Route.Separators() > 0 {
+		if r, err := p.Route.Part(2); err == nil {
+			name = r.String()
+		}
+	}
+
+	s.mu.Lock()
+	s.generation++
+	for fk := range s.flags {
+		if name == "" || fk.name == name {
+			delete(s.flags, fk)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, fn := range s.listeners {
+		fn(name)
+	}
+	return nil
+}
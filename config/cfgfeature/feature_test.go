@@ -0,0 +1,236 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgfeature_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgfeature"
+	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/corestoreio/pkg/util/hashpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.MessageReceiver = (*cfgfeature.Service)(nil)
+
+func pathFlag(name, field string) cfgpath.Path {
+	return cfgpath.MustNewByParts(cfgfeature.RouteSection, name, field)
+}
+
+func TestFlag_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	tnk := hashpool.New64(fnv.New64a)
+	tests := []struct {
+		flag cfgfeature.Flag
+		key  string
+		want bool
+	}{
+		{cfgfeature.Flag{Name: "a", Enabled: true, Percentage: 100}, "", true},
+		{cfgfeature.Flag{Name: "a", Enabled: false, Percentage: 100}, "k1", false},
+		{cfgfeature.Flag{Name: "a", Enabled: true, Percentage: 0}, "k1", false},
+		{cfgfeature.Flag{Name: "a", Enabled: true, Percentage: 50}, "", false},
+		{cfgfeature.Flag{Name: "a", Enabled: false, Allow: []string{"k1"}}, "k1", true},
+		{cfgfeature.Flag{Name: "a", Enabled: true, Percentage: 100, Deny: []string{"k1"}}, "k1", false},
+		{cfgfeature.Flag{Name: "a", Enabled: true, Percentage: 100, Allow: []string{"k1"}, Deny: []string{"k1"}}, "k1", false},
+	}
+	for i, test := range tests {
+		assert.Exactly(t, test.want, test.flag.Evaluate(tnk, test.key), "Index %d", i)
+	}
+}
+
+func TestBucket(t *testing.T) {
+	t.Parallel()
+
+	for _, tnk := range []hashpool.Tank{hashpool.New64(fnv.New64a), hashpool.New(sha256.New)} {
+		f := cfgfeature.Flag{Name: "checkout_v2", Enabled: true, Percentage: 30}
+		var on int
+		for i := 0; i < 2000; i++ {
+			key := strconv.Itoa(i)
+			b := cfgfeature.Bucket(tnk, f.Name, key)
+			assert.True(t, b < 100)
+			assert.Exactly(t, b, cfgfeature.Bucket(tnk, f.Name, key), "stable bucket")
+			if f.Evaluate(tnk, key) {
+				on++
+			}
+		}
+		assert.InDelta(t, 600, on, 100)
+
+		// raising the percentage keeps all previously enabled keys
+		f2 := f
+		f2.Percentage = 60
+		for i := 0; i < 2000; i++ {
+			if key := strconv.Itoa(i); f.Evaluate(tnk, key) {
+				assert.True(t, f2.Evaluate(tnk, key), "Key %s", key)
+			}
+		}
+	}
+	assert.NotEqual(t,
+		cfgfeature.Bucket(hashpool.New64(fnv.New64a), "a", "customer-4711"),
+		cfgfeature.Bucket(hashpool.New64(fnv.New64a), "b", "customer-4711"),
+	)
+}
+
+func TestService_IsEnabled(t *testing.T) {
+	t.Parallel()
+
+	cfg := cfgmock.NewService(cfgmock.PathValue{
+		pathFlag("checkout_v2", cfgfeature.FieldEnabled).String():                 false,
+		pathFlag("checkout_v2", cfgfeature.FieldEnabled).BindWebsite(1).String():  true,
+		pathFlag("checkout_v2", cfgfeature.FieldPercentage).BindStore(2).String(): 0,
+		pathFlag("checkout_v2", cfgfeature.FieldAllow).BindStore(2).String():      "4711, 4712",
+		pathFlag("checkout_v2", cfgfeature.FieldDeny).String():                    "666",
+	})
+	srv := cfgfeature.MustNewService(cfg)
+
+	tests := []struct {
+		websiteID, storeID int64
+		key                string
+		want               bool
+	}{
+		{0, 0, "", false},
+		{1, 0, "", true},
+		{1, 1, "1", true},
+		{1, 1, "666", false},
+		{1, 2, "1", false},
+		{1, 2, "4712", true},
+	}
+	for i, test := range tests {
+		ctx := scope.WithContext(context.Background(), test.websiteID, test.storeID)
+		if test.key != "" {
+			ctx = cfgfeature.WithContextKey(ctx, test.key)
+		}
+		on, err := srv.IsEnabled(ctx, "checkout_v2")
+		require.NoError(t, err, "Index %d", i)
+		assert.Exactly(t, test.want, on, "Index %d", i)
+	}
+
+	on, err := srv.IsEnabled(context.Background(), "unknown")
+	require.NoError(t, err)
+	assert.False(t, on)
+
+	_, err = srv.IsEnabled(context.Background(), "in valid")
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+}
+
+func TestService_MessageConfig(t *testing.T) {
+	t.Parallel()
+
+	p := pathFlag("checkout_v2", cfgfeature.FieldEnabled)
+	cfg := cfgmock.NewService(cfgmock.PathValue{
+		p.String(): true,
+	})
+	var subscribed string
+	cfg.SubscribeFn = func(r cfgpath.Route, _ config.MessageReceiver) (int, error) {
+		subscribed = r.String()
+		return 3, nil
+	}
+	var changed []string
+	srv := cfgfeature.MustNewService(cfg, cfgfeature.WithChangeListener(func(name string) {
+		changed = append(changed, name)
+	}))
+	id, err := srv.Subscribe(cfg)
+	require.NoError(t, err)
+	assert.Exactly(t, 3, id)
+	assert.Exactly(t, cfgfeature.RouteSection, subscribed)
+
+	ctx := context.Background()
+	on, err := srv.IsEnabled(ctx, "checkout_v2")
+	require.NoError(t, err)
+	assert.True(t, on)
+
+	cfg.UpdateValues(cfgmock.PathValue{p.String(): false})
+	on, err = srv.IsEnabled(ctx, "checkout_v2")
+	require.NoError(t, err)
+	assert.True(t, on, "cached")
+
+	require.NoError(t, srv.MessageConfig(p))
+	on, err = srv.IsEnabled(ctx, "checkout_v2")
+	require.NoError(t, err)
+	assert.False(t, on, "reloaded")
+
+	require.NoError(t, srv.MessageConfig(cfgpath.Path{Route: cfgpath.NewRoute(cfgfeature.RouteSection)}))
+	assert.Exactly(t, []string{"checkout_v2", ""}, changed)
+}
+
+// blockingGetter blocks the first Bool call after reading the value until
+// resume gets closed.
+type blockingGetter struct {
+	*cfgmock.Service
+	once    sync.Once
+	loading chan struct{}
+	resume  chan struct{}
+}
+
+func (bg *blockingGetter) NewScoped(websiteID, storeID int64) config.Scoped {
+	return config.NewScoped(bg, websiteID, storeID)
+}
+
+func (bg *blockingGetter) Bool(p cfgpath.Path) (bool, error) {
+	v, err := bg.Service.Bool(p)
+	bg.once.Do(func() {
+		close(bg.loading)
+		<-bg.resume
+	})
+	return v, err
+}
+
+func TestService_MessageConfig_DuringLoad(t *testing.T) {
+	t.Parallel()
+
+	p := pathFlag("checkout_v2", cfgfeature.FieldEnabled)
+	cfg := &blockingGetter{
+		Service: cfgmock.NewService(cfgmock.PathValue{p.String(): true}),
+		loading: make(chan struct{}),
+		resume:  make(chan struct{}),
+	}
+	srv := cfgfeature.MustNewService(cfg)
+	ctx := context.Background()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		on, err := srv.IsEnabled(ctx, "checkout_v2")
+		assert.NoError(t, err)
+		assert.True(t, on, "old value")
+	}()
+	<-cfg.loading
+	// The flag changes while the old value gets loaded.
+	cfg.UpdateValues(cfgmock.PathValue{p.String(): false})
+	require.NoError(t, srv.MessageConfig(p))
+	close(cfg.resume)
+	<-done
+
+	on, err := srv.IsEnabled(ctx, "checkout_v2")
+	require.NoError(t, err)
+	assert.False(t, on, "The old value must not be cached")
+}
+
+func TestWithHashName(t *testing.T) {
+	t.Parallel()
+
+	_, err := cfgfeature.NewService(cfgmock.NewService(), cfgfeature.WithHashName("cfgfeature_unknown"))
+	assert.True(t, errors.NotFound.Match(err), "%+v", err)
+}
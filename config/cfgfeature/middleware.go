@@ -0,0 +1,82 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgfeature
+
+import (
+	"net/http"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/net/mw"
+	"github.com/corestoreio/pkg/net/request"
+)
+
+// KeyFunc extracts the key for the flag evaluation from a request. An empty
+// key disables the percentage rollout and the allow and deny lists for the
+// request.
+type KeyFunc func(*http.Request) string
+
+// KeyFromHeader uses the value of a request header as key.
+func KeyFromHeader(name string) KeyFunc {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// KeyFromCookie uses the value of a cookie as key, e.g. the session ID.
+func KeyFromCookie(name string) KeyFunc {
+	return func(r *http.Request) string {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return c.Value
+	}
+}
+
+// KeyFromRealIP uses the IP address of the client as key. For the argument
+// opts see request.RealIP.
+func KeyFromRealIP(opts int) KeyFunc {
+	return func(r *http.Request) string {
+		if ip := request.RealIP(r, opts); ip != nil {
+			return ip.String()
+		}
+		return ""
+	}
+}
+
+// WithFlags resolves the flags once per request for the scope found in the
+// request context and adds the result to the context. Must be added after the
+// middleware which sets the scope, like runmode.WithRunMode. The key gets
+// extracted via keyFn, which can be nil if the context already contains a
+// key. Handlers access the result via FromContextEvaluated or
+// Service.IsEnabled.
+func (s *Service) WithFlags(keyFn KeyFunc, names ...string) mw.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if keyFn != nil {
+				if key := keyFn(r); key != "" {
+					ctx = WithContextKey(ctx, key)
+				}
+			}
+			ev, err := s.Evaluate(ctx, names...)
+			if err != nil {
+				s.errorHandler(errors.Wrap(err, "[cfgfeature] WithFlags.Evaluate")).ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithContextEvaluated(ctx, ev)))
+		})
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgfeature_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/corestoreio/pkg/config/cfgfeature"
+	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/net/mw"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService_WithFlags(t *testing.T) {
+	t.Parallel()
+
+	cfg := cfgmock.NewService(cfgmock.PathValue{
+		pathFlag("checkout_v2", cfgfeature.FieldEnabled).BindStore(2).String(): true,
+		pathFlag("checkout_v2", cfgfeature.FieldDeny).BindStore(2).String():    "4711",
+		pathFlag("new_search", cfgfeature.FieldEnabled).String():               true,
+	})
+	srv := cfgfeature.MustNewService(cfg, cfgfeature.WithErrorHandler(mw.ErrorWithPanic))
+
+	var flagCalls int
+	h := srv.WithFlags(cfgfeature.KeyFromHeader("X-Customer-ID"), "checkout_v2", "new_search")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ev, ok := cfgfeature.FromContextEvaluated(r.Context())
+			require.True(t, ok)
+			flagCalls = cfg.BoolInvokes().Sum()
+			on, err := srv.IsEnabled(r.Context(), "checkout_v2")
+			require.NoError(t, err)
+			assert.Exactly(t, ev.IsEnabled("checkout_v2"), on)
+			assert.Exactly(t, flagCalls, cfg.BoolInvokes().Sum(), "resolved once per request")
+			if ev.IsEnabled("checkout_v2") {
+				w.Header().Set("X-Checkout", "v2")
+			}
+			if ev.IsEnabled("new_search") {
+				w.Header().Set("X-Search", "new")
+			}
+		}))
+
+	tests := []struct {
+		websiteID, storeID int64
+		customerID         string
+		wantCheckout       string
+		wantSearch         string
+	}{
+		{1, 2, "1", "v2", "new"},
+		{1, 2, "4711", "", "new"},
+		{1, 1, "1", "", "new"},
+	}
+	for i, test := range tests {
+		req := httptest.NewRequest("GET", "http://corestore.io/checkout", nil)
+		req = req.WithContext(scope.WithContext(req.Context(), test.websiteID, test.storeID))
+		req.Header.Set("X-Customer-ID", test.customerID)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Exactly(t, test.wantCheckout, rec.Header().Get("X-Checkout"), "Index %d", i)
+		assert.Exactly(t, test.wantSearch, rec.Header().Get("X-Search"), "Index %d", i)
+	}
+}
+
+func TestService_WithFlags_Error(t *testing.T) {
+	t.Parallel()
+
+	srv := cfgfeature.MustNewService(cfgmock.NewService())
+	h := srv.WithFlags(nil, "in valid")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("Should not get called")
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "http://corestore.io/", nil))
+	assert.Exactly(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), "[cfgfeature] WithFlags.Evaluate")
+}
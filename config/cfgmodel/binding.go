@@ -0,0 +1,302 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+)
+
+// BindingTag defines the name of the struct tag which contains the route of a
+// field. The route can be followed by the scope, which restricts the scope
+// fallback, if the route cannot be found in the sections:
+//		CookieLifetime time.Duration `cfg:"web/cookie/cookie_lifetime,store"`
+// Allowed scopes are default, website and store. Default scope is default.
+const BindingTag = "cfg"
+
+var (
+	typeString      = reflect.TypeOf("")
+	typeBool        = reflect.TypeOf(false)
+	typeInt         = reflect.TypeOf(int(0))
+	typeFloat64     = reflect.TypeOf(float64(0))
+	typeDuration    = reflect.TypeOf(time.Duration(0))
+	typeTime        = reflect.TypeOf(time.Time{})
+	typeURL         = reflect.TypeOf((*url.URL)(nil))
+	typeByteSlice   = reflect.TypeOf([]byte(nil))
+	typeStringSlice = reflect.TypeOf([]string(nil))
+	typeIntSlice    = reflect.TypeOf([]int(nil))
+)
+
+// BindingOption applies options to a Binding.
+type BindingOption func(*Binding) error
+
+// WithBindingSections sets the configuration structure. A field found in the
+// sections provides the default value and the scope permission.
+func WithBindingSections(ss element.SectionSlice) BindingOption {
+	return func(b *Binding) error {
+		b.sections = ss
+		return nil
+	}
+}
+
+// WithBindingDecoder sets the decoder for all struct fields which are not of a
+// primitive type. The raw value gets decoded into the field like in type
+// Encode.
+func WithBindingDecoder(d Decoder) BindingOption {
+	return func(b *Binding) error {
+		b.decoder = d
+		return nil
+	}
+}
+
+// boundField loads the value of a route into a struct field.
+type boundField struct {
+	index int
+	route cfgpath.Route
+	// load gets called with a pointer to the struct field.
+	load func(sg config.Scoped, fieldPtr interface{}) error
+}
+
+// Binding populates a struct from the configuration. The struct fields get
+// analyzed only once when calling NewBinding. Each tagged field gets compiled
+// into a loader which uses the matching type of this package, e.g. Str, Int or
+// Duration. Hence Load only iterates over the compiled fields without parsing
+// tags or switching types. Create a Binding once per struct type and reuse
+// it. Binding is safe for concurrent use.
+//
+// Supported field types: string, bool, int, float64, time.Duration,
+// time.Time, *url.URL, []byte, []string and []int, where the slices are comma
+// separated values. All other types require a Decoder, see
+// WithBindingDecoder.
+type Binding struct {
+	typ      reflect.Type
+	sections element.SectionSlice
+	decoder  Decoder
+	fields   []boundField
+}
+
+// NewBinding creates a new Binding for the struct pointer vPtr. vPtr serves
+// only as a type template. Error behaviour: NotSupported, NotValid.
+func NewBinding(vPtr interface{}, opts ...BindingOption) (*Binding, error) {
+	b := &Binding{
+		typ: reflect.TypeOf(vPtr),
+	}
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if b.typ == nil || b.typ.Kind() != reflect.Ptr || b.typ.Elem().Kind() != reflect.Struct {
+		return nil, errors.NotSupported.Newf("[cfgmodel] NewBinding requires a pointer to a struct. Have: %T", vPtr)
+	}
+
+	st := b.typ.Elem()
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		tag := sf.Tag.Get(BindingTag)
+		if tag == "" || tag == "-" {
+			continue
+		}
+		if sf.PkgPath != "" {
+			return nil, errors.NotSupported.Newf("[cfgmodel] NewBinding: Field %s.%s must be exported", st.Name(), sf.Name)
+		}
+		bf, err := b.compile(sf, tag)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[cfgmodel] NewBinding: Field %s.%s", st.Name(), sf.Name)
+		}
+		bf.index = i
+		b.fields = append(b.fields, bf)
+	}
+	return b, nil
+}
+
+// MustNewBinding same as NewBinding but panics on error.
+func MustNewBinding(vPtr interface{}, opts ...BindingOption) *Binding {
+	b, err := NewBinding(vPtr, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// compile creates the loader for a struct field.
+func (b *Binding) compile(sf reflect.StructField, tag string) (boundField, error) {
+	route, scp := tag, ""
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		route, scp = tag[:i], tag[i+1:]
+	}
+	if _, err := cfgpath.NewByParts(route); err != nil {
+		return boundField{}, errors.Wrapf(err, "[cfgmodel] Route %q", route)
+	}
+	bf := boundField{route: cfgpath.NewRoute(route)}
+
+	var opts []Option
+	switch scp {
+	case "", "default":
+	case "website":
+		opts = append(opts, WithScopeWebsite())
+	case "store":
+		opts = append(opts, WithScopeStore())
+	default:
+		return boundField{}, errors.NotValid.Newf("[cfgmodel] Unknown scope %q in tag %q", scp, tag)
+	}
+	if b.sections != nil {
+		f, _, err := b.sections.FindField(bf.route)
+		switch {
+		case err == nil:
+			opts = append(opts, WithField(&f))
+		case !errors.IsNotFound(err):
+			return boundField{}, errors.Wrapf(err, "[cfgmodel] Route %q", route)
+		}
+	}
+
+	switch sf.Type {
+	case typeString:
+		m := NewStr(route, opts...)
+		bf.load = func(sg config.Scoped, ptr interface{}) (err error) {
+			*ptr.(*string), err = m.Get(sg)
+			return
+		}
+	case typeBool:
+		m := NewBool(route, opts...)
+		bf.load = func(sg config.Scoped, ptr interface{}) (err error) {
+			*ptr.(*bool), err = m.Get(sg)
+			return
+		}
+	case typeInt:
+		m := NewInt(route, opts...)
+		bf.load = func(sg config.Scoped, ptr interface{}) (err error) {
+			*ptr.(*int), err = m.Get(sg)
+			return
+		}
+	case typeFloat64:
+		m := NewFloat64(route, opts...)
+		bf.load = func(sg config.Scoped, ptr interface{}) (err error) {
+			*ptr.(*float64), err = m.Get(sg)
+			return
+		}
+	case typeDuration:
+		m := NewDuration(route, opts...)
+		bf.load = func(sg config.Scoped, ptr interface{}) (err error) {
+			*ptr.(*time.Duration), err = m.Get(sg)
+			return
+		}
+	case typeTime:
+		m := NewTime(route, opts...)
+		bf.load = func(sg config.Scoped, ptr interface{}) (err error) {
+			*ptr.(*time.Time), err = m.Get(sg)
+			return
+		}
+	case typeURL:
+		m := NewURL(route, opts...)
+		bf.load = func(sg config.Scoped, ptr interface{}) (err error) {
+			*ptr.(**url.URL), err = m.Get(sg)
+			return
+		}
+	case typeByteSlice:
+		m := NewByte(route, opts...)
+		bf.load = func(sg config.Scoped, ptr interface{}) (err error) {
+			*ptr.(*[]byte), err = m.Get(sg)
+			return
+		}
+	case typeStringSlice:
+		m := NewStringCSV(route, opts...)
+		bf.load = func(sg config.Scoped, ptr interface{}) (err error) {
+			*ptr.(*[]string), err = m.Get(sg)
+			return
+		}
+	case typeIntSlice:
+		m := NewIntCSV(route, opts...)
+		bf.load = func(sg config.Scoped, ptr interface{}) (err error) {
+			*ptr.(*[]int), err = m.Get(sg)
+			return
+		}
+	default:
+		if b.decoder == nil {
+			return boundField{}, errors.NotSupported.Newf("[cfgmodel] Type %s requires a Decoder", sf.Type)
+		}
+		m := NewEncode(route, append(opts, WithDecoder(b.decoder))...)
+		bf.load = func(sg config.Scoped, ptr interface{}) error {
+			raw, err := m.Byte.Get(sg)
+			if err != nil || len(raw) == 0 {
+				return err // an empty value keeps the zero value of the field
+			}
+			return m.Decode(raw, ptr)
+		}
+	}
+	return bf, nil
+}
+
+// Routes returns the routes of all bound fields.
+func (b *Binding) Routes() []cfgpath.Route {
+	rs := make([]cfgpath.Route, len(b.fields))
+	for i, f := range b.fields {
+		rs[i] = f.route.Clone()
+	}
+	return rs
+}
+
+// Load populates the struct vPtr with the values of the scope. vPtr must have
+// the same type as passed to NewBinding. If a value cannot be found, the
+// default value of the field in the sections gets applied. Error behaviour:
+// NotValid.
+func (b *Binding) Load(sg config.Scoped, vPtr interface{}) error {
+	if t := reflect.TypeOf(vPtr); t != b.typ {
+		return errors.NotValid.Newf("[cfgmodel] Binding.Load type mismatch. Have: %s Want: %s", t, b.typ)
+	}
+	rv := reflect.ValueOf(vPtr)
+	if rv.IsNil() {
+		return errors.NotValid.Newf("[cfgmodel] Binding.Load nil pointer of type %s", b.typ)
+	}
+	rv = rv.Elem()
+	for _, f := range b.fields {
+		if err := f.load(sg, rv.Field(f.index).Addr().Interface()); err != nil {
+			return errors.Wrapf(err, "[cfgmodel] Binding.Load Route %q", f.route)
+		}
+	}
+	return nil
+}
+
+// Subscribe subscribes the MessageReceiver to the routes of all bound fields,
+// so the struct can be reloaded after a value has been written. The receiver
+// should load into a new struct and swap it, because writing into a struct
+// which gets read concurrently causes a race. Returns the subscription IDs.
+func (b *Binding) Subscribe(s config.Subscriber, mr config.MessageReceiver) ([]int, error) {
+	ids := make([]int, 0, len(b.fields))
+	for _, f := range b.fields {
+		id, err := s.Subscribe(f.route, mr)
+		if err != nil {
+			return ids, errors.Wrapf(err, "[cfgmodel] Binding.Subscribe Route %q", f.route)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ReloadFunc wraps a function to be used as a config.MessageReceiver, e.g. in
+// Binding.Subscribe.
+type ReloadFunc func(cfgpath.Path) error
+
+// MessageConfig implements interface config.MessageReceiver.
+func (rf ReloadFunc) MessageConfig(p cfgpath.Path) error {
+	return rf(p)
+}
@@ -0,0 +1,170 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel_test
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.MessageReceiver = (cfgmodel.ReloadFunc)(nil)
+
+type webCookie struct {
+	Lifetime   time.Duration `cfg:"web/cookie/cookie_lifetime"`
+	Path       string        `cfg:"web/cookie/cookie_path"`
+	Domain     string        `cfg:"web/cookie/cookie_domain,store"`
+	HTTPOnly   bool          `cfg:"web/cookie/cookie_httponly"`
+	MaxAge     int           `cfg:"web/cookie/max_age,website"`
+	Ratio      float64       `cfg:"web/cookie/ratio"`
+	BaseURL    *url.URL      `cfg:"web/unsecure/base_url,store"`
+	Methods    []string      `cfg:"web/cors/allowed_methods,store"`
+	Ports      []int         `cfg:"web/cors/ports"`
+	Restricted struct {
+		Countries []string `json:"countries"`
+	} `cfg:"web/cookie/restriction,store"`
+	ignored string
+	Skipped string `cfg:"-"`
+}
+
+var bindingStructure = element.MustNewConfiguration(
+	element.Section{
+		ID: cfgpath.NewRoute("web"),
+		Groups: element.NewGroupSlice(
+			element.Group{
+				ID: cfgpath.NewRoute("cookie"),
+				Fields: element.NewFieldSlice(
+					element.Field{ID: cfgpath.NewRoute("cookie_lifetime"), Type: element.TypeText, Scopes: scope.PermStore, Default: "1h"},
+					element.Field{ID: cfgpath.NewRoute("cookie_path"), Type: element.TypeText, Scopes: scope.PermWebsite, Default: "/"},
+					element.Field{ID: cfgpath.NewRoute("cookie_httponly"), Type: element.TypeSelect, Scopes: scope.PermStore, Default: true},
+				),
+			},
+		),
+	},
+)
+
+func TestBinding_Load(t *testing.T) {
+	t.Parallel()
+
+	b := cfgmodel.MustNewBinding(&webCookie{},
+		cfgmodel.WithBindingSections(bindingStructure),
+		cfgmodel.WithBindingDecoder(cfgmodel.DecodeFunc(json.Unmarshal)),
+	)
+	assert.Len(t, b.Routes(), 10)
+
+	sg := cfgmock.NewService(cfgmock.PathValue{
+		cfgpath.MustNewByParts("web/cookie/cookie_lifetime").BindStore(2).String(): "2h",
+		cfgpath.MustNewByParts("web/cookie/cookie_path").BindStore(2).String():     "/store", // website scope only
+		cfgpath.MustNewByParts("web/cookie/cookie_domain").BindWebsite(1).String(): "corestore.io",
+		cfgpath.MustNewByParts("web/cookie/max_age").BindWebsite(1).String():       3600,
+		cfgpath.MustNewByParts("web/cookie/ratio").String():                        0.5,
+		cfgpath.MustNewByParts("web/unsecure/base_url").BindStore(2).String():      "https://corestore.io/",
+		cfgpath.MustNewByParts("web/cors/allowed_methods").BindStore(2).String():   "GET,POST",
+		cfgpath.MustNewByParts("web/cors/ports").String():                          "80,443",
+		cfgpath.MustNewByParts("web/cookie/restriction").BindStore(2).String():     `{"countries":["DE","CH"]}`,
+	}).NewScoped(1, 2)
+
+	var wc webCookie
+	require.NoError(t, b.Load(sg, &wc))
+
+	assert.Exactly(t, 2*time.Hour, wc.Lifetime)
+	assert.Exactly(t, "/", wc.Path, "default value")
+	assert.Exactly(t, "corestore.io", wc.Domain, "fallback to website")
+	assert.True(t, wc.HTTPOnly, "default value")
+	assert.Exactly(t, 3600, wc.MaxAge)
+	assert.Exactly(t, 0.5, wc.Ratio)
+	require.NotNil(t, wc.BaseURL)
+	assert.Exactly(t, "corestore.io", wc.BaseURL.Host)
+	assert.Exactly(t, []string{"GET", "POST"}, wc.Methods)
+	assert.Exactly(t, []int{80, 443}, wc.Ports)
+	assert.Exactly(t, []string{"DE", "CH"}, wc.Restricted.Countries)
+	assert.Empty(t, wc.ignored)
+	assert.Empty(t, wc.Skipped)
+
+	// reuse for another scope
+	var wcDefault webCookie
+	require.NoError(t, b.Load(sg.Root.NewScoped(0, 0), &wcDefault))
+	assert.Exactly(t, time.Hour, wcDefault.Lifetime)
+	assert.Empty(t, wcDefault.Domain)
+	assert.Nil(t, wcDefault.BaseURL)
+	assert.Empty(t, wcDefault.Restricted.Countries)
+}
+
+func TestBinding_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := cfgmodel.NewBinding(webCookie{})
+	assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+
+	_, err = cfgmodel.NewBinding(&webCookie{})
+	assert.True(t, errors.NotSupported.Match(err), "requires a Decoder: %+v", err)
+
+	_, err = cfgmodel.NewBinding(&struct {
+		private string `cfg:"a/b/c"`
+	}{})
+	assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+
+	_, err = cfgmodel.NewBinding(&struct {
+		Scope string `cfg:"a/b/c,galaxy"`
+	}{})
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+
+	type cookie struct {
+		Path string `cfg:"web/cookie/cookie_path"`
+	}
+	b := cfgmodel.MustNewBinding(&cookie{})
+	err = b.Load(cfgmock.NewService().NewScoped(0, 0), &webCookie{})
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	err = b.Load(cfgmock.NewService().NewScoped(0, 0), (*cookie)(nil))
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+}
+
+func TestBinding_Subscribe(t *testing.T) {
+	t.Parallel()
+
+	type cookie struct {
+		Path   string `cfg:"web/cookie/cookie_path"`
+		Domain string `cfg:"web/cookie/cookie_domain"`
+	}
+	b := cfgmodel.MustNewBinding(&cookie{})
+
+	var routes []string
+	srv := cfgmock.NewService()
+	srv.SubscribeFn = func(r cfgpath.Route, mr config.MessageReceiver) (int, error) {
+		routes = append(routes, r.String())
+		return len(routes), mr.MessageConfig(cfgpath.MustNew(r))
+	}
+
+	var reloads int
+	ids, err := b.Subscribe(srv, cfgmodel.ReloadFunc(func(p cfgpath.Path) error {
+		reloads++
+		return nil
+	}))
+	require.NoError(t, err)
+	assert.Exactly(t, []int{1, 2}, ids)
+	assert.Exactly(t, []string{"web/cookie/cookie_path", "web/cookie/cookie_domain"}, routes)
+	assert.Exactly(t, 2, reloads)
+}
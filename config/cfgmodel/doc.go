@@ -41,4 +41,8 @@
 //
 // The default value gets returned if the Get call to the store configuration
 // value fails or a value is not set.
+//
+// Type Binding populates a whole struct at once. The fields declare their
+// routes in the struct tag `cfg` and get compiled once into the types of this
+// package.
 package cfgmodel
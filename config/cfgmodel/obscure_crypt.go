@@ -0,0 +1,335 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"strconv"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"golang.org/x/crypto/blowfish"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// Cipher versions as used in the ciphertext prefix
+// "<key version>:<cipher version>:<base64 data>". The numeric versions are
+// defined by Magento 2.
+const (
+	CipherBlowfish         = "0" // Magento 1 and early Magento 2, mcrypt ECB
+	CipherRijndael128      = "1" // mcrypt ECB
+	CipherRijndael256      = "2" // mcrypt CBC, Magento 2.0 - 2.2
+	CipherChaCha20Poly1305 = "3" // libsodium IETF, Magento >= 2.3
+	CipherAES256GCM        = "aes256gcm"
+)
+
+// CryptKeyLength defines the required length of a key.
+const CryptKeyLength = 32
+
+// Crypter encrypts values with AES-256-GCM and implements the interfaces
+// Encrypter and Decrypter. The ciphertext gets prefixed with the version of
+// the key and the cipher, so keys can be rotated: Encrypt uses always the
+// newest key and Decrypt selects the key by its version. The key versions
+// match the line numbers of the key in Magento's env.php `crypt/key`, so
+// Decrypt can also read values encrypted by Magento 2 (ChaCha20-Poly1305),
+// and legacy mcrypt values (Blowfish and Rijndael-128, both ECB, and
+// Rijndael-256 CBC). Crypter is safe for concurrent use.
+type Crypter struct {
+	keys [][]byte
+	gcms []cipher.AEAD
+	rand io.Reader
+}
+
+// NewCrypter creates a new Crypter. The index of a key in the slice defines
+// its version, the last key is the newest one. Each key must have a length of
+// 32 bytes. Error behaviour: Empty, NotValid.
+func NewCrypter(keys ...[]byte) (*Crypter, error) {
+	if len(keys) == 0 {
+		return nil, errors.Empty.Newf("[cfgmodel] NewCrypter requires at least one key")
+	}
+	c := &Crypter{
+		keys: keys,
+		gcms: make([]cipher.AEAD, len(keys)),
+		rand: rand.Reader,
+	}
+	for i, k := range keys {
+		if len(k) != CryptKeyLength {
+			return nil, errors.NotValid.Newf("[cfgmodel] Key version %d has an invalid length of %d, want %d", i, len(k), CryptKeyLength)
+		}
+		block, err := aes.NewCipher(k)
+		if err != nil {
+			return nil, errors.NotValid.New(err, "[cfgmodel] Key version %d", i)
+		}
+		if c.gcms[i], err = cipher.NewGCM(block); err != nil {
+			return nil, errors.NotValid.New(err, "[cfgmodel] Key version %d", i)
+		}
+	}
+	return c, nil
+}
+
+// MustNewCrypter same as NewCrypter but panics on error.
+func MustNewCrypter(keys ...[]byte) *Crypter {
+	c, err := NewCrypter(keys...)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// NewCrypterMagento creates a new Crypter from the value of `crypt/key` in
+// Magento's app/etc/env.php. Multiple keys are separated by a new line.
+func NewCrypterMagento(cryptKey string) (*Crypter, error) {
+	var keys [][]byte
+	for _, k := range bytes.Split(bytes.TrimSpace([]byte(cryptKey)), []byte("\n")) {
+		if k = bytes.TrimSpace(k); len(k) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	c, err := NewCrypter(keys...)
+	return c, errors.WithStack(err)
+}
+
+// prefix returns the ciphertext prefix of the newest key.
+func (c *Crypter) prefix() []byte {
+	return []byte(strconv.Itoa(len(c.keys)-1) + ":" + CipherAES256GCM + ":")
+}
+
+// Encrypt encrypts the plaintext with the newest key. The prefix gets
+// authenticated as additional data.
+func (c *Crypter) Encrypt(plaintext []byte) ([]byte, error) {
+	gcm := c.gcms[len(c.gcms)-1]
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := io.ReadFull(c.rand, nonce); err != nil {
+		return nil, errors.Fatal.New(err, "[cfgmodel] Crypter.Encrypt.Nonce")
+	}
+	prefix := c.prefix()
+	data := gcm.Seal(nonce, nonce, plaintext, prefix)
+
+	ret := make([]byte, len(prefix)+base64.StdEncoding.EncodedLen(len(data)))
+	copy(ret, prefix)
+	base64.StdEncoding.Encode(ret[len(prefix):], data)
+	return ret, nil
+}
+
+// Decrypt decrypts a ciphertext created by Encrypt or by Magento. Magento
+// supports these formats: "<key>:<cipher>:<iv>:<data>" with Rijndael-256 CBC,
+// "<key>:<cipher>:<data>", "<cipher>:<data>" with key version 0 and "<data>"
+// with key version 0 and Blowfish. Error behaviour: NotValid, NotFound,
+// NotSupported, DecryptionFailed.
+func (c *Crypter) Decrypt(ciphertext []byte) ([]byte, error) {
+	keyVersion, cipherVersion, data := 0, CipherBlowfish, ciphertext
+	var iv []byte
+	switch parts := bytes.SplitN(ciphertext, []byte(":"), 4); len(parts) {
+	case 4:
+		// Magento ignores the cipher version, an initialization vector
+		// implies always Rijndael-256.
+		v, err := strconv.Atoi(string(parts[0]))
+		if err != nil {
+			return nil, errors.NotValid.New(err, "[cfgmodel] Crypter.Decrypt invalid key version %q", parts[0])
+		}
+		keyVersion, cipherVersion, iv, data = v, CipherRijndael256, parts[2], parts[3]
+	case 3:
+		v, err := strconv.Atoi(string(parts[0]))
+		if err != nil {
+			return nil, errors.NotValid.New(err, "[cfgmodel] Crypter.Decrypt invalid key version %q", parts[0])
+		}
+		keyVersion, cipherVersion, data = v, string(parts[1]), parts[2]
+	case 2:
+		cipherVersion, data = string(parts[0]), parts[1]
+	}
+	if keyVersion < 0 || keyVersion >= len(c.keys) {
+		return nil, errors.NotFound.Newf("[cfgmodel] Crypter.Decrypt key version %d not found", keyVersion)
+	}
+
+	raw := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(raw, data)
+	if err != nil {
+		return nil, errors.NotValid.New(err, "[cfgmodel] Crypter.Decrypt.Base64")
+	}
+	raw = raw[:n]
+
+	var plaintext []byte
+	switch cipherVersion {
+	case CipherAES256GCM:
+		plaintext, err = openAEAD(c.gcms[keyVersion], raw, ciphertext[:len(ciphertext)-len(data)])
+	case CipherChaCha20Poly1305:
+		var aead cipher.AEAD
+		if aead, err = chacha20poly1305.New(c.keys[keyVersion]); err == nil {
+			// libsodium: the nonce serves also as additional data.
+			plaintext, err = openAEAD(aead, raw, nil)
+		}
+	case CipherRijndael256:
+		var block cipher.Block
+		if block, err = newRijndael256(c.keys[keyVersion]); err == nil {
+			plaintext, err = decryptCBC(block, iv, raw)
+		}
+	case CipherRijndael128:
+		var block cipher.Block
+		if block, err = aes.NewCipher(c.keys[keyVersion]); err == nil {
+			plaintext, err = decryptECB(block, raw)
+		}
+	case CipherBlowfish:
+		var block cipher.Block
+		if block, err = blowfish.NewCipher(c.keys[keyVersion]); err == nil {
+			plaintext, err = decryptECB(block, raw)
+		}
+	default:
+		return nil, errors.NotSupported.Newf("[cfgmodel] Crypter.Decrypt cipher version %q not supported", cipherVersion)
+	}
+	if err != nil {
+		return nil, errors.DecryptionFailed.New(err, "[cfgmodel] Crypter.Decrypt key version %d cipher version %q", keyVersion, cipherVersion)
+	}
+	return plaintext, nil
+}
+
+// openAEAD splits the nonce from the data and decrypts it. If additionalData
+// is nil, the nonce gets used as additional data.
+func openAEAD(aead cipher.AEAD, raw, additionalData []byte) ([]byte, error) {
+	if len(raw) < aead.NonceSize() {
+		return nil, errors.NotValid.Newf("[cfgmodel] Ciphertext too short")
+	}
+	nonce, data := raw[:aead.NonceSize()], raw[aead.NonceSize():]
+	if additionalData == nil {
+		additionalData = nonce
+	}
+	return aead.Open(nil, nonce, data, additionalData)
+}
+
+// decryptECB decrypts the data block by block like mcrypt in ECB mode and
+// trims the zero padding and white spaces like Magento.
+func decryptECB(block cipher.Block, raw []byte) ([]byte, error) {
+	bs := block.BlockSize()
+	if len(raw)%bs != 0 {
+		return nil, errors.NotValid.Newf("[cfgmodel] Ciphertext length %d is not a multiple of the block size %d", len(raw), bs)
+	}
+	plaintext := make([]byte, len(raw))
+	for i := 0; i < len(raw); i += bs {
+		block.Decrypt(plaintext[i:i+bs], raw[i:i+bs])
+	}
+	return bytes.Trim(plaintext, " \t\n\r\x00\x0B"), nil
+}
+
+// decryptCBC decrypts the data like mcrypt in CBC mode and trims like
+// decryptECB. An empty initialization vector means a vector of zero bytes,
+// which Magento uses for ciphertexts without a vector.
+func decryptCBC(block cipher.Block, iv, raw []byte) ([]byte, error) {
+	bs := block.BlockSize()
+	if len(iv) == 0 {
+		iv = make([]byte, bs)
+	}
+	if len(iv) != bs {
+		return nil, errors.NotValid.Newf("[cfgmodel] Initialization vector length %d does not match the block size %d", len(iv), bs)
+	}
+	if len(raw)%bs != 0 {
+		return nil, errors.NotValid.Newf("[cfgmodel] Ciphertext length %d is not a multiple of the block size %d", len(raw), bs)
+	}
+	plaintext := make([]byte, len(raw))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, raw)
+	return bytes.Trim(plaintext, " \t\n\r\x00\x0B"), nil
+}
+
+// IsCurrent reports whether the ciphertext has been encrypted by Encrypt with
+// the newest key.
+func (c *Crypter) IsCurrent(ciphertext []byte) bool {
+	return bytes.HasPrefix(ciphertext, c.prefix())
+}
+
+// Reencrypt decrypts all values of the obscure fields in the storage and
+// encrypts them with the newest key. The obscure fields get detected by their
+// type element.TypeObscure. Values which are already encrypted with the
+// newest key and empty or NULL values get skipped. Returns the number of
+// re-encrypted values.
+func (c *Crypter) Reencrypt(ss element.SectionSlice, s config.Storager) (count int, err error) {
+	obscure := make(map[string]bool)
+	for _, sec := range ss {
+		for _, g := range sec.Groups {
+			for _, f := range g.Fields {
+				if f.Type != element.TypeObscure {
+					continue
+				}
+				r, err := f.Route(sec.ID, g.ID)
+				if err != nil {
+					return 0, errors.Wrapf(err, "[cfgmodel] Crypter.Reencrypt.Route Section %q Group %q", sec.ID, g.ID)
+				}
+				obscure[r.String()] = true
+			}
+		}
+	}
+	if len(obscure) == 0 {
+		return 0, nil
+	}
+
+	keys, err := s.AllKeys()
+	if err != nil {
+		return 0, errors.Wrap(err, "[cfgmodel] Crypter.Reencrypt.AllKeys")
+	}
+	for _, p := range keys {
+		if !obscure[p.Route.String()] {
+			continue
+		}
+		ok, err := c.reencryptPath(s, p)
+		if err != nil {
+			return count, errors.Wrapf(err, "[cfgmodel] Crypter.Reencrypt Path %q", p)
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// reencryptPath reports whether the value of the path has been re-encrypted.
+// A NotFound error, like for a NULL value in the database, skips the path.
+func (c *Crypter) reencryptPath(s config.Storager, p cfgpath.Path) (bool, error) {
+	v, err := s.Get(p)
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	var ciphertext []byte
+	switch vt := v.(type) {
+	case []byte:
+		ciphertext = vt
+	case string:
+		ciphertext = []byte(vt)
+	case nil:
+		return false, nil
+	default:
+		return false, errors.NotSupported.Newf("[cfgmodel] Value type %T not supported", v)
+	}
+	if len(ciphertext) == 0 || c.IsCurrent(ciphertext) {
+		return false, nil
+	}
+	plaintext, err := c.Decrypt(ciphertext)
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if ciphertext, err = c.Encrypt(plaintext); err != nil {
+		return false, errors.WithStack(err)
+	}
+	if err := s.Set(p, string(ciphertext)); err != nil {
+		return false, errors.WithStack(err)
+	}
+	return true, nil
+}
@@ -0,0 +1,287 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blowfish"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var _ cfgmodel.Encrypter = (*cfgmodel.Crypter)(nil)
+var _ cfgmodel.Decrypter = (*cfgmodel.Crypter)(nil)
+
+var (
+	cryptKey0 = []byte("0123456789abcdef0123456789abcdef")
+	cryptKey1 = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func TestNewCrypter(t *testing.T) {
+	t.Parallel()
+
+	_, err := cfgmodel.NewCrypter()
+	assert.True(t, errors.Empty.Match(err), "%+v", err)
+
+	_, err = cfgmodel.NewCrypter(cryptKey0, []byte("short"))
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+
+	c, err := cfgmodel.NewCrypterMagento(" " + string(cryptKey0) + "\n" + string(cryptKey1) + "\n")
+	require.NoError(t, err)
+	ct, err := c.Encrypt([]byte("secret"))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(ct, []byte("1:aes256gcm:")), "%s", ct)
+}
+
+func TestCrypter_Rotation(t *testing.T) {
+	t.Parallel()
+
+	plain := []byte(`H3llo G0phers`)
+	old := cfgmodel.MustNewCrypter(cryptKey0)
+	ct0, err := old.Encrypt(plain)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(ct0, []byte("0:aes256gcm:")), "%s", ct0)
+
+	ct0b, err := old.Encrypt(plain)
+	require.NoError(t, err)
+	assert.NotEqual(t, ct0, ct0b, "nonce must differ")
+
+	c := cfgmodel.MustNewCrypter(cryptKey0, cryptKey1)
+	ct1, err := c.Encrypt(plain)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(ct1, []byte("1:aes256gcm:")), "%s", ct1)
+	assert.True(t, c.IsCurrent(ct1))
+	assert.False(t, c.IsCurrent(ct0))
+
+	for _, ct := range [][]byte{ct0, ct1} {
+		have, err := c.Decrypt(ct)
+		require.NoError(t, err, "%s", ct)
+		assert.Exactly(t, plain, have)
+	}
+
+	_, err = old.Decrypt(ct1)
+	assert.True(t, errors.NotFound.Match(err), "%+v", err)
+
+	// changing the key version must fail the authentication
+	_, err = c.Decrypt(append([]byte("1"), ct0[1:]...))
+	assert.True(t, errors.DecryptionFailed.Match(err), "%+v", err)
+}
+
+func TestCrypter_Decrypt_Magento(t *testing.T) {
+	t.Parallel()
+
+	c := cfgmodel.MustNewCrypter(cryptKey0, cryptKey1)
+	plain := []byte(`sk_live_4711`)
+
+	t.Run("ChaCha20Poly1305 IETF", func(t *testing.T) {
+		aead, err := chacha20poly1305.New(cryptKey1)
+		require.NoError(t, err)
+		nonce := []byte("123456789012")
+		data := aead.Seal(append([]byte(nil), nonce...), nonce, plain, nonce)
+
+		have, err := c.Decrypt([]byte("1:3:" + base64.StdEncoding.EncodeToString(data)))
+		require.NoError(t, err)
+		assert.Exactly(t, plain, have)
+	})
+
+	t.Run("Blowfish ECB legacy", func(t *testing.T) {
+		block, err := blowfish.NewCipher(cryptKey0)
+		require.NoError(t, err)
+		padded := make([]byte, 16) // zero padding like mcrypt
+		copy(padded, plain)
+		data := make([]byte, len(padded))
+		for i := 0; i < len(padded); i += block.BlockSize() {
+			block.Encrypt(data[i:i+block.BlockSize()], padded[i:i+block.BlockSize()])
+		}
+		b64 := base64.StdEncoding.EncodeToString(data)
+
+		for _, ct := range []string{b64, "0:" + b64, "0:0:" + b64} {
+			have, err := c.Decrypt([]byte(ct))
+			require.NoError(t, err, "%s", ct)
+			assert.Exactly(t, plain, have, "%s", ct)
+		}
+	})
+
+	t.Run("Rijndael-256 CBC Magento 2.2", func(t *testing.T) {
+		// Format of Magento 2.0 - 2.2 Encryptor::encrypt: key version, cipher
+		// version, the random alphanumeric IV of 32 bytes and the zero
+		// padded data. Ciphertexts without an IV use a zero IV.
+		for _, ct := range []string{
+			"1:2:Qz2xTn8VbP4mK7wR1yH6dJ3sF9gL5cA0:AR20NlFcSjgyR0ioMxJZRTgshdihiwGAmYR3n45r6f4=",
+			"0:2:FvI6oKo1uk7/84megAEtoISMFgBzUtoaP0O/4R/CPdI=",
+		} {
+			have, err := c.Decrypt([]byte(ct))
+			require.NoError(t, err, "%s", ct)
+			assert.Exactly(t, plain, have, "%s", ct)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := c.Decrypt([]byte("0:2:short iv:AR20NlFcSjgyR0ioMxJZRTgshdihiwGAmYR3n45r6f4="))
+		assert.True(t, errors.DecryptionFailed.Match(err), "%+v", err)
+		_, err = c.Decrypt([]byte("0:2:ZGF0YQ=="))
+		assert.True(t, errors.DecryptionFailed.Match(err), "%+v", err)
+		_, err = c.Decrypt([]byte("0:4:ZGF0YQ=="))
+		assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+		_, err = c.Decrypt([]byte("x:3:ZGF0YQ=="))
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+		_, err = c.Decrypt([]byte("1:3:%%%"))
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+		_, err = c.Decrypt([]byte("1:3:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="))
+		assert.True(t, errors.DecryptionFailed.Match(err), "%+v", err)
+	})
+}
+
+func TestCrypter_Obscure(t *testing.T) {
+	t.Parallel()
+
+	c := cfgmodel.MustNewCrypter(cryptKey0)
+	b := cfgmodel.NewObscure("payment/stripe/secret_key",
+		cfgmodel.WithEncrypter(c),
+		cfgmodel.WithDecrypter(c),
+		cfgmodel.WithScopeStore(),
+	)
+
+	mw := new(cfgmock.Write)
+	require.NoError(t, b.Write(mw, []byte("sk_live_4711"), scope.Store.Pack(2)))
+	ct, ok := mw.ArgValue.([]byte)
+	require.True(t, ok, "%#v", mw.ArgValue)
+	assert.True(t, c.IsCurrent(ct))
+
+	have, err := b.Get(cfgmock.NewService(cfgmock.PathValue{
+		cfgpath.MustNewByParts("payment/stripe/secret_key").BindStore(2).String(): ct,
+	}).NewScoped(1, 2))
+	require.NoError(t, err)
+	assert.Exactly(t, []byte("sk_live_4711"), have)
+}
+
+func TestCrypter_Reencrypt(t *testing.T) {
+	t.Parallel()
+
+	ss := element.MustNewConfiguration(
+		element.Section{
+			ID: cfgpath.NewRoute("payment"),
+			Groups: element.NewGroupSlice(
+				element.Group{
+					ID: cfgpath.NewRoute("stripe"),
+					Fields: element.NewFieldSlice(
+						element.Field{ID: cfgpath.NewRoute("secret_key"), Type: element.TypeObscure},
+						element.Field{ID: cfgpath.NewRoute("title"), Type: element.TypeText},
+					),
+				},
+			),
+		},
+	)
+
+	secret := cfgmodel.MustNewCrypter(cryptKey0).Encrypt
+	ct0, err := secret([]byte("sk_live_4711"))
+	require.NoError(t, err)
+	ct2, err := secret([]byte("sk_live_0815"))
+	require.NoError(t, err)
+
+	s := config.NewInMemoryStore()
+	pSecret := cfgpath.MustNewByParts("payment/stripe/secret_key")
+	pTitle := cfgpath.MustNewByParts("payment/stripe/title")
+	require.NoError(t, s.Set(pSecret, string(ct0)))
+	require.NoError(t, s.Set(pSecret.BindStore(2), ct2))
+	require.NoError(t, s.Set(pTitle, "0:aes256gcm:not encrypted"))
+
+	c := cfgmodel.MustNewCrypter(cryptKey0, cryptKey1)
+	n, err := c.Reencrypt(ss, s)
+	require.NoError(t, err)
+	assert.Exactly(t, 2, n)
+
+	for _, test := range []struct {
+		p    cfgpath.Path
+		want string
+	}{
+		{pSecret, "sk_live_4711"},
+		{pSecret.BindStore(2), "sk_live_0815"},
+	} {
+		v, err := s.Get(test.p)
+		require.NoError(t, err)
+		ct := v.(string)
+		assert.True(t, strings.HasPrefix(ct, "1:aes256gcm:"), "%s", ct)
+		have, err := c.Decrypt([]byte(ct))
+		require.NoError(t, err)
+		assert.Exactly(t, test.want, string(have))
+	}
+	v, err := s.Get(pTitle)
+	require.NoError(t, err)
+	assert.Exactly(t, "0:aes256gcm:not encrypted", v)
+
+	n, err = c.Reencrypt(ss, s)
+	require.NoError(t, err)
+	assert.Exactly(t, 0, n, "already re-encrypted")
+}
+
+// nullRowStorage lists the path null in AllKeys but returns a NotFound error in
+// Get, like the ccd storage for a row with a NULL value.
+type nullRowStorage struct {
+	config.Storager
+	null cfgpath.Path
+}
+
+func (ns nullRowStorage) AllKeys() (cfgpath.PathSlice, error) {
+	ps, err := ns.Storager.AllKeys()
+	return append(ps, ns.null), err
+}
+
+func (ns nullRowStorage) Get(p cfgpath.Path) (interface{}, error) {
+	if p.String() == ns.null.String() {
+		return nil, errors.NotFound.Newf("[cfgmodel_test] Path %q has a NULL value", p)
+	}
+	return ns.Storager.Get(p)
+}
+
+func TestCrypter_Reencrypt_NULL(t *testing.T) {
+	t.Parallel()
+
+	ss := element.MustNewConfiguration(
+		element.Section{
+			ID: cfgpath.NewRoute("payment"),
+			Groups: element.NewGroupSlice(
+				element.Group{
+					ID: cfgpath.NewRoute("stripe"),
+					Fields: element.NewFieldSlice(
+						element.Field{ID: cfgpath.NewRoute("secret_key"), Type: element.TypeObscure},
+					),
+				},
+			),
+		},
+	)
+
+	ct0, err := cfgmodel.MustNewCrypter(cryptKey0).Encrypt([]byte("sk_live_4711"))
+	require.NoError(t, err)
+	pSecret := cfgpath.MustNewByParts("payment/stripe/secret_key")
+	s := nullRowStorage{Storager: config.NewInMemoryStore(), null: pSecret.BindWebsite(1)}
+	require.NoError(t, s.Set(pSecret, string(ct0)))
+
+	n, err := cfgmodel.MustNewCrypter(cryptKey0, cryptKey1).Reencrypt(ss, s)
+	require.NoError(t, err)
+	assert.Exactly(t, 1, n, "NULL row skipped")
+}
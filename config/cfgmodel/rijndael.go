@@ -0,0 +1,193 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel
+
+import (
+	"strconv"
+)
+
+// rijndael256BlockSize is the block size of mcrypt's MCRYPT_RIJNDAEL_256.
+// AES is Rijndael with a block size of 16 bytes, so crypto/aes cannot be used.
+const rijndael256BlockSize = 32
+
+var (
+	rijndaelSBox    [256]byte
+	rijndaelInvSBox [256]byte
+)
+
+func init() {
+	// Creates the S-box from the multiplicative inverse in GF(2^8) followed
+	// by the affine transformation, see FIPS-197 section 5.1.1.
+	p, q := byte(1), byte(1)
+	for {
+		// multiply p by 3
+		p ^= p<<1 ^ gfCarry(p)
+		// divide q by 3
+		q ^= q << 1
+		q ^= q << 2
+		q ^= q << 4
+		if q&0x80 != 0 {
+			q ^= 0x09
+		}
+		x := q ^ rotl8(q, 1) ^ rotl8(q, 2) ^ rotl8(q, 3) ^ rotl8(q, 4) ^ 0x63
+		rijndaelSBox[p] = x
+		rijndaelInvSBox[x] = p
+		if p == 1 {
+			break
+		}
+	}
+	rijndaelSBox[0] = 0x63
+	rijndaelInvSBox[0x63] = 0
+}
+
+func gfCarry(b byte) byte {
+	if b&0x80 != 0 {
+		return 0x1b
+	}
+	return 0
+}
+
+func rotl8(b byte, n uint) byte { return b<<n | b>>(8-n) }
+
+// xtime multiplies by x in GF(2^8).
+func xtime(b byte) byte { return b<<1 ^ gfCarry(b) }
+
+// gfMul multiplies two elements of GF(2^8).
+func gfMul(a, b byte) (p byte) {
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		a = xtime(a)
+		b >>= 1
+	}
+	return p
+}
+
+// rijndael256 implements cipher.Block for Rijndael with a block size and a key
+// size of 256 bits, as used by Magento 2.0 - 2.2 via mcrypt. Only the
+// decryption of legacy values requires it, so the implementation favours
+// simplicity over speed.
+type rijndael256 struct {
+	// rk contains the expanded key, one 32 byte round key per round.
+	rk [15][rijndael256BlockSize]byte
+}
+
+// rijndael256Shift contains the row offsets of ShiftRows for Nb=8.
+var rijndael256Shift = [4]int{0, 1, 3, 4}
+
+type rijndaelKeySizeError int
+
+func (k rijndaelKeySizeError) Error() string {
+	return "[cfgmodel] Invalid Rijndael-256 key size " + strconv.Itoa(int(k))
+}
+
+func newRijndael256(key []byte) (*rijndael256, error) {
+	if len(key) != 32 {
+		return nil, rijndaelKeySizeError(len(key))
+	}
+	const nk, nb, nr = 8, 8, 14
+	var w [nb * (nr + 1)][4]byte
+	for i := 0; i < nk; i++ {
+		copy(w[i][:], key[4*i:])
+	}
+	rcon := byte(1)
+	for i := nk; i < len(w); i++ {
+		t := w[i-1]
+		switch i % nk {
+		case 0:
+			t = [4]byte{rijndaelSBox[t[1]] ^ rcon, rijndaelSBox[t[2]], rijndaelSBox[t[3]], rijndaelSBox[t[0]]}
+			rcon = xtime(rcon)
+		case 4:
+			t = [4]byte{rijndaelSBox[t[0]], rijndaelSBox[t[1]], rijndaelSBox[t[2]], rijndaelSBox[t[3]]}
+		}
+		for j := range t {
+			w[i][j] = w[i-nk][j] ^ t[j]
+		}
+	}
+	r := new(rijndael256)
+	for i := range w {
+		copy(r.rk[i/nb][4*(i%nb):], w[i][:])
+	}
+	return r, nil
+}
+
+func (r *rijndael256) BlockSize() int { return rijndael256BlockSize }
+
+func (r *rijndael256) addRoundKey(s *[rijndael256BlockSize]byte, round int) {
+	for i := range s {
+		s[i] ^= r.rk[round][i]
+	}
+}
+
+// shiftRows moves the bytes of each row; the state is stored column by
+// column. Argument dir is 1 for encryption and -1 for decryption.
+func shiftRows(s *[rijndael256BlockSize]byte, dir int) {
+	const nb = rijndael256BlockSize / 4
+	var t [rijndael256BlockSize]byte
+	for c := 0; c < nb; c++ {
+		for row := 0; row < 4; row++ {
+			t[4*c+row] = s[4*((c+dir*rijndael256Shift[row]+nb)%nb)+row]
+		}
+	}
+	*s = t
+}
+
+func mixColumns(s *[rijndael256BlockSize]byte, m [4]byte) {
+	for c := 0; c < rijndael256BlockSize; c += 4 {
+		a0, a1, a2, a3 := s[c], s[c+1], s[c+2], s[c+3]
+		s[c] = gfMul(a0, m[0]) ^ gfMul(a1, m[1]) ^ gfMul(a2, m[2]) ^ gfMul(a3, m[3])
+		s[c+1] = gfMul(a0, m[3]) ^ gfMul(a1, m[0]) ^ gfMul(a2, m[1]) ^ gfMul(a3, m[2])
+		s[c+2] = gfMul(a0, m[2]) ^ gfMul(a1, m[3]) ^ gfMul(a2, m[0]) ^ gfMul(a3, m[1])
+		s[c+3] = gfMul(a0, m[1]) ^ gfMul(a1, m[2]) ^ gfMul(a2, m[3]) ^ gfMul(a3, m[0])
+	}
+}
+
+// Encrypt encrypts one block. Only used to create test data.
+func (r *rijndael256) Encrypt(dst, src []byte) {
+	var s [rijndael256BlockSize]byte
+	copy(s[:], src[:rijndael256BlockSize])
+	r.addRoundKey(&s, 0)
+	for round := 1; round < len(r.rk); round++ {
+		for i := range s {
+			s[i] = rijndaelSBox[s[i]]
+		}
+		shiftRows(&s, 1)
+		if round < len(r.rk)-1 {
+			mixColumns(&s, [4]byte{2, 3, 1, 1})
+		}
+		r.addRoundKey(&s, round)
+	}
+	copy(dst, s[:])
+}
+
+// Decrypt decrypts one block.
+func (r *rijndael256) Decrypt(dst, src []byte) {
+	var s [rijndael256BlockSize]byte
+	copy(s[:], src[:rijndael256BlockSize])
+	last := len(r.rk) - 1
+	r.addRoundKey(&s, last)
+	for round := last - 1; round >= 0; round-- {
+		shiftRows(&s, -1)
+		for i := range s {
+			s[i] = rijndaelInvSBox[s[i]]
+		}
+		r.addRoundKey(&s, round)
+		if round > 0 {
+			mixColumns(&s, [4]byte{14, 11, 13, 9})
+		}
+	}
+	copy(dst, s[:])
+}
@@ -0,0 +1,46 @@
+// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRijndael256(t *testing.T) {
+	t.Parallel()
+
+	// Known answer test from the Rijndael reference vectors with a block and
+	// key size of 256 bits.
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfe")
+	plain, _ := hex.DecodeString("3243f6a8885a308d313198a2e03707344a4093822299f31d0082efa98ec4e6c8")
+
+	r, err := newRijndael256(key)
+	require.NoError(t, err)
+	assert.Exactly(t, rijndael256BlockSize, r.BlockSize())
+
+	have := make([]byte, rijndael256BlockSize)
+	r.Encrypt(have, plain)
+	assert.Exactly(t, "a49406115dfb30a40418aafa4869b7c6a886ff31602a7dd19c889dc64f7e4e7a", hex.EncodeToString(have))
+
+	r.Decrypt(have, have)
+	assert.Exactly(t, plain, have)
+
+	_, err = newRijndael256(key[:16])
+	assert.EqualError(t, err, "[cfgmodel] Invalid Rijndael-256 key size 16")
+}
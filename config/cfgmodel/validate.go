@@ -0,0 +1,214 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// Validator validates a value before the ValidatingWriter writes it. All types
+// of this package implement this interface. Custom types can embed a type of
+// this package and override the validation functions.
+type Validator interface {
+	Route() cfgpath.Route
+	ValidateString(v string) error
+	ValidateInt(v int) error
+}
+
+// FieldError describes a value rejected by the ValidatingWriter.
+type FieldError struct {
+	Path cfgpath.Path
+	// Label of the element.Field, if found.
+	Label string
+	Value interface{}
+	// Err contains the reason and its behaviour: NotFound for unknown paths,
+	// Unauthorized for a not allowed scope, NotAllowed for read-only field
+	// types and NotValid for invalid values.
+	Err error
+}
+
+// Error implements the error interface.
+func (fe *FieldError) Error() string {
+	if fe.Label != "" {
+		return fmt.Sprintf("[cfgmodel] Field %q (%s) Value %#v: %s", fe.Label, fe.Path, fe.Value, fe.Err)
+	}
+	return fmt.Sprintf("[cfgmodel] Path %q Value %#v: %s", fe.Path, fe.Value, fe.Err)
+}
+
+// ValidatingWriter checks a value against the element.Field of the path
+// before writing it to the underlying config.Writer, mostly the
+// *config.Service. Invalid values get rejected with a *FieldError. A
+// ValidatingWriter is safe for concurrent use after creation.
+type ValidatingWriter struct {
+	w        config.Writer
+	sections element.SectionSlice
+	models   map[uint32]Validator
+}
+
+// NewValidatingWriter creates a new writer. Argument models contains the
+// cfgmodel types, whose options and validation functions run additionally to
+// the checks of the element.Field.
+func NewValidatingWriter(w config.Writer, ss element.SectionSlice, models ...Validator) *ValidatingWriter {
+	vw := &ValidatingWriter{
+		w:        w,
+		sections: ss,
+		models:   make(map[uint32]Validator, len(models)),
+	}
+	for _, m := range models {
+		vw.models[m.Route().Sum32] = m
+	}
+	return vw
+}
+
+// Write validates and writes the value. A failed validation returns a
+// *FieldError. Implements interface config.Writer.
+func (vw *ValidatingWriter) Write(p cfgpath.Path, v interface{}) error {
+	if err := vw.Validate(p, v); err != nil {
+		return err
+	}
+	return errors.Wrapf(vw.w.Write(p, v), "[cfgmodel] ValidatingWriter.Write Path %q", p)
+}
+
+// Validate checks whether the path exists in the element.SectionSlice, whether
+// the scope of the path is allowed, whether the value matches the type of the
+// field and whether a registered Validator accepts the value. Returns a
+// *FieldError on failure.
+func (vw *ValidatingWriter) Validate(p cfgpath.Path, v interface{}) error {
+	f, _, err := vw.sections.FindField(p.Route)
+	if err != nil {
+		return &FieldError{Path: p, Value: v, Err: errors.NotFound.New(err, "[cfgmodel] Path not defined in the sections")}
+	}
+	fe := &FieldError{Path: p, Label: f.Label.String(), Value: v}
+
+	if scp, _ := p.ScopeID.Unpack(); !f.Scopes.Has(scp) {
+		fe.Err = errors.NewUnauthorizedf(errScopePermissionInsufficient, p.ScopeID, f.Scopes, p.Route)
+		return fe
+	}
+
+	values, err := fieldValues(f, v)
+	if err != nil {
+		fe.Err = err
+		return fe
+	}
+
+	m, ok := vw.models[p.Route.Sum32]
+	if !ok {
+		return nil
+	}
+	for _, val := range values {
+		if err := validateValue(m, val); err != nil {
+			fe.Err = err
+			return fe
+		}
+	}
+	return nil
+}
+
+// fieldValues checks the value against the field type and returns the values
+// for the validation with the Source of a model. A multiselect field splits a
+// CSV string into its values.
+func fieldValues(f element.Field, v interface{}) ([]interface{}, error) {
+	var ft element.FieldType
+	if f.Type != nil {
+		ft = f.Type.Type()
+	}
+
+	switch ft {
+	case element.TypeButton, element.TypeLabel:
+		return nil, errors.NotAllowed.Newf("[cfgmodel] Field type %s cannot be written", ft)
+	case element.TypeMultiselect:
+		switch vt := v.(type) {
+		case []string:
+			ret := make([]interface{}, len(vt))
+			for i, s := range vt {
+				ret[i] = s
+			}
+			return ret, nil
+		case []int:
+			ret := make([]interface{}, len(vt))
+			for i, s := range vt {
+				ret[i] = s
+			}
+			return ret, nil
+		}
+		s, err := conv.ToStringE(v)
+		if err != nil {
+			return nil, errors.NotValid.New(err, "[cfgmodel] Multiselect value")
+		}
+		if s == "" {
+			if !f.CanBeEmpty {
+				return nil, errors.NotValid.Newf("[cfgmodel] Multiselect value cannot be empty")
+			}
+			return nil, nil
+		}
+		var ret []interface{}
+		for _, s := range strings.Split(s, string(CSVComma)) {
+			ret = append(ret, s)
+		}
+		return ret, nil
+	case element.TypeTime:
+		if _, err := conv.ToTimeE(v); err != nil {
+			return nil, errors.NotValid.New(err, "[cfgmodel] Time value")
+		}
+	case element.TypeDuration:
+		if _, err := conv.ToDurationE(v); err != nil {
+			return nil, errors.NotValid.New(err, "[cfgmodel] Duration value")
+		}
+	case element.TypeObscure:
+		if _, err := conv.ToByteE(v); err != nil {
+			return nil, errors.NotValid.New(err, "[cfgmodel] Obscure value")
+		}
+	default:
+		switch v.(type) {
+		case []string, []int:
+			return nil, errors.NotValid.Newf("[cfgmodel] Field type %s accepts only a single value", ft)
+		}
+	}
+	return []interface{}{v}, nil
+}
+
+// validateValue calls ValidateInt for the integer types of this package or
+// for integer values, otherwise ValidateString.
+func validateValue(m Validator, v interface{}) error {
+	isInt := false
+	switch m.(type) {
+	case Int, *Int, IntCSV, *IntCSV:
+		isInt = true
+	}
+	switch v.(type) {
+	case int, int64, int32, int16, int8:
+		isInt = true
+	}
+
+	if isInt {
+		i, err := conv.ToIntE(v)
+		if err != nil {
+			return errors.NotValid.New(err, "[cfgmodel] Value is not an integer")
+		}
+		return m.ValidateInt(i)
+	}
+	s, err := conv.ToStringE(v)
+	if err != nil {
+		return errors.NotValid.New(err, "[cfgmodel] Value")
+	}
+	return m.ValidateString(s)
+}
@@ -0,0 +1,117 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmodel_test
+
+import (
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/cfgsource"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/storage/text"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Writer = (*cfgmodel.ValidatingWriter)(nil)
+var _ cfgmodel.Validator = (*cfgmodel.Str)(nil)
+var _ cfgmodel.Validator = (*cfgmodel.IntCSV)(nil)
+
+var validateStructure = element.MustNewConfiguration(
+	element.Section{
+		ID: cfgpath.NewRoute("checkout"),
+		Groups: element.NewGroupSlice(
+			element.Group{
+				ID: cfgpath.NewRoute("options"),
+				Fields: element.NewFieldSlice(
+					element.Field{ID: cfgpath.NewRoute("guest_checkout"), Label: text.Chars(`Allow Guest Checkout`), Type: element.TypeSelect, Scopes: scope.PermStore},
+					element.Field{ID: cfgpath.NewRoute("max_items"), Type: element.TypeText, Scopes: scope.PermWebsite},
+					element.Field{ID: cfgpath.NewRoute("payment_methods"), Type: element.TypeMultiselect, Scopes: scope.PermStore},
+					element.Field{ID: cfgpath.NewRoute("info"), Type: element.TypeLabel, Scopes: scope.PermStore},
+					element.Field{ID: cfgpath.NewRoute("session_lifetime"), Type: element.TypeDuration, Scopes: scope.PermDefault},
+					element.Field{ID: cfgpath.NewRoute("comment"), Type: element.TypeTextarea, Scopes: scope.PermStore},
+				),
+			},
+		),
+	},
+)
+
+func TestValidatingWriter(t *testing.T) {
+	t.Parallel()
+
+	mw := &cfgmock.Write{}
+	vw := cfgmodel.NewValidatingWriter(mw, validateStructure,
+		cfgmodel.NewStr("checkout/options/guest_checkout", cfgmodel.WithSourceByString("1", "Yes", "0", "No")),
+		cfgmodel.NewInt("checkout/options/max_items", cfgmodel.WithSourceByInt(cfgsource.Ints{{Value: 10}, {Value: 50}, {Value: 100}})),
+		cfgmodel.NewStringCSV("checkout/options/payment_methods", cfgmodel.WithSourceByString("checkmo", "Check / Money order", "paypal", "PayPal", "banktransfer", "Bank Transfer")),
+	)
+	p := func(route string) cfgpath.Path {
+		return cfgpath.MustNewByParts("checkout/options/" + route)
+	}
+
+	tests := []struct {
+		path   cfgpath.Path
+		value  interface{}
+		errBhf func(error) bool
+	}{
+		{p("guest_checkout").BindStore(2), "1", nil},
+		{p("guest_checkout").BindStore(2), "2", errors.IsNotValid},
+		{p("guest_checkout").BindStore(2), []string{"1", "0"}, errors.NotValid.Match},
+		{p("max_items").BindWebsite(1), "50", nil},
+		{p("max_items").BindWebsite(1), 100, nil},
+		{p("max_items").BindWebsite(1), 51, errors.IsNotValid},
+		{p("max_items").BindWebsite(1), "fifty", errors.NotValid.Match},
+		{p("max_items").BindStore(1), 50, errors.IsUnauthorized},
+		{p("payment_methods").BindStore(1), "checkmo,paypal", nil},
+		{p("payment_methods").BindStore(1), []string{"banktransfer"}, nil},
+		{p("payment_methods").BindStore(1), "checkmo,bitcoin", errors.IsNotValid},
+		{p("payment_methods").BindStore(1), "", errors.NotValid.Match},
+		{p("info").BindStore(1), "Hello", errors.NotAllowed.Match},
+		{p("session_lifetime"), "2h30m", nil},
+		{p("session_lifetime"), "two hours", errors.NotValid.Match},
+		{p("comment").BindStore(1), "Any text", nil},
+		{p("unknown"), "1", errors.NotFound.Match},
+	}
+	for i, test := range tests {
+		mw.ArgPath, mw.ArgValue = "", nil
+		err := vw.Write(test.path, test.value)
+		if test.errBhf == nil {
+			require.NoError(t, err, "Index %d", i)
+			assert.Exactly(t, test.path.String(), mw.ArgPath, "Index %d", i)
+			assert.Exactly(t, test.value, mw.ArgValue, "Index %d", i)
+			continue
+		}
+		fe, ok := err.(*cfgmodel.FieldError)
+		require.True(t, ok, "Index %d: %#v", i, err)
+		assert.True(t, test.errBhf(fe.Err), "Index %d: %+v", i, fe.Err)
+		assert.Exactly(t, test.path, fe.Path, "Index %d", i)
+		assert.Exactly(t, test.value, fe.Value, "Index %d", i)
+		assert.Empty(t, mw.ArgPath, "Index %d: nothing must be written", i)
+	}
+}
+
+func TestFieldError_Error(t *testing.T) {
+	t.Parallel()
+
+	vw := cfgmodel.NewValidatingWriter(&cfgmock.Write{}, validateStructure)
+	err := vw.Write(cfgpath.MustNewByParts("checkout/options/guest_checkout").BindWebsite(1), []string{"1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `Field "Allow Guest Checkout" (websites/1/checkout/options/guest_checkout)`)
+}
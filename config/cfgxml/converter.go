@@ -0,0 +1,479 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgxml
+
+import (
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/storage/text"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// Paths of the XML files within a Magento 2 module directory.
+const (
+	PathSystemXML = "etc/adminhtml/system.xml"
+	PathConfigXML = "etc/config.xml"
+)
+
+// xmlScopes contains the scope attributes of a section, group or field.
+type xmlScopes struct {
+	ShowInDefault string `xml:"showInDefault,attr"`
+	ShowInWebsite string `xml:"showInWebsite,attr"`
+	ShowInStore   string `xml:"showInStore,attr"`
+}
+
+func (s xmlScopes) isEmpty() bool {
+	return s.ShowInDefault == "" && s.ShowInWebsite == "" && s.ShowInStore == ""
+}
+
+// Perm converts the showIn* attributes into a permission.
+func (s xmlScopes) Perm() scope.Perm {
+	var p scope.Perm
+	if s.ShowInDefault == "1" {
+		p = p.Set(scope.Default)
+	}
+	if s.ShowInWebsite == "1" {
+		p = p.Set(scope.Website)
+	}
+	if s.ShowInStore == "1" {
+		p = p.Set(scope.Store)
+	}
+	return p
+}
+
+type xmlSection struct {
+	ID        string `xml:"id,attr"`
+	SortOrder int    `xml:"sortOrder,attr"`
+	xmlScopes
+	Label    string      `xml:"label"`
+	Tab      string      `xml:"tab"`
+	Resource string      `xml:"resource"`
+	Groups   []*xmlGroup `xml:"group"`
+}
+
+type xmlGroup struct {
+	ID        string `xml:"id,attr"`
+	SortOrder int    `xml:"sortOrder,attr"`
+	xmlScopes
+	Label                 string      `xml:"label"`
+	Comment               string      `xml:"comment"`
+	HelpURL               string      `xml:"help_url"`
+	MoreURL               string      `xml:"more_url"`
+	DemoLink              string      `xml:"demo_link"`
+	HideInSingleStoreMode string      `xml:"hide_in_single_store_mode"`
+	Fields                []*xmlField `xml:"field"`
+}
+
+type xmlField struct {
+	ID        string `xml:"id,attr"`
+	Type      string `xml:"type,attr"`
+	SortOrder int    `xml:"sortOrder,attr"`
+	xmlScopes
+	Label         string `xml:"label"`
+	Comment       string `xml:"comment"`
+	Tooltip       string `xml:"tooltip"`
+	SourceModel   string `xml:"source_model"`
+	BackendModel  string `xml:"backend_model"`
+	FrontendModel string `xml:"frontend_model"`
+	ConfigPath    string `xml:"config_path"`
+	CanBeEmpty    string `xml:"can_be_empty"`
+
+	// Hidden gets set for fields which only exist in config.xml.
+	Hidden bool `xml:"-"`
+	// Route contains the config_path or the FieldRoute.
+	Route string `xml:"-"`
+	// FieldRoute contains section/group/field.
+	FieldRoute string `xml:"-"`
+	// Default contains the value from config.xml, if HasDefault.
+	Default    string `xml:"-"`
+	HasDefault bool   `xml:"-"`
+}
+
+type xmlSystem struct {
+	Sections []*xmlSection `xml:"system>section"`
+}
+
+// xmlNode represents an arbitrary XML element of config.xml.
+type xmlNode struct {
+	XMLName xml.Name
+	Nodes   []xmlNode `xml:",any"`
+	Value   string    `xml:",chardata"`
+}
+
+type xmlConfig struct {
+	Default xmlNode `xml:"default"`
+}
+
+// Converter reads Magento 2 system.xml and config.xml files and converts them
+// into an element.SectionSlice or into Go source code. Several files can be
+// read, e.g. of different modules, and get merged by their IDs. Not safe for
+// concurrent use.
+type Converter struct {
+	sections []*xmlSection
+	// defaults contains the values of config.xml by route. defaultRoutes
+	// keeps the order of the file.
+	defaults      map[string]string
+	defaultRoutes []string
+}
+
+// NewConverter creates a new empty Converter.
+func NewConverter() *Converter {
+	return &Converter{
+		defaults: make(map[string]string),
+	}
+}
+
+// ReadModule reads the files etc/adminhtml/system.xml and etc/config.xml of
+// the Magento 2 module directory. A missing file gets skipped. Error
+// behaviour: NotFound, NotValid.
+func (c *Converter) ReadModule(dir string) error {
+	var found int
+	for _, fn := range [...]string{PathSystemXML, PathConfigXML} {
+		f, err := os.Open(filepath.Join(dir, fn))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "[cfgxml] ReadModule.Open %q", fn)
+		}
+		found++
+		if fn == PathSystemXML {
+			err = c.ReadSystemXML(f)
+		} else {
+			err = c.ReadConfigXML(f)
+		}
+		if cErr := f.Close(); err == nil {
+			err = cErr
+		}
+		if err != nil {
+			return errors.Wrapf(err, "[cfgxml] ReadModule %q", filepath.Join(dir, fn))
+		}
+	}
+	if found == 0 {
+		return errors.NotFound.Newf("[cfgxml] ReadModule: Neither %q nor %q found in directory %q", PathSystemXML, PathConfigXML, dir)
+	}
+	return nil
+}
+
+// ReadSystemXML reads the sections, groups and fields of a system.xml file.
+// Sections, groups and fields with an already known ID get merged, where non
+// empty values overwrite the previous ones. Error behaviour: NotValid.
+func (c *Converter) ReadSystemXML(r io.Reader) error {
+	var sys xmlSystem
+	if err := xml.NewDecoder(r).Decode(&sys); err != nil {
+		return errors.NotValid.New(err, "[cfgxml] ReadSystemXML.Decode")
+	}
+	for _, s := range sys.Sections {
+		c.mergeSection(s)
+	}
+	return nil
+}
+
+// ReadConfigXML reads the default values of a config.xml file. Values nested
+// deeper than three levels, like arrays, get skipped. Error behaviour:
+// NotValid.
+func (c *Converter) ReadConfigXML(r io.Reader) error {
+	var cfg xmlConfig
+	if err := xml.NewDecoder(r).Decode(&cfg); err != nil {
+		return errors.NotValid.New(err, "[cfgxml] ReadConfigXML.Decode")
+	}
+	for _, s := range cfg.Default.Nodes {
+		for _, g := range s.Nodes {
+			for _, f := range g.Nodes {
+				if len(f.Nodes) > 0 {
+					continue
+				}
+				route := s.XMLName.Local + "/" + g.XMLName.Local + "/" + f.XMLName.Local
+				if _, ok := c.defaults[route]; !ok {
+					c.defaultRoutes = append(c.defaultRoutes, route)
+				}
+				c.defaults[route] = strings.TrimSpace(f.Value)
+			}
+		}
+	}
+	return nil
+}
+
+func setStr(dst *string, src string) {
+	if src = strings.TrimSpace(src); src != "" {
+		*dst = src
+	}
+}
+
+func setInt(dst *int, src int) {
+	if src != 0 {
+		*dst = src
+	}
+}
+
+func setScopes(dst *xmlScopes, src xmlScopes) {
+	if !src.isEmpty() {
+		*dst = src
+	}
+}
+
+func (c *Converter) mergeSection(ns *xmlSection) {
+	var s *xmlSection
+	for _, cs := range c.sections {
+		if cs.ID == ns.ID {
+			s = cs
+			break
+		}
+	}
+	if s == nil {
+		s = &xmlSection{ID: ns.ID}
+		c.sections = append(c.sections, s)
+	}
+	setInt(&s.SortOrder, ns.SortOrder)
+	setScopes(&s.xmlScopes, ns.xmlScopes)
+	setStr(&s.Label, ns.Label)
+	setStr(&s.Tab, ns.Tab)
+	setStr(&s.Resource, ns.Resource)
+	for _, ng := range ns.Groups {
+		s.mergeGroup(ng)
+	}
+}
+
+func (s *xmlSection) mergeGroup(ng *xmlGroup) {
+	var g *xmlGroup
+	for _, cg := range s.Groups {
+		if cg.ID == ng.ID {
+			g = cg
+			break
+		}
+	}
+	if g == nil {
+		g = &xmlGroup{ID: ng.ID}
+		s.Groups = append(s.Groups, g)
+	}
+	setInt(&g.SortOrder, ng.SortOrder)
+	setScopes(&g.xmlScopes, ng.xmlScopes)
+	setStr(&g.Label, ng.Label)
+	setStr(&g.Comment, ng.Comment)
+	setStr(&g.HelpURL, ng.HelpURL)
+	setStr(&g.MoreURL, ng.MoreURL)
+	setStr(&g.DemoLink, ng.DemoLink)
+	setStr(&g.HideInSingleStoreMode, ng.HideInSingleStoreMode)
+	for _, nf := range ng.Fields {
+		g.mergeField(nf)
+	}
+}
+
+func (g *xmlGroup) mergeField(nf *xmlField) {
+	var f *xmlField
+	for _, cf := range g.Fields {
+		if cf.ID == nf.ID {
+			f = cf
+			break
+		}
+	}
+	if f == nil {
+		f = &xmlField{ID: nf.ID}
+		g.Fields = append(g.Fields, f)
+	}
+	setStr(&f.Type, nf.Type)
+	setInt(&f.SortOrder, nf.SortOrder)
+	setScopes(&f.xmlScopes, nf.xmlScopes)
+	setStr(&f.Label, nf.Label)
+	setStr(&f.Comment, nf.Comment)
+	setStr(&f.Tooltip, nf.Tooltip)
+	setStr(&f.SourceModel, nf.SourceModel)
+	setStr(&f.BackendModel, nf.BackendModel)
+	setStr(&f.FrontendModel, nf.FrontendModel)
+	setStr(&f.ConfigPath, nf.ConfigPath)
+	setStr(&f.CanBeEmpty, nf.CanBeEmpty)
+}
+
+// tree returns the merged sections including the default values. Default
+// values without a field get appended as hidden fields. The sections of the
+// Converter do not get modified.
+func (c *Converter) tree() []*xmlSection {
+	ss := make([]*xmlSection, 0, len(c.sections))
+	seen := make(map[string]bool, len(c.defaults))
+	for _, cs := range c.sections {
+		s := *cs
+		s.Groups = make([]*xmlGroup, 0, len(cs.Groups))
+		for _, cg := range cs.Groups {
+			g := *cg
+			g.Fields = make([]*xmlField, 0, len(cg.Fields))
+			for _, cf := range cg.Fields {
+				f := *cf
+				f.FieldRoute = s.ID + "/" + g.ID + "/" + f.ID
+				f.Route = f.FieldRoute
+				if f.ConfigPath != "" {
+					f.Route = f.ConfigPath
+				}
+				f.Default, f.HasDefault = c.defaults[f.Route]
+				seen[f.Route] = true
+				g.Fields = append(g.Fields, &f)
+			}
+			s.Groups = append(s.Groups, &g)
+		}
+		ss = append(ss, &s)
+	}
+
+	for _, route := range c.defaultRoutes {
+		if seen[route] {
+			continue
+		}
+		parts := strings.Split(route, "/")
+		var s *xmlSection
+		for _, cs := range ss {
+			if cs.ID == parts[0] {
+				s = cs
+			}
+		}
+		if s == nil {
+			s = &xmlSection{ID: parts[0]}
+			ss = append(ss, s)
+		}
+		var g *xmlGroup
+		for _, cg := range s.Groups {
+			if cg.ID == parts[1] {
+				g = cg
+			}
+		}
+		if g == nil {
+			g = &xmlGroup{ID: parts[1]}
+			s.Groups = append(s.Groups, g)
+		}
+		g.Fields = append(g.Fields, &xmlField{
+			ID:         parts[2],
+			Hidden:     true,
+			Route:      route,
+			FieldRoute: route,
+			Default:    c.defaults[route],
+			HasDefault: true,
+		})
+	}
+	return ss
+}
+
+// Sections converts the read files into a validated SectionSlice. Error
+// behaviour: NotValid.
+func (c *Converter) Sections() (element.SectionSlice, error) {
+	ss := c.tree()
+	sections := make(element.SectionSlice, 0, len(ss))
+	for _, s := range ss {
+		es := element.Section{
+			ID:        cfgpath.NewRoute(s.ID),
+			Label:     text.Chars(s.Label),
+			SortOrder: s.SortOrder,
+			Scopes:    s.Perm(),
+			Groups:    make(element.GroupSlice, 0, len(s.Groups)),
+		}
+		for _, g := range s.Groups {
+			eg := element.Group{
+				ID:                    cfgpath.NewRoute(g.ID),
+				Label:                 text.Chars(g.Label),
+				Comment:               text.Chars(g.Comment),
+				SortOrder:             g.SortOrder,
+				Scopes:                g.Perm(),
+				HelpURL:               text.Chars(g.HelpURL),
+				MoreURL:               text.Chars(g.MoreURL),
+				DemoLink:              text.Chars(g.DemoLink),
+				HideInSingleStoreMode: g.HideInSingleStoreMode == "1",
+				Fields:                make(element.FieldSlice, 0, len(g.Fields)),
+			}
+			for _, f := range g.Fields {
+				eg.Fields = append(eg.Fields, f.element())
+			}
+			es.Groups = append(es.Groups, eg)
+		}
+		sections = append(sections, es)
+	}
+	ss2, err := element.NewConfiguration(sections...)
+	return ss2, errors.Wrap(err, "[cfgxml] Sections")
+}
+
+func (f *xmlField) element() element.Field {
+	ef := element.Field{
+		ID:         cfgpath.NewRoute(f.ID),
+		Type:       f.fieldType(),
+		Label:      text.Chars(f.Label),
+		Comment:    text.Chars(f.Comment),
+		Tooltip:    text.Chars(f.Tooltip),
+		Scopes:     f.Perm(),
+		SortOrder:  f.SortOrder,
+		Visible:    element.VisibleYes,
+		CanBeEmpty: f.CanBeEmpty == "1",
+	}
+	if f.ConfigPath != "" {
+		ef.ConfigPath = cfgpath.NewRoute(f.ConfigPath)
+	}
+	if f.Hidden {
+		ef.Visible = element.VisibleNo
+	}
+	if f.HasDefault {
+		ef.Default = f.defaultValue()
+	}
+	return ef
+}
+
+// fieldType maps the Magento field type to the element type. Unknown types
+// are rendered by a frontend model and become TypeCustom.
+func (f *xmlField) fieldType() element.FieldType {
+	if f.Hidden {
+		return element.TypeHidden
+	}
+	switch f.Type {
+	case "", "text":
+		return element.TypeText
+	case "textarea", "editor":
+		return element.TypeTextarea
+	case "select":
+		return element.TypeSelect
+	case "multiselect":
+		return element.TypeMultiselect
+	case "obscure", "password":
+		return element.TypeObscure
+	case "label", "note":
+		return element.TypeLabel
+	case "hidden":
+		return element.TypeHidden
+	case "image", "file":
+		return element.TypeImage
+	case "button":
+		return element.TypeButton
+	case "time":
+		return element.TypeTime
+	}
+	return element.TypeCustom
+}
+
+// isBool reports whether the source model provides a yes/no selection.
+func (f *xmlField) isBool() bool {
+	return strings.HasSuffix(f.SourceModel, `\Yesno`) || strings.HasSuffix(f.SourceModel, `\Enabledisable`)
+}
+
+// defaultValue converts the default value to a bool for yes/no fields and to
+// an int for integers without leading zeros. All other values stay strings.
+func (f *xmlField) defaultValue() interface{} {
+	if f.isBool() {
+		return f.Default == "1" || f.Default == "true"
+	}
+	if i, err := strconv.Atoi(f.Default); err == nil && strconv.Itoa(i) == f.Default {
+		return i
+	}
+	return f.Default
+}
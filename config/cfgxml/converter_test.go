@@ -0,0 +1,177 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgxml_test
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/cfgxml"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testModule = "testdata/module-directory"
+
+func TestConverter_Sections(t *testing.T) {
+	t.Parallel()
+
+	c := cfgxml.NewConverter()
+	require.NoError(t, c.ReadModule(testModule))
+	ss, err := c.Sections()
+	require.NoError(t, err)
+	assert.Exactly(t, 8, ss.TotalFields())
+
+	s, _, err := ss.Find(cfgpath.NewRoute("currency"))
+	require.NoError(t, err)
+	assert.Exactly(t, "Currency Setup", s.Label.String())
+	assert.Exactly(t, 60, s.SortOrder)
+	assert.Exactly(t, scope.PermStore, s.Scopes)
+
+	tests := []struct {
+		route      string
+		typ        element.FieldType
+		scopes     scope.Perm
+		visible    element.Visible
+		defaultVal interface{}
+	}{
+		{"currency/options/base", element.TypeSelect, scope.PermWebsite, element.VisibleYes, "USD"},
+		{"currency/options/allow", element.TypeMultiselect, scope.PermStore, element.VisibleYes, "USD,EUR"},
+		{"currency/import/enabled", element.TypeSelect, scope.PermDefault, element.VisibleYes, false},
+		{"currency/import/api_key", element.TypeObscure, scope.PermDefault, element.VisibleYes, nil},
+		{"currency/import/timeout", element.TypeText, scope.Perm(0).Set(scope.Default, scope.Store), element.VisibleYes, 100},
+		{"general/locale/date_format_short", element.TypeHidden, 0, element.VisibleNo, "%m/%d/%y"},
+		{"general/locale/language", element.TypeHidden, 0, element.VisibleNo, "en"},
+		{"general/country/optional_zip_countries", element.TypeHidden, 0, element.VisibleNo, "HK,IE,MO,PA"},
+	}
+	for _, test := range tests {
+		f, _, err := ss.FindField(cfgpath.NewRoute(test.route))
+		require.NoError(t, err, "Route %q", test.route)
+		assert.Exactly(t, test.typ, f.Type, "Route %q", test.route)
+		assert.Exactly(t, test.scopes, f.Scopes, "Route %q", test.route)
+		assert.Exactly(t, test.visible, f.Visible, "Route %q", test.route)
+		assert.Exactly(t, test.defaultVal, f.Default, "Route %q", test.route)
+	}
+
+	f, _, err := ss.FindField(cfgpath.NewRoute("currency/options/allow"))
+	require.NoError(t, err)
+	assert.True(t, f.CanBeEmpty)
+
+	f, _, err = ss.FindField(cfgpath.NewRoute("currency/import/timeout"))
+	require.NoError(t, err)
+	assert.Exactly(t, "currency/webservicex/timeout", f.ConfigPath.SelfRoute().String())
+
+	_, _, err = ss.FindField(cfgpath.NewRoute("general/country/list"))
+	assert.True(t, errors.IsNotFound(err), "arrays get skipped %+v", err)
+}
+
+func TestConverter_ReadSystemXML_Merge(t *testing.T) {
+	t.Parallel()
+
+	c := cfgxml.NewConverter()
+	require.NoError(t, c.ReadModule(testModule))
+	require.NoError(t, c.ReadSystemXML(strings.NewReader(`<config><system>
+		<section id="currency">
+			<group id="options">
+				<field id="base" showInDefault="1"><label>Base Currency Code</label></field>
+				<field id="display" type="select" sortOrder="4" showInDefault="1" showInWebsite="1" showInStore="1">
+					<label>Display</label>
+					<source_model>Magento\Config\Model\Config\Source\Enabledisable</source_model>
+				</field>
+			</group>
+		</section>
+	</system></config>`)))
+
+	ss, err := c.Sections()
+	require.NoError(t, err)
+	assert.Exactly(t, 9, ss.TotalFields())
+
+	f, _, err := ss.FindField(cfgpath.NewRoute("currency/options/base"))
+	require.NoError(t, err)
+	assert.Exactly(t, "Base Currency Code", f.Label.String())
+	assert.Exactly(t, scope.PermDefault, f.Scopes)
+	assert.Exactly(t, element.TypeSelect, f.Type, "type from the first file")
+	assert.Exactly(t, "USD", f.Default)
+}
+
+func TestConverter_WriteGo(t *testing.T) {
+	t.Parallel()
+
+	c := cfgxml.NewConverter()
+	require.NoError(t, c.ReadModule(testModule))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.WriteGo(&buf, "directory"))
+
+	_, err := parser.ParseFile(token.NewFileSet(), "config_gen.go", buf.Bytes(), parser.ParseComments)
+	require.NoError(t, err, buf.String())
+
+	src := buf.String()
+	for _, want := range []string{
+		"package directory\n",
+		"\"github.com/corestoreio/pkg/config/cfgmodel\"",
+		"func NewConfigStructure() (element.SectionSlice, error) {",
+		"ID:        cfgpath.NewRoute(`currency`),",
+		"Label:     text.Chars(`Currency Setup`),",
+		"Resource:  0, // Magento_Backend::currency",
+		"Scopes:    scope.PermWebsite,",
+		"Scopes:     scope.Perm(0).Set(scope.Default, scope.Store),",
+		"ConfigPath: cfgpath.NewRoute(`currency/webservicex/timeout`),",
+		"Default:    100,",
+		"Default:   false,",
+		"Default: `%m/%d/%y`,",
+		"Visible: element.VisibleNo,",
+		"// SourceModel: Magento\\Config\\Model\\Config\\Source\\Locale\\Currency",
+		"// BackendModel: Magento\\Config\\Model\\Config\\Backend\\Currency\\Allow",
+		"// CurrencyOptionsBase => Base Currency.\n\t// Base currency is used for all online payment transactions.",
+		"CurrencyOptionsAllow cfgmodel.StringCSV",
+		"CurrencyImportEnabled cfgmodel.Bool",
+		"CurrencyImportAPIKey cfgmodel.Obscure",
+		"CurrencyWebservicexTimeout cfgmodel.Str",
+		"GeneralLocaleLanguage cfgmodel.Str",
+		"pp.CurrencyOptionsAllow = cfgmodel.NewStringCSV(`currency/options/allow`, opt)",
+		"if f, _, err := cfgStruct.FindField(cfgpath.NewRoute(`currency/import/timeout`)); err == nil {\n\t\tpp.CurrencyWebservicexTimeout = cfgmodel.NewStr(`currency/webservicex/timeout`, cfgmodel.WithField(&f))",
+		"pp.GeneralCountryOptionalZipCountries = cfgmodel.NewStr(`general/country/optional_zip_countries`, opt)",
+	} {
+		assert.Contains(t, src, want)
+	}
+}
+
+func TestConverter_Errors(t *testing.T) {
+	t.Parallel()
+
+	c := cfgxml.NewConverter()
+	err := c.ReadModule("testdata/not-a-module")
+	assert.True(t, errors.NotFound.Match(err), "%+v", err)
+
+	err = c.ReadSystemXML(strings.NewReader(`<config><system>`))
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+
+	err = c.ReadConfigXML(strings.NewReader(`<config><default>`))
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+
+	require.NoError(t, c.ReadSystemXML(strings.NewReader(`<config><system>
+		<section id="a€"><group id="bb"><field id="cc"/></group></section>
+	</system></config>`)))
+	_, err = c.Sections()
+	assert.True(t, errors.IsNotValid(err), "%+v", err)
+}
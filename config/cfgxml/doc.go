@@ -0,0 +1,38 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgxml converts the admin configuration of a Magento 2 module into
+// an element.SectionSlice.
+//
+// The Converter reads the sections, groups and fields of the file
+// etc/adminhtml/system.xml and the default values of the file etc/config.xml.
+// The attributes showInDefault, showInWebsite and showInStore become a
+// scope.Perm, the field type becomes an element.FieldType. Default values
+// without a field in system.xml become hidden fields, like the date formats in
+// package directory.
+//
+// The result can be used at runtime via Converter.Sections or written as Go
+// source code via Converter.WriteGo. The generated code contains the
+// function NewConfigStructure and the type PkgBackend with a cfgmodel
+// accessor for each field:
+//
+//		c := cfgxml.NewConverter()
+//		if err := c.ReadModule("vendor/magento/module-directory"); err != nil {
+//			panic(err)
+//		}
+//		err := c.WriteGo(file, "directory")
+//
+// Nested groups within a group are not supported by package element and get
+// skipped.
+package cfgxml
@@ -0,0 +1,304 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgxml
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/corestoreio/pkg/util/strs"
+)
+
+// genImportPaths lists all packages which the generated code might use.
+var genImportPaths = []string{
+	"github.com/corestoreio/pkg/config/cfgmodel",
+	"github.com/corestoreio/pkg/config/cfgpath",
+	"github.com/corestoreio/pkg/config/element",
+	"github.com/corestoreio/pkg/storage/text",
+	"github.com/corestoreio/pkg/store/scope",
+}
+
+var genFuncMap = template.FuncMap{
+	"quote":     goQuote,
+	"perm":      goPerm,
+	"fieldType": func(f *xmlField) string { return "element." + f.fieldType().String() },
+	"default":   goDefault,
+	"comment":   goComment,
+	"oneLine":   oneLine,
+}
+
+var genTpl = template.Must(template.New("cfgxml").Funcs(genFuncMap).Parse(`
+// NewConfigStructure global configuration structure for this package.
+// Used in frontend (to display the user all the settings) and in
+// backend (scope checks and default values). See the source code
+// of this function for the overall available sections, groups and fields.
+func NewConfigStructure() (element.SectionSlice, error) {
+	return element.NewConfiguration(
+{{- range $s := .Sections}}
+		element.Section{
+			ID: cfgpath.NewRoute({{quote $s.ID}}),
+			{{- if $s.Label}}
+			Label: text.Chars({{quote $s.Label}}),{{end}}
+			{{- if $s.SortOrder}}
+			SortOrder: {{$s.SortOrder}},{{end}}
+			{{- if $s.Perm}}
+			Scopes: {{perm $s.Perm}},{{end}}
+			{{- if $s.Resource}}
+			Resource: 0, // {{$s.Resource}}{{end}}
+			Groups: element.NewGroupSlice(
+			{{- range $g := $s.Groups}}
+				element.Group{
+					ID: cfgpath.NewRoute({{quote $g.ID}}),
+					{{- if $g.Label}}
+					Label: text.Chars({{quote $g.Label}}),{{end}}
+					{{- if $g.Comment}}
+					Comment: text.Chars({{quote $g.Comment}}),{{end}}
+					{{- if $g.SortOrder}}
+					SortOrder: {{$g.SortOrder}},{{end}}
+					{{- if $g.Perm}}
+					Scopes: {{perm $g.Perm}},{{end}}
+					{{- if $g.HelpURL}}
+					HelpURL: text.Chars({{quote $g.HelpURL}}),{{end}}
+					{{- if $g.MoreURL}}
+					MoreURL: text.Chars({{quote $g.MoreURL}}),{{end}}
+					{{- if $g.DemoLink}}
+					DemoLink: text.Chars({{quote $g.DemoLink}}),{{end}}
+					{{- if eq $g.HideInSingleStoreMode "1"}}
+					HideInSingleStoreMode: true,{{end}}
+					Fields: element.NewFieldSlice(
+					{{- range $f := $g.Fields}}
+						element.Field{
+							// Path: {{$f.Route}}
+							ID: cfgpath.NewRoute({{quote $f.ID}}),
+							{{- if $f.ConfigPath}}
+							ConfigPath: cfgpath.NewRoute({{quote $f.ConfigPath}}),{{end}}
+							{{- if $f.Label}}
+							Label: text.Chars({{quote $f.Label}}),{{end}}
+							{{- if $f.Comment}}
+							Comment: text.Chars({{quote $f.Comment}}),{{end}}
+							{{- if $f.Tooltip}}
+							Tooltip: text.Chars({{quote $f.Tooltip}}),{{end}}
+							Type: {{fieldType $f}},
+							{{- if $f.SortOrder}}
+							SortOrder: {{$f.SortOrder}},{{end}}
+							{{- if $f.Hidden}}
+							Visible: element.VisibleNo,{{else}}
+							Visible: element.VisibleYes,{{end}}
+							{{- if $f.Perm}}
+							Scopes: {{perm $f.Perm}},{{end}}
+							{{- if eq $f.CanBeEmpty "1"}}
+							CanBeEmpty: true,{{end}}
+							{{- if $f.HasDefault}}
+							Default: {{default $f}},{{end}}
+							{{- if $f.BackendModel}}
+							// BackendModel: {{$f.BackendModel}}{{end}}
+							{{- if $f.SourceModel}}
+							// SourceModel: {{$f.SourceModel}}{{end}}
+							{{- if $f.FrontendModel}}
+							// FrontendModel: {{$f.FrontendModel}}{{end}}
+						},
+					{{end}}
+					),
+				},
+			{{end}}
+			),
+		},
+{{end}}
+	)
+}
+
+// PkgBackend just exported for the sake of documentation. See fields
+// for more information. The PkgBackend handles the reading and writing
+// of configuration values within this package.
+type PkgBackend struct {
+{{- range $bf := .Fields}}
+	// {{$bf.GoName}}{{if $bf.Label}} => {{oneLine $bf.Label}}.{{end}}
+	{{- if $bf.Comment}}
+{{comment $bf.Comment}}{{end}}
+	// Path: {{$bf.Route}}
+	{{- if $bf.BackendModel}}
+	// BackendModel: {{$bf.BackendModel}}{{end}}
+	{{- if $bf.SourceModel}}
+	// SourceModel: {{$bf.SourceModel}}{{end}}
+	{{$bf.GoName}} cfgmodel.{{$bf.Model}}
+{{end -}}
+}
+
+// NewBackend initializes the global configuration models containing the
+// cfgpath.Route variable to the appropriate entry.
+// The function Load() will be executed to apply the SectionSlice
+// to all models. See Load() for more details.
+func NewBackend(cfgStruct element.SectionSlice) *PkgBackend {
+	return (&PkgBackend{}).Load(cfgStruct)
+}
+
+// Load creates the configuration models for each PkgBackend field.
+// The argument SectionSlice will be applied to all models.
+func (pp *PkgBackend) Load(cfgStruct element.SectionSlice) *PkgBackend {
+	{{- if .HasOpt}}
+	opt := cfgmodel.WithFieldFromSectionSlice(cfgStruct)
+	{{end}}
+{{- range $bf := .Fields}}
+	{{- if $bf.ConfigPath}}
+	if f, _, err := cfgStruct.FindField(cfgpath.NewRoute({{quote $bf.FieldRoute}})); err == nil {
+		pp.{{$bf.GoName}} = cfgmodel.New{{$bf.Model}}({{quote $bf.Route}}, cfgmodel.WithField(&f))
+	}
+	{{- else}}
+	pp.{{$bf.GoName}} = cfgmodel.New{{$bf.Model}}({{quote $bf.Route}}, opt)
+	{{- end}}
+{{- end}}
+
+	return pp
+}
+`))
+
+// backendField represents a field of the generated type PkgBackend.
+type backendField struct {
+	*xmlField
+	GoName string
+	// Model contains the name of the cfgmodel type.
+	Model string
+}
+
+// model returns the name of the cfgmodel type to access the value.
+func (f *xmlField) model() string {
+	switch {
+	case f.isBool():
+		return "Bool"
+	case f.fieldType() == element.TypeMultiselect:
+		return "StringCSV"
+	case f.fieldType() == element.TypeObscure:
+		return "Obscure"
+	}
+	return "Str"
+}
+
+// WriteGo writes the Go source code of the read files into w. The code
+// contains the function NewConfigStructure, which returns the
+// element.SectionSlice, and the type PkgBackend with a cfgmodel accessor for
+// each field. A field with a config_path stores its value under the
+// config_path, hence its accessor uses the config_path. Error behaviour:
+// WriteFailed.
+func (c *Converter) WriteGo(w io.Writer, packageName string) error {
+	data := struct {
+		Sections []*xmlSection
+		Fields   []backendField
+		HasOpt   bool
+	}{
+		Sections: c.tree(),
+	}
+	for _, s := range data.Sections {
+		for _, g := range s.Groups {
+			for _, f := range g.Fields {
+				data.Fields = append(data.Fields, backendField{
+					xmlField: f,
+					GoName:   strs.ToGoCamelCase(strings.Replace(f.Route, "/", "_", -1)),
+					Model:    f.model(),
+				})
+				data.HasOpt = data.HasOpt || f.ConfigPath == ""
+			}
+		}
+	}
+
+	body := new(bytes.Buffer)
+	if err := genTpl.Execute(body, data); err != nil {
+		return errors.WriteFailed.New(err, "[cfgxml] WriteGo.Execute")
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "// Auto generated via github.com/corestoreio/pkg/config/cfgxml\n\npackage %s\n\nimport (\n", packageName)
+	for _, path := range genImportPaths {
+		if bytes.Contains(body.Bytes(), []byte(path[strings.LastIndexByte(path, '/')+1:]+".")) {
+			fmt.Fprintf(buf, "\t%q\n", path)
+		}
+	}
+	buf.WriteString(")\n")
+	buf.Write(body.Bytes())
+
+	fmted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.WriteFailed.New(err, "[cfgxml] WriteGo.format.Source")
+	}
+	_, err = w.Write(fmted)
+	return errors.WithStack(err)
+}
+
+// goQuote returns a raw string literal if possible.
+func goQuote(s string) string {
+	if strings.ContainsRune(s, '`') || !strconv.CanBackquote(strings.Replace(s, "\n", "", -1)) {
+		return strconv.Quote(s)
+	}
+	return "`" + s + "`"
+}
+
+// goDefault returns the default value of a field as Go literal.
+func goDefault(f *xmlField) string {
+	if s, ok := f.defaultValue().(string); ok {
+		return goQuote(s)
+	}
+	return fmt.Sprintf("%#v", f.defaultValue())
+}
+
+// goPerm returns the name of the constant for a permission.
+func goPerm(p scope.Perm) string {
+	switch p {
+	case scope.PermStore:
+		return "scope.PermStore"
+	case scope.PermWebsite:
+		return "scope.PermWebsite"
+	case scope.PermDefault:
+		return "scope.PermDefault"
+	}
+	var types []string
+	for _, t := range [...]scope.Type{scope.Default, scope.Website, scope.Store} {
+		if p.Has(t) {
+			types = append(types, "scope."+t.String())
+		}
+	}
+	return "scope.Perm(0).Set(" + strings.Join(types, ", ") + ")"
+}
+
+// oneLine collapses all white spaces.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// goComment wraps the text into comment lines of a struct field.
+func goComment(s string) string {
+	var buf bytes.Buffer
+	line := 0
+	for _, w := range strings.Fields(s) {
+		if line > 0 && line+len(w) > 76 {
+			buf.WriteByte('\n')
+			line = 0
+		}
+		if line == 0 {
+			buf.WriteString("\t//")
+			line = 2
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(w)
+		line += len(w) + 1
+	}
+	return buf.String()
+}
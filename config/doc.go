@@ -29,6 +29,13 @@ itself (package config/db) or etcd (package config/etcd) or consul (package todo
 If you use any other configuration storage engine besides config/db package all values
 gets bi-directional automatically synchronized (todo).
 
+Several Processes
+
+Option WithTransport broadcasts each written path to all processes running a
+Service, so their MessageReceiver get notified. Available transports are Redis
+pub/sub (package config/transport/cfgredis) and a polled MySQL table (package
+config/transport/cfgmysql).
+
 Elements
 
 The package config/element contains more detailed information.
@@ -0,0 +1,360 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// EnvPrefix identifies the environment variables which override configuration
+// paths.
+const EnvPrefix = "CONFIG__"
+
+// envSeparator separates the scope, the code and the path parts of an
+// environment variable name.
+const envSeparator = "__"
+
+// ScopeCodeResolver resolves the code of a website or store to its ID. The
+// *store.Service implements this interface.
+type ScopeCodeResolver interface {
+	IDbyCode(scp scope.Type, code string) (int64, error)
+}
+
+// ScopeCodeResolverFunc type is an adapter to allow the use of ordinary
+// functions as ScopeCodeResolver.
+type ScopeCodeResolverFunc func(scp scope.Type, code string) (int64, error)
+
+// IDbyCode calls f(scp, code).
+func (f ScopeCodeResolverFunc) IDbyCode(scp scope.Type, code string) (int64, error) {
+	return f(scp, code)
+}
+
+type envVar struct {
+	name  string
+	scp   scope.Type
+	code  string
+	route cfgpath.Route
+	value string
+}
+
+type envValue struct {
+	name  string
+	path  cfgpath.Path
+	value string
+}
+
+// EnvStorage layers configuration values from environment variables on top of
+// another Storager. The name of an environment variable maps to a path:
+//
+//		CONFIG__DEFAULT__WEB__UNSECURE__BASE_URL      => default/0/web/unsecure/base_url
+//		CONFIG__WEBSITES__EURO__GENERAL__LOCALE__CODE => websites/<ID of euro>/general/locale/code
+//		CONFIG__STORES__DE__GENERAL__LOCALE__CODE     => stores/<ID of de>/general/locale/code
+//
+// The parts get separated by two underscores and converted to lower case, so
+// website and store codes must be lower case, as Magento enforces it. Website
+// and store codes get resolved to their IDs with the first access of a path,
+// because the store.Service requires an already created config.Service. Each
+// variable gets resolved on its own: a code which cannot be resolved only
+// affects the paths of its variable, the underlying Storager answers for them
+// and the code gets retried with the next access. Overrides reports such
+// variables.
+// Resolved IDs get cached until ClearCache gets called, e.g. by a
+// store.ReloadObserver. The values are strings and get converted by the getters
+// of the Service with package util/conv. Overridden paths are read-only: Set
+// returns a NotAllowed error. EnvStorage is safe for concurrent use.
+type EnvStorage struct {
+	Storager
+	resolver ScopeCodeResolver
+	vars     []envVar
+
+	mu sync.RWMutex
+	// paths contains the resolved paths, key is the index of vars. Failed
+	// resolves do not get cached.
+	paths map[int]cfgpath.Path
+}
+
+// NewEnvStorage parses the environment variables with the prefix EnvPrefix
+// and wraps backend. Argument environ has the format of os.Environ. Argument r
+// can be nil if no website or store scope gets overridden. Variables with an
+// invalid name, an unsupported scope or a website or store scope without r
+// get logged with the info level and skipped, because the environment might be
+// shared with other applications. Argument l can be nil.
+func NewEnvStorage(backend Storager, environ []string, r ScopeCodeResolver, l log.Logger) *EnvStorage {
+	if l == nil {
+		l = log.BlackHole{}
+	}
+	es := &EnvStorage{
+		Storager: backend,
+		resolver: r,
+		paths:    make(map[int]cfgpath.Path),
+	}
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvPrefix) {
+			continue
+		}
+		ev, err := parseEnvVar(kv)
+		if err == nil && ev.scp != scope.Default && r == nil {
+			err = errors.NotSupported.Newf("[config] Environment variable %q requires a ScopeCodeResolver", ev.name)
+		}
+		if err != nil {
+			if l.IsInfo() {
+				l.Info("config.NewEnvStorage.Skip", log.Err(err), log.String("env", ev.name))
+			}
+			continue
+		}
+		es.vars = append(es.vars, ev)
+	}
+	return es
+}
+
+// parseEnvVar parses an environment variable in the format NAME=VALUE.
+func parseEnvVar(kv string) (ev envVar, err error) {
+	ev.name = kv
+	if pos := strings.IndexByte(kv, '='); pos > 0 {
+		ev.name = kv[:pos]
+		ev.value = kv[pos+1:]
+	}
+	parts := strings.Split(strings.ToLower(ev.name[len(EnvPrefix):]), envSeparator)
+
+	switch ev.scp = scope.FromString(parts[0]); {
+	case parts[0] == "default":
+		ev.scp = scope.Default
+		parts = parts[1:]
+	case ev.scp == scope.Website || ev.scp == scope.Store:
+		if len(parts) < 2 || parts[1] == "" {
+			return ev, errors.NotValid.Newf("[config] Environment variable %q misses the code", ev.name)
+		}
+		ev.code = parts[1]
+		parts = parts[2:]
+	default:
+		return ev, errors.NotSupported.Newf("[config] Environment variable %q contains an unsupported scope", ev.name)
+	}
+
+	p, err := cfgpath.NewByParts(parts...)
+	if err != nil {
+		return ev, errors.NotValid.New(err, "[config] Environment variable %q contains an invalid path", ev.name)
+	}
+	ev.route = p.Route
+	return ev, nil
+}
+
+// resolve returns the path of the environment variable with index i. The
+// website or store code gets resolved only once.
+func (es *EnvStorage) resolve(i int) (cfgpath.Path, error) {
+	es.mu.RLock()
+	p, ok := es.paths[i]
+	es.mu.RUnlock()
+	if ok {
+		return p, nil
+	}
+
+	ev := es.vars[i]
+	var id int64
+	if ev.scp != scope.Default {
+		var err error
+		if id, err = es.resolver.IDbyCode(ev.scp, ev.code); err != nil {
+			return cfgpath.Path{}, errors.Wrapf(err, "[config] Environment variable %q", ev.name)
+		}
+	}
+	p = cfgpath.Path{Route: ev.route}.Bind(scope.MakeTypeID(ev.scp, id))
+	es.mu.Lock()
+	es.paths[i] = p
+	es.mu.Unlock()
+	return p, nil
+}
+
+// overrides resolves all environment variables. The returned error contains
+// one error for each variable which cannot be resolved, the values of all
+// other variables get returned.
+func (es *EnvStorage) overrides() ([]envValue, error) {
+	values := make([]envValue, 0, len(es.vars))
+	var mErr *errors.MultiErr
+	for i, ev := range es.vars {
+		p, err := es.resolve(i)
+		if err != nil {
+			mErr = mErr.AppendErrors(err)
+			continue
+		}
+		values = append(values, envValue{name: ev.name, path: p, value: ev.value})
+	}
+	if mErr != nil {
+		return values, mErr
+	}
+	return values, nil
+}
+
+// lookup resolves only the environment variables with the same scope and
+// route, so writes during the boot process, before the store.Service exists,
+// do not trigger the ScopeCodeResolver. Variables whose code cannot be
+// resolved get skipped, so they do not affect the paths of other codes and the
+// underlying Storager answers. Overrides reports them.
+func (es *EnvStorage) lookup(p cfgpath.Path) (envValue, bool) {
+	scp, _ := p.ScopeID.Unpack()
+	for i, ev := range es.vars {
+		if ev.scp != scp || !ev.route.Equal(p.Route) {
+			continue
+		}
+		rp, err := es.resolve(i)
+		if err != nil {
+			continue
+		}
+		if rp.ScopeID == p.ScopeID {
+			return envValue{name: ev.name, path: rp, value: ev.value}, true
+		}
+	}
+	return envValue{}, false
+}
+
+// ClearCache removes the resolved website and store IDs. The codes get
+// resolved again with the next access. Call it after the store.Service has
+// been reloaded, see Service.ClearEnvOverrideCache.
+func (es *EnvStorage) ClearCache() {
+	es.mu.Lock()
+	es.paths = make(map[int]cfgpath.Path, len(es.vars))
+	es.mu.Unlock()
+}
+
+// Set implements Storager interface. Error behaviour: NotAllowed if an
+// environment variable overrides the path.
+func (es *EnvStorage) Set(p cfgpath.Path, value interface{}) error {
+	if ev, ok := es.lookup(p); ok {
+		return errors.NotAllowed.Newf("[config] Path %q is read-only because environment variable %q overrides it", p, ev.name)
+	}
+	return es.Storager.Set(p, value)
+}
+
+// Invalidate implements Invalidator interface and forwards the path to the
+// underlying Storager if supported.
+func (es *EnvStorage) Invalidate(p cfgpath.Path) error {
+	if inv, ok := es.Storager.(Invalidator); ok {
+		return inv.Invalidate(p)
+	}
+	return nil
+}
+
+// SetContext implements ContextStorager interface and forwards the context to
+// the underlying Storager if supported. Error behaviour: NotAllowed if an
+// environment variable overrides the path.
+func (es *EnvStorage) SetContext(ctx context.Context, p cfgpath.Path, value interface{}) error {
+	cs, ok := es.Storager.(ContextStorager)
+	if !ok {
+		return es.Set(p, value)
+	}
+	if ev, ok := es.lookup(p); ok {
+		return errors.NotAllowed.Newf("[config] Path %q is read-only because environment variable %q overrides it", p, ev.name)
+	}
+	return cs.SetContext(ctx, p, value)
+}
+
+// Get implements Storager interface and returns the string value of the
+// environment variable or the value of the underlying Storager.
+func (es *EnvStorage) Get(p cfgpath.Path) (interface{}, error) {
+	if ev, ok := es.lookup(p); ok {
+		return ev.value, nil
+	}
+	return es.Storager.Get(p)
+}
+
+// AllKeys implements Storager interface and merges the overridden paths with
+// the paths of the underlying Storager. Variables whose code cannot be
+// resolved get skipped, Overrides reports them.
+func (es *EnvStorage) AllKeys() (cfgpath.PathSlice, error) {
+	values, _ := es.overrides()
+	ps, err := es.Storager.AllKeys()
+	if err != nil {
+		return nil, errors.Wrap(err, "[config] EnvStorage.AllKeys")
+	}
+	seen := make(map[uint32]bool, len(ps)+len(values))
+	for _, p := range ps {
+		if h, err := p.Hash(-1); err == nil {
+			seen[h] = true
+		}
+	}
+	for _, ev := range values {
+		if h, err := ev.path.Hash(-1); err == nil && !seen[h] {
+			seen[h] = true
+			ps = append(ps, ev.path)
+		}
+	}
+	return ps, nil
+}
+
+// Overrides returns all paths which are overridden by an environment
+// variable, sorted by their fully qualified path. If codes cannot be resolved,
+// the paths of all other variables get returned together with an
+// *errors.MultiErr which contains one error for each failed variable.
+func (es *EnvStorage) Overrides() (cfgpath.PathSlice, error) {
+	values, err := es.overrides()
+	ps := make(cfgpath.PathSlice, 0, len(values))
+	for _, ev := range values {
+		ps = append(ps, ev.path)
+	}
+	sort.Slice(ps, func(i, j int) bool { return ps[i].String() < ps[j].String() })
+	return ps, err
+}
+
+// WithEnvOverride layers the environment variables with the prefix EnvPrefix
+// on top of the current Storager of the Service. Argument environ is mostly
+// os.Environ(). Argument r resolves website and store codes and can be a
+// ScopeCodeResolverFunc which calls the later created *store.Service. See
+// EnvStorage for the mapping of the names. Writes to overridden paths return a
+// NotAllowed error. Invalid variables get logged with the logger of the
+// Service, so apply WithLogger before.
+func WithEnvOverride(environ []string, r ScopeCodeResolver) Option {
+	return func(s *Service) error {
+		es := NewEnvStorage(s.backend, environ, r, s.Log)
+		s.backend = es
+		if s.Log.IsInfo() {
+			for _, ev := range es.vars {
+				s.Log.Info("config.WithEnvOverride", log.String("env", ev.name), log.String("scope", ev.scp.String()), log.String("code", ev.code), log.Stringer("route", ev.route))
+			}
+		}
+		return nil
+	}
+}
+
+// EnvOverrides returns all paths overridden by environment variables. Returns
+// nil if the option WithEnvOverride has not been applied.
+func (s *Service) EnvOverrides() (cfgpath.PathSlice, error) {
+	es, ok := s.backend.(*EnvStorage)
+	if !ok {
+		return nil, nil
+	}
+	return es.Overrides()
+}
+
+// ClearEnvOverrideCache forces the resolving of the website and store codes of
+// the environment variables with the next access. Register it as an observer
+// of the store.Service, so renamed or new codes get picked up after a reload:
+//
+//		storeSrv.RegisterReloadObserver(store.ReloadObserverFunc(func(*store.Service) error {
+//			cfgSrv.ClearEnvOverrideCache()
+//			return nil
+//		}))
+//
+// Does nothing if the option WithEnvOverride has not been applied.
+func (s *Service) ClearEnvOverrideCache() {
+	if es, ok := s.backend.(*EnvStorage); ok {
+		es.ClearCache()
+	}
+}
@@ -0,0 +1,251 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	goLog "log"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/log/logw"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*config.EnvStorage)(nil)
+var _ config.Invalidator = (*config.EnvStorage)(nil)
+
+var testEnviron = []string{
+	"PATH=/usr/bin",
+	"CONFIG__DEFAULT__WEB__UNSECURE__BASE_URL=https://corestore.io/",
+	"CONFIG__WEBSITES__EURO__GENERAL__COUNTRY__DEFAULT=DE",
+	"CONFIG__STORES__DE__GENERAL__LOCALE__CODE=de_DE",
+	"CONFIG__STORES__DE__CATALOG__FRONTEND__FLAT_CATALOG_PRODUCT=1",
+}
+
+var testResolver = config.ScopeCodeResolverFunc(func(scp scope.Type, code string) (int64, error) {
+	switch {
+	case scp == scope.Website && code == "euro":
+		return 1, nil
+	case scp == scope.Store && code == "de":
+		return 3, nil
+	}
+	return 0, errors.NotFound.Newf("Code %q not found", code)
+})
+
+func TestEnvStorage(t *testing.T) {
+	t.Parallel()
+
+	backend := config.NewInMemoryStore()
+	pLocale := cfgpath.MustNewByParts("general/locale/code")
+	require.NoError(t, backend.Set(pLocale.BindStore(3), "en_US"))
+	require.NoError(t, backend.Set(pLocale.BindStore(4), "en_GB"))
+
+	es := config.NewEnvStorage(backend, testEnviron, testResolver, nil)
+
+	t.Run("Get", func(t *testing.T) {
+		v, err := es.Get(pLocale.BindStore(3))
+		require.NoError(t, err)
+		assert.Exactly(t, "de_DE", v)
+
+		v, err = es.Get(pLocale.BindStore(4))
+		require.NoError(t, err)
+		assert.Exactly(t, "en_GB", v)
+
+		v, err = es.Get(cfgpath.MustNewByParts("general/country/default").BindWebsite(1))
+		require.NoError(t, err)
+		assert.Exactly(t, "DE", v)
+	})
+
+	t.Run("Set read-only", func(t *testing.T) {
+		err := es.Set(cfgpath.MustNewByParts("web/unsecure/base_url"), "https://example.com/")
+		assert.True(t, errors.NotAllowed.Match(err), "%+v", err)
+		assert.Contains(t, err.Error(), "CONFIG__DEFAULT__WEB__UNSECURE__BASE_URL")
+
+		require.NoError(t, es.Set(pLocale.BindStore(4), "en_AU"))
+		v, err := backend.Get(pLocale.BindStore(4))
+		require.NoError(t, err)
+		assert.Exactly(t, "en_AU", v)
+	})
+
+	t.Run("AllKeys", func(t *testing.T) {
+		ps, err := es.AllKeys()
+		require.NoError(t, err)
+		assert.Len(t, ps, 5)
+		assert.True(t, ps.Contains(cfgpath.MustNewByParts("catalog/frontend/flat_catalog_product").BindStore(3)))
+	})
+
+	t.Run("Overrides", func(t *testing.T) {
+		ps, err := es.Overrides()
+		require.NoError(t, err)
+		have := make([]string, 0, len(ps))
+		for _, p := range ps {
+			have = append(have, p.String())
+		}
+		assert.Exactly(t, []string{
+			"default/0/web/unsecure/base_url",
+			"stores/3/catalog/frontend/flat_catalog_product",
+			"stores/3/general/locale/code",
+			"websites/1/general/country/default",
+		}, have)
+	})
+}
+
+func TestNewEnvStorage_SkipInvalid(t *testing.T) {
+	t.Parallel()
+
+	infoBuf := new(log.MutexBuffer)
+	lg := logw.NewLog(logw.WithInfo(infoBuf, "testInfo: ", goLog.Lshortfile))
+	lg.SetLevel(logw.LevelInfo)
+
+	invalid := []string{
+		"CONFIG__GROUPS__DACH__WEB__UNSECURE__BASE_URL", // group scope
+		"CONFIG__STORES",                            // missing code
+		"CONFIG__DEFAULT__WEB",                      // short path
+		"CONFIG__STORES__DE__GENERAL__LOCALE__CODE", // missing resolver
+	}
+	environ := []string{"CONFIG__DEFAULT__WEB__UNSECURE__BASE_URL=https://corestore.io/"}
+	for _, name := range invalid {
+		environ = append(environ, name+"=x")
+	}
+	es := config.NewEnvStorage(config.NewInMemoryStore(), environ, nil, lg)
+
+	ps, err := es.Overrides()
+	require.NoError(t, err)
+	require.Len(t, ps, 1)
+	assert.Exactly(t, "default/0/web/unsecure/base_url", ps[0].String())
+
+	for _, name := range invalid {
+		assert.Contains(t, infoBuf.String(), `"`+name+`"`)
+	}
+}
+
+func TestEnvStorage_ResolveRetry(t *testing.T) {
+	t.Parallel()
+
+	var ready bool
+	r := config.ScopeCodeResolverFunc(func(scp scope.Type, code string) (int64, error) {
+		if !ready {
+			return 0, errors.NotFound.Newf("Store Service not yet loaded")
+		}
+		return testResolver(scp, code)
+	})
+	es := config.NewEnvStorage(config.NewInMemoryStore(), testEnviron, r, nil)
+
+	// paths without an environment variable do not need the resolver
+	require.NoError(t, es.Set(cfgpath.MustNewByParts("general/locale/timezone").BindStore(3), "Europe/Berlin"))
+
+	// The backend answers until the code can be resolved.
+	p := cfgpath.MustNewByParts("general/locale/code").BindStore(3)
+	v, err := es.Get(p)
+	assert.Nil(t, v)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	ready = true
+	v, err = es.Get(p)
+	require.NoError(t, err)
+	assert.Exactly(t, "de_DE", v)
+}
+
+func TestEnvStorage_ResolvePerVariable(t *testing.T) {
+	t.Parallel()
+
+	r := config.ScopeCodeResolverFunc(func(scp scope.Type, code string) (int64, error) {
+		if scp == scope.Website {
+			return 0, errors.NotFound.Newf("Website %q not found", code)
+		}
+		return testResolver(scp, code)
+	})
+	backend := config.NewInMemoryStore()
+	pCountry := cfgpath.MustNewByParts("general/country/default").BindWebsite(1)
+	require.NoError(t, backend.Set(pCountry, "AT"))
+	es := config.NewEnvStorage(backend, testEnviron, r, nil)
+
+	v, err := es.Get(cfgpath.MustNewByParts("general/locale/code").BindStore(3))
+	require.NoError(t, err)
+	assert.Exactly(t, "de_DE", v)
+
+	// The unresolvable variable gets skipped and the backend answers.
+	v, err = es.Get(pCountry)
+	require.NoError(t, err)
+	assert.Exactly(t, "AT", v)
+	require.NoError(t, es.Set(pCountry, "CH"))
+
+	ps, err := es.AllKeys()
+	require.NoError(t, err)
+	assert.Len(t, ps, 4)
+
+	ps, err = es.Overrides()
+	assert.Len(t, ps, 3)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CONFIG__WEBSITES__EURO__GENERAL__COUNTRY__DEFAULT")
+	assert.NotContains(t, err.Error(), "CONFIG__STORES__DE")
+}
+
+func TestEnvStorage_ClearCache(t *testing.T) {
+	t.Parallel()
+
+	var storeID int64 = 3
+	r := config.ScopeCodeResolverFunc(func(scp scope.Type, code string) (int64, error) {
+		if scp == scope.Store && code == "de" {
+			return storeID, nil
+		}
+		return testResolver(scp, code)
+	})
+	srv := config.MustNewService(config.NewInMemoryStore(), config.WithEnvOverride(testEnviron, r))
+
+	v, err := srv.String(cfgpath.MustNewByParts("general/locale/code").BindStore(3))
+	require.NoError(t, err)
+	assert.Exactly(t, "de_DE", v)
+
+	// The store view de got a new ID after a reload of the store.Service.
+	storeID = 5
+	ps, err := srv.EnvOverrides()
+	require.NoError(t, err)
+	assert.True(t, ps.Contains(cfgpath.MustNewByParts("general/locale/code").BindStore(3)), "still cached")
+
+	srv.ClearEnvOverrideCache()
+	ps, err = srv.EnvOverrides()
+	require.NoError(t, err)
+	assert.True(t, ps.Contains(cfgpath.MustNewByParts("general/locale/code").BindStore(5)))
+	assert.False(t, ps.Contains(cfgpath.MustNewByParts("general/locale/code").BindStore(3)))
+	require.NoError(t, srv.Write(cfgpath.MustNewByParts("general/locale/code").BindStore(3), "en_GB"))
+}
+
+func TestWithEnvOverride(t *testing.T) {
+	t.Parallel()
+
+	srv := config.MustNewService(config.NewInMemoryStore(), config.WithEnvOverride(testEnviron, testResolver))
+
+	p := cfgpath.MustNewByParts("catalog/frontend/flat_catalog_product").BindStore(3)
+	b, err := srv.Bool(p)
+	require.NoError(t, err)
+	assert.True(t, b)
+
+	err = srv.Write(p, false)
+	assert.True(t, errors.NotAllowed.Match(err), "%+v", err)
+
+	ps, err := srv.EnvOverrides()
+	require.NoError(t, err)
+	assert.Len(t, ps, 4)
+
+	ps, err = config.MustNewService(config.NewInMemoryStore()).EnvOverrides()
+	assert.NoError(t, err)
+	assert.Nil(t, ps)
+}
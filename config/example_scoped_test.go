@@ -17,12 +17,12 @@ package config_test
 import (
 	"fmt"
 
+	"github.com/corestoreio/errors"
 	"github.com/corestoreio/pkg/config"
 	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/store"
 	"github.com/corestoreio/pkg/store/scope"
-	"github.com/corestoreio/pkg/util/null"
-	"github.com/corestoreio/errors"
 )
 
 // Config Service, the Default storage engine with build-in in-memory map. The
@@ -38,9 +38,9 @@ var storeSrv = store.MustNewService(
 	// website, group and store. For the sake of this example the storage
 	// is hard coded.
 	store.WithTableWebsites(
-		&store.TableWebsite{WebsiteID: 0, Code: null.StringFrom("admin"), Name: null.StringFrom("Admin"), SortOrder: 0, DefaultGroupID: 0, IsDefault: null.BoolFrom(false)},
-		&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)},
-		&store.TableWebsite{WebsiteID: 2, Code: null.StringFrom("oz"), Name: null.StringFrom("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: null.BoolFrom(false)},
+		&store.TableWebsite{WebsiteID: 0, Code: dml.MakeNullString("admin"), Name: dml.MakeNullString("Admin"), SortOrder: 0, DefaultGroupID: 0, IsDefault: dml.MakeNullBool(false)},
+		&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)},
+		&store.TableWebsite{WebsiteID: 2, Code: dml.MakeNullString("oz"), Name: dml.MakeNullString("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: dml.MakeNullBool(false)},
 	),
 	store.WithTableGroups(
 		&store.TableGroup{GroupID: 3, WebsiteID: 2, Name: "Australia", RootCategoryID: 2, DefaultStoreID: 5},
@@ -49,13 +49,13 @@ var storeSrv = store.MustNewService(
 		&store.TableGroup{GroupID: 2, WebsiteID: 1, Name: "UK Group", RootCategoryID: 2, DefaultStoreID: 4},
 	),
 	store.WithTableStores(
-		&store.TableStore{StoreID: 0, Code: null.StringFrom("admin"), WebsiteID: 0, GroupID: 0, Name: "Admin", SortOrder: 0, IsActive: true},
-		&store.TableStore{StoreID: 5, Code: null.StringFrom("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
-		&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
-		&store.TableStore{StoreID: 4, Code: null.StringFrom("uk"), WebsiteID: 1, GroupID: 2, Name: "UK", SortOrder: 10, IsActive: true},
-		&store.TableStore{StoreID: 2, Code: null.StringFrom("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20, IsActive: true},
-		&store.TableStore{StoreID: 6, Code: null.StringFrom("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
-		&store.TableStore{IsActive: false, StoreID: 3, Code: null.StringFrom("ch"), WebsiteID: 1, GroupID: 1, Name: "Schweiz", SortOrder: 30},
+		&store.TableStore{StoreID: 0, Code: dml.MakeNullString("admin"), WebsiteID: 0, GroupID: 0, Name: "Admin", SortOrder: 0, IsActive: true},
+		&store.TableStore{StoreID: 5, Code: dml.MakeNullString("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
+		&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
+		&store.TableStore{StoreID: 4, Code: dml.MakeNullString("uk"), WebsiteID: 1, GroupID: 2, Name: "UK", SortOrder: 10, IsActive: true},
+		&store.TableStore{StoreID: 2, Code: dml.MakeNullString("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20, IsActive: true},
+		&store.TableStore{StoreID: 6, Code: dml.MakeNullString("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
+		&store.TableStore{IsActive: false, StoreID: 3, Code: dml.MakeNullString("ch"), WebsiteID: 1, GroupID: 1, Name: "Schweiz", SortOrder: 30},
 	),
 )
 
@@ -39,14 +39,18 @@ func NewInMemoryStore() Storager {
 	}
 }
 
-// Set implements Storager interface
+// Set implements Storager interface. A nil value deletes the key.
 func (sp *kvmap) Set(key cfgpath.Path, value interface{}) error {
 	h32, err := key.Hash(-1)
 	if err != nil {
 		return errors.Wrap(err, "[storage] key.Hash")
 	}
 	sp.Lock()
-	sp.kv[h32] = keyVal{key, value}
+	if value == nil {
+		delete(sp.kv, h32)
+	} else {
+		sp.kv[h32] = keyVal{key, value}
+	}
 	sp.Unlock()
 	return nil
 }
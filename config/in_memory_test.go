@@ -63,3 +63,23 @@ func TestSimpleStorage(t *testing.T) {
 	assert.True(t, errors.IsNotFound(err), "Error: %s", err)
 	assert.Nil(t, ni)
 }
+
+func TestSimpleStorage_Delete(t *testing.T) {
+
+	sp := config.NewInMemoryStore()
+	p1 := cfgpath.MustNewByParts("aa/bb/cc").BindStore(2)
+
+	assert.NoError(t, sp.Set(p1, "19.99"))
+	assert.NoError(t, sp.Set(p1, nil))
+
+	v, err := sp.Get(p1)
+	assert.True(t, errors.IsNotFound(err), "Error: %s", err)
+	assert.Nil(t, v)
+
+	keys, err := sp.AllKeys()
+	assert.NoError(t, err)
+	assert.Len(t, keys, 0)
+
+	// deleting a non-existent key is not an error
+	assert.NoError(t, sp.Set(p1, nil))
+}
@@ -0,0 +1,171 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// Query searches the stored paths across all scopes. Empty fields match
+// everything. Example to find all base URLs of all stores:
+//		config.Query{Pattern: "web/*/base_url", Scopes: scope.PermStoreReverse}
+type Query struct {
+	// Pattern matches the route without the scope. The syntax is the one of
+	// path.Match, so a star matches any characters of one route level, e.g.
+	// "web/*/base_url".
+	Pattern string
+	// Prefix matches the beginning of the route, e.g. "payment/".
+	Prefix string
+	// Scopes restricts the scope types, e.g. scope.PermStoreReverse for
+	// store values only.
+	Scopes scope.Perm
+	// ScopeIDs restricts the result to the listed scopes and their IDs.
+	ScopeIDs scope.TypeIDs
+}
+
+// Validate checks the syntax of the pattern. Error behaviour: NotValid.
+func (q Query) Validate() error {
+	if _, err := path.Match(q.Pattern, ""); err != nil {
+		return errors.NotValid.New(err, "[config] Query Pattern %q", q.Pattern)
+	}
+	return nil
+}
+
+// LiteralPrefix returns the longest route prefix which all matching paths
+// must have. Storage backends can use it for a prefix scan or a SQL LIKE
+// condition before filtering the remaining paths with Match.
+func (q Query) LiteralPrefix() string {
+	lp := q.Pattern
+	if i := strings.IndexAny(lp, `*?[\`); i >= 0 {
+		lp = lp[:i]
+	}
+	if len(q.Prefix) > len(lp) {
+		return q.Prefix
+	}
+	return lp
+}
+
+// MatchScope reports whether the scope matches the filters Scopes and
+// ScopeIDs.
+func (q Query) MatchScope(id scope.TypeID) bool {
+	if q.Scopes > 0 && !q.Scopes.Has(id.Type()) {
+		return false
+	}
+	if len(q.ScopeIDs) == 0 {
+		return true
+	}
+	for _, qid := range q.ScopeIDs {
+		if qid == id {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchRoute reports whether the route without scope matches the filters
+// Pattern and Prefix. An invalid pattern never matches.
+func (q Query) MatchRoute(route string) bool {
+	if q.Prefix != "" && !strings.HasPrefix(route, q.Prefix) {
+		return false
+	}
+	if q.Pattern == "" {
+		return true
+	}
+	ok, err := path.Match(q.Pattern, route)
+	return ok && err == nil
+}
+
+// Match reports whether the path matches all filters of the query.
+func (q Query) Match(p cfgpath.Path) bool {
+	return q.MatchScope(p.ScopeID) && q.MatchRoute(p.Route.String())
+}
+
+// KeyValue contains a path and its value as returned by a Storager.
+type KeyValue struct {
+	Key   cfgpath.Path
+	Value interface{}
+}
+
+// KeyValues a list of KeyValue, sortable by scope and route.
+type KeyValues []KeyValue
+
+func (kvs KeyValues) Len() int      { return len(kvs) }
+func (kvs KeyValues) Swap(i, j int) { kvs[i], kvs[j] = kvs[j], kvs[i] }
+func (kvs KeyValues) Less(i, j int) bool {
+	if kvs[i].Key.ScopeID != kvs[j].Key.ScopeID {
+		return kvs[i].Key.ScopeID < kvs[j].Key.ScopeID
+	}
+	return kvs[i].Key.Route.String() < kvs[j].Key.Route.String()
+}
+
+// Sort sorts the list by scope, scope ID and route.
+func (kvs KeyValues) Sort() KeyValues {
+	sort.Sort(kvs)
+	return kvs
+}
+
+// Querier can be implemented by a Storager to run a Query natively, like a
+// SQL LIKE condition or a prefix scan. The query has already been validated.
+type Querier interface {
+	Query(Query) (KeyValues, error)
+}
+
+// QueryStorage runs the query against the Storager and returns the sorted
+// result. If the Storager does not implement Querier, all keys get loaded via
+// AllKeys and each matching value via Get. Error behaviour: NotValid.
+func QueryStorage(s Storager, q Query) (KeyValues, error) {
+	if err := q.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if qr, ok := s.(Querier); ok {
+		kvs, err := qr.Query(q)
+		if err != nil {
+			return nil, errors.Wrap(err, "[config] QueryStorage.Query")
+		}
+		return kvs.Sort(), nil
+	}
+
+	keys, err := s.AllKeys()
+	if err != nil {
+		return nil, errors.Wrap(err, "[config] QueryStorage.AllKeys")
+	}
+	var kvs KeyValues
+	for _, k := range keys {
+		if !q.Match(k) {
+			continue
+		}
+		v, err := s.Get(k)
+		switch {
+		case errors.IsNotFound(err):
+			continue // deleted in the meantime
+		case err != nil:
+			return nil, errors.Wrapf(err, "[config] QueryStorage.Get Path %q", k)
+		}
+		kvs = append(kvs, KeyValue{Key: k, Value: v})
+	}
+	return kvs.Sort(), nil
+}
+
+// Query searches the paths and values of the backend. See QueryStorage.
+func (s *Service) Query(q Query) (KeyValues, error) {
+	kvs, err := QueryStorage(s.backend, q)
+	return kvs, errors.WithStack(err)
+}
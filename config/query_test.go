@@ -0,0 +1,114 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery_LiteralPrefix(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		q    config.Query
+		want string
+	}{
+		{config.Query{}, ""},
+		{config.Query{Pattern: "web/*/base_url"}, "web/"},
+		{config.Query{Pattern: "web/unsecure/base_url"}, "web/unsecure/base_url"},
+		{config.Query{Pattern: "web/?ecure/*", Prefix: "web/secure/"}, "web/secure/"},
+		{config.Query{Prefix: "payment/"}, "payment/"},
+	}
+	for i, test := range tests {
+		assert.Exactly(t, test.want, test.q.LiteralPrefix(), "Index %d", i)
+	}
+}
+
+func TestQuery_Match(t *testing.T) {
+	t.Parallel()
+
+	p := cfgpath.MustNewByParts("web/unsecure/base_url")
+	tests := []struct {
+		q    config.Query
+		p    cfgpath.Path
+		want bool
+	}{
+		{config.Query{}, p, true},
+		{config.Query{Pattern: "web/*/base_url"}, p.BindStore(2), true},
+		{config.Query{Pattern: "web/*"}, p, false},
+		{config.Query{Pattern: "web/*/*_url"}, p, true},
+		{config.Query{Prefix: "web/"}, p.BindWebsite(1), true},
+		{config.Query{Prefix: "payment/"}, p, false},
+		{config.Query{Scopes: scope.PermStoreReverse}, p.BindStore(2), true},
+		{config.Query{Scopes: scope.PermStoreReverse}, p.BindWebsite(2), false},
+		{config.Query{ScopeIDs: scope.TypeIDs{scope.Store.Pack(2), scope.Store.Pack(3)}}, p.BindStore(3), true},
+		{config.Query{ScopeIDs: scope.TypeIDs{scope.Store.Pack(2)}}, p.BindWebsite(2), false},
+		{config.Query{Pattern: "web/[/base_url"}, p, false},
+	}
+	for i, test := range tests {
+		assert.Exactly(t, test.want, test.q.Match(test.p), "Index %d", i)
+	}
+}
+
+func TestQueryStorage(t *testing.T) {
+	t.Parallel()
+
+	s := config.NewInMemoryStore()
+	p := cfgpath.MustNewByParts("web/unsecure/base_url")
+	ps := cfgpath.MustNewByParts("web/secure/base_url")
+	require.NoError(t, s.Set(p, "http://corestore.io/"))
+	require.NoError(t, s.Set(p.BindStore(3), "http://ch.corestore.io/"))
+	require.NoError(t, s.Set(ps.BindStore(2), "https://at.corestore.io/"))
+	require.NoError(t, s.Set(p.BindWebsite(1), "http://euro.corestore.io/"))
+	require.NoError(t, s.Set(cfgpath.MustNewByParts("payment/checkmo/active").BindStore(2), 1))
+
+	t.Run("all base_url in every store", func(t *testing.T) {
+		kvs, err := config.QueryStorage(s, config.Query{Pattern: "web/*/base_url", Scopes: scope.PermStoreReverse})
+		require.NoError(t, err)
+		require.Len(t, kvs, 2)
+		assert.Exactly(t, "stores/2/web/secure/base_url", kvs[0].Key.String())
+		assert.Exactly(t, "https://at.corestore.io/", kvs[0].Value)
+		assert.Exactly(t, "stores/3/web/unsecure/base_url", kvs[1].Key.String())
+	})
+
+	t.Run("sorted by scope", func(t *testing.T) {
+		kvs, err := config.QueryStorage(s, config.Query{Prefix: "web/unsecure/"})
+		require.NoError(t, err)
+		require.Len(t, kvs, 3)
+		assert.Exactly(t, scope.DefaultTypeID, kvs[0].Key.ScopeID)
+		assert.Exactly(t, scope.Website.Pack(1), kvs[1].Key.ScopeID)
+		assert.Exactly(t, scope.Store.Pack(3), kvs[2].Key.ScopeID)
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		kvs, err := config.QueryStorage(s, config.Query{Prefix: "payment/", ScopeIDs: scope.TypeIDs{scope.Store.Pack(2)}})
+		require.NoError(t, err)
+		require.Len(t, kvs, 1)
+		assert.Exactly(t, 1, kvs[0].Value)
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		kvs, err := config.QueryStorage(s, config.Query{Pattern: "web/["})
+		assert.Nil(t, kvs)
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+}
@@ -15,6 +15,7 @@
 package config
 
 import (
+	"context"
 	"time"
 
 	"github.com/corestoreio/pkg/config/cfgpath"
@@ -62,6 +63,27 @@ type Writer interface {
 	Write(p cfgpath.Path, value interface{}) error
 }
 
+// ContextWriter writes a configuration entry and passes the context to the
+// Storager, e.g. to record who has changed the value. Implemented by the
+// Service.
+type ContextWriter interface {
+	WriteContext(ctx context.Context, p cfgpath.Path, value interface{}) error
+}
+
+// ContextStorager is an optional interface of a Storager. If implemented,
+// Service.WriteContext calls SetContext instead of Set.
+type ContextStorager interface {
+	SetContext(ctx context.Context, key cfgpath.Path, value interface{}) error
+}
+
+// Invalidator is an optional interface of a Storager which caches values. A
+// path written by another node and received via the Transport gets removed
+// from the cache before the path gets published, so the next Get returns the
+// new value.
+type Invalidator interface {
+	Invalidate(key cfgpath.Path) error
+}
+
 // Storager is the underlying data storage for holding the keys and its values.
 // Implementations can be spf13/viper or MySQL backed. Default Storager is a
 // simple mutex protected map[string]interface{}. The config.Writer function
@@ -70,7 +92,9 @@ type Writer interface {
 // engine.
 type Storager interface {
 	// Set sets a key with a value and returns on success nil or
-	// ErrKeyOverwritten, on failure any other error
+	// ErrKeyOverwritten, on failure any other error. A nil value deletes the
+	// key, a following Get must return a NotFound error so that scoped reads
+	// fall back to the parent scope.
 	Set(key cfgpath.Path, value interface{}) error
 	// Get returns the raw value on success or may return a NotFound error
 	// behaviour if an entry cannot be found or does not exists. Any other error
@@ -90,6 +114,10 @@ type Service struct {
 	// config values.
 	*pubSub
 
+	// nodes propagates the writes to other processes. Nil if option
+	// WithTransport has not been applied.
+	nodes *nodeSync
+
 	// Log can be set for debugging purpose. If nil, it panics. Default
 	// log.Blackhole with disabled debug and info logging. You should use the
 	// option function WithLogger because the logger gets also set to the
@@ -155,7 +183,8 @@ func (s *Service) NewScoped(websiteID, storeID int64) Scoped {
 	return NewScoped(s, websiteID, storeID)
 }
 
-// Write puts a value back into the Service. Example usage:
+// Write puts a value back into the Service. With option WithTransport the
+// path gets broadcast to the other nodes. Example usage:
 //		// Default Scope
 //		p, err := cfgpath.NewByParts("currency/option/base") // or use cfgpath.MustNewByParts( ... )
 // 		err := Write(p, "USD")
@@ -168,19 +197,49 @@ func (s *Service) NewScoped(websiteID, storeID int64) Scoped {
 //		// 6 for example comes from core_store/store database table
 //		err := Write(p.Bind(scope.StoreID, 6), "CHF")
 func (s *Service) Write(p cfgpath.Path, v interface{}) error {
+	return s.WriteContext(context.Background(), p, v)
+}
+
+// WriteContext same as Write but passes the context to the Storager if it
+// implements interface ContextStorager. Implements interface ContextWriter.
+func (s *Service) WriteContext(ctx context.Context, p cfgpath.Path, v interface{}) error {
 	if s.Log.IsDebug() {
 		s.Log.Debug("config.Service.Write", log.Stringer("path", p), log.Object("val", v))
 	}
 
-	if err := s.backend.Set(p, v); err != nil {
+	var err error
+	if cs, ok := s.backend.(ContextStorager); ok {
+		err = cs.SetContext(ctx, p, v)
+	} else {
+		err = s.backend.Set(p, v)
+	}
+	if err != nil {
 		return errors.Wrap(err, "[config] sStorage.Set")
 	}
 	if s.pubSub != nil {
 		s.sendMsg(p)
 	}
+	if s.nodes != nil {
+		// the value has been written, only the other nodes do not know it.
+		if err := s.nodes.broadcast(p); err != nil {
+			return errors.Wrapf(err, "[config] Transport.Broadcast Path %q", p)
+		}
+	}
 	return nil
 }
 
+// Publish notifies the subscribers about a changed path without writing to the
+// Storager. It does nothing if the pub/sub feature has not been enabled. The
+// path gets not broadcast to other nodes. Implements interface Publisher.
+func (s *Service) Publish(p cfgpath.Path) {
+	if s.Log.IsDebug() {
+		s.Log.Debug("config.Service.Publish", log.Stringer("path", p))
+	}
+	if s.pubSub != nil {
+		s.sendMsg(p)
+	}
+}
+
 // get generic getter ... not sure if this should be public ...
 func (s *Service) get(p cfgpath.Path) (interface{}, error) {
 	if s.Log.IsDebug() {
@@ -51,6 +51,15 @@ type Subscriber interface {
 	Subscribe(cfgpath.Route, MessageReceiver) (subscriptionID int, err error)
 }
 
+// Publisher notifies all subscribed MessageReceiver about a changed path
+// without writing a value. Storage backends shared between several processes,
+// like etcd, use it to announce values changed by other processes. This
+// interface is at the moment only implemented by the config.Service.
+type Publisher interface {
+	// Publish sends the path to all MessageReceiver subscribed to the path.
+	Publish(cfgpath.Path)
+}
+
 // pubSub embedded pointer struct into the Service
 type pubSub struct {
 	// subMap, subscribed writers are getting called when a write event
@@ -155,7 +164,10 @@ func (s *pubSub) publish() {
 				return
 			}
 
-			if len(s.subMap) == 0 {
+			s.mu.RLock()
+			hasSubs := len(s.subMap) > 0
+			s.mu.RUnlock()
+			if !hasSubs {
 				break
 			}
 
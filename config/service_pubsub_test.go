@@ -259,3 +259,30 @@ func TestPubSubEvict(t *testing.T) {
 	err = s.Close()
 	assert.True(t, errors.IsAlreadyClosed(err), "Error: %s", err)
 }
+
+var _ config.Publisher = (*config.Service)(nil)
+
+func TestService_Publish(t *testing.T) {
+	testPath := cfgpath.MustNewByParts("aa/bb/cc").BindStore(2)
+
+	s := config.MustNewService(config.NewInMemoryStore(), config.WithPubSub())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	_, err := s.Subscribe(testPath.Route, &testSubscriber{
+		t: t,
+		f: func(p cfgpath.Path) error {
+			defer wg.Done()
+			assert.Exactly(t, testPath.String(), p.String())
+			return nil
+		},
+	})
+	assert.NoError(t, err)
+
+	s.Publish(testPath)
+	wg.Wait()
+	assert.NoError(t, s.Close())
+
+	_, err = s.String(testPath)
+	assert.True(t, errors.IsNotFound(err), "Publish must not write a value: %+v", err)
+}
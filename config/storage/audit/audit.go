@@ -0,0 +1,332 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// Actor describes who changed a configuration value.
+type Actor struct {
+	UserID     string `json:"user_id,omitempty"`
+	UserName   string `json:"user_name,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+}
+
+type ctxKeyActor struct{}
+type ctxKeyChangeSet struct{}
+
+// WithActor returns a new context containing the actor.
+func WithActor(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, ctxKeyActor{}, a)
+}
+
+// ActorFromContext returns the actor of the context or an empty Actor.
+func ActorFromContext(ctx context.Context) Actor {
+	a, _ := ctx.Value(ctxKeyActor{}).(Actor)
+	return a
+}
+
+// WithChangeSet returns a new context containing the ID of a change set. All
+// changes with the same ID can be reverted together.
+func WithChangeSet(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKeyChangeSet{}, id)
+}
+
+// ChangeSetFromContext returns the change set ID of the context or an empty
+// string.
+func ChangeSetFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKeyChangeSet{}).(string)
+	return id
+}
+
+// Entry represents one recorded change of a configuration value.
+type Entry struct {
+	// ID gets assigned by the History.
+	ID        int64
+	ChangeSet string
+	Path      cfgpath.Path
+	// Old contains the previous value. Not valid if the path has not been
+	// set before.
+	Old dml.NullString
+	// New contains the written value. Not valid if the path has been deleted
+	// with a nil value.
+	New     dml.NullString
+	Actor   Actor
+	Created time.Time
+}
+
+// Query filters the entries of a History. Empty fields get ignored.
+type Query struct {
+	// Path matches the scope and the route of an entry.
+	Path      cfgpath.Path
+	ChangeSet string
+	// From includes entries created at or after From.
+	From time.Time
+	// To includes entries created before To.
+	To time.Time
+}
+
+// Matches reports whether the entry matches the filters of the query.
+func (q Query) Matches(e Entry) bool {
+	switch {
+	case !q.Path.Route.IsEmpty() && (q.Path.ScopeID != e.Path.ScopeID || !q.Path.Route.Equal(e.Path.Route)):
+		return false
+	case q.ChangeSet != "" && q.ChangeSet != e.ChangeSet:
+		return false
+	case !q.From.IsZero() && e.Created.Before(q.From):
+		return false
+	case !q.To.IsZero() && !e.Created.Before(q.To):
+		return false
+	}
+	return true
+}
+
+// History stores the entries in an append-only way.
+type History interface {
+	// Append adds entries to the history and assigns their IDs.
+	Append(ctx context.Context, entries ...Entry) error
+	// Query returns the matching entries sorted ascending by their creation
+	// time.
+	Query(ctx context.Context, q Query) ([]Entry, error)
+}
+
+// Option applies options to the Storage.
+type Option func(*Storage) error
+
+// WithLogger sets a custom logger. Default logger is a black hole.
+func WithLogger(l log.Logger) Option {
+	return func(s *Storage) error {
+		s.log = l
+		return nil
+	}
+}
+
+// WithWriter sets the writer for rollbacks, mostly the *config.Service which
+// uses the Storage as its backend. Writing through the config.Service
+// publishes the restored paths to the subscribers and to the other nodes. As
+// the config.Service requires an already created Storage, apply this option
+// via function Options. Without a writer, rollbacks get written directly into
+// the Storage and nobody gets notified.
+func WithWriter(w config.ContextWriter) Option {
+	return func(s *Storage) error {
+		s.writer = w
+		return nil
+	}
+}
+
+// Storage records each change of the embedded config.Storager in a History.
+// Implements interface config.Storager and config.ContextStorager, so
+// config.Service.WriteContext passes the actor and the change set of the
+// context. Storage is safe for concurrent use.
+type Storage struct {
+	config.Storager
+	history History
+	writer  config.ContextWriter
+	log     log.Logger
+	// mu serializes the writes, so the old value of an entry is always the
+	// new value of the previous entry of the same path.
+	mu sync.Mutex
+}
+
+// New creates a new audit Storage.
+func New(s config.Storager, h History, opts ...Option) (*Storage, error) {
+	as := &Storage{
+		Storager: s,
+		history:  h,
+		log:      log.BlackHole{}, // skip debug and info level via init with empty fields
+	}
+	if err := as.Options(opts...); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return as, nil
+}
+
+// Options applies options after the creation of the Storage.
+//
+//		as := audit.MustNew(backend, history)
+//		cfgSrv := config.MustNewService(as, config.WithPubSub())
+//		err := as.Options(audit.WithWriter(cfgSrv))
+func (s *Storage) Options(opts ...Option) error {
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// MustNew same as New but panics on error.
+func MustNew(s config.Storager, h History, opts ...Option) *Storage {
+	as, err := New(s, h, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return as
+}
+
+// Invalidate implements config.Invalidator interface and forwards the path to
+// the embedded config.Storager if supported.
+func (s *Storage) Invalidate(p cfgpath.Path) error {
+	if inv, ok := s.Storager.(config.Invalidator); ok {
+		return errors.WithStack(inv.Invalidate(p))
+	}
+	return nil
+}
+
+// Set implements config.Storager interface. The change gets recorded without
+// actor and change set.
+func (s *Storage) Set(p cfgpath.Path, value interface{}) error {
+	return s.SetContext(context.Background(), p, value)
+}
+
+// SetContext writes the value and records the change with the actor and the
+// change set from the context. If the History fails to record the change, the
+// previous value gets restored, so no change stays unrecorded.
+func (s *Storage) SetContext(ctx context.Context, p cfgpath.Path, value interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := Entry{
+		ChangeSet: ChangeSetFromContext(ctx),
+		Path:      p,
+		Actor:     ActorFromContext(ctx),
+	}
+	old, err := s.Storager.Get(p)
+	switch {
+	case err == nil && old != nil:
+		str, err := conv.ToStringE(old)
+		if err != nil {
+			return errors.Wrapf(err, "[audit] SetContext.ToStringE old value of Path %q", p)
+		}
+		e.Old = dml.MakeNullString(str)
+	case err != nil && !errors.IsNotFound(err):
+		return errors.Wrapf(err, "[audit] SetContext.Get Path %q", p)
+	}
+	if value != nil {
+		str, err := conv.ToStringE(value)
+		if err != nil {
+			return errors.Wrapf(err, "[audit] SetContext.ToStringE Path %q", p)
+		}
+		e.New = dml.MakeNullString(str)
+	}
+
+	if err := s.Storager.Set(p, value); err != nil {
+		return errors.Wrapf(err, "[audit] SetContext.Set Path %q", p)
+	}
+	e.Created = time.Now()
+	if err := s.history.Append(ctx, e); err != nil {
+		if rbErr := s.Storager.Set(p, nullValue(e.Old)); rbErr != nil && s.log.IsInfo() {
+			s.log.Info("audit.Storage.SetContext.Restore", log.Err(rbErr), log.Stringer("path", p))
+		}
+		return errors.Wrapf(err, "[audit] SetContext.Append Path %q", p)
+	}
+	if s.log.IsDebug() {
+		s.log.Debug("audit.Storage.SetContext", log.Stringer("path", p), log.String("user_id", e.Actor.UserID), log.String("change_set", e.ChangeSet))
+	}
+	return nil
+}
+
+// nullValue converts an invalid NullString to nil, which deletes a path.
+func nullValue(ns dml.NullString) interface{} {
+	if !ns.Valid {
+		return nil
+	}
+	return ns.String
+}
+
+// History returns the recorded entries matching the query.
+func (s *Storage) History(ctx context.Context, q Query) ([]Entry, error) {
+	entries, err := s.history.Query(ctx, q)
+	return entries, errors.Wrap(err, "[audit] History.Query")
+}
+
+// Rollback restores paths to the values they had at the point in time to. An
+// empty paths argument restores all paths changed since then. The rollback
+// gets recorded with the actor and the change set of the context. Returns the
+// number of restored paths.
+func (s *Storage) Rollback(ctx context.Context, to time.Time, paths ...cfgpath.Path) (int, error) {
+	entries, err := s.history.Query(ctx, Query{From: to})
+	if err != nil {
+		return 0, errors.Wrap(err, "[audit] Rollback.Query")
+	}
+	if len(paths) > 0 {
+		var filtered []Entry
+		for _, e := range entries {
+			for _, p := range paths {
+				if (Query{Path: p}).Matches(e) {
+					filtered = append(filtered, e)
+					break
+				}
+			}
+		}
+		entries = filtered
+	}
+	n, err := s.restoreFirst(ctx, entries)
+	return n, errors.Wrapf(err, "[audit] Rollback to %s", to)
+}
+
+// RollbackChangeSet restores all paths of a change set to the values they had
+// before the change set. Later changes of the same paths get overwritten.
+// Returns the number of restored paths. Error behaviour: NotFound.
+func (s *Storage) RollbackChangeSet(ctx context.Context, changeSet string) (int, error) {
+	if changeSet == "" {
+		return 0, errors.Empty.Newf("[audit] RollbackChangeSet requires a change set ID")
+	}
+	entries, err := s.history.Query(ctx, Query{ChangeSet: changeSet})
+	if err != nil {
+		return 0, errors.Wrap(err, "[audit] RollbackChangeSet.Query")
+	}
+	if len(entries) == 0 {
+		return 0, errors.NotFound.Newf("[audit] Change set %q not found", changeSet)
+	}
+	n, err := s.restoreFirst(ctx, entries)
+	return n, errors.Wrapf(err, "[audit] RollbackChangeSet %q", changeSet)
+}
+
+// restoreFirst writes for each path the old value of its first entry. The
+// entries must be sorted by their creation time. The values get written via
+// the writer of option WithWriter, if set.
+func (s *Storage) restoreFirst(ctx context.Context, entries []Entry) (int, error) {
+	seen := make(map[string]bool, len(entries))
+	var n int
+	for _, e := range entries {
+		key := e.Path.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		var err error
+		if s.writer != nil {
+			err = s.writer.WriteContext(ctx, e.Path, nullValue(e.Old))
+		} else {
+			err = s.SetContext(ctx, e.Path, nullValue(e.Old))
+		}
+		if err != nil {
+			return n, errors.WithStack(err)
+		}
+		n++
+	}
+	return n, nil
+}
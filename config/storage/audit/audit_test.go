@@ -0,0 +1,286 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*audit.Storage)(nil)
+var _ config.ContextStorager = (*audit.Storage)(nil)
+var _ config.Invalidator = (*audit.Storage)(nil)
+var _ audit.History = (*audit.FileHistory)(nil)
+
+func newFileStorage(t *testing.T) (*audit.Storage, *audit.FileHistory, func()) {
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	fh, err := audit.NewFileHistory(filepath.Join(dir, "history.jsonl"))
+	require.NoError(t, err)
+	return audit.MustNew(config.NewInMemoryStore(), fh), fh, func() {
+		assert.NoError(t, fh.Close())
+		assert.NoError(t, os.RemoveAll(dir))
+	}
+}
+
+func TestStorage_SetContext(t *testing.T) {
+	t.Parallel()
+
+	s, _, closer := newFileStorage(t)
+	defer closer()
+
+	p := cfgpath.MustNewByParts("web/cors/allowed_origins").BindWebsite(1)
+	actor := audit.Actor{UserID: "42", UserName: "admin", RemoteAddr: "127.0.0.1"}
+	ctx := audit.WithChangeSet(audit.WithActor(context.Background(), actor), "cs1")
+
+	require.NoError(t, s.SetContext(ctx, p, "https://corestore.io"))
+	require.NoError(t, s.Set(p, 4711))
+	require.NoError(t, s.Set(p.BindStore(2), "https://corestore.de"))
+
+	v, err := s.Get(p)
+	require.NoError(t, err)
+	assert.Exactly(t, 4711, v)
+
+	entries, err := s.History(context.Background(), audit.Query{Path: p})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Exactly(t, int64(1), entries[0].ID)
+	assert.Exactly(t, "cs1", entries[0].ChangeSet)
+	assert.Exactly(t, p.String(), entries[0].Path.String())
+	assert.False(t, entries[0].Old.Valid)
+	assert.Exactly(t, "https://corestore.io", entries[0].New.String)
+	assert.Exactly(t, actor, entries[0].Actor)
+
+	assert.Exactly(t, int64(2), entries[1].ID)
+	assert.Empty(t, entries[1].ChangeSet)
+	assert.Exactly(t, "https://corestore.io", entries[1].Old.String)
+	assert.Exactly(t, "4711", entries[1].New.String)
+	assert.Exactly(t, audit.Actor{}, entries[1].Actor)
+
+	entries, err = s.History(context.Background(), audit.Query{ChangeSet: "cs1"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	entries, err = s.History(context.Background(), audit.Query{To: entries[0].Created})
+	require.NoError(t, err)
+	assert.Len(t, entries, 0)
+}
+
+func TestFileHistory_Reopen(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "history.jsonl")
+
+	fh, err := audit.NewFileHistory(name)
+	require.NoError(t, err)
+	p := cfgpath.MustNewByParts("carriers/dhl/active")
+	require.NoError(t, fh.Append(context.Background(), audit.Entry{Path: p}, audit.Entry{Path: p.BindStore(3)}))
+	require.NoError(t, fh.Close())
+
+	fh, err = audit.NewFileHistory(name)
+	require.NoError(t, err)
+	defer fh.Close()
+	require.NoError(t, fh.Append(context.Background(), audit.Entry{Path: p.BindWebsite(1)}))
+
+	entries, err := fh.Query(context.Background(), audit.Query{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Exactly(t, int64(3), entries[2].ID)
+	assert.Exactly(t, "websites/1/carriers/dhl/active", entries[2].Path.String())
+}
+
+func TestStorage_Rollback(t *testing.T) {
+	t.Parallel()
+
+	s, _, closer := newFileStorage(t)
+	defer closer()
+
+	p1 := cfgpath.MustNewByParts("carriers/dhl/active")
+	p2 := cfgpath.MustNewByParts("carriers/ups/active").BindStore(2)
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(p1, "1"))
+	mark := time.Now()
+	require.NoError(t, s.Set(p1, "0"))
+	require.NoError(t, s.Set(p1, "2"))
+	require.NoError(t, s.Set(p2, "1"))
+
+	t.Run("single path", func(t *testing.T) {
+		n, err := s.Rollback(ctx, mark, p1)
+		require.NoError(t, err)
+		assert.Exactly(t, 1, n)
+		v, err := s.Get(p1)
+		require.NoError(t, err)
+		assert.Exactly(t, "1", v)
+		v, err = s.Get(p2)
+		require.NoError(t, err)
+		assert.Exactly(t, "1", v)
+	})
+
+	t.Run("all paths", func(t *testing.T) {
+		n, err := s.Rollback(ctx, mark)
+		require.NoError(t, err)
+		assert.Exactly(t, 2, n)
+		v, err := s.Get(p1)
+		require.NoError(t, err)
+		assert.Exactly(t, "1", v)
+		v, err = s.Get(p2)
+		assert.True(t, errors.IsNotFound(err), "p2 did not exists before the mark: %+v", err)
+		assert.Nil(t, v)
+	})
+}
+
+func TestStorage_RollbackChangeSet(t *testing.T) {
+	t.Parallel()
+
+	s, _, closer := newFileStorage(t)
+	defer closer()
+
+	p1 := cfgpath.MustNewByParts("carriers/dhl/active")
+	p2 := cfgpath.MustNewByParts("carriers/ups/active").BindStore(2)
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(p1, "1"))
+	csCtx := audit.WithChangeSet(ctx, "deploy-1")
+	require.NoError(t, s.SetContext(csCtx, p1, "0"))
+	require.NoError(t, s.SetContext(csCtx, p2, "1"))
+	require.NoError(t, s.SetContext(csCtx, p1, "3"))
+
+	n, err := s.RollbackChangeSet(audit.WithChangeSet(ctx, "revert-deploy-1"), "deploy-1")
+	require.NoError(t, err)
+	assert.Exactly(t, 2, n)
+
+	v, err := s.Get(p1)
+	require.NoError(t, err)
+	assert.Exactly(t, "1", v)
+	v, err = s.Get(p2)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+	assert.Nil(t, v)
+
+	entries, err := s.History(ctx, audit.Query{ChangeSet: "revert-deploy-1"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	_, err = s.RollbackChangeSet(ctx, "deploy-2")
+	assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	_, err = s.RollbackChangeSet(ctx, "")
+	assert.True(t, errors.Empty.Match(err), "%+v", err)
+}
+
+func TestStorage_WithWriter(t *testing.T) {
+	t.Parallel()
+
+	s, _, closer := newFileStorage(t)
+	defer closer()
+	srv := config.MustNewService(s, config.WithPubSub())
+	defer func() { assert.NoError(t, srv.Close()) }()
+	require.NoError(t, s.Options(audit.WithWriter(srv)))
+
+	received := make(chan string, 10)
+	_, err := srv.Subscribe(cfgpath.NewRoute("carriers"), &testSubscriber{
+		f: func(p cfgpath.Path) error {
+			received <- p.String()
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	waitFor := func(want string) {
+		t.Helper()
+		select {
+		case have := <-received:
+			assert.Exactly(t, want, have)
+		case <-time.After(time.Second):
+			t.Fatalf("MessageReceiver has not been called for %q", want)
+		}
+	}
+
+	p1 := cfgpath.MustNewByParts("carriers/dhl/active")
+	p2 := cfgpath.MustNewByParts("carriers/ups/active").BindStore(2)
+	actor := audit.Actor{UserID: "42", UserName: "admin"}
+	ctx := audit.WithActor(context.Background(), actor)
+
+	require.NoError(t, srv.Write(p1, "1"))
+	waitFor("default/0/carriers/dhl/active")
+	csCtx := audit.WithChangeSet(ctx, "deploy-1")
+	require.NoError(t, srv.WriteContext(csCtx, p1, "0"))
+	waitFor("default/0/carriers/dhl/active")
+	require.NoError(t, srv.WriteContext(csCtx, p2, "1"))
+	waitFor("stores/2/carriers/ups/active")
+
+	entries, err := s.History(ctx, audit.Query{ChangeSet: "deploy-1"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Exactly(t, actor, entries[0].Actor)
+
+	n, err := s.RollbackChangeSet(audit.WithChangeSet(ctx, "revert-deploy-1"), "deploy-1")
+	require.NoError(t, err)
+	assert.Exactly(t, 2, n)
+	waitFor("default/0/carriers/dhl/active")
+	waitFor("stores/2/carriers/ups/active")
+
+	v, err := srv.String(p1)
+	require.NoError(t, err)
+	assert.Exactly(t, "1", v)
+	_, err = srv.String(p2)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	entries, err = s.History(ctx, audit.Query{ChangeSet: "revert-deploy-1"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Exactly(t, actor, entries[1].Actor)
+}
+
+type testSubscriber struct {
+	f func(p cfgpath.Path) error
+}
+
+func (ts *testSubscriber) MessageConfig(p cfgpath.Path) error { return ts.f(p) }
+
+type failingHistory struct{ audit.History }
+
+func (failingHistory) Append(context.Context, ...audit.Entry) error {
+	return errors.WriteFailed.Newf("disk full")
+}
+
+func TestStorage_SetContext_AppendFailed(t *testing.T) {
+	t.Parallel()
+
+	cs := config.NewInMemoryStore()
+	p := cfgpath.MustNewByParts("carriers/dhl/active")
+	require.NoError(t, cs.Set(p, "1"))
+
+	s := audit.MustNew(cs, failingHistory{})
+	err := s.Set(p, "0")
+	assert.True(t, errors.WriteFailed.Match(err), "%+v", err)
+
+	v, err := s.Get(p)
+	require.NoError(t, err)
+	assert.Exactly(t, "1", v, "old value must be restored")
+}
@@ -0,0 +1,39 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit records every change of configuration values in an append-only
+// history.
+//
+// The Storage type decorates any config.Storager. Each Set writes an Entry
+// with the path, the scope, the old and the new value, the actor and the time
+// into a History. The actor and an optional change set ID get read from the
+// context passed to config.Service.WriteContext, which calls SetContext of the
+// Storage and afterwards notifies the subscribers and the other nodes:
+//
+//		as := audit.MustNew(backend, history)
+//		cfgSrv := config.MustNewService(as, config.WithPubSub())
+//		err := as.Options(audit.WithWriter(cfgSrv)) // rollbacks notify too
+//
+//		ctx = audit.WithActor(ctx, audit.Actor{UserID: "42", UserName: "admin"})
+//		ctx = audit.WithChangeSet(ctx, "deploy-2017-07-12")
+//		err := cfgSrv.WriteContext(ctx, p, "https://corestore.io/")
+//
+// The History can be queried per path, per change set or per time window.
+// Rollback restores paths to the values they had at an earlier point in time
+// and RollbackChangeSet reverts all changes of a change set. A rollback gets
+// recorded as a new change.
+//
+// Two History implementations exist: DBHistory stores the entries in a MySQL
+// table and FileHistory appends them as JSON lines to a file.
+package audit
@@ -0,0 +1,249 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// TableNameHistory default name of the history table.
+//
+//		CREATE TABLE `core_config_data_history` (
+//		  `history_id` bigint unsigned NOT NULL AUTO_INCREMENT,
+//		  `change_set` varchar(255) NOT NULL DEFAULT '',
+//		  `scope` varchar(8) NOT NULL DEFAULT 'default',
+//		  `scope_id` int NOT NULL DEFAULT '0',
+//		  `path` varchar(255) NOT NULL,
+//		  `old_value` text,
+//		  `new_value` text,
+//		  `user_id` varchar(255) NOT NULL DEFAULT '',
+//		  `user_name` varchar(255) NOT NULL DEFAULT '',
+//		  `remote_addr` varchar(64) NOT NULL DEFAULT '',
+//		  `created_at` datetime(6) NOT NULL,
+//		  PRIMARY KEY (`history_id`),
+//		  KEY `IDX_PATH` (`scope`,`scope_id`,`path`),
+//		  KEY `IDX_CHANGE_SET` (`change_set`),
+//		  KEY `IDX_CREATED_AT` (`created_at`)
+//		) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+const TableNameHistory = "core_config_data_history"
+
+var historyColumns = []string{"change_set", "scope", "scope_id", "path", "old_value", "new_value", "user_id", "user_name", "remote_addr", "created_at"}
+
+// historyRow represents a row of the history table.
+type historyRow struct {
+	HistoryID  int64
+	ChangeSet  string
+	Scope      string
+	ScopeID    int64
+	Path       string
+	OldValue   dml.NullString
+	NewValue   dml.NullString
+	UserID     string
+	UserName   string
+	RemoteAddr string
+	CreatedAt  time.Time
+}
+
+func newHistoryRow(e Entry) (*historyRow, error) {
+	route, err := e.Path.Level(-1)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[audit] Path %q", e.Path)
+	}
+	scp, id := e.Path.ScopeID.Unpack()
+	return &historyRow{
+		HistoryID:  e.ID,
+		ChangeSet:  e.ChangeSet,
+		Scope:      scp.StrType(),
+		ScopeID:    id,
+		Path:       route.String(),
+		OldValue:   e.Old,
+		NewValue:   e.New,
+		UserID:     e.Actor.UserID,
+		UserName:   e.Actor.UserName,
+		RemoteAddr: e.Actor.RemoteAddr,
+		CreatedAt:  e.Created,
+	}, nil
+}
+
+func (r *historyRow) entry() (Entry, error) {
+	p, err := cfgpath.NewByParts(r.Path)
+	if err != nil {
+		return Entry{}, errors.Wrapf(err, "[audit] History ID %d", r.HistoryID)
+	}
+	return Entry{
+		ID:        r.HistoryID,
+		ChangeSet: r.ChangeSet,
+		Path:      p.Bind(scope.FromString(r.Scope).Pack(r.ScopeID)),
+		Old:       r.OldValue,
+		New:       r.NewValue,
+		Actor:     Actor{UserID: r.UserID, UserName: r.UserName, RemoteAddr: r.RemoteAddr},
+		Created:   r.CreatedAt,
+	}, nil
+}
+
+// MapColumns implements interface dml.ColumnMapper.
+func (r *historyRow) MapColumns(cm *dml.ColumnMap) error {
+	if cm.Mode() == dml.ColumnMapEntityReadAll {
+		return cm.Int64(&r.HistoryID).String(&r.ChangeSet).String(&r.Scope).Int64(&r.ScopeID).String(&r.Path).
+			NullString(&r.OldValue).NullString(&r.NewValue).String(&r.UserID).String(&r.UserName).
+			String(&r.RemoteAddr).Time(&r.CreatedAt).Err()
+	}
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "history_id":
+			cm.Int64(&r.HistoryID)
+		case "change_set":
+			cm.String(&r.ChangeSet)
+		case "scope":
+			cm.String(&r.Scope)
+		case "scope_id":
+			cm.Int64(&r.ScopeID)
+		case "path":
+			cm.String(&r.Path)
+		case "old_value":
+			cm.NullString(&r.OldValue)
+		case "new_value":
+			cm.NullString(&r.NewValue)
+		case "user_id":
+			cm.String(&r.UserID)
+		case "user_name":
+			cm.String(&r.UserName)
+		case "remote_addr":
+			cm.String(&r.RemoteAddr)
+		case "created_at":
+			cm.Time(&r.CreatedAt)
+		default:
+			return errors.NotFound.Newf("[audit] historyRow Column %q not found", c)
+		}
+	}
+	return cm.Err()
+}
+
+type historyRows []*historyRow
+
+// MapColumns implements interface dml.ColumnMapper. In the read modes all
+// rows get written as arguments, which creates a multi row INSERT.
+func (rs *historyRows) MapColumns(cm *dml.ColumnMap) error {
+	switch m := cm.Mode(); m {
+	case dml.ColumnMapEntityReadAll, dml.ColumnMapEntityReadSet:
+		for _, r := range *rs {
+			if err := r.MapColumns(cm); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case dml.ColumnMapScan:
+		if cm.Count == 0 {
+			*rs = (*rs)[:0]
+		}
+		r := new(historyRow)
+		if err := r.MapColumns(cm); err != nil {
+			return errors.WithStack(err)
+		}
+		*rs = append(*rs, r)
+	default:
+		return errors.NotSupported.Newf("[audit] Unknown Mode: %q", string(m))
+	}
+	return cm.Err()
+}
+
+// DBHistory stores the entries in a MySQL table. See TableNameHistory for the
+// table structure. DBHistory is safe for concurrent use.
+type DBHistory struct {
+	db        *dml.ConnPool
+	tableName string
+}
+
+// NewDBHistory creates a new History for the table. An empty tableName
+// defaults to TableNameHistory. Error behaviour: NotValid.
+func NewDBHistory(db *dml.ConnPool, tableName string) (*DBHistory, error) {
+	if tableName == "" {
+		tableName = TableNameHistory
+	}
+	if err := dml.IsValidIdentifier(tableName); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &DBHistory{db: db, tableName: tableName}, nil
+}
+
+// Append implements History interface and inserts all entries with one
+// statement. The IDs get assigned by the auto increment column.
+func (h *DBHistory) Append(ctx context.Context, entries ...Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	rows := make(historyRows, 0, len(entries))
+	for _, e := range entries {
+		r, err := newHistoryRow(e)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		rows = append(rows, r)
+	}
+	_, err := h.db.InsertInto(h.tableName).AddColumns(historyColumns...).
+		WithArgs().Record("", &rows).ExecContext(ctx)
+	return errors.Wrapf(err, "[audit] DBHistory.Append table %q", h.tableName)
+}
+
+// Query implements History interface.
+func (h *DBHistory) Query(ctx context.Context, q Query) ([]Entry, error) {
+	var conds []*dml.Condition
+	if !q.Path.Route.IsEmpty() {
+		route, err := q.Path.Level(-1)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[audit] DBHistory.Query Path %q", q.Path)
+		}
+		scp, id := q.Path.ScopeID.Unpack()
+		conds = append(conds,
+			dml.Column("scope").Str(scp.StrType()),
+			dml.Column("scope_id").Int64(id),
+			dml.Column("path").Str(route.String()),
+		)
+	}
+	if q.ChangeSet != "" {
+		conds = append(conds, dml.Column("change_set").Str(q.ChangeSet))
+	}
+	if !q.From.IsZero() {
+		conds = append(conds, dml.Column("created_at").GreaterOrEqual().Time(q.From))
+	}
+	if !q.To.IsZero() {
+		conds = append(conds, dml.Column("created_at").Less().Time(q.To))
+	}
+
+	var rows historyRows
+	sel := h.db.SelectFrom(h.tableName).AddColumns(append([]string{"history_id"}, historyColumns...)...).
+		OrderBy("created_at", "history_id")
+	if len(conds) > 0 {
+		sel.Where(conds...)
+	}
+	if _, err := sel.WithArgs().Load(ctx, &rows); err != nil {
+		return nil, errors.Wrapf(err, "[audit] DBHistory.Query table %q", h.tableName)
+	}
+
+	entries := make([]Entry, 0, len(rows))
+	for _, r := range rows {
+		e, err := r.entry()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/audit"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ audit.History = (*audit.DBHistory)(nil)
+
+const (
+	sqlHistoryInsert2 = "INSERT INTO `core_config_data_history` (`change_set`,`scope`,`scope_id`,`path`,`old_value`,`new_value`,`user_id`,`user_name`,`remote_addr`,`created_at`) VALUES (?,?,?,?,?,?,?,?,?,?),(?,?,?,?,?,?,?,?,?,?)"
+	sqlHistorySelect  = "SELECT `history_id`, `change_set`, `scope`, `scope_id`, `path`, `old_value`, `new_value`, `user_id`, `user_name`, `remote_addr`, `created_at` FROM `core_config_data_history` WHERE (`scope` = 'stores') AND (`scope_id` = 2) AND (`path` = 'web/cors/allowed_origins') AND (`change_set` = 'cs1') ORDER BY `created_at`, `history_id`"
+)
+
+func TestNewDBHistory(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	h, err := audit.NewDBHistory(dbc, "")
+	require.NoError(t, err)
+	assert.NotNil(t, h)
+
+	h, err = audit.NewDBHistory(dbc, "core_config_data_history; DROP")
+	assert.Nil(t, h)
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+}
+
+func TestDBHistory_Append(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	h, err := audit.NewDBHistory(dbc, "")
+	require.NoError(t, err)
+
+	now := time.Now()
+	actor := audit.Actor{UserID: "42", UserName: "admin", RemoteAddr: "127.0.0.1"}
+	p := cfgpath.MustNewByParts("web/cors/allowed_origins")
+
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(sqlHistoryInsert2)).WithArgs(
+		"cs1", "default", int64(0), "web/cors/allowed_origins", nil, "https://corestore.io", "42", "admin", "127.0.0.1", sqlmock.AnyArg(),
+		"cs1", "stores", int64(2), "web/cors/allowed_origins", "https://corestore.io", nil, "42", "admin", "127.0.0.1", sqlmock.AnyArg(),
+	).WillReturnResult(sqlmock.NewResult(1, 2))
+
+	require.NoError(t, h.Append(context.Background(),
+		audit.Entry{ChangeSet: "cs1", Path: p, New: dml.MakeNullString("https://corestore.io"), Actor: actor, Created: now},
+		audit.Entry{ChangeSet: "cs1", Path: p.BindStore(2), Old: dml.MakeNullString("https://corestore.io"), Actor: actor, Created: now},
+	))
+	// nothing to append, no query gets executed.
+	require.NoError(t, h.Append(context.Background()))
+}
+
+func TestDBHistory_Query(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	h, err := audit.NewDBHistory(dbc, "")
+	require.NoError(t, err)
+
+	created := time.Date(2017, 7, 12, 13, 14, 15, 0, time.UTC)
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlHistorySelect)).WillReturnRows(
+		sqlmock.NewRows([]string{"history_id", "change_set", "scope", "scope_id", "path", "old_value", "new_value", "user_id", "user_name", "remote_addr", "created_at"}).
+			AddRow(int64(3), "cs1", "stores", int64(2), "web/cors/allowed_origins", nil, "https://corestore.io", "42", "admin", "127.0.0.1", created).
+			AddRow(int64(7), "cs1", "stores", int64(2), "web/cors/allowed_origins", "https://corestore.io", "https://corestore.de", "42", "admin", "127.0.0.1", created.Add(time.Hour)),
+	)
+
+	p := cfgpath.MustNewByParts("web/cors/allowed_origins").BindStore(2)
+	entries, err := h.Query(context.Background(), audit.Query{Path: p, ChangeSet: "cs1"})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	assert.Exactly(t, int64(3), entries[0].ID)
+	assert.Exactly(t, p.String(), entries[0].Path.String())
+	assert.False(t, entries[0].Old.Valid)
+	assert.Exactly(t, "https://corestore.io", entries[0].New.String)
+	assert.Exactly(t, audit.Actor{UserID: "42", UserName: "admin", RemoteAddr: "127.0.0.1"}, entries[0].Actor)
+	assert.True(t, created.Equal(entries[0].Created))
+
+	assert.Exactly(t, int64(7), entries[1].ID)
+	assert.Exactly(t, "https://corestore.io", entries[1].Old.String)
+	assert.Exactly(t, "https://corestore.de", entries[1].New.String)
+}
@@ -0,0 +1,171 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+)
+
+// fileEntry defines the JSON representation of an Entry. The path gets stored
+// fully qualified.
+type fileEntry struct {
+	ID        int64     `json:"id"`
+	ChangeSet string    `json:"change_set,omitempty"`
+	Path      string    `json:"path"`
+	Old       *string   `json:"old"`
+	New       *string   `json:"new"`
+	Actor     Actor     `json:"actor"`
+	Created   time.Time `json:"created"`
+}
+
+func nullStringPtr(ns dml.NullString) *string {
+	if !ns.Valid {
+		return nil
+	}
+	s := ns.String
+	return &s
+}
+
+func ptrNullString(s *string) dml.NullString {
+	if s == nil {
+		return dml.NullString{}
+	}
+	return dml.MakeNullString(*s)
+}
+
+// FileHistory appends the entries as JSON lines to a file. Query reads the
+// whole file, so FileHistory suits small installations and development
+// environments. FileHistory is safe for concurrent use within one process.
+type FileHistory struct {
+	mu     sync.Mutex
+	f      *os.File
+	lastID int64
+}
+
+// NewFileHistory opens or creates the history file. Close must be called to
+// release the file.
+func NewFileHistory(name string) (*FileHistory, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.Fatal.New(err, "[audit] OpenFile %q", name)
+	}
+	fh := &FileHistory{f: f}
+	err = fh.each(func(fe fileEntry) error {
+		fh.lastID = fe.ID
+		return nil
+	})
+	if err != nil {
+		_ = f.Close()
+		return nil, errors.WithStack(err)
+	}
+	return fh, nil
+}
+
+// Close closes the file.
+func (fh *FileHistory) Close() error {
+	return errors.WithStack(fh.f.Close())
+}
+
+// each decodes all lines of the file. The caller must hold the lock or the
+// FileHistory must not yet be shared.
+func (fh *FileHistory) each(fn func(fileEntry) error) error {
+	if _, err := fh.f.Seek(0, 0); err != nil {
+		return errors.WithStack(err)
+	}
+	sc := bufio.NewScanner(fh.f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		var fe fileEntry
+		if err := json.Unmarshal(sc.Bytes(), &fe); err != nil {
+			return errors.BadEncoding.New(err, "[audit] Line %d in file %q", line, fh.f.Name())
+		}
+		if err := fn(fe); err != nil {
+			return err
+		}
+	}
+	return errors.WithStack(sc.Err())
+}
+
+// Append implements History interface. Each entry gets written as one line.
+func (fh *FileHistory) Append(_ context.Context, entries ...Entry) error {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+
+	buf := make([]byte, 0, 256*len(entries))
+	id := fh.lastID
+	for _, e := range entries {
+		id++
+		fq, err := e.Path.FQ()
+		if err != nil {
+			return errors.Wrapf(err, "[audit] FileHistory.Append Path %q", e.Path)
+		}
+		line, err := json.Marshal(fileEntry{
+			ID:        id,
+			ChangeSet: e.ChangeSet,
+			Path:      fq.String(),
+			Old:       nullStringPtr(e.Old),
+			New:       nullStringPtr(e.New),
+			Actor:     e.Actor,
+			Created:   e.Created,
+		})
+		if err != nil {
+			return errors.BadEncoding.New(err, "[audit] FileHistory.Append Path %q", e.Path)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if _, err := fh.f.Write(buf); err != nil {
+		return errors.WriteFailed.New(err, "[audit] FileHistory.Append")
+	}
+	fh.lastID = id
+	return nil
+}
+
+// Query implements History interface. The entries are already sorted because
+// the file gets only appended.
+func (fh *FileHistory) Query(_ context.Context, q Query) ([]Entry, error) {
+	fh.mu.Lock()
+	defer fh.mu.Unlock()
+
+	var entries []Entry
+	err := fh.each(func(fe fileEntry) error {
+		p, err := cfgpath.SplitFQ(fe.Path)
+		if err != nil {
+			return errors.Wrapf(err, "[audit] FileHistory.Query ID %d", fe.ID)
+		}
+		e := Entry{
+			ID:        fe.ID,
+			ChangeSet: fe.ChangeSet,
+			Path:      p,
+			Old:       ptrNullString(fe.Old),
+			New:       ptrNullString(fe.New),
+			Actor:     fe.Actor,
+			Created:   fe.Created,
+		}
+		if q.Matches(e) {
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, errors.WithStack(err)
+}
@@ -0,0 +1,39 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb
+
+import (
+	"context"
+
+	"github.com/corestoreio/pkg/config/storage/ccd"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+)
+
+// NewBinlogHandler creates a binlogsync.RowsChangeHandler which triggers a
+// synchronization of s, if the table core_config_data changes. PollSync must
+// be running to perform the synchronization. Argument tableName can be empty
+// and defaults to core_config_data.
+//
+//		go boltStorage.PollSync(ctx, 5*time.Minute, errFn)
+//		c.RegisterRowsEventHandler(boltdb.NewBinlogHandler(boltStorage, ""))
+func NewBinlogHandler(s *Storage, tableName string) *binlogsync.RowsChangeHandler {
+	if tableName == "" {
+		tableName = ccd.TableNameCoreConfigData
+	}
+	return binlogsync.NewRowsChangeHandler("boltdb.BinlogHandler", func(context.Context, *binlogsync.RowsChangeEvent) error {
+		s.TriggerSync()
+		return nil
+	}, binlogsync.RowsChangeFilter{Table: tableName})
+}
@@ -15,6 +15,13 @@
 // Package boltdb uses the bolt database for reading and writing
 // configuration paths.
 //
-// Maybe implements synchronization with MySQL core_config_data table.
-// Converts all values to byte slices.
+// The Storage type implements config.Storager and gives fast local reads, for
+// example on edge nodes without a permanent database connection. Converts all
+// values to byte slices.
+//
+// With the option WithSync the Storage synchronizes in both directions with the
+// MySQL core_config_data table. The synchronization runs via Sync, on a
+// schedule via PollSync or gets triggered by binlog change events via
+// NewBinlogHandler. Paths changed on both sides get resolved by a
+// ConflictRule.
 package boltdb
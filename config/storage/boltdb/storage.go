@@ -0,0 +1,299 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// DefaultBucketName name of the bucket which contains the configuration
+// values. Two further buckets with the suffixes ".base" and ".meta" get
+// created for the synchronization with core_config_data.
+const DefaultBucketName = "config"
+
+// Option applies options to the Storage.
+type Option func(*Storage) error
+
+// WithBucketName sets a custom bucket name. Useful if several configurations
+// share the same bolt database file.
+func WithBucketName(name string) Option {
+	return func(s *Storage) error {
+		if name == "" {
+			return errors.Empty.Newf("[boltdb] Bucket name cannot be empty")
+		}
+		s.bucket = []byte(name)
+		s.bucketBase = []byte(name + ".base")
+		s.bucketMeta = []byte(name + ".meta")
+		return nil
+	}
+}
+
+// WithLogger sets a custom logger. Default logger is a black hole.
+func WithLogger(l log.Logger) Option {
+	return func(s *Storage) error {
+		s.log = l
+		return nil
+	}
+}
+
+// Storage stores the configuration values in a bolt database. Implements
+// interface config.Storager. The key of an entry is the fully qualified path
+// and the value gets converted to a byte slice. Each entry contains its
+// modification time, which the synchronization with core_config_data requires.
+// Setting a nil value deletes the entry. Storage is safe for concurrent use.
+type Storage struct {
+	log        log.Logger
+	db         *bolt.DB
+	bucket     []byte
+	bucketBase []byte
+	bucketMeta []byte
+
+	// sync* fields get set via option WithSync.
+	syncDB    *dml.ConnPool
+	syncTable string
+	syncRule  ConflictRule
+	// syncMu serializes Sync calls.
+	syncMu      sync.Mutex
+	syncTrigger chan struct{}
+}
+
+// New creates a new Storage for an already opened bolt database and creates
+// the buckets, if they don't exist.
+func New(db *bolt.DB, opts ...Option) (*Storage, error) {
+	s := &Storage{
+		log:         log.BlackHole{}, // skip debug and info level via init with empty fields
+		db:          db,
+		syncTrigger: make(chan struct{}, 1),
+	}
+	if err := WithBucketName(DefaultBucketName)(s); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	err := db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [...][]byte{s.bucket, s.bucketBase, s.bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return errors.Fatal.New(err, "[boltdb] CreateBucketIfNotExists %q", b)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return s, nil
+}
+
+// NewFile opens or creates the bolt database file at path and creates a new
+// Storage. Close must be called to release the file lock.
+func NewFile(path string, mode os.FileMode, opts ...Option) (*Storage, error) {
+	db, err := bolt.Open(path, mode, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Fatal.New(err, "[boltdb] bolt.Open %q", path)
+	}
+	s, err := New(db, opts...)
+	if err != nil {
+		_ = db.Close()
+		return nil, errors.WithStack(err)
+	}
+	return s, nil
+}
+
+// MustNewFile same as NewFile but panics on error.
+func MustNewFile(path string, mode os.FileMode, opts ...Option) *Storage {
+	s, err := NewFile(path, mode, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Close closes the underlying bolt database.
+func (s *Storage) Close() error {
+	return errors.WithStack(s.db.Close())
+}
+
+// recordHeaderLen defines the length of the modification time and the flags
+// which prefix each value.
+const recordHeaderLen = 9
+
+const flagDeleted byte = 1
+
+// record represents a value in the bolt database. A deleted record gets kept
+// as a tombstone until the next synchronization with core_config_data.
+type record struct {
+	modified int64 // Unix nano seconds
+	deleted  bool
+	value    []byte
+}
+
+func (r record) encode() []byte {
+	buf := make([]byte, recordHeaderLen+len(r.value))
+	binary.BigEndian.PutUint64(buf, uint64(r.modified))
+	if r.deleted {
+		buf[8] = flagDeleted
+	}
+	copy(buf[recordHeaderLen:], r.value)
+	return buf
+}
+
+// decodeRecord copies the value because the memory returned by bolt is only
+// valid during a transaction.
+func decodeRecord(raw []byte) (r record, err error) {
+	if len(raw) < recordHeaderLen {
+		return r, errors.NotValid.Newf("[boltdb] Record too short: %d bytes", len(raw))
+	}
+	r.modified = int64(binary.BigEndian.Uint64(raw))
+	r.deleted = raw[8]&flagDeleted != 0
+	r.value = append([]byte(nil), raw[recordHeaderLen:]...)
+	return r, nil
+}
+
+// Set implements config.Storager interface. A nil value deletes the path.
+func (s *Storage) Set(key cfgpath.Path, value interface{}) error {
+	fq, err := key.FQ()
+	if err != nil {
+		return errors.Wrapf(err, "[boltdb] Set.FQ Path %q", key)
+	}
+	rec := record{modified: time.Now().UnixNano(), deleted: value == nil}
+	if !rec.deleted {
+		if rec.value, err = conv.ToByteE(value); err != nil {
+			return errors.Wrapf(err, "[boltdb] Set.ToByteE Path %q", key)
+		}
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Put(fq, rec.encode())
+	})
+	return errors.Wrapf(err, "[boltdb] Set.Put Path %q", fq)
+}
+
+// Get implements config.Storager interface and returns a byte slice. Error
+// behaviour: NotFound.
+func (s *Storage) Get(key cfgpath.Path) (interface{}, error) {
+	fq, err := key.FQ()
+	if err != nil {
+		return nil, errors.Wrapf(err, "[boltdb] Get.FQ Path %q", key)
+	}
+	var rec record
+	var found bool
+	err = s.db.View(func(tx *bolt.Tx) (err error) {
+		raw := tx.Bucket(s.bucket).Get(fq)
+		if found = raw != nil; found {
+			rec, err = decodeRecord(raw)
+		}
+		return err
+	})
+	switch {
+	case err != nil:
+		return nil, errors.Wrapf(err, "[boltdb] Get Path %q", fq)
+	case !found || rec.deleted:
+		return nil, errors.NotFound.Newf("[boltdb] Path %q not found", fq)
+	}
+	return rec.value, nil
+}
+
+// AllKeys implements config.Storager interface and returns the paths of all
+// non-deleted values. Invalid keys get logged and skipped.
+func (s *Storage) AllKeys() (cfgpath.PathSlice, error) {
+	var ps cfgpath.PathSlice
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).ForEach(func(k, v []byte) error {
+			if len(v) > 8 && v[8]&flagDeleted != 0 {
+				return nil
+			}
+			p, err := cfgpath.SplitFQ(string(k))
+			if err != nil {
+				if s.log.IsInfo() {
+					s.log.Info("boltdb.Storage.AllKeys.SplitFQ", log.Err(err), log.String("key", string(k)))
+				}
+				return nil
+			}
+			ps = append(ps, p)
+			return nil
+		})
+	})
+	return ps, errors.Wrap(err, "[boltdb] AllKeys")
+}
+
+// queryPrefixes returns the key prefixes to scan. Because the keys start with
+// the scope, the literal route prefix can only be appended if the scope ID is
+// known.
+func queryPrefixes(q config.Query) [][]byte {
+	lp := q.LiteralPrefix()
+	var ps [][]byte
+	if len(q.ScopeIDs) > 0 {
+		for _, id := range q.ScopeIDs {
+			if q.MatchScope(id) {
+				scp, sID := id.Unpack()
+				ps = append(ps, []byte(scp.StrType()+"/"+strconv.FormatInt(sID, 10)+"/"+lp))
+			}
+		}
+		return ps
+	}
+	for _, st := range [...]scope.Type{scope.Default, scope.Website, scope.Store} {
+		switch {
+		case q.Scopes > 0 && !q.Scopes.Has(st):
+		case st == scope.Default:
+			ps = append(ps, []byte(st.StrType()+"/0/"+lp))
+		default:
+			ps = append(ps, []byte(st.StrType()+"/"))
+		}
+	}
+	return ps
+}
+
+// Query implements interface config.Querier with prefix scans over the keys.
+// Values are byte slices. Deleted values and invalid keys get skipped.
+func (s *Storage) Query(q config.Query) (config.KeyValues, error) {
+	var kvs config.KeyValues
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(s.bucket).Cursor()
+		for _, prefix := range queryPrefixes(q) {
+			for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+				if len(v) > 8 && v[8]&flagDeleted != 0 {
+					continue
+				}
+				p, err := cfgpath.SplitFQ(string(k))
+				if err != nil || !q.Match(p) {
+					continue
+				}
+				rec, err := decodeRecord(v)
+				if err != nil {
+					return errors.Wrapf(err, "[boltdb] Query Key %q", k)
+				}
+				kvs = append(kvs, config.KeyValue{Key: p, Value: rec.value})
+			}
+		}
+		return nil
+	})
+	return kvs, errors.Wrap(err, "[boltdb] Query")
+}
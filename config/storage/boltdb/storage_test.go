@@ -0,0 +1,163 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/boltdb"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*boltdb.Storage)(nil)
+var _ config.Querier = (*boltdb.Storage)(nil)
+
+func getTempFile(t *testing.T) string {
+	f, err := ioutil.TempFile("", "cfgboltdb_")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	return f.Name()
+}
+
+func TestStorage_SetGet(t *testing.T) {
+	t.Parallel()
+
+	fn := getTempFile(t)
+	defer os.Remove(fn)
+
+	s := boltdb.MustNewFile(fn, 0600)
+
+	tests := []struct {
+		key       cfgpath.Path
+		value     interface{}
+		wantValue []byte
+	}{
+		{cfgpath.MustNewByParts("web/secure/base_url").BindStore(1), "http://corestore.io", []byte("http://corestore.io")},
+		{cfgpath.MustNewByParts("dev/log/active").BindWebsite(2), 1, []byte("1")},
+		{cfgpath.MustNewByParts("catalog/price/scope"), []byte("website"), []byte("website")},
+	}
+	for i, test := range tests {
+		require.NoError(t, s.Set(test.key, test.value), "Index %d", i)
+	}
+	for i, test := range tests {
+		v, err := s.Get(test.key)
+		require.NoError(t, err, "Index %d", i)
+		assert.Exactly(t, test.wantValue, v, "Index %d", i)
+	}
+
+	keys, err := s.AllKeys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 3)
+
+	t.Run("not found", func(t *testing.T) {
+		v, err := s.Get(cfgpath.MustNewByParts("web/secure/base_url").BindStore(2))
+		assert.Nil(t, v)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+
+	t.Run("nil deletes", func(t *testing.T) {
+		require.NoError(t, s.Set(tests[1].key, nil))
+		v, err := s.Get(tests[1].key)
+		assert.Nil(t, v)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+
+		keys, err := s.AllKeys()
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+	})
+
+	t.Run("persists after reopening", func(t *testing.T) {
+		require.NoError(t, s.Close())
+		s = boltdb.MustNewFile(fn, 0600)
+		v, err := s.Get(tests[0].key)
+		require.NoError(t, err)
+		assert.Exactly(t, tests[0].wantValue, v)
+	})
+
+	t.Run("separate buckets", func(t *testing.T) {
+		require.NoError(t, s.Close())
+		s = boltdb.MustNewFile(fn, 0600, boltdb.WithBucketName("config_edge"))
+		v, err := s.Get(tests[0].key)
+		assert.Nil(t, v)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+
+	require.NoError(t, s.Close())
+}
+
+func TestStorage_Query(t *testing.T) {
+	t.Parallel()
+
+	fn := getTempFile(t)
+	defer os.Remove(fn)
+	s := boltdb.MustNewFile(fn, 0600)
+	defer s.Close()
+
+	p := cfgpath.MustNewByParts("web/unsecure/base_url")
+	require.NoError(t, s.Set(p, "http://corestore.io/"))
+	require.NoError(t, s.Set(p.BindStore(3), "http://ch.corestore.io/"))
+	require.NoError(t, s.Set(cfgpath.MustNewByParts("web/secure/base_url").BindStore(2), "https://at.corestore.io/"))
+	require.NoError(t, s.Set(cfgpath.MustNewByParts("web/cookie/cookie_path").BindStore(2), "/"))
+	require.NoError(t, s.Set(p.BindStore(4), "deleted"))
+	require.NoError(t, s.Set(p.BindStore(4), nil))
+	require.NoError(t, s.Set(cfgpath.MustNewByParts("payment/checkmo/active").BindWebsite(1), 1))
+
+	tests := []struct {
+		q        config.Query
+		wantKeys []string
+	}{
+		{config.Query{Pattern: "web/*/base_url", Scopes: scope.PermStoreReverse}, []string{"stores/2/web/secure/base_url", "stores/3/web/unsecure/base_url"}},
+		{config.Query{Pattern: "web/*/base_url"}, []string{"default/0/web/unsecure/base_url", "stores/2/web/secure/base_url", "stores/3/web/unsecure/base_url"}},
+		{config.Query{Prefix: "web/", ScopeIDs: scope.TypeIDs{scope.Store.Pack(2)}}, []string{"stores/2/web/cookie/cookie_path", "stores/2/web/secure/base_url"}},
+		{config.Query{Prefix: "payment/"}, []string{"websites/1/payment/checkmo/active"}},
+		{config.Query{Prefix: "payment/", Scopes: scope.PermDefault}, nil},
+	}
+	for i, test := range tests {
+		kvs, err := config.QueryStorage(s, test.q)
+		require.NoError(t, err, "Index %d", i)
+		var haveKeys []string
+		for _, kv := range kvs {
+			haveKeys = append(haveKeys, kv.Key.String())
+		}
+		assert.Exactly(t, test.wantKeys, haveKeys, "Index %d", i)
+	}
+
+	kvs, err := config.QueryStorage(s, config.Query{Pattern: "web/secure/base_url"})
+	require.NoError(t, err)
+	require.Len(t, kvs, 1)
+	assert.Exactly(t, []byte("https://at.corestore.io/"), kvs[0].Value)
+}
+
+func TestNewFile_Error(t *testing.T) {
+	t.Parallel()
+
+	s, err := boltdb.NewFile(filepath.Join("non", "existent"), 0400)
+	assert.Nil(t, s)
+	assert.True(t, errors.Fatal.Match(err), "%+v", err)
+
+	fn := getTempFile(t)
+	defer os.Remove(fn)
+	s, err = boltdb.NewFile(fn, 0600, boltdb.WithBucketName(""))
+	assert.Nil(t, s)
+	assert.True(t, errors.Empty.Match(err), "%+v", err)
+}
@@ -0,0 +1,412 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sort"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/ccd"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// ConflictRule defines which value wins, if a path has been changed locally and
+// in the table core_config_data since the last synchronization.
+type ConflictRule uint8
+
+// List of available conflict rules.
+const (
+	// DatabaseWins overwrites the local value with the value from
+	// core_config_data.
+	DatabaseWins ConflictRule = iota
+	// LocalWins writes the local value into core_config_data.
+	LocalWins
+)
+
+// keyLastSync gets stored in the meta bucket.
+var keyLastSync = []byte("last_sync")
+
+// WithSync enables the two-way synchronization with the table
+// core_config_data. Argument tableName can be empty and defaults to
+// core_config_data. Sync must be called to synchronize, either manually or via
+// PollSync.
+func WithSync(db *dml.ConnPool, tableName string, rule ConflictRule) Option {
+	return func(s *Storage) error {
+		if tableName == "" {
+			tableName = ccd.TableNameCoreConfigData
+		}
+		if err := dml.IsValidIdentifier(tableName); err != nil {
+			return errors.WithStack(err)
+		}
+		s.syncDB = db
+		s.syncTable = tableName
+		s.syncRule = rule
+		return nil
+	}
+}
+
+// SyncStats contains the number of changed paths of one synchronization.
+type SyncStats struct {
+	// Pulled number of paths written from core_config_data into bolt.
+	Pulled int
+	// Pushed number of paths written from bolt into core_config_data.
+	Pushed int
+	// Conflicts number of paths which have been changed on both sides. They
+	// got resolved via the ConflictRule.
+	Conflicts int
+}
+
+// syncState represents the value of a path on one side. Present is false if
+// the path does not exist, has been deleted or its value is NULL.
+type syncState struct {
+	present bool
+	value   []byte
+}
+
+func (s syncState) equal(o syncState) bool {
+	return s.present == o.present && (!s.present || bytes.Equal(s.value, o.value))
+}
+
+// syncPlan contains the changes determined by comparing the local records,
+// the base values of the last synchronization and the rows of
+// core_config_data.
+type syncPlan struct {
+	stats SyncStats
+	// pull contains the new local states.
+	pull map[string]syncState
+	// push contains the paths and their new states for core_config_data.
+	push map[string]syncState
+	// base contains the agreed state of each path after the synchronization.
+	base map[string]syncState
+}
+
+// LastSync returns the start time of the last successful synchronization. A
+// zero time means that no synchronization has happened yet.
+func (s *Storage) LastSync() (t time.Time, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(s.bucketMeta).Get(keyLastSync); len(v) == 8 {
+			t = time.Unix(0, int64(binary.BigEndian.Uint64(v)))
+		}
+		return nil
+	})
+	return t, errors.Wrap(err, "[boltdb] LastSync")
+}
+
+// TriggerSync requests a synchronization from the PollSync loop without
+// waiting for the next interval. It never blocks.
+func (s *Storage) TriggerSync() {
+	select {
+	case s.syncTrigger <- struct{}{}:
+	default: // a synchronization has already been requested
+	}
+}
+
+// PollSync synchronizes every interval or after TriggerSync has been called.
+// PollSync blocks until the context gets cancelled and should run in its own
+// goroutine. Errors do not stop the polling and get passed to the optional
+// errFn.
+func (s *Storage) PollSync(ctx context.Context, interval time.Duration, errFn func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.syncTrigger:
+		}
+		if _, err := s.Sync(ctx); err != nil && errFn != nil {
+			errFn(err)
+		}
+	}
+}
+
+// Sync synchronizes the local values with the table core_config_data in both
+// directions. A path counts as changed locally, if it has been modified after
+// the last synchronization. A path counts as changed in the database, if its
+// value differs from the value agreed on during the last synchronization.
+// Paths changed on both sides get resolved via the ConflictRule. The database
+// gets written within one transaction. Local values set while Sync runs are
+// not overwritten and get pushed with the next synchronization.
+func (s *Storage) Sync(ctx context.Context) (SyncStats, error) {
+	if s.syncDB == nil {
+		return SyncStats{}, errors.NotSupported.Newf("[boltdb] Sync requires the option WithSync")
+	}
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	start := time.Now().UnixNano()
+
+	remote, err := s.loadRemote(ctx)
+	if err != nil {
+		return SyncStats{}, errors.WithStack(err)
+	}
+
+	var plan syncPlan
+	if err := s.db.View(func(tx *bolt.Tx) (err error) {
+		plan, err = s.newSyncPlan(tx, remote)
+		return err
+	}); err != nil {
+		return SyncStats{}, errors.Wrap(err, "[boltdb] Sync.Plan")
+	}
+
+	if err := s.pushRemote(ctx, plan.push); err != nil {
+		return SyncStats{}, errors.WithStack(err)
+	}
+
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return s.applySyncPlan(tx, plan, start)
+	}); err != nil {
+		return SyncStats{}, errors.Wrap(err, "[boltdb] Sync.Apply")
+	}
+
+	if s.log.IsDebug() {
+		s.log.Debug("boltdb.Storage.Sync", log.Int("pulled", plan.stats.Pulled), log.Int("pushed", plan.stats.Pushed), log.Int("conflicts", plan.stats.Conflicts))
+	}
+	return plan.stats, nil
+}
+
+// loadRemote loads all rows of core_config_data. The map key is the fully
+// qualified path. Invalid rows get logged and skipped.
+func (s *Storage) loadRemote(ctx context.Context) (map[string]syncState, error) {
+	var rows ccd.TableCoreConfigDataSlice
+	if _, err := s.syncDB.SelectFrom(s.syncTable).
+		AddColumns("scope", "scope_id", "path", "value").
+		WithArgs().Load(ctx, &rows); err != nil {
+		return nil, errors.Wrapf(err, "[boltdb] Sync.Load table %q", s.syncTable)
+	}
+
+	remote := make(map[string]syncState, len(rows))
+	for _, r := range rows {
+		p, err := cfgpath.NewByParts(r.Path)
+		if err == nil {
+			var fq cfgpath.Route
+			if fq, err = p.Bind(scope.FromString(r.Scope).Pack(r.ScopeID)).FQ(); err == nil {
+				remote[fq.String()] = syncState{present: r.Value.Valid, value: []byte(r.Value.String)}
+				continue
+			}
+		}
+		if s.log.IsInfo() {
+			s.log.Info("boltdb.Storage.Sync.InvalidRow", log.Err(err), log.String("scope", r.Scope), log.Int64("scope_id", r.ScopeID), log.String("path", r.Path))
+		}
+	}
+	return remote, nil
+}
+
+func (s *Storage) newSyncPlan(tx *bolt.Tx, remote map[string]syncState) (syncPlan, error) {
+	plan := syncPlan{
+		pull: make(map[string]syncState),
+		push: make(map[string]syncState),
+		base: make(map[string]syncState),
+	}
+
+	var lastSync int64
+	if v := tx.Bucket(s.bucketMeta).Get(keyLastSync); len(v) == 8 {
+		lastSync = int64(binary.BigEndian.Uint64(v))
+	}
+
+	local := make(map[string]record)
+	if err := tx.Bucket(s.bucket).ForEach(func(k, v []byte) error {
+		rec, err := decodeRecord(v)
+		local[string(k)] = rec
+		return errors.Wrapf(err, "[boltdb] Key %q", k)
+	}); err != nil {
+		return plan, errors.WithStack(err)
+	}
+
+	keys := make([]string, 0, len(local)+len(remote))
+	for k := range local {
+		keys = append(keys, k)
+	}
+	for k := range remote {
+		if _, ok := local[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+
+	bb := tx.Bucket(s.bucketBase)
+	for _, k := range keys {
+		rec, isLocal := local[k]
+		localState := syncState{present: isLocal && !rec.deleted, value: rec.value}
+		remoteState := remote[k]
+		var baseState syncState
+		if v := bb.Get([]byte(k)); v != nil {
+			baseState = syncState{present: true, value: append([]byte(nil), v...)}
+		}
+
+		localChanged := isLocal && rec.modified > lastSync
+		remoteChanged := !remoteState.equal(baseState)
+
+		var agreed syncState
+		switch {
+		case localState.equal(remoteState):
+			agreed = remoteState
+			if isLocal && rec.deleted {
+				plan.pull[k] = remoteState // removes the tombstone
+			}
+		case !localChanged:
+			agreed = remoteState
+			plan.pull[k] = remoteState
+			plan.stats.Pulled++
+		case !remoteChanged:
+			agreed = localState
+			plan.push[k] = localState
+			plan.stats.Pushed++
+		case s.syncRule == LocalWins:
+			agreed = localState
+			plan.push[k] = localState
+			plan.stats.Pushed++
+			plan.stats.Conflicts++
+		default:
+			agreed = remoteState
+			plan.pull[k] = remoteState
+			plan.stats.Pulled++
+			plan.stats.Conflicts++
+		}
+		plan.base[k] = agreed
+	}
+	return plan, nil
+}
+
+// pushRemote writes the pushed paths within one transaction into
+// core_config_data. Present values get upserted, all others deleted.
+func (s *Storage) pushRemote(ctx context.Context, push map[string]syncState) error {
+	if len(push) == 0 {
+		return nil
+	}
+	var upserts, deletes ccd.TableCoreConfigDataSlice
+	for fq, st := range push {
+		p, err := cfgpath.SplitFQ(fq)
+		if err != nil {
+			return errors.Wrapf(err, "[boltdb] Sync.Push.SplitFQ %q", fq)
+		}
+		route, err := p.Level(-1)
+		if err != nil {
+			return errors.Wrapf(err, "[boltdb] Sync.Push.Level %q", fq)
+		}
+		scp, id := p.ScopeID.Unpack()
+		row := &ccd.TableCoreConfigData{Scope: scp.StrType(), ScopeID: id, Path: route.String()}
+		if st.present {
+			row.Value = dml.MakeNullString(string(st.value))
+			upserts = append(upserts, row)
+		} else {
+			deletes = append(deletes, row)
+		}
+	}
+	// sorted by fully qualified path for a deterministic order of the arguments.
+	for _, rows := range [...]ccd.TableCoreConfigDataSlice{upserts, deletes} {
+		sort.Slice(rows, func(i, j int) bool {
+			a, b := rows[i], rows[j]
+			if a.Scope != b.Scope {
+				return a.Scope < b.Scope
+			}
+			if a.ScopeID != b.ScopeID {
+				return a.ScopeID < b.ScopeID
+			}
+			return a.Path < b.Path
+		})
+	}
+
+	err := s.syncDB.Transaction(ctx, nil, func(tx *dml.Tx) error {
+		if len(upserts) > 0 {
+			if _, err := s.syncDB.InsertInto(s.syncTable).
+				AddColumns("scope", "scope_id", "path", "value").
+				AddOnDuplicateKeyExclude("scope", "scope_id", "path").
+				WithDB(tx.DB).WithArgs().Record("", &upserts).ExecContext(ctx); err != nil {
+				return errors.Wrap(err, "[boltdb] Sync.Push.Upsert")
+			}
+		}
+		if len(deletes) == 0 {
+			return nil
+		}
+		stmt, err := s.syncDB.DeleteFrom(s.syncTable).Where(
+			dml.Column("scope").PlaceHolder(),
+			dml.Column("scope_id").PlaceHolder(),
+			dml.Column("path").PlaceHolder(),
+		).WithDB(tx.DB).Prepare(ctx)
+		if err != nil {
+			return errors.Wrap(err, "[boltdb] Sync.Push.Delete.Prepare")
+		}
+		defer stmt.Close()
+		for _, row := range deletes {
+			if _, err := stmt.WithArgs().ExecContext(ctx, row.Scope, row.ScopeID, row.Path); err != nil {
+				return errors.Wrapf(err, "[boltdb] Sync.Push.Delete %s/%d/%s", row.Scope, row.ScopeID, row.Path)
+			}
+		}
+		return nil
+	})
+	return errors.Wrapf(err, "[boltdb] Sync.Push table %q", s.syncTable)
+}
+
+// applySyncPlan writes the pulled values and base values into bolt and sets
+// the last synchronization time to start. Local records modified after start
+// stay untouched.
+func (s *Storage) applySyncPlan(tx *bolt.Tx, plan syncPlan, start int64) error {
+	b := tx.Bucket(s.bucket)
+	for k, st := range plan.pull {
+		if raw := b.Get([]byte(k)); raw != nil {
+			if rec, err := decodeRecord(raw); err == nil && rec.modified > start {
+				continue // changed while syncing
+			}
+		}
+		var err error
+		if st.present {
+			err = b.Put([]byte(k), record{modified: start, value: st.value}.encode())
+		} else {
+			err = b.Delete([]byte(k))
+		}
+		if err != nil {
+			return errors.Wrapf(err, "[boltdb] Pull Key %q", k)
+		}
+	}
+	// Tombstones are not needed anymore after their deletion has been pushed.
+	for k, st := range plan.push {
+		if st.present {
+			continue
+		}
+		if raw := b.Get([]byte(k)); raw != nil {
+			if rec, err := decodeRecord(raw); err == nil && rec.deleted && rec.modified <= start {
+				if err := b.Delete([]byte(k)); err != nil {
+					return errors.Wrapf(err, "[boltdb] Delete tombstone %q", k)
+				}
+			}
+		}
+	}
+
+	bb := tx.Bucket(s.bucketBase)
+	for k, st := range plan.base {
+		var err error
+		if st.present {
+			err = bb.Put([]byte(k), st.value)
+		} else {
+			err = bb.Delete([]byte(k))
+		}
+		if err != nil {
+			return errors.Wrapf(err, "[boltdb] Base Key %q", k)
+		}
+	}
+
+	var ls [8]byte
+	binary.BigEndian.PutUint64(ls[:], uint64(start))
+	return errors.Wrap(tx.Bucket(s.bucketMeta).Put(keyLastSync, ls[:]), "[boltdb] Put last sync")
+}
@@ -0,0 +1,191 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package boltdb_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/boltdb"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	sqlSyncSelect = "SELECT `scope`, `scope_id`, `path`, `value` FROM `core_config_data`"
+	sqlSyncUpsert = "INSERT INTO `core_config_data` (`scope`,`scope_id`,`path`,`value`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE `value`=VALUES(`value`)"
+	sqlSyncDelete = "DELETE FROM `core_config_data` WHERE (`scope` = ?) AND (`scope_id` = ?) AND (`path` = ?)"
+)
+
+var (
+	pathBaseURL = cfgpath.MustNewByParts("web/unsecure/base_url").BindStore(1)
+	pathDHL     = cfgpath.MustNewByParts("carriers/dhl/active")
+	pathLocale  = cfgpath.MustNewByParts("general/locale/code")
+)
+
+func newSyncRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"scope", "scope_id", "path", "value"})
+}
+
+func TestStorage_Sync(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	fn := getTempFile(t)
+	defer os.Remove(fn)
+	s := boltdb.MustNewFile(fn, 0600, boltdb.WithSync(dbc, "", boltdb.DatabaseWins))
+	defer func() { assert.NoError(t, s.Close()) }()
+	ctx := context.Background()
+
+	ls, err := s.LastSync()
+	require.NoError(t, err)
+	assert.True(t, ls.IsZero())
+
+	t.Run("initial conflict, pull and push", func(t *testing.T) {
+		require.NoError(t, s.Set(pathBaseURL, "http://local"))
+		require.NoError(t, s.Set(pathDHL, 1))
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlSyncSelect)).WillReturnRows(newSyncRows().
+			AddRow("default", 0, "general/locale/code", "de_DE").
+			AddRow("stores", 1, "web/unsecure/base_url", "http://remote").
+			AddRow("default", 0, "web/cookie/path", nil))
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(sqlSyncUpsert)).
+			WithArgs("default", int64(0), "carriers/dhl/active", "1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectCommit()
+
+		stats, err := s.Sync(ctx)
+		require.NoError(t, err)
+		assert.Exactly(t, boltdb.SyncStats{Pulled: 2, Pushed: 1, Conflicts: 1}, stats)
+
+		v, err := s.Get(pathBaseURL)
+		require.NoError(t, err)
+		assert.Exactly(t, []byte("http://remote"), v)
+		v, err = s.Get(pathLocale)
+		require.NoError(t, err)
+		assert.Exactly(t, []byte("de_DE"), v)
+
+		ls, err := s.LastSync()
+		require.NoError(t, err)
+		assert.False(t, ls.IsZero())
+	})
+
+	t.Run("local delete and remote update", func(t *testing.T) {
+		require.NoError(t, s.Set(pathBaseURL, nil))
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlSyncSelect)).WillReturnRows(newSyncRows().
+			AddRow("default", 0, "carriers/dhl/active", "1").
+			AddRow("default", 0, "general/locale/code", "en_US").
+			AddRow("stores", 1, "web/unsecure/base_url", "http://remote"))
+		dbMock.ExpectBegin()
+		prep := dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlSyncDelete))
+		prep.ExpectExec().WithArgs("stores", int64(1), "web/unsecure/base_url").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		prep.WillBeClosed()
+		dbMock.ExpectCommit()
+
+		stats, err := s.Sync(ctx)
+		require.NoError(t, err)
+		assert.Exactly(t, boltdb.SyncStats{Pulled: 1, Pushed: 1}, stats)
+
+		v, err := s.Get(pathLocale)
+		require.NoError(t, err)
+		assert.Exactly(t, []byte("en_US"), v)
+	})
+
+	t.Run("remote delete", func(t *testing.T) {
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlSyncSelect)).WillReturnRows(newSyncRows().
+			AddRow("default", 0, "general/locale/code", "en_US"))
+
+		stats, err := s.Sync(ctx)
+		require.NoError(t, err)
+		assert.Exactly(t, boltdb.SyncStats{Pulled: 1}, stats)
+
+		_, err = s.Get(pathDHL)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+		keys, err := s.AllKeys()
+		require.NoError(t, err)
+		assert.Len(t, keys, 1)
+	})
+
+	t.Run("database error keeps local data", func(t *testing.T) {
+		require.NoError(t, s.Set(pathDHL, 0))
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlSyncSelect)).WillReturnRows(newSyncRows().
+			AddRow("default", 0, "general/locale/code", "en_US"))
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(sqlSyncUpsert)).
+			WithArgs("default", int64(0), "carriers/dhl/active", "0").
+			WillReturnError(errors.AlreadyClosed.Newf("DB closed"))
+		dbMock.ExpectRollback()
+
+		_, err := s.Sync(ctx)
+		assert.True(t, errors.AlreadyClosed.Match(err), "%+v", err)
+
+		v, err := s.Get(pathDHL)
+		require.NoError(t, err)
+		assert.Exactly(t, []byte("0"), v)
+	})
+}
+
+func TestStorage_Sync_LocalWins(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	fn := getTempFile(t)
+	defer os.Remove(fn)
+	s := boltdb.MustNewFile(fn, 0600, boltdb.WithSync(dbc, "", boltdb.LocalWins))
+	defer func() { assert.NoError(t, s.Close()) }()
+
+	require.NoError(t, s.Set(pathBaseURL, "http://local"))
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlSyncSelect)).WillReturnRows(newSyncRows().
+		AddRow("stores", 1, "web/unsecure/base_url", "http://remote"))
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(sqlSyncUpsert)).
+		WithArgs("stores", int64(1), "web/unsecure/base_url", "http://local").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	dbMock.ExpectCommit()
+
+	stats, err := s.Sync(context.Background())
+	require.NoError(t, err)
+	assert.Exactly(t, boltdb.SyncStats{Pushed: 1, Conflicts: 1}, stats)
+
+	v, err := s.Get(pathBaseURL)
+	require.NoError(t, err)
+	assert.Exactly(t, []byte("http://local"), v)
+}
+
+func TestStorage_Sync_NotEnabled(t *testing.T) {
+	t.Parallel()
+
+	fn := getTempFile(t)
+	defer os.Remove(fn)
+	s := boltdb.MustNewFile(fn, 0600)
+	defer func() { assert.NoError(t, s.Close()) }()
+
+	_, err := s.Sync(context.Background())
+	assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+}
@@ -0,0 +1,108 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ccd
+
+import (
+	"context"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+)
+
+// TableNameCoreConfigData default name of the Magento configuration table.
+const TableNameCoreConfigData = "core_config_data"
+
+// NewBinlogHandler creates a binlogsync.RowsEventHandler which listens to
+// changes of the table core_config_data and writes the new values into w,
+// mostly the *config.Service. Writing into the config.Service triggers the
+// pub/sub MessageReceiver subscriptions, so scoped services can reconfigure
+// themselves without a restart. A deleted row writes a nil value to its path.
+// If the path, scope or scope ID of a row changes, the old path gets a nil
+// value. Argument tableName can be empty and defaults to core_config_data, set
+// it if a table prefix is in use.
+//
+//		cfgSrv := config.MustNewService(config.NewInMemoryStore(), config.WithPubSub())
+//		c.RegisterRowsEventHandler(ccd.NewBinlogHandler(cfgSrv, ""))
+func NewBinlogHandler(w config.Writer, tableName string) *binlogsync.RowsChangeHandler {
+	if tableName == "" {
+		tableName = TableNameCoreConfigData
+	}
+	return binlogsync.NewRowsChangeHandler("ccd.BinlogHandler", func(_ context.Context, ev *binlogsync.RowsChangeEvent) error {
+		for _, rc := range ev.Changes {
+			if err := writeRowChange(w, rc); err != nil {
+				return errors.Wrapf(err, "[ccd] BinlogHandler table %q action %q", tableName, ev.Action)
+			}
+		}
+		return nil
+	}, binlogsync.RowsChangeFilter{Table: tableName})
+}
+
+func writeRowChange(w config.Writer, rc binlogsync.RowChange) error {
+	var oldPath cfgpath.Path
+	if rc.Before != nil {
+		p, _, err := decodeConfigRow(rc.Before)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		oldPath = p
+	}
+
+	if rc.After == nil { // deleted
+		return errors.Wrapf(w.Write(oldPath, nil), "[ccd] Write Path %q", oldPath)
+	}
+
+	p, v, err := decodeConfigRow(rc.After)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if rc.Before != nil && oldPath.String() != p.String() {
+		if err := w.Write(oldPath, nil); err != nil {
+			return errors.Wrapf(err, "[ccd] Write Path %q", oldPath)
+		}
+	}
+	return errors.Wrapf(w.Write(p, v), "[ccd] Write Path %q", p)
+}
+
+// decodeConfigRow creates the scoped path and the value of a core_config_data
+// row. A NULL value returns a nil interface.
+func decodeConfigRow(r binlogsync.Row) (cfgpath.Path, interface{}, error) {
+	path, _ := r["path"].(dml.NullString)
+	scp, _ := r["scope"].(dml.NullString)
+
+	var id int64
+	switch sid := r["scope_id"].(type) {
+	case dml.NullInt64:
+		id = sid.Int64
+	case dml.NullUint64:
+		id = int64(sid.Uint64)
+	default:
+		return cfgpath.Path{}, nil, errors.NotSupported.Newf("[ccd] Type %T of column scope_id not supported", sid)
+	}
+
+	p, err := cfgpath.NewByParts(path.String)
+	if err != nil {
+		return cfgpath.Path{}, nil, errors.Wrapf(err, "[ccd] cfgpath.NewByParts Path %q", path.String)
+	}
+	p = p.Bind(scope.FromString(scp.String).Pack(id))
+
+	if v, ok := r["value"].(dml.NullString); ok && v.Valid {
+		return p, v.String, nil
+	}
+	return p, nil, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ccd_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/ccd"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ binlogsync.RowsEventHandler = ccd.NewBinlogHandler(nil, "")
+
+var tableCoreConfigData = ddl.Table{
+	Name: "core_config_data",
+	Columns: ddl.Columns{
+		&ddl.Column{Field: "config_id", Pos: 1, DataType: "int", ColumnType: "int(10) unsigned", Key: "PRI"},
+		&ddl.Column{Field: "scope", Pos: 2, DataType: "varchar", ColumnType: "varchar(8)"},
+		&ddl.Column{Field: "scope_id", Pos: 3, DataType: "int", ColumnType: "int(11)"},
+		&ddl.Column{Field: "path", Pos: 4, DataType: "varchar", ColumnType: "varchar(255)"},
+		&ddl.Column{Field: "value", Pos: 5, DataType: "text", ColumnType: "text"},
+	},
+}
+
+type recordWriter struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (rw *recordWriter) Write(p cfgpath.Path, v interface{}) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if v == nil {
+		v = "<nil>"
+	}
+	rw.writes = append(rw.writes, p.String()+"="+v.(string))
+	return nil
+}
+
+func TestNewBinlogHandler(t *testing.T) {
+	t.Parallel()
+
+	t.Run("actions", func(t *testing.T) {
+		rw := new(recordWriter)
+		h := ccd.NewBinlogHandler(rw, "")
+		ctx := context.Background()
+
+		require.NoError(t, h.Do(ctx, binlogsync.InsertAction, tableCoreConfigData, [][]interface{}{
+			{int32(1), "default", int32(0), "web/secure/base_url", "https://corestore.io/"},
+			{int32(2), "stores", int32(2), "web/cors/allowed_origins", nil},
+		}))
+		require.NoError(t, h.Do(ctx, binlogsync.UpdateAction, tableCoreConfigData, [][]interface{}{
+			{int32(1), "default", int32(0), "web/secure/base_url", "https://corestore.io/"},
+			{int32(1), "websites", int32(1), "web/secure/base_url", "https://corestore.de/"},
+		}))
+		require.NoError(t, h.Do(ctx, binlogsync.DeleteAction, tableCoreConfigData, [][]interface{}{
+			{int32(2), "stores", int32(2), "web/cors/allowed_origins", nil},
+		}))
+		// other tables get ignored
+		require.NoError(t, h.Do(ctx, binlogsync.InsertAction, ddl.Table{Name: "sales_order"}, [][]interface{}{{1}}))
+
+		assert.Exactly(t, []string{
+			"default/0/web/secure/base_url=https://corestore.io/",
+			"stores/2/web/cors/allowed_origins=<nil>",
+			"default/0/web/secure/base_url=<nil>",
+			"websites/1/web/secure/base_url=https://corestore.de/",
+			"stores/2/web/cors/allowed_origins=<nil>",
+		}, rw.writes)
+	})
+
+	t.Run("table prefix", func(t *testing.T) {
+		rw := new(recordWriter)
+		h := ccd.NewBinlogHandler(rw, "mage_core_config_data")
+		require.NoError(t, h.Do(context.Background(), binlogsync.InsertAction, tableCoreConfigData, [][]interface{}{
+			{int32(1), "default", int32(0), "web/secure/base_url", "https://corestore.io/"},
+		}))
+		assert.Empty(t, rw.writes)
+	})
+
+	t.Run("invalid path", func(t *testing.T) {
+		h := ccd.NewBinlogHandler(new(recordWriter), "")
+		err := h.Do(context.Background(), binlogsync.InsertAction, tableCoreConfigData, [][]interface{}{
+			{int32(1), "default", int32(0), "web", "https://corestore.io/"},
+		})
+		assert.Error(t, err)
+	})
+}
+
+func TestNewBinlogHandler_PubSub(t *testing.T) {
+	t.Parallel()
+
+	srv := config.MustNewService(config.NewInMemoryStore(), config.WithPubSub())
+	defer func() { assert.NoError(t, srv.Close()) }()
+
+	received := make(chan string, 1)
+	_, err := srv.Subscribe(cfgpath.MustNewByParts("web/cors/allowed_origins").Route, &testSubscriber{
+		f: func(p cfgpath.Path) error {
+			received <- p.String()
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	h := ccd.NewBinlogHandler(srv, "")
+	require.NoError(t, h.Do(context.Background(), binlogsync.InsertAction, tableCoreConfigData, [][]interface{}{
+		{int32(2), "stores", int32(2), "web/cors/allowed_origins", "https://corestore.io"},
+	}))
+
+	select {
+	case p := <-received:
+		assert.Exactly(t, "stores/2/web/cors/allowed_origins", p)
+	case <-time.After(time.Second):
+		t.Fatal("MessageReceiver has not been called")
+	}
+	v, err := srv.String(cfgpath.MustNewByParts("web/cors/allowed_origins").BindStore(2))
+	require.NoError(t, err)
+	assert.Exactly(t, "https://corestore.io", v)
+}
+
+type testSubscriber struct {
+	f func(p cfgpath.Path) error
+}
+
+func (ts *testSubscriber) MessageConfig(p cfgpath.Path) error { return ts.f(p) }
+
+func TestNewBinlogHandler_DeleteFallback(t *testing.T) {
+	t.Parallel()
+
+	srv := config.MustNewService(config.NewInMemoryStore())
+	h := ccd.NewBinlogHandler(srv, "")
+	ctx := context.Background()
+	route := cfgpath.NewRoute("web/cors/allowed_origins")
+
+	require.NoError(t, h.Do(ctx, binlogsync.InsertAction, tableCoreConfigData, [][]interface{}{
+		{int32(1), "default", int32(0), "web/cors/allowed_origins", "https://corestore.io"},
+		{int32(2), "stores", int32(2), "web/cors/allowed_origins", "https://corestore.de"},
+	}))
+	v, err := srv.NewScoped(1, 2).String(route)
+	require.NoError(t, err)
+	assert.Exactly(t, "https://corestore.de", v)
+
+	require.NoError(t, h.Do(ctx, binlogsync.DeleteAction, tableCoreConfigData, [][]interface{}{
+		{int32(2), "stores", int32(2), "web/cors/allowed_origins", "https://corestore.de"},
+	}))
+	v, err = srv.NewScoped(1, 2).String(route)
+	require.NoError(t, err)
+	assert.Exactly(t, "https://corestore.io", v, "Store scope must fall back to the default scope")
+}
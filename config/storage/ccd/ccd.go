@@ -1,4 +1,4 @@
-// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -16,236 +16,454 @@ package ccd
 
 import (
 	"context"
-	"fmt"
-	"time"
+	"strings"
+	"sync"
 
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
 	"github.com/corestoreio/pkg/config/cfgpath"
-	"github.com/corestoreio/pkg/storage/csdb"
+	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/pkg/util/conv"
-	"github.com/corestoreio/pkg/util/null"
-	"github.com/corestoreio/errors"
-	"github.com/corestoreio/log"
 )
 
-// TableCollection handles all tables and its columns. init() in generated Go
-// file will set the value.
-var TableCollection *csdb.Tables
+// DBStorageOption applies options to the DBStorage.
+type DBStorageOption func(*DBStorage) error
 
-// DBStorage connects the MySQL DB with the config.Service type. Implements
-// interface config.Storager.
+// WithTableName sets the name of the core_config_data table, in case a table
+// prefix is in use.
+func WithTableName(name string) DBStorageOption {
+	return func(dbs *DBStorage) error {
+		if err := dml.IsValidIdentifier(name); err != nil {
+			return errors.WithStack(err)
+		}
+		dbs.tableName = name
+		return nil
+	}
+}
+
+// WithLogger sets a custom logger. Default logger is a black hole.
+func WithLogger(l log.Logger) DBStorageOption {
+	return func(dbs *DBStorage) error {
+		dbs.log = l
+		return nil
+	}
+}
+
+// WithWriteBatchSize buffers writes until `size` values have been set and
+// writes them with one prepared multi row INSERT statement. A size smaller
+// than two writes each value immediately. Buffered values can already be read
+// from the cache. Flush or Close must be called to write the remaining values.
+func WithWriteBatchSize(size int) DBStorageOption {
+	return func(dbs *DBStorage) error {
+		dbs.batchSize = size
+		return nil
+	}
+}
+
+// DBStorage connects the MySQL table core_config_data with the config.Service
+// type. Implements interface config.Storager. All SQL statements get
+// prepared once. Read values get cached in memory, so the database gets only
+// queried once per path. Writes update the cache and use an INSERT ... ON
+// DUPLICATE KEY UPDATE statement. Values changed by other processes are not
+// visible until ClearCache or Invalidate has been called.
 type DBStorage struct {
-	log log.Logger
-	// All is a SQL statement for the all keys query
-	All *csdb.ResurrectStmt
-	// Read is a SQL statement for selecting a value from a path/key
-	Read *csdb.ResurrectStmt
-	// Write statement inserts or updates a value
-	Write *csdb.ResurrectStmt
-}
-
-// NewDBStorage creates a new pointer with resurrecting prepared SQL statements.
-// Default logger for the three underlying ResurrectStmt type sports to black
-// hole.
-//
-// All has an idle time of 15s. Read an idle time of 10s. Write an idle time of
-// 30s. Implements interface config.Storager.
-func NewDBStorage(p csdb.Preparer) (*DBStorage, error) {
-	// todo: instead of logging the error we may write it into an
-	// error channel and the gopher who calls NewDBStorage is responsible
-	// for continuously reading from the error channel. or we accept an error channel
-	// as argument here and then writing to it ...
+	log       log.Logger
+	db        *dml.ConnPool
+	tableName string
+	batchSize int
+
+	// stmtAll selects all rows, ordered by scope, scope_id and path.
+	stmtAll *dml.Stmt
+	// stmtRead selects the value of a scoped path.
+	stmtRead *dml.Stmt
+	// stmtWrite inserts or updates one row.
+	stmtWrite *dml.Stmt
+	// stmtWriteBatch inserts or updates batchSize rows.
+	stmtWriteBatch *dml.Stmt
+
+	mu      sync.RWMutex
+	cache   map[uint32]cachedValue
+	pending TableCoreConfigDataSlice
+	// pendingHashes contains the cache keys of the pending rows.
+	pendingHashes []uint32
+	// generation gets incremented by each cache modification which does not
+	// originate from Get. Get caches a loaded value only if the generation did
+	// not change while the value got loaded without holding the lock.
+	generation uint64
+}
+
+type cachedValue struct {
+	key   cfgpath.Path
+	value dml.NullString
+}
 
+// NewDBStorage creates a new pointer and prepares all SQL statements. Don't
+// forget to call Close. Implements interface config.Storager.
+func NewDBStorage(db *dml.ConnPool, opts ...DBStorageOption) (*DBStorage, error) {
 	dbs := &DBStorage{
-		log: log.BlackHole{}, // skip debug and info level via init with empty fields
-		All: csdb.NewResurrectStmt(p, fmt.Sprintf(
-			"SELECT scope,scope_id,path FROM `%s` ORDER BY scope,scope_id,path",
-			TableCollection.Name(TableIndexCoreConfigData),
-		)),
-		Read: csdb.NewResurrectStmt(p, fmt.Sprintf(
-			"SELECT `value` FROM `%s` WHERE `scope`=? AND `scope_id`=? AND `path`=?",
-			TableCollection.Name(TableIndexCoreConfigData),
-		)),
-
-		Write: csdb.NewResurrectStmt(p, fmt.Sprintf(
-			"INSERT INTO `%s` (`scope`,`scope_id`,`path`,`value`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE `value`=?",
-			TableCollection.Name(TableIndexCoreConfigData),
-		)),
-	}
-	dbs.All.Idle = time.Second * 15
-	dbs.All.Log = dbs.log
-	dbs.Read.Idle = time.Second * 10
-	dbs.Read.Log = dbs.log
-	dbs.Write.Idle = time.Second * 30
-	dbs.Write.Log = dbs.log
-	// in the future we may add errors ... just to have for now the func signature
+		log:       log.BlackHole{}, // skip debug and info level via init with empty fields
+		db:        db,
+		tableName: TableNameCoreConfigData,
+		cache:     make(map[uint32]cachedValue),
+	}
+	for _, opt := range opts {
+		if err := opt(dbs); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := dbs.prepare(context.Background()); err != nil {
+		_ = dbs.closeStmts()
+		return nil, errors.WithStack(err)
+	}
 	return dbs, nil
 }
 
 // MustNewDBStorage same as NewDBStorage but panics on error. Implements
 // interface config.Storager.
-func MustNewDBStorage(p csdb.Preparer) *DBStorage {
-	s, err := NewDBStorage(p)
+func MustNewDBStorage(db *dml.ConnPool, opts ...DBStorageOption) *DBStorage {
+	s, err := NewDBStorage(db, opts...)
 	if err != nil {
 		panic(err)
 	}
 	return s
 }
 
-// SetLogger applies your custom logger
-func (dbs *DBStorage) SetLogger(l log.Logger) *DBStorage {
-	dbs.log = l
-	dbs.All.Log = l
-	dbs.Read.Log = l
-	dbs.Write.Log = l
-	return dbs
+func (dbs *DBStorage) newInsert(rowCount int) *dml.Insert {
+	return dbs.db.InsertInto(dbs.tableName).
+		AddColumns("scope", "scope_id", "path", "value").
+		SetRowCount(rowCount).BuildValues().
+		AddOnDuplicateKeyExclude("scope", "scope_id", "path")
 }
 
-// Start starts the internal idle time checker for the resurrecting SQL statements.
-func (dbs *DBStorage) Start() *DBStorage {
-	dbs.All.StartIdleChecker()
-	dbs.Read.StartIdleChecker()
-	dbs.Write.StartIdleChecker()
-	return dbs
-}
+func (dbs *DBStorage) prepare(ctx context.Context) (err error) {
+	dbs.stmtAll, err = dbs.db.SelectFrom(dbs.tableName).
+		AddColumns("scope", "scope_id", "path", "value").
+		OrderBy("scope", "scope_id", "path").Prepare(ctx)
+	if err != nil {
+		return errors.Wrap(err, "[ccd] Prepare All")
+	}
 
-// Stop stops the internal goroutines for idle time checking. Returns the
-// first occurring sql.Stmt.Close() error.
-func (dbs *DBStorage) Stop() error {
-	if err := dbs.All.StopIdleChecker(); err != nil {
-		return errors.Wrap(err, "[ccd] All.StopIdleChecker")
+	dbs.stmtRead, err = dbs.db.SelectFrom(dbs.tableName).AddColumns("value").
+		Where(
+			dml.Column("scope").PlaceHolder(),
+			dml.Column("scope_id").PlaceHolder(),
+			dml.Column("path").PlaceHolder(),
+		).Prepare(ctx)
+	if err != nil {
+		return errors.Wrap(err, "[ccd] Prepare Read")
 	}
-	if err := dbs.Read.StopIdleChecker(); err != nil {
-		return errors.Wrap(err, "[ccd] Read.StopIdleChecker")
+
+	dbs.stmtWrite, err = dbs.newInsert(1).Prepare(ctx)
+	if err != nil {
+		return errors.Wrap(err, "[ccd] Prepare Write")
 	}
-	if err := dbs.Write.StopIdleChecker(); err != nil {
-		return errors.Wrap(err, "[ccd] Write.StopIdleChecker")
+
+	if dbs.batchSize > 1 {
+		dbs.stmtWriteBatch, err = dbs.newInsert(dbs.batchSize).Prepare(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "[ccd] Prepare Write Batch with size %d", dbs.batchSize)
+		}
 	}
 	return nil
 }
 
-// Set sets a value with its key. Database errors get logged as Info message.
-// Enabled debug level logs the insert ID or rows affected.
-func (dbs *DBStorage) Set(key cfgpath.Path, value interface{}) error {
-	// update lastUsed at the end because there might be the slight chance that
-	// a statement gets closed despite we're waiting for the result from the
-	// server.
-	dbs.Write.StartStmtUse()
-	defer dbs.Write.StopStmtUse()
-
-	valStr, err := conv.ToStringE(value)
+// newRow converts a scoped path and its value into a row.
+func newRow(key cfgpath.Path, value interface{}) (*TableCoreConfigData, error) {
+	pl, err := key.Level(-1)
 	if err != nil {
-		return errors.Wrapf(err, "[ccd] Set.conv.ToStringE. SQL: %q Key: %q Value: %v", dbs.Write.sqlRaw, key, value)
+		return nil, errors.Wrapf(err, "[ccd] key.Level Key: %q", key)
+	}
+	scp, id := key.ScopeID.Unpack()
+	r := &TableCoreConfigData{
+		Scope:   scp.StrType(),
+		ScopeID: id,
+		Path:    pl.String(),
+	}
+	if value != nil {
+		s, err := conv.ToStringE(value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[ccd] conv.ToStringE Key: %q Value: %v", key, value)
+		}
+		r.Value = dml.MakeNullString(s)
 	}
+	return r, nil
+}
 
-	stmt, err := dbs.Write.Stmt(context.TODO())
+// Set writes a value with its key into the database and the cache. A nil
+// value writes NULL and a following Get returns a NotFound error, like for a
+// deleted key. With an enabled write batch size, the value gets written once
+// the batch is full.
+func (dbs *DBStorage) Set(key cfgpath.Path, value interface{}) error {
+	h32, err := key.Hash(-1)
 	if err != nil {
-		return errors.Wrapf(err, "[ccd] Set.Write.Stmt. SQL: %q Key: %q", dbs.Write.sqlRaw, key)
+		return errors.Wrapf(err, "[ccd] Set.key.Hash Key: %q", key)
 	}
-
-	pathLeveled, err := key.Level(-1)
+	row, err := newRow(key, value)
 	if err != nil {
-		return errors.Wrapf(err, "[ccd] Set.key.Level. SQL: %q Key: %q", dbs.Write.sqlRaw, key)
+		return errors.WithStack(err)
 	}
 
-	scp, id := key.ScopeID.Unpack()
-	result, err := stmt.Exec(scp.StrType(), id, pathLeveled, valStr, valStr)
-	if err != nil {
-		return errors.Wrapf(err, "[ccd] Set.stmt.Exec. SQL: %q KeyID: %d Scope: %q Path: %q Value: %q", dbs.Write.sqlRaw, id, scp, pathLeveled, valStr)
-	}
-	if dbs.log.IsDebug() {
-		li, err1 := result.LastInsertId()
-		ra, err2 := result.RowsAffected()
-		dbs.log.Debug(
-			"config.DBStorage.Set.Write.Result",
-			log.Int64("lastInsertID", li),
-			log.ErrWithKey("lastInsertIDErr", err1),
-			log.Int64("rowsAffected", ra),
-			log.ErrWithKey("rowsAffectedErr", err2),
-			log.String("SQL", dbs.Write.sqlRaw),
-			log.Stringer("key", key),
-			log.Object("value", value),
-		)
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+	dbs.generation++
+	dbs.cache[h32] = cachedValue{key: key, value: row.Value}
+
+	if dbs.stmtWriteBatch == nil {
+		res, err := dbs.stmtWrite.WithArgs().Record("", row).ExecContext(context.Background())
+		if err != nil {
+			delete(dbs.cache, h32)
+			return errors.Wrapf(err, "[ccd] Set.Write Key: %q", key)
+		}
+		if dbs.log.IsDebug() {
+			ra, err := res.RowsAffected()
+			dbs.log.Debug("ccd.DBStorage.Set.Write",
+				log.Int64("rows_affected", ra), log.ErrWithKey("rows_affected_error", err),
+				log.Stringer("key", key))
+		}
+		return nil
+	}
+
+	dbs.pending = append(dbs.pending, row)
+	dbs.pendingHashes = append(dbs.pendingHashes, h32)
+	if len(dbs.pending) < dbs.batchSize {
+		return nil
+	}
+	return errors.WithStack(dbs.flush(context.Background()))
+}
+
+// Flush writes all buffered values into the database. Full batches use the
+// prepared statement, the remaining values get written with one multi row
+// INSERT.
+func (dbs *DBStorage) Flush() error {
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+	return errors.WithStack(dbs.flush(context.Background()))
+}
+
+// flush expects a locked mutex.
+func (dbs *DBStorage) flush(ctx context.Context) error {
+	for len(dbs.pending) > 0 {
+		batch := dbs.pending
+		var a *dml.Artisan
+		if dbs.stmtWriteBatch != nil && len(batch) >= dbs.batchSize {
+			batch = batch[:dbs.batchSize]
+			a = dbs.stmtWriteBatch.WithArgs()
+		} else {
+			a = dbs.newInsert(len(batch)).WithArgs()
+		}
+		if _, err := a.Record("", &batch).ExecContext(ctx); err != nil {
+			return errors.Wrapf(err, "[ccd] Flush %d rows", len(batch))
+		}
+		if dbs.log.IsDebug() {
+			dbs.log.Debug("ccd.DBStorage.Flush", log.Int("rows", len(batch)))
+		}
+		dbs.pending = dbs.pending[len(batch):]
+		dbs.pendingHashes = dbs.pendingHashes[len(batch):]
 	}
+	dbs.pending = nil
+	dbs.pendingHashes = nil
 	return nil
 }
 
-// Get returns a value from the database by its key. It is guaranteed that the
-// type in the empty interface is a string. It returns nil on error but errors
-// get logged as info message. Error behaviour: NotFound
+// Get returns a value from the cache or from the database by its key. It is
+// guaranteed that the type in the empty interface is a string. A missing row
+// or a NULL value returns a NotFound error.
 func (dbs *DBStorage) Get(key cfgpath.Path) (interface{}, error) {
-	// update lastUsed at the end because there might be the slight chance that
-	// a statement gets closed despite we're waiting for the result from the
-	// server.
-	dbs.Read.StartStmtUse()
-	defer dbs.Read.StopStmtUse()
-
-	stmt, err := dbs.Read.Stmt(context.TODO())
+	h32, err := key.Hash(-1)
 	if err != nil {
-		return nil, errors.Wrapf(err, "[ccd] Get.Read.Stmt. SQL: %q Key: %q", dbs.Read.sqlRaw, key)
+		return nil, errors.Wrapf(err, "[ccd] Get.key.Hash Key: %q", key)
 	}
 
-	pl, err := key.Level(-1)
-	if err != nil {
-		return nil, errors.Wrapf(err, "[ccd] Get.key.Level. SQL: %q Key: %q", dbs.Read.sqlRaw, key)
+	dbs.mu.RLock()
+	cv, ok := dbs.cache[h32]
+	gen := dbs.generation
+	dbs.mu.RUnlock()
+	if !ok {
+		row, err := newRow(key, nil)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		v, found, err := dbs.stmtRead.WithArgs().LoadNullString(context.Background(), row.Scope, row.ScopeID, row.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[ccd] Get.Read Key: %q", key)
+		}
+		if !found {
+			return nil, errors.NotFound.Newf("[ccd] Key %q not found", key)
+		}
+		cv = cachedValue{key: key, value: v}
+		dbs.mu.Lock()
+		// A concurrent Set, Invalidate or ClearCache might have happened
+		// during the load, the loaded value could be stale.
+		if dbs.generation == gen {
+			dbs.cache[h32] = cv
+		}
+		dbs.mu.Unlock()
 	}
 
-	var data null.String
-	scp, id := key.ScopeID.Unpack()
-	err = stmt.QueryRow(scp.StrType(), id, pl).Scan(&data)
-	if err != nil {
-		return nil, errors.Wrapf(err, "[ccd] Get.QueryRow. SQL: %q Key: %q PathLevel: %q", dbs.Read.sqlRaw, key, pl)
+	if !cv.value.Valid {
+		return nil, errors.NotFound.Newf("[ccd] Key %q has a NULL value", key)
 	}
-	if data.Valid {
-		return data.String, nil
+	return cv.value.String, nil
+}
+
+// AllKeys writes all buffered values, loads all rows with one query into the
+// cache and returns their keys. Rows with an invalid path get skipped and
+// logged.
+func (dbs *DBStorage) AllKeys() (cfgpath.PathSlice, error) {
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+	dbs.generation++
+
+	ctx := context.Background()
+	if err := dbs.flush(ctx); err != nil {
+		return nil, errors.WithStack(err)
 	}
-	return nil, errKeyNotFound
+
+	var rows TableCoreConfigDataSlice
+	if _, err := dbs.stmtAll.WithArgs().Load(ctx, &rows); err != nil {
+		return nil, errors.Wrap(err, "[ccd] AllKeys.Load")
+	}
+
+	ret := make(cfgpath.PathSlice, 0, len(rows))
+	for _, r := range rows {
+		p, err := cfgpath.NewByParts(r.Path)
+		if err != nil {
+			if dbs.log.IsInfo() {
+				dbs.log.Info("ccd.DBStorage.AllKeys.NewByParts", log.Err(err), log.String("path", r.Path))
+			}
+			continue
+		}
+		p = p.Bind(scope.FromString(r.Scope).Pack(r.ScopeID))
+		h32, err := p.Hash(-1)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[ccd] AllKeys.Hash Path: %q", p)
+		}
+		dbs.cache[h32] = cachedValue{key: p, value: r.Value}
+		ret = append(ret, p)
+	}
+	return ret, nil
 }
 
-var errKeyNotFound = errors.NewNotFoundf(`[ccd] Key not found`) // todo add test
+// likeEscaper escapes the wildcard characters of a LIKE condition.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
 
-// AllKeys returns all available keys. Database errors get logged as info message.
-func (dbs *DBStorage) AllKeys() (cfgpath.PathSlice, error) {
-	// update lastUsed at the end because there might be the slight chance
-	// that a statement gets closed despite we're waiting for the result
-	// from the server.
-	dbs.All.StartStmtUse()
-	defer dbs.All.StopStmtUse()
+// Query implements interface config.Querier. The database filters the rows by
+// the literal prefix of the query and by the scope types, all other filters
+// get applied afterwards. Rows with a NULL value get skipped. Buffered values
+// get written before and the loaded values get cached.
+func (dbs *DBStorage) Query(q config.Query) (config.KeyValues, error) {
+	conds := []*dml.Condition{dml.Column("value").NotNull()}
+	if lp := q.LiteralPrefix(); lp != "" {
+		conds = append(conds, dml.Column("path").Like().Str(likeEscaper.Replace(lp)+"%"))
+	}
+	if q.Scopes > 0 {
+		var scopes []string
+		for _, st := range [...]scope.Type{scope.Default, scope.Website, scope.Store} {
+			if q.Scopes.Has(st) {
+				scopes = append(scopes, st.StrType())
+			}
+		}
+		if len(scopes) == 0 {
+			return nil, nil // core_config_data does not contain other scopes
+		}
+		conds = append(conds, dml.Column("scope").In().Strs(scopes...))
+	}
+
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+	dbs.generation++
 
-	stmt, err := dbs.All.Stmt(context.TODO())
+	ctx := context.Background()
+	if err := dbs.flush(ctx); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var rows TableCoreConfigDataSlice
+	_, err := dbs.db.SelectFrom(dbs.tableName).AddColumns("scope", "scope_id", "path", "value").
+		Where(conds...).WithArgs().Load(ctx, &rows)
 	if err != nil {
-		return nil, errors.Wrapf(err, "[ccd] AllKeys.All.Stmt. SQL: %q", dbs.All.sqlRaw)
+		return nil, errors.Wrap(err, "[ccd] Query.Load")
+	}
+
+	kvs := make(config.KeyValues, 0, len(rows))
+	for _, r := range rows {
+		p, err := cfgpath.NewByParts(r.Path)
+		if err != nil {
+			if dbs.log.IsInfo() {
+				dbs.log.Info("ccd.DBStorage.Query.NewByParts", log.Err(err), log.String("path", r.Path))
+			}
+			continue
+		}
+		p = p.Bind(scope.FromString(r.Scope).Pack(r.ScopeID))
+		if !q.Match(p) {
+			continue
+		}
+		h32, err := p.Hash(-1)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[ccd] Query.Hash Path: %q", p)
+		}
+		dbs.cache[h32] = cachedValue{key: p, value: r.Value}
+		kvs = append(kvs, config.KeyValue{Key: p, Value: r.Value.String})
 	}
+	return kvs, nil
+}
 
-	rows, err := stmt.Query()
+// Invalidate removes the cached value of a path, so the next Get reads it
+// from the database. A buffered value stays in the cache until it has been
+// written. Implements interface config.Invalidator.
+func (dbs *DBStorage) Invalidate(key cfgpath.Path) error {
+	h32, err := key.Hash(-1)
 	if err != nil {
-		return nil, errors.Wrapf(err, "[ccd] AllKeys.All.Query. SQL: %q", dbs.All.sqlRaw)
+		return errors.Wrapf(err, "[ccd] Invalidate.key.Hash Key: %q", key)
+	}
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+	dbs.generation++
+	for _, ph := range dbs.pendingHashes {
+		if ph == h32 {
+			return nil
+		}
 	}
-	defer rows.Close()
+	delete(dbs.cache, h32)
+	return nil
+}
 
-	const maxCap = 750 // Just a guess the 750
-	var ret = make(cfgpath.PathSlice, 0, maxCap)
-	var sqlScope null.String
-	var sqlScopeID null.Int64
-	var sqlPath null.String
+// ClearCache removes all cached values. Buffered values stay in the cache
+// until they have been written.
+func (dbs *DBStorage) ClearCache() {
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+	dbs.generation++
+	cache := make(map[uint32]cachedValue, len(dbs.pendingHashes))
+	for _, h32 := range dbs.pendingHashes {
+		cache[h32] = dbs.cache[h32]
+	}
+	dbs.cache = cache
+}
+
+// Close writes all buffered values and closes the prepared statements. It
+// returns the first occurring error.
+func (dbs *DBStorage) Close() error {
+	dbs.mu.Lock()
+	defer dbs.mu.Unlock()
+	err := dbs.flush(context.Background())
+	if cErr := dbs.closeStmts(); err == nil {
+		err = cErr
+	}
+	return errors.WithStack(err)
+}
 
-	for rows.Next() {
-		if err := rows.Scan(&sqlScope, &sqlScopeID, &sqlPath); err != nil {
-			return nil, errors.Wrapf(err, "[ccd] AllKeys.rows.Scan. SQL: %q", dbs.All.sqlRaw)
+func (dbs *DBStorage) closeStmts() error {
+	var firstErr error
+	for _, st := range [...]*dml.Stmt{dbs.stmtAll, dbs.stmtRead, dbs.stmtWrite, dbs.stmtWriteBatch} {
+		if st == nil {
+			continue
 		}
-		if sqlPath.Valid {
-			p, err := cfgpath.NewByParts(sqlPath.String)
-			if err != nil {
-				return ret, errors.Wrapf(err, "[ccd] AllKeys.rows.cfgpath.NewByParts. SQL: %q: Path: %q", dbs.All.sqlRaw, sqlPath.String)
-			}
-			ret = append(ret, p.Bind(scope.FromString(sqlScope.String).Pack(sqlScopeID.Int64)))
+		if err := st.Close(); err != nil && firstErr == nil {
+			firstErr = errors.Wrap(err, "[ccd] Stmt.Close")
 		}
-		sqlScope.String = ""
-		sqlScope.Valid = false
-		sqlScopeID.Int64 = 0
-		sqlScopeID.Valid = false
-		sqlPath.String = ""
-		sqlPath.Valid = false
 	}
-	return ret, nil
+	return firstErr
 }
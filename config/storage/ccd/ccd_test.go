@@ -1,4 +1,4 @@
-// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -15,258 +15,310 @@
 package ccd_test
 
 import (
-	"database/sql/driver"
-	"fmt"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
 	"github.com/corestoreio/pkg/config"
 	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/config/storage/ccd"
+	"github.com/corestoreio/pkg/sql/dmltest"
 	"github.com/corestoreio/pkg/store/scope"
-	"github.com/corestoreio/pkg/util/cstesting"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var _ config.Storager = (*ccd.DBStorage)(nil)
+var _ config.Querier = (*ccd.DBStorage)(nil)
+var _ config.Invalidator = (*ccd.DBStorage)(nil)
+
+const (
+	sqlAll         = "SELECT `scope`, `scope_id`, `path`, `value` FROM `core_config_data` ORDER BY"
+	sqlRead        = "SELECT `value` FROM `core_config_data` WHERE (`scope` = ?) AND (`scope_id` = ?) AND (`path` = ?)"
+	sqlWrite       = "INSERT INTO `core_config_data` (`scope`,`scope_id`,`path`,`value`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE `value`=VALUES(`value`)"
+	sqlWriteBatch2 = "INSERT INTO `core_config_data` (`scope`,`scope_id`,`path`,`value`) VALUES (?,?,?,?),(?,?,?,?) ON DUPLICATE KEY UPDATE `value`=VALUES(`value`)"
+	sqlWriteBatch3 = "INSERT INTO `core_config_data` (`scope`,`scope_id`,`path`,`value`) VALUES (?,?,?,?),(?,?,?,?),(?,?,?,?) ON DUPLICATE KEY UPDATE `value`=VALUES(`value`)"
+)
 
-func TestDBStorageOneStmt(t *testing.T) {
-	t.Parallel()
+type dbStorageMocks struct {
+	all, read, write, writeBatch *sqlmock.ExpectedPrepare
+}
 
-	dbc, dbMock := cstesting.MockDB(t)
-	defer func() {
-		dbMock.ExpectClose()
+func expectPrepares(dbMock sqlmock.Sqlmock, batchSQL string) (m dbStorageMocks) {
+	m.all = dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlAll))
+	m.read = dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlRead))
+	m.write = dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlWrite))
+	if batchSQL != "" {
+		m.writeBatch = dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(batchSQL))
+	}
+	return m
+}
 
-		assert.NoError(t, dbc.Close())
+func (m dbStorageMocks) expectClose() {
+	m.all.WillBeClosed()
+	m.read.WillBeClosed()
+	m.write.WillBeClosed()
+	if m.writeBatch != nil {
+		m.writeBatch.WillBeClosed()
+	}
+}
 
-		if err := dbMock.ExpectationsWereMet(); err != nil {
-			t.Error("there were unfulfilled expections", err)
-		}
-	}()
+func TestDBStorage_SetGet(t *testing.T) {
+	t.Parallel()
 
-	sdb := ccd.MustNewDBStorage(dbc.DB).Start()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
 
-	// Stop() would only be called under rare circumstances on a production system
-	defer func() { assert.NoError(t, sdb.Stop()) }()
+	m := expectPrepares(dbMock, "")
+	m.expectClose()
+	sdb := ccd.MustNewDBStorage(dbc)
 
 	tests := []struct {
 		key       cfgpath.Path
 		value     interface{}
-		wantNil   bool
 		wantValue string
 	}{
-		{cfgpath.MustNewByParts("testDBStorage/secure/base_url").BindStore(1), "http://corestore.io", false, "http://corestore.io"},
-		{cfgpath.MustNewByParts("testDBStorage/log/active").BindStore(2), 1, false, "1"},
-		{cfgpath.MustNewByParts("testDBStorage/log/clean").BindStore(99999), 19.999, false, "19.999"},
-		{cfgpath.MustNewByParts("testDBStorage/log/clean").BindStore(99999), 29.999, false, "29.999"},
-		{cfgpath.MustNewByParts("testDBStorage/catalog/purge").Bind(scope.DefaultTypeID), true, false, "true"},
-		{cfgpath.MustNewByParts("testDBStorage/catalog/clean").Bind(scope.DefaultTypeID), 0, false, "0"},
+		{cfgpath.MustNewByParts("testDBStorage/secure/base_url").BindStore(1), "http://corestore.io", "http://corestore.io"},
+		{cfgpath.MustNewByParts("testDBStorage/log/active").BindStore(2), 1, "1"},
+		{cfgpath.MustNewByParts("testDBStorage/log/clean").BindWebsite(3), 19.999, "19.999"},
+		{cfgpath.MustNewByParts("testDBStorage/catalog/purge").Bind(scope.DefaultTypeID), true, "true"},
 	}
 
-	prepIns := dbMock.ExpectPrepare("INSERT INTO `[^`]+` \\(.+\\) VALUES \\(\\?,\\?,\\?,\\?\\) ON DUPLICATE KEY UPDATE `value`=\\?")
 	for i, test := range tests {
+		scp, id := test.key.ScopeID.Unpack()
+		pl, err := test.key.Level(-1)
+		require.NoError(t, err)
+		m.write.ExpectExec().WithArgs(scp.StrType(), id, pl.String(), test.wantValue).
+			WillReturnResult(sqlmock.NewResult(0, 1))
 
-		prepIns.ExpectExec().WithArgs(
-			driver.Value(test.key.ScopeID.Type().StrType()),
-			driver.Value(test.key.ScopeID.ID()),
-			driver.Value(test.key.Bytes()),
-			driver.Value(test.wantValue), // value
-			driver.Value(test.wantValue), // value fall back on duplicate key
-		).WillReturnResult(sqlmock.NewResult(0, 1))
-
-		if err := sdb.Set(test.key, test.value); err != nil {
-			t.Fatal("Index", i, " => ", err)
-		}
+		require.NoError(t, sdb.Set(test.key, test.value), "Index %d", i)
 	}
 
-	// both prepared statements cannot run within one for loop :-(
-
-	prepSel := dbMock.ExpectPrepare("SELECT `value` FROM `[^`]+` WHERE `scope`=\\? AND `scope_id`=\\? AND `path`=\\?")
+	// Get reads from the cache, no database query.
 	for i, test := range tests {
-
-		prepSel.ExpectQuery().WithArgs(
-			driver.Value(test.key.ScopeID.Type().StrType()),
-			driver.Value(test.key.ScopeID.ID()),
-			driver.Value(test.key.Bytes()),
-		).WillReturnRows(sqlmock.NewRows([]string{"value"}).FromCSVString(test.wantValue))
-
-		if test.wantNil {
-			g, err := sdb.Get(test.key)
-			assert.NoError(t, err, "Index %d", i)
-			assert.Nil(t, g, "Index %d", i)
-		} else {
-			g, err := sdb.Get(test.key)
-			assert.NoError(t, err, "Index %d", i)
-			assert.Exactly(t, test.wantValue, g, "Index %d", i)
-		}
+		g, err := sdb.Get(test.key)
+		require.NoError(t, err, "Index %d", i)
+		assert.Exactly(t, test.wantValue, g, "Index %d", i)
 	}
 
-	//assert.Exactly(t, 1, strings.Count(debugLogBuf.String(), `csdb.ResurrectStmt.stmt.Prepare SQL: "INSERT INTO`))
-	//assert.Exactly(t, 1, strings.Count(debugLogBuf.String(), "csdb.ResurrectStmt.stmt.Prepare SQL: \"SELECT `value` FROM"))
+	require.NoError(t, sdb.Close())
+}
 
-	mockRows := sqlmock.NewRows([]string{"scope", "scope_id", "path"})
-	for _, test := range tests {
-		mockRows.FromCSVString(fmt.Sprintf("%s,%d,%s", test.key.ScopeID.Type().StrType(), test.key.ScopeID.ID(), test.key.Chars))
-	}
-	prepAll := dbMock.ExpectPrepare("SELECT scope,scope_id,path FROM `[^`]+` ORDER BY scope,scope_id,path")
-	prepAll.ExpectQuery().WillReturnRows(mockRows)
+func TestDBStorage_Get_ReadThrough(t *testing.T) {
+	t.Parallel()
 
-	allKeys, err := sdb.AllKeys()
-	assert.NoError(t, err)
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
 
-	for i, test := range tests {
-		assert.True(t, allKeys.Contains(test.key), "Missing Key: %s\nIndex %d", test.key, i)
+	m := expectPrepares(dbMock, "")
+	m.expectClose()
+	sdb := ccd.MustNewDBStorage(dbc)
+
+	key := cfgpath.MustNewByParts("web/cors/allowed_origins").BindStore(2)
+	m.read.ExpectQuery().WithArgs("stores", int64(2), "web/cors/allowed_origins").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("https://corestore.io"))
+
+	for i := 0; i < 3; i++ { // only one query
+		v, err := sdb.Get(key)
+		require.NoError(t, err)
+		assert.Exactly(t, "https://corestore.io", v)
 	}
-	//assert.Exactly(t, 1, strings.Count(debugLogBuf.String(), `SELECT scope,scope_id,path FROM `))
-}
 
-var dbStorageMultiTests = []struct {
-	key       cfgpath.Path
-	value     interface{}
-	wantValue string
-}{
-	{cfgpath.MustNewByParts("testDBStorage/secure/base_url").BindWebsite(10), "http://corestore.io", "http://corestore.io"},
-	{cfgpath.MustNewByParts("testDBStorage/log/active").BindWebsite(10), 1, "1"},
-	{cfgpath.MustNewByParts("testDBStorage/log/clean").BindWebsite(20), 19.999, "19.999"},
-	{cfgpath.MustNewByParts("testDBStorage/product/shipping").BindWebsite(20), 29.999, "29.999"},
-	{cfgpath.MustNewByParts("testDBStorage/checkout/multishipping"), false, "false"},
-	{cfgpath.MustNewByParts("testDBStorage/shipping/rate").BindStore(321), 3.14159, "3.14159"},
+	t.Run("not found", func(t *testing.T) {
+		key := cfgpath.MustNewByParts("web/cors/allowed_methods").BindStore(2)
+		m.read.ExpectQuery().WithArgs("stores", int64(2), "web/cors/allowed_methods").
+			WillReturnRows(sqlmock.NewRows([]string{"value"}))
+		v, err := sdb.Get(key)
+		assert.Nil(t, v)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+
+	t.Run("NULL value", func(t *testing.T) {
+		key := cfgpath.MustNewByParts("web/cors/exposed_headers").BindStore(2)
+		m.read.ExpectQuery().WithArgs("stores", int64(2), "web/cors/exposed_headers").
+			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow(nil))
+		v, err := sdb.Get(key)
+		assert.Nil(t, v)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+
+	t.Run("ClearCache", func(t *testing.T) {
+		sdb.ClearCache()
+		m.read.ExpectQuery().WithArgs("stores", int64(2), "web/cors/allowed_origins").
+			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("https://corestore.de"))
+		v, err := sdb.Get(key)
+		require.NoError(t, err)
+		assert.Exactly(t, "https://corestore.de", v)
+	})
+
+	t.Run("Invalidate", func(t *testing.T) {
+		require.NoError(t, sdb.Invalidate(key))
+		m.read.ExpectQuery().WithArgs("stores", int64(2), "web/cors/allowed_origins").
+			WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow("https://corestore.ch"))
+		v, err := sdb.Get(key)
+		require.NoError(t, err)
+		assert.Exactly(t, "https://corestore.ch", v)
+	})
+
+	require.NoError(t, sdb.Close())
 }
 
-func TestDBStorageMultipleStmt_Set(t *testing.T) {
+func TestDBStorage_Get_ConcurrentInvalidate(t *testing.T) {
 	t.Parallel()
-	dbc, dbMock := cstesting.MockDB(t)
-	defer func() {
-		dbMock.ExpectClose()
 
-		assert.NoError(t, dbc.Close())
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
 
-		if err := dbMock.ExpectationsWereMet(); err != nil {
-			t.Error("there were unfulfilled expections", err)
-		}
-	}()
+	m := expectPrepares(dbMock, "")
+	m.expectClose()
+	sdb := ccd.MustNewDBStorage(dbc)
 
-	sdb := ccd.MustNewDBStorage(dbc.DB)
-	sdb.Write.Idle = time.Second * 1
-
-	sdb.Start()
-
-	var prepIns *sqlmock.ExpectedPrepare
-	for i, test := range dbStorageMultiTests {
-		if i < 3 {
-			prepIns = dbMock.ExpectPrepare("INSERT INTO `[^`]+` \\(.+\\) VALUES \\(\\?,\\?,\\?,\\?\\) ON DUPLICATE KEY UPDATE `value`=\\?")
-		}
-
-		prepIns.ExpectExec().WithArgs(
-			driver.Value(test.key.ScopeID.Type().StrType()),
-			driver.Value(test.key.ScopeID.ID()),
-			driver.Value(test.key.Bytes()),
-			driver.Value(test.wantValue), // value
-			driver.Value(test.wantValue), // value fall back on duplicate key
-		).WillReturnResult(sqlmock.NewResult(0, 1))
-
-		if err := sdb.Set(test.key, test.value); err != nil {
-			t.Fatal("Index", i, "with error:", err)
-		}
-
-		if i < 2 {
-			// last two iterations reopen a new statement, not closing it and reusing it
-			time.Sleep(time.Millisecond * 1500) // trigger ticker to close statements
-		}
-	}
+	key := cfgpath.MustNewByParts("web/cors/allowed_origins").BindStore(2)
+	m.read.ExpectQuery().WithArgs("stores", int64(2), "web/cors/allowed_origins").
+		WillDelayFor(100 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte("https://stale.io")))
 
-	assert.NoError(t, sdb.Stop())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		v, err := sdb.Get(key)
+		assert.NoError(t, err)
+		assert.Exactly(t, "https://stale.io", v)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	// The value changes in the database while Get loads the old one.
+	require.NoError(t, sdb.Invalidate(key))
+	<-done
+
+	m.read.ExpectQuery().WithArgs("stores", int64(2), "web/cors/allowed_origins").
+		WillReturnRows(sqlmock.NewRows([]string{"value"}).AddRow([]byte("https://corestore.io")))
+	v, err := sdb.Get(key)
+	require.NoError(t, err)
+	assert.Exactly(t, "https://corestore.io", v, "The stale value must not be cached")
+
+	require.NoError(t, sdb.Close())
 }
 
-func TestDBStorageMultipleStmt_Get(t *testing.T) {
+func TestDBStorage_WriteBatch(t *testing.T) {
 	t.Parallel()
-	dbc, dbMock := cstesting.MockDB(t)
-	defer func() {
-		dbMock.ExpectClose()
 
-		assert.NoError(t, dbc.Close())
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
 
-		if err := dbMock.ExpectationsWereMet(); err != nil {
-			t.Error("there were unfulfilled expections", err)
-		}
-	}()
+	m := expectPrepares(dbMock, sqlWriteBatch3)
+	sdb := ccd.MustNewDBStorage(dbc, ccd.WithWriteBatchSize(3))
 
-	sdb := ccd.MustNewDBStorage(dbc.DB)
-	sdb.Read.Idle = time.Second * 1
+	p := cfgpath.MustNewByParts("carriers/dhl/active")
+	m.writeBatch.ExpectExec().WithArgs(
+		"default", int64(0), "carriers/dhl/active", "1",
+		"websites", int64(1), "carriers/dhl/active", "0",
+		"stores", int64(3), "carriers/dhl/active", "1",
+	).WillReturnResult(sqlmock.NewResult(0, 3))
 
-	sdb.Start()
+	require.NoError(t, sdb.Set(p, 1))
+	require.NoError(t, sdb.Set(p.BindWebsite(1), 0))
 
-	// both prepared statements cannot run within one for loop :-(
+	// not yet written but available in the cache
+	v, err := sdb.Get(p.BindWebsite(1))
+	require.NoError(t, err)
+	assert.Exactly(t, "0", v)
 
-	var prepSel *sqlmock.ExpectedPrepare
-	for i, test := range dbStorageMultiTests {
-		if i < 3 {
-			prepSel = dbMock.ExpectPrepare("SELECT `value` FROM `[^`]+` WHERE `scope`=\\? AND `scope_id`=\\? AND `path`=\\?")
-		}
+	require.NoError(t, sdb.Set(p.BindStore(3), 1)) // triggers the batch
 
-		prepSel.ExpectQuery().WithArgs(
-			driver.Value(test.key.ScopeID.Type().StrType()),
-			driver.Value(test.key.ScopeID.ID()),
-			driver.Value(test.key.Bytes()),
-		).WillReturnRows(sqlmock.NewRows([]string{"value"}).FromCSVString(test.wantValue))
+	require.NoError(t, sdb.Set(p.BindStore(4), 0))
+	require.NoError(t, sdb.Set(p.BindStore(5), nil))
 
-		g, err := sdb.Get(test.key)
-		assert.NoError(t, err, "Index %d", i)
-		assert.Exactly(t, test.wantValue, g, "Index %d", i)
-
-		if i < 2 {
-			// last two iterations reopen a new statement, not closing it and reusing it
-			time.Sleep(time.Millisecond * 1500) // trigger ticker to close statements
-		}
-	}
+	// Close writes the remaining two values with a non-prepared statement.
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(sqlWriteBatch2)).WithArgs(
+		"stores", int64(4), "carriers/dhl/active", "0",
+		"stores", int64(5), "carriers/dhl/active", nil,
+	).WillReturnResult(sqlmock.NewResult(0, 2))
+	m.expectClose()
 
-	assert.NoError(t, sdb.Stop())
+	require.NoError(t, sdb.Close())
 }
 
-func TestDBStorageMultipleStmt_All(t *testing.T) {
+func TestDBStorage_AllKeys(t *testing.T) {
 	t.Parallel()
 
-	dbc, dbMock := cstesting.MockDB(t)
-	defer func() {
-		dbMock.ExpectClose()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
 
-		assert.NoError(t, dbc.Close())
+	m := expectPrepares(dbMock, "")
+	m.expectClose()
+	sdb := ccd.MustNewDBStorage(dbc)
 
-		if err := dbMock.ExpectationsWereMet(); err != nil {
-			t.Error("there were unfulfilled expections", err)
-		}
-	}()
+	m.all.ExpectQuery().WillReturnRows(
+		dmltest.MustMockRows(dmltest.WithFile("testdata", "core_config_data.csv")),
+	)
 
-	sdb := ccd.MustNewDBStorage(dbc.DB)
-	sdb.All.Idle = time.Second * 1
+	allKeys, err := sdb.AllKeys()
+	require.NoError(t, err)
+	assert.Len(t, allKeys, 20)
+	assert.True(t, allKeys.Contains(cfgpath.MustNewByParts("general/region/state_required").BindStore(2)))
 
-	sdb.Start()
+	// all values have been cached by AllKeys
+	v, err := sdb.Get(cfgpath.MustNewByParts("general/region/state_required").BindStore(2))
+	require.NoError(t, err)
+	assert.Exactly(t, "AT", v)
 
-	var prepAll *sqlmock.ExpectedPrepare
-	for i, test := range dbStorageMultiTests {
+	require.NoError(t, sdb.Close())
+}
 
-		if i < 3 {
-			prepAll = dbMock.ExpectPrepare("SELECT scope,scope_id,path FROM `[^`]+` ORDER BY scope,scope_id,path")
-		}
+func TestDBStorage_Query(t *testing.T) {
+	t.Parallel()
 
-		mockRows := sqlmock.NewRows([]string{"scope", "scope_id", "path"})
-		for _, test := range dbStorageMultiTests {
-			mockRows.FromCSVString(fmt.Sprintf("%s,%d,%s", test.key.ScopeID.Type().StrType(), test.key.ScopeID.ID(), test.key.Chars))
-		}
-		prepAll.ExpectQuery().WillReturnRows(mockRows)
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	m := expectPrepares(dbMock, "")
+	m.expectClose()
+	sdb := ccd.MustNewDBStorage(dbc)
+
+	dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(
+		"SELECT `scope`, `scope_id`, `path`, `value` FROM `core_config_data` WHERE (`value` IS NOT NULL) AND (`path` LIKE 'web/%') AND (`scope` IN ('stores'))",
+	)).WillReturnRows(sqlmock.NewRows([]string{"scope", "scope_id", "path", "value"}).
+		AddRow("stores", int64(2), "web/secure/base_url", "https://at.corestore.io/").
+		AddRow("stores", int64(1), "web/unsecure/base_url", "http://de.corestore.io/").
+		AddRow("stores", int64(1), "web/cookie/cookie_path", "/"),
+	)
+
+	kvs, err := config.QueryStorage(sdb, config.Query{Pattern: "web/*/base_url", Scopes: scope.PermStoreReverse})
+	require.NoError(t, err)
+	require.Len(t, kvs, 2)
+	assert.Exactly(t, "stores/1/web/unsecure/base_url", kvs[0].Key.String())
+	assert.Exactly(t, "http://de.corestore.io/", kvs[0].Value)
+	assert.Exactly(t, "stores/2/web/secure/base_url", kvs[1].Key.String())
+
+	// the values have been cached by Query
+	v, err := sdb.Get(cfgpath.MustNewByParts("web/secure/base_url").BindStore(2))
+	require.NoError(t, err)
+	assert.Exactly(t, "https://at.corestore.io/", v)
+
+	require.NoError(t, sdb.Close())
+}
 
-		allKeys, err := sdb.AllKeys()
-		if err != nil {
-			t.Fatal("Index", i, "with error AllKeys:", err)
-		}
+func TestNewDBStorage_Errors(t *testing.T) {
+	t.Parallel()
 
-		assert.True(t, allKeys.Contains(test.key), "Missing Key: %s", test.key)
+	t.Run("invalid table name", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
 
-		if i < 2 {
-			time.Sleep(time.Millisecond * 1500) // trigger ticker to close statements
-		}
-	}
-	assert.NoError(t, sdb.Stop())
+		sdb, err := ccd.NewDBStorage(dbc, ccd.WithTableName("core config"))
+		assert.Nil(t, sdb)
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+
+	t.Run("prepare fails", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlAll)).WillReturnError(errors.ConnectionFailed.Newf("DB away"))
 
+		sdb, err := ccd.NewDBStorage(dbc)
+		assert.Nil(t, sdb)
+		assert.True(t, errors.ConnectionFailed.Match(err), "%+v", err)
+	})
 }
@@ -15,6 +15,14 @@
 // Package ccd = core_config_data uses the MySQL based table core_config_data
 // for reading and writing configuration paths, scopes and values.
 //
+// DBStorage implements config.Storager on top of a dml.ConnPool. It prepares
+// its statements once, caches read values in memory, upserts values via
+// INSERT ... ON DUPLICATE KEY UPDATE and can batch multiple writes into one
+// multi row INSERT.
+//
 // It also provides an option function to load data from core_config_data into
 // a storage service.
+//
+// NewBinlogHandler keeps a config.Service up to date by listening to the
+// binary log replication stream of core_config_data via package binlogsync.
 package ccd
@@ -15,27 +15,35 @@
 package ccd
 
 import (
+	"context"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
 	"github.com/corestoreio/pkg/config"
 	"github.com/corestoreio/pkg/config/cfgpath"
-	"github.com/corestoreio/pkg/storage/dbr"
+	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/store/scope"
-	"github.com/corestoreio/errors"
-	"github.com/corestoreio/log"
 )
 
 // WithCoreConfigData reads the table core_config_data into the Service and
 // overrides existing values. If the column `value` is NULL entry will be
-// ignored. Stops on errors.
-func WithCoreConfigData(dbrSess dbr.SessionRunner) config.Option {
+// ignored. Stops on errors. Argument tableName can be empty and defaults to
+// core_config_data.
+func WithCoreConfigData(db *dml.ConnPool, tableName string) config.Option {
 	return func(s *config.Service) error {
+		if tableName == "" {
+			tableName = TableNameCoreConfigData
+		}
 
 		var ccd TableCoreConfigDataSlice
-		loadedRows, err := TableCollection.MustTable(TableIndexCoreConfigData).Load(dbrSess, &ccd)
+		loadedRows, err := db.SelectFrom(tableName, "main_table").
+			AddColumns("config_id", "scope", "scope_id", "path", "value").
+			WithArgs().Load(context.Background(), &ccd)
 		if s.Log.IsDebug() {
-			s.Log.Debug("ccd.WithCoreConfigData.Load", log.Int("rows", loadedRows), log.Err(err))
+			s.Log.Debug("ccd.WithCoreConfigData.Load", log.Uint64("rows", loadedRows), log.Err(err))
 		}
 		if err != nil {
-			return errors.Wrap(err, "[ccd] WithCoreConfigData.csdb.Load")
+			return errors.Wrap(err, "[ccd] WithCoreConfigData.Load")
 		}
 
 		var writtenRows int
@@ -54,7 +62,7 @@ func WithCoreConfigData(dbrSess dbr.SessionRunner) config.Option {
 			}
 		}
 		if s.Log.IsDebug() {
-			s.Log.Debug("ccd.WithCoreConfigData.Written", log.Int("loadedRows", loadedRows), log.Int("writtenRows", writtenRows))
+			s.Log.Debug("ccd.WithCoreConfigData.Written", log.Uint64("loadedRows", loadedRows), log.Int("writtenRows", writtenRows))
 		}
 		return nil
 	}
@@ -1,17 +1,3 @@
-// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
-//
-// Licensed under the Apache License, Version 2.0 (the "License");
-// you may not use this file except in compliance with the License.
-// You may obtain a copy of the License at
-//
-//     http://www.apache.org/licenses/LICENSE-2.0
-//
-// Unless required by applicable law or agreed to in writing, software
-// distributed under the License is distributed on an "AS IS" BASIS,
-// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
-// See the License for the specific language governing permissions and
-// limitations under the License.
-
 // +build !mage1,!mage2
 
 // Only include this file IF no specific build tag for mage has been set
@@ -21,42 +7,110 @@ package ccd
 // Auto generated via tableToStruct
 
 import (
-	"github.com/corestoreio/pkg/storage/csdb"
-	"github.com/corestoreio/pkg/util/null"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
 )
 
-// TableIndex... is the index to a table. These constants are guaranteed
-// to stay the same for all Magento versions. Please access a table via this
-// constant instead of the raw table name. TableIndex iotas must start with 0.
-const (
-	TableIndexCoreConfigData = iota + 1 // Table: core_config_data
-	TableIndexZZZ                       // the maximum index, which is not available.
+// TableCollection handles all tables and its columns.
+var TableCollection = ddl.MustNewTables(
+	ddl.WithTable(
+		TableNameCoreConfigData,
+		&ddl.Column{Field: `config_id`, ColumnType: `int(10) unsigned`, Null: `NO`, Key: `PRI`, Extra: `auto_increment`},
+		&ddl.Column{Field: `scope`, ColumnType: `varchar(8)`, Null: `NO`, Key: `MUL`, Default: dml.MakeNullString(`default`)},
+		&ddl.Column{Field: `scope_id`, ColumnType: `int(11)`, Null: `NO`, Default: dml.MakeNullString(`0`)},
+		&ddl.Column{Field: `path`, ColumnType: `varchar(255)`, Null: `NO`, Default: dml.MakeNullString(`general`)},
+		&ddl.Column{Field: `value`, ColumnType: `text`, Null: `YES`},
+	),
 )
 
-func init() {
-	TableCollection = csdb.MustNewTables(
-		csdb.WithTable(
-			TableIndexCoreConfigData,
-			"core_config_data",
-			&csdb.Column{Field: (`config_id`), ColumnType: (`int(10) unsigned`), Null: (`NO`), Key: (`PRI`), Extra: (`auto_increment`)},
-			&csdb.Column{Field: (`scope`), ColumnType: (`varchar(8)`), Null: (`NO`), Key: (`MUL`), Default: null.StringFrom(`default`), Extra: (``)},
-			&csdb.Column{Field: (`scope_id`), ColumnType: (`int(11)`), Null: (`NO`), Key: (``), Default: null.StringFrom(`0`), Extra: (``)},
-			&csdb.Column{Field: (`path`), ColumnType: (`varchar(255)`), Null: (`NO`), Key: (``), Default: null.StringFrom(`general`), Extra: (``)},
-			&csdb.Column{Field: (`value`), ColumnType: (`text`), Null: (`YES`), Key: (``), Extra: (``)},
-		),
-	)
-}
-
-// TableCoreConfigDataSlice represents a collection type for DB table core_config_data
+// TableCoreConfigDataSlice represents a collection type for DB table
+// core_config_data. It implements dml.ColumnMapper to load rows and to provide
+// the arguments for a multi row INSERT.
 // Generated via tableToStruct.
 type TableCoreConfigDataSlice []*TableCoreConfigData
 
 // TableCoreConfigData represents a type for DB table core_config_data
 // Generated via tableToStruct.
 type TableCoreConfigData struct {
-	ConfigID int64       `db:"config_id" json:",omitempty"` // config_id int(10) unsigned NOT NULL PRI  auto_increment
-	Scope    string      `db:"scope" json:",omitempty"`     // scope varchar(8) NOT NULL MUL DEFAULT 'default'
-	ScopeID  int64       `db:"scope_id" json:",omitempty"`  // scope_id int(11) NOT NULL  DEFAULT '0'
-	Path     string      `db:"path" json:",omitempty"`      // path varchar(255) NOT NULL  DEFAULT 'general'
-	Value    null.String `db:"value" json:",omitempty"`     // value text NULL
+	ConfigID int64          `json:",omitempty"` // config_id int(10) unsigned NOT NULL PRI  auto_increment
+	Scope    string         `json:",omitempty"` // scope varchar(8) NOT NULL MUL DEFAULT 'default'
+	ScopeID  int64          `json:",omitempty"` // scope_id int(11) NOT NULL  DEFAULT '0'
+	Path     string         `json:",omitempty"` // path varchar(255) NOT NULL  DEFAULT 'general'
+	Value    dml.NullString `json:",omitempty"` // value text NULL
+}
+
+// MapColumns implements interface dml.ColumnMapper.
+func (e *TableCoreConfigData) MapColumns(cm *dml.ColumnMap) error {
+	if cm.Mode() == dml.ColumnMapEntityReadAll {
+		return cm.Int64(&e.ConfigID).String(&e.Scope).Int64(&e.ScopeID).String(&e.Path).NullString(&e.Value).Err()
+	}
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "config_id":
+			cm.Int64(&e.ConfigID)
+		case "scope":
+			cm.String(&e.Scope)
+		case "scope_id":
+			cm.Int64(&e.ScopeID)
+		case "path":
+			cm.String(&e.Path)
+		case "value":
+			cm.NullString(&e.Value)
+		default:
+			return errors.NotFound.Newf("[ccd] TableCoreConfigData Column %q not found", c)
+		}
+	}
+	return cm.Err()
+}
+
+// MapColumns implements interface dml.ColumnMapper. In the read modes all
+// entities get written as arguments, which creates a multi row INSERT.
+func (s *TableCoreConfigDataSlice) MapColumns(cm *dml.ColumnMap) error {
+	switch m := cm.Mode(); m {
+	case dml.ColumnMapEntityReadAll, dml.ColumnMapEntityReadSet:
+		for _, e := range *s {
+			if err := e.MapColumns(cm); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case dml.ColumnMapScan:
+		if cm.Count == 0 {
+			*s = (*s)[:0]
+		}
+		e := new(TableCoreConfigData)
+		if err := e.MapColumns(cm); err != nil {
+			return errors.WithStack(err)
+		}
+		*s = append(*s, e)
+	case dml.ColumnMapCollectionReadSet:
+		for cm.Next() {
+			switch c := cm.Column(); c {
+			case "path":
+				cm.Strings(s.Paths()...)
+			default:
+				return errors.NotFound.Newf("[ccd] TableCoreConfigDataSlice Column %q not found", c)
+			}
+		}
+	default:
+		return errors.NotSupported.Newf("[ccd] Unknown Mode: %q", string(m))
+	}
+	return cm.Err()
+}
+
+// Paths belongs to the column `path` and returns a slice or appends to a slice
+// only unique values of that column. The values will be filtered internally in
+// a Go map. No DB query gets executed.
+func (s TableCoreConfigDataSlice) Paths(ret ...string) []string {
+	if ret == nil {
+		ret = make([]string, 0, len(s))
+	}
+	dupCheck := make(map[string]struct{}, len(s))
+	for _, e := range s {
+		if _, ok := dupCheck[e.Path]; !ok {
+			ret = append(ret, e.Path)
+			dupCheck[e.Path] = struct{}{}
+		}
+	}
+	return ret
 }
@@ -43,12 +43,19 @@ func New(config bigcache.Config) (*Storage, error) {
 }
 
 // Set writes a key with its value into the storage. The value
-// gets converted to a byte slice.
+// gets converted to a byte slice. A nil value deletes the key.
 func (s *Storage) Set(key cfgpath.Path, value interface{}) error {
 	fq, err := key.FQ() // safe path
 	if err != nil {
 		return err
 	}
+	if value == nil {
+		err := s.Cache.Delete(fq.String())
+		if _, isNotFound := (err).(*bigcache.EntryNotFoundError); isNotFound {
+			return nil
+		}
+		return err
+	}
 	b, err := conv.ToByteE(value)
 	if err != nil {
 		return err
@@ -14,7 +14,12 @@
 
 // Package etcd uses etcd service for reading and writing configuration paths.
 //
-// todo(CS): https://github.com/coreos/etcd/tree/master/client
+// The Storage type implements config.Storager on top of the etcd v3 client.
+// The etcd key contains a prefix and the fully qualified path, e.g.
+// corestore/config/stores/2/web/unsecure/base_url. Values get cached locally.
+// Storage.Watch keeps the cache of each process up to date and publishes
+// changes via the config.Service, so config.MessageReceiver subscriptions
+// fire cluster-wide without querying MySQL.
 //
 // Maybe implements synchronization with MySQL core_config_data table.
 package etcd
@@ -0,0 +1,353 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/clientv3"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/util/conv"
+)
+
+// DefaultKeyPrefix gets prepended to each fully qualified path to build the
+// etcd key, e.g. corestore/config/stores/2/web/unsecure/base_url.
+const DefaultKeyPrefix = "corestore/config/"
+
+// Option applies options to the Storage.
+type Option func(*Storage) error
+
+// WithKeyPrefix sets a custom prefix for all keys. Useful if several
+// configurations share the same etcd cluster. The prefix must end with a
+// slash.
+func WithKeyPrefix(prefix string) Option {
+	return func(s *Storage) error {
+		if prefix == "" || !strings.HasSuffix(prefix, "/") {
+			return errors.NotValid.Newf("[etcd] Key prefix %q must end with a slash", prefix)
+		}
+		s.prefix = prefix
+		return nil
+	}
+}
+
+// WithTimeout sets the timeout for each request to etcd. Default five seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Storage) error {
+		s.timeout = d
+		return nil
+	}
+}
+
+// WithLogger sets a custom logger. Default logger is a black hole.
+func WithLogger(l log.Logger) Option {
+	return func(s *Storage) error {
+		s.log = l
+		return nil
+	}
+}
+
+// Storage stores the configuration values in etcd. Implements interface
+// config.Storager. The key of an entry is the key prefix plus the fully
+// qualified path and the value gets converted to a byte slice. Read values get
+// cached locally. Watch keeps the cache up to date with changes of other
+// processes and publishes them to the config.Service. Setting a nil value
+// deletes the path. Storage is safe for concurrent use.
+type Storage struct {
+	log     log.Logger
+	client  *clientv3.Client
+	prefix  string
+	timeout time.Duration
+
+	mu    sync.RWMutex
+	cache map[string][]byte
+	// watching gets set while Watch runs. Then only the watch and Set write
+	// into the cache, because a value loaded by Get before its watch event
+	// arrives would suppress the publishing of the change.
+	watching bool
+}
+
+// New creates a new Storage. The client gets not closed by the Storage.
+func New(c *clientv3.Client, opts ...Option) (*Storage, error) {
+	s := &Storage{
+		log:     log.BlackHole{}, // skip debug and info level via init with empty fields
+		client:  c,
+		prefix:  DefaultKeyPrefix,
+		timeout: 5 * time.Second,
+		cache:   make(map[string][]byte),
+	}
+	for _, opt := range opts {
+		if err := opt(s); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return s, nil
+}
+
+// MustNew same as New but panics on error.
+func MustNew(c *clientv3.Client, opts ...Option) *Storage {
+	s, err := New(c, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func (s *Storage) key(p cfgpath.Path) (string, error) {
+	fq, err := p.FQ()
+	if err != nil {
+		return "", errors.Wrapf(err, "[etcd] FQ Path %q", p)
+	}
+	return s.prefix + fq.String(), nil
+}
+
+// Set implements config.Storager interface. A nil value deletes the path.
+func (s *Storage) Set(p cfgpath.Path, value interface{}) error {
+	key, err := s.key(p)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if value == nil {
+		s.mu.Lock()
+		delete(s.cache, key)
+		s.mu.Unlock()
+		_, err = s.client.Delete(ctx, key)
+		return errors.Wrapf(err, "[etcd] Delete Key %q", key)
+	}
+
+	v, err := conv.ToByteE(value)
+	if err != nil {
+		return errors.Wrapf(err, "[etcd] ToByteE Key %q", key)
+	}
+	// The cache gets updated before the Put, so the watch event of this Put
+	// does not publish the path a second time.
+	s.mu.Lock()
+	s.cache[key] = v
+	s.mu.Unlock()
+	if _, err = s.client.Put(ctx, key, string(v)); err != nil {
+		s.mu.Lock()
+		delete(s.cache, key)
+		s.mu.Unlock()
+		return errors.Wrapf(err, "[etcd] Put Key %q", key)
+	}
+	return nil
+}
+
+// Get implements config.Storager interface and returns a byte slice. A value
+// not in the cache gets loaded from etcd. While Watch runs, the loaded value
+// does not get cached, the watch event adds it. Error behaviour: NotFound.
+func (s *Storage) Get(p cfgpath.Path) (interface{}, error) {
+	key, err := s.key(p)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s.mu.RLock()
+	v, ok := s.cache[key]
+	s.mu.RUnlock()
+	if ok {
+		return v, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	resp, err := s.client.Get(ctx, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[etcd] Get Key %q", key)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, errors.NotFound.Newf("[etcd] Key %q not found", key)
+	}
+	v = resp.Kvs[0].Value
+	s.mu.Lock()
+	if !s.watching {
+		s.cache[key] = v
+	}
+	s.mu.Unlock()
+	return v, nil
+}
+
+// Invalidate implements config.Invalidator interface and removes the path
+// from the cache, so the next Get loads it from etcd.
+func (s *Storage) Invalidate(p cfgpath.Path) error {
+	key, err := s.key(p)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s.mu.Lock()
+	delete(s.cache, key)
+	s.mu.Unlock()
+	return nil
+}
+
+// AllKeys implements config.Storager interface and loads all paths from etcd.
+// Invalid keys get logged and skipped.
+func (s *Storage) AllKeys() (cfgpath.PathSlice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	resp, err := s.client.Get(ctx, s.prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, errors.Wrapf(err, "[etcd] AllKeys Prefix %q", s.prefix)
+	}
+	ps := make(cfgpath.PathSlice, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		p, err := s.splitKey(kv.Key)
+		if err != nil {
+			if s.log.IsInfo() {
+				s.log.Info("etcd.Storage.AllKeys.splitKey", log.Err(err), log.String("key", string(kv.Key)))
+			}
+			continue
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+func (s *Storage) splitKey(key []byte) (cfgpath.Path, error) {
+	p, err := cfgpath.SplitFQ(string(bytes.TrimPrefix(key, []byte(s.prefix))))
+	return p, errors.Wrapf(err, "[etcd] SplitFQ Key %q", key)
+}
+
+// Watch loads all values into the cache and watches afterwards in a new
+// goroutine for changes of other processes. Each change updates the cache and
+// gets published via pub, mostly the *config.Service, so all subscribed
+// config.MessageReceiver of this process get notified. Changes already known
+// to the cache, like the own writes, get not published a second time. The
+// returned error belongs to the initial load. Watch errors, for example due to
+// compaction of the etcd revisions, get passed to the optional errFn and the
+// values get loaded again. The goroutine terminates when ctx gets cancelled.
+//
+//		cfgSrv := config.MustNewService(etcdStorage, config.WithPubSub())
+//		err := etcdStorage.Watch(ctx, cfgSrv, errFn)
+func (s *Storage) Watch(ctx context.Context, pub config.Publisher, errFn func(error)) error {
+	s.setWatching(true)
+	rev, err := s.load(ctx, pub)
+	if err != nil {
+		s.setWatching(false)
+		return errors.WithStack(err)
+	}
+	go func() {
+		defer s.setWatching(false)
+		for {
+			err := s.watch(ctx, pub, rev)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && errFn != nil {
+				errFn(err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			r, err := s.load(ctx, pub)
+			if err != nil {
+				if errFn != nil {
+					errFn(err)
+				}
+				continue
+			}
+			rev = r
+		}
+	}()
+	return nil
+}
+
+func (s *Storage) setWatching(w bool) {
+	s.mu.Lock()
+	s.watching = w
+	s.mu.Unlock()
+}
+
+// load loads all values into the cache and publishes changed and deleted
+// paths. Returns the revision of the etcd cluster.
+func (s *Storage) load(ctx context.Context, pub config.Publisher) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	resp, err := s.client.Get(ctx, s.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, errors.Wrapf(err, "[etcd] Load Prefix %q", s.prefix)
+	}
+	seen := make(map[string]bool, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		seen[string(kv.Key)] = true
+		s.apply(pub, kv.Key, kv.Value, false)
+	}
+
+	// Removes the values deleted while no watch has been running.
+	var deleted []string
+	s.mu.RLock()
+	for k := range s.cache {
+		if !seen[k] {
+			deleted = append(deleted, k)
+		}
+	}
+	s.mu.RUnlock()
+	for _, k := range deleted {
+		s.apply(pub, []byte(k), nil, true)
+	}
+	return resp.Header.Revision, nil
+}
+
+func (s *Storage) watch(ctx context.Context, pub config.Publisher, rev int64) error {
+	wch := s.client.Watch(ctx, s.prefix, clientv3.WithPrefix(), clientv3.WithRev(rev+1))
+	for wr := range wch {
+		if err := wr.Err(); err != nil {
+			return errors.Wrapf(err, "[etcd] Watch Prefix %q", s.prefix)
+		}
+		for _, ev := range wr.Events {
+			s.apply(pub, ev.Kv.Key, ev.Kv.Value, ev.Type == clientv3.EventTypeDelete)
+		}
+	}
+	return nil
+}
+
+// apply writes a changed value into the cache and publishes the path, if the
+// cache contained a different value.
+func (s *Storage) apply(pub config.Publisher, key, value []byte, deleted bool) {
+	k := string(key)
+	s.mu.Lock()
+	old, ok := s.cache[k]
+	changed := ok == deleted || !bytes.Equal(old, value)
+	if deleted {
+		delete(s.cache, k)
+	} else {
+		s.cache[k] = value
+	}
+	s.mu.Unlock()
+	if !changed || pub == nil {
+		return
+	}
+	p, err := s.splitKey(key)
+	if err != nil {
+		if s.log.IsInfo() {
+			s.log.Info("etcd.Storage.Watch.splitKey", log.Err(err), log.String("key", k))
+		}
+		return
+	}
+	if s.log.IsDebug() {
+		s.log.Debug("etcd.Storage.Watch.Publish", log.Stringer("path", p), log.Bool("deleted", deleted))
+	}
+	pub.Publish(p)
+}
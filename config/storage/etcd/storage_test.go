@@ -0,0 +1,181 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package etcd_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/storage/etcd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Storager = (*etcd.Storage)(nil)
+var _ config.Invalidator = (*etcd.Storage)(nil)
+
+type chanReceiver chan cfgpath.Path
+
+func (cr chanReceiver) MessageConfig(p cfgpath.Path) error {
+	cr <- p
+	return nil
+}
+
+func (cr chanReceiver) wait(t *testing.T) cfgpath.Path {
+	select {
+	case p := <-cr:
+		return p
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for a published path")
+	}
+	return cfgpath.Path{}
+}
+
+func TestStorage_SetGet(t *testing.T) {
+	t.Parallel()
+
+	c := newClient(t)
+	defer func() { assert.NoError(t, c.Close()) }()
+	s := etcd.MustNew(c, etcd.WithKeyPrefix("test_setget/"))
+
+	tests := []struct {
+		key       cfgpath.Path
+		value     interface{}
+		wantValue []byte
+	}{
+		{cfgpath.MustNewByParts("web/secure/base_url").BindStore(1), "http://corestore.io", []byte("http://corestore.io")},
+		{cfgpath.MustNewByParts("dev/log/active").BindWebsite(2), 1, []byte("1")},
+		{cfgpath.MustNewByParts("catalog/price/scope"), []byte("website"), []byte("website")},
+	}
+	for i, test := range tests {
+		require.NoError(t, s.Set(test.key, test.value), "Index %d", i)
+	}
+
+	// a new Storage has an empty cache and must read from etcd.
+	s2 := etcd.MustNew(c, etcd.WithKeyPrefix("test_setget/"))
+	for i, test := range tests {
+		v, err := s2.Get(test.key)
+		require.NoError(t, err, "Index %d", i)
+		assert.Exactly(t, test.wantValue, v, "Index %d", i)
+	}
+
+	keys, err := s2.AllKeys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 3)
+
+	t.Run("not found", func(t *testing.T) {
+		v, err := s.Get(cfgpath.MustNewByParts("web/secure/base_url").BindStore(2))
+		assert.Nil(t, v)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+
+	t.Run("nil deletes", func(t *testing.T) {
+		require.NoError(t, s.Set(tests[1].key, nil))
+		v, err := s.Get(tests[1].key)
+		assert.Nil(t, v)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+
+		keys, err := s.AllKeys()
+		require.NoError(t, err)
+		assert.Len(t, keys, 2)
+	})
+}
+
+func TestNew_InvalidPrefix(t *testing.T) {
+	t.Parallel()
+
+	s, err := etcd.New(nil, etcd.WithKeyPrefix("config"))
+	assert.Nil(t, s)
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+}
+
+func TestStorage_Watch(t *testing.T) {
+	t.Parallel()
+
+	const prefix = "test_watch/"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// two nodes with their own etcd client
+	cA, cB := newClient(t), newClient(t)
+	defer func() { assert.NoError(t, cA.Close()) }()
+	defer func() { assert.NoError(t, cB.Close()) }()
+
+	nodeA := etcd.MustNew(cA, etcd.WithKeyPrefix(prefix))
+	srvA := config.MustNewService(nodeA, config.WithPubSub())
+	defer func() { assert.NoError(t, srvA.Close()) }()
+
+	nodeB := etcd.MustNew(cB, etcd.WithKeyPrefix(prefix))
+	srvB := config.MustNewService(nodeB, config.WithPubSub())
+	defer func() { assert.NoError(t, srvB.Close()) }()
+
+	errFn := func(err error) { t.Errorf("%+v", err) }
+	require.NoError(t, nodeA.Watch(ctx, srvA, errFn))
+	require.NoError(t, nodeB.Watch(ctx, srvB, errFn))
+
+	baseURL := cfgpath.MustNewByParts("web/unsecure/base_url").BindStore(3)
+	recvA, recvB := make(chanReceiver, 10), make(chanReceiver, 10)
+	_, err := srvA.Subscribe(baseURL.Route, recvA)
+	require.NoError(t, err)
+	_, err = srvB.Subscribe(baseURL.Route, recvB)
+	require.NoError(t, err)
+
+	t.Run("write on A publishes on B", func(t *testing.T) {
+		require.NoError(t, srvA.Write(baseURL, "https://corestore.io"))
+		assert.Exactly(t, baseURL.String(), recvA.wait(t).String())
+		assert.Exactly(t, baseURL.String(), recvB.wait(t).String())
+
+		v, err := srvB.String(baseURL)
+		require.NoError(t, err)
+		assert.Exactly(t, "https://corestore.io", v)
+	})
+
+	t.Run("delete on B publishes on A", func(t *testing.T) {
+		require.NoError(t, srvB.Write(baseURL, nil))
+		assert.Exactly(t, baseURL.String(), recvB.wait(t).String())
+		assert.Exactly(t, baseURL.String(), recvA.wait(t).String())
+
+		_, err := srvA.String(baseURL)
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+
+	t.Run("Get before the watch event publishes on both", func(t *testing.T) {
+		fq, err := baseURL.FQ()
+		require.NoError(t, err)
+		_, err = cA.Put(ctx, prefix+fq.String(), "https://corestore.de")
+		require.NoError(t, err)
+
+		// Loads the value most likely before the watch event of B arrives.
+		v, err := nodeB.Get(baseURL)
+		require.NoError(t, err)
+		assert.Exactly(t, []byte("https://corestore.de"), v)
+
+		assert.Exactly(t, baseURL.String(), recvA.wait(t).String())
+		assert.Exactly(t, baseURL.String(), recvB.wait(t).String())
+	})
+
+	// Own writes have been published only once.
+	select {
+	case p := <-recvA:
+		t.Errorf("Unexpected message for path %q", p)
+	case p := <-recvB:
+		t.Errorf("Unexpected message for path %q", p)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config/cfgpath"
+)
+
+// Message announces a written path to the other nodes. A node is a process
+// running a Service. Messages transport only the path and never the value,
+// hence all nodes must share the same storage, for example the database. A
+// Storager caching the values of the shared storage must implement
+// Invalidator.
+type Message struct {
+	// NodeID identifies the Service which has written the path.
+	NodeID string
+	// Seq increases with each message of a node. A receiving Service drops
+	// a message if it has already received a message with the same or a
+	// higher Seq for the same node and path. The highest Seq gets remembered
+	// for at least ten minutes.
+	Seq uint64
+	// Path contains the written path including its scope.
+	Path cfgpath.Path
+}
+
+// MarshalText encodes the message into the format "NodeID Seq FQPath".
+func (m Message) MarshalText() ([]byte, error) {
+	fq, err := m.Path.FQ()
+	if err != nil {
+		return nil, errors.Wrapf(err, "[config] Message.MarshalText.FQ Path %q", m.Path)
+	}
+	buf := make([]byte, 0, len(m.NodeID)+len(fq.Chars)+22)
+	buf = append(buf, m.NodeID...)
+	buf = append(buf, ' ')
+	buf = strconv.AppendUint(buf, m.Seq, 10)
+	buf = append(buf, ' ')
+	return append(buf, fq.Chars...), nil
+}
+
+// UnmarshalText decodes the format of MarshalText. Error behaviour: NotValid.
+func (m *Message) UnmarshalText(text []byte) error {
+	parts := strings.SplitN(string(text), " ", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return errors.NotValid.Newf("[config] Message %q has an invalid format", text)
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return errors.NotValid.New(err, "[config] Message %q has an invalid sequence", text)
+	}
+	p, err := cfgpath.SplitFQ(parts[2])
+	if err != nil {
+		return errors.NotValid.New(err, "[config] Message %q has an invalid path", text)
+	}
+	m.NodeID = parts[0]
+	m.Seq = seq
+	m.Path = p
+	return nil
+}
+
+// Transport broadcasts messages between all nodes. Implemented for example
+// in the subpackages of config/transport.
+type Transport interface {
+	// Broadcast sends the message to all nodes. The sending node may receive
+	// its own message.
+	Broadcast(Message) error
+	// Listen receives the messages of all nodes and calls fn for each
+	// message. Listen blocks until ctx gets cancelled or an error occurs.
+	// Calls to fn must be serial and in the order of receiving.
+	Listen(ctx context.Context, fn func(Message)) error
+}
+
+// lastSeqTTL defines how long the highest received Seq of a node and path gets
+// remembered. Messages arrive within seconds, so a duplicate or an overtaken
+// message gets detected long before. A later message gets accepted again,
+// which only causes an additional invalidation.
+const lastSeqTTL = 10 * time.Minute
+
+// nodeSync broadcasts the written paths and filters the received messages.
+type nodeSync struct {
+	Transport
+	nodeID string
+	seq    uint64 // atomic access
+	now    func() time.Time
+	mu     sync.Mutex
+	// lastSeq contains the highest received Seq per node and path. The
+	// key has the format of a Message without the Seq. Entries older than
+	// lastSeqTTL get removed, so paths of stopped nodes do not stay forever.
+	lastSeq   map[string]seqEntry
+	lastSweep time.Time
+}
+
+type seqEntry struct {
+	seq      uint64
+	received time.Time
+}
+
+func newNodeID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	host, _ := os.Hostname()
+	if host == "" || strings.ContainsAny(host, " \t\r\n") {
+		host = "node"
+	}
+	return host + "-" + hex.EncodeToString(b[:])
+}
+
+// WithTransport propagates each Write to all other nodes via Transport t. The
+// path gets sent after the local MessageReceiver have been notified. A node
+// invalidates received paths in its Storager, see Invalidator, and publishes
+// them to its own MessageReceiver, if the pub/sub feature has been enabled,
+// but does not broadcast them again. The own messages get
+// skipped. Duplicates and messages overtaken by a newer message of the same
+// node for the same path get dropped, so the MessageReceiver see the writes
+// of a node to a path in the written order.
+//
+// Limitation: The order is guaranteed only per node and path, not per path.
+// Writes of different nodes to the same path get published in the order the
+// Transport delivers them, which can differ from the order of the writes to the
+// storage. As messages contain only the path, the MessageReceiver always read
+// the current value of the shared storage, so all nodes end up with the value
+// of the last write, but a MessageReceiver cannot rely on seeing the
+// intermediate values.
+//
+// nodeID identifies this Service and must be unique within all nodes. An
+// empty nodeID generates a random ID from the host name. The sequence of the
+// messages starts with the current Unix time in nanoseconds, so a restarted
+// node with the same ID gets not filtered out. Call ListenTransport to
+// receive the messages of the other nodes.
+//
+//		cfgSrv := config.MustNewService(ccdStorage,
+//			config.WithPubSub(),
+//			config.WithTransport(cfgredis.MustNew(pool), ""),
+//		)
+//		err := cfgSrv.ListenTransport(ctx, errFn)
+func WithTransport(t Transport, nodeID string) Option {
+	return func(s *Service) error {
+		if t == nil {
+			return errors.Empty.Newf("[config] WithTransport: Transport cannot be nil")
+		}
+		if nodeID == "" {
+			nodeID = newNodeID()
+		}
+		if strings.ContainsAny(nodeID, " \t\r\n") {
+			return errors.NotValid.Newf("[config] WithTransport: Node ID %q cannot contain white spaces", nodeID)
+		}
+		s.nodes = &nodeSync{
+			Transport: t,
+			nodeID:    nodeID,
+			seq:       uint64(time.Now().UnixNano()),
+			now:       time.Now,
+			lastSeq:   make(map[string]seqEntry),
+		}
+		return nil
+	}
+}
+
+// NodeID returns the ID of this node, or an empty string if no Transport has
+// been set.
+func (s *Service) NodeID() string {
+	if s.nodes == nil {
+		return ""
+	}
+	return s.nodes.nodeID
+}
+
+// broadcast sends the path with the next sequence to all other nodes.
+func (ns *nodeSync) broadcast(p cfgpath.Path) error {
+	return ns.Broadcast(Message{
+		NodeID: ns.nodeID,
+		Seq:    atomic.AddUint64(&ns.seq, 1),
+		Path:   p,
+	})
+}
+
+// accept reports whether a received message must be published. It returns
+// false for the own messages, for duplicates and for outdated messages.
+func (ns *nodeSync) accept(m Message) bool {
+	if m.NodeID == ns.nodeID {
+		return false
+	}
+	key := m.NodeID + " " + m.Path.String()
+	now := ns.now()
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.sweep(now)
+	if last, ok := ns.lastSeq[key]; ok && m.Seq <= last.seq {
+		return false
+	}
+	ns.lastSeq[key] = seqEntry{seq: m.Seq, received: now}
+	return true
+}
+
+// sweep removes the entries older than lastSeqTTL, at most once per
+// lastSeqTTL. It expects a locked mutex.
+func (ns *nodeSync) sweep(now time.Time) {
+	if now.Sub(ns.lastSweep) < lastSeqTTL {
+		return
+	}
+	for key, e := range ns.lastSeq {
+		if now.Sub(e.received) >= lastSeqTTL {
+			delete(ns.lastSeq, key)
+		}
+	}
+	ns.lastSweep = now
+}
+
+// receive invalidates the path in the backend, if the backend implements
+// Invalidator, and publishes a message of another node to the local
+// MessageReceiver.
+func (s *Service) receive(m Message) {
+	if !s.nodes.accept(m) {
+		if s.Log.IsDebug() {
+			s.Log.Debug("config.Service.ListenTransport.drop", log.String("node_id", m.NodeID), log.Uint64("seq", m.Seq), log.Stringer("path", m.Path))
+		}
+		return
+	}
+	if inv, ok := s.backend.(Invalidator); ok {
+		if err := inv.Invalidate(m.Path); err != nil && s.Log.IsInfo() {
+			s.Log.Info("config.Service.ListenTransport.Invalidate", log.Err(err), log.String("node_id", m.NodeID), log.Stringer("path", m.Path))
+		}
+	}
+	s.Publish(m.Path)
+}
+
+// ListenTransport receives in a new goroutine the messages of the other nodes
+// and publishes their paths to the local MessageReceiver. Errors of the
+// Transport get passed to the optional errFn and the Transport gets called
+// again after one second. The goroutine terminates when ctx gets cancelled.
+// Error behaviour: Empty, if option WithTransport has not been applied.
+func (s *Service) ListenTransport(ctx context.Context, errFn func(error)) error {
+	if s.nodes == nil {
+		return errors.Empty.Newf("[config] ListenTransport: Transport not set. Please apply option WithTransport.")
+	}
+	go func() {
+		for {
+			err := s.nodes.Listen(ctx, s.receive)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil && errFn != nil {
+				errFn(errors.Wrap(err, "[config] ListenTransport"))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+	return nil
+}
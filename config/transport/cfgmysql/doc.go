@@ -0,0 +1,43 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgmysql propagates the written configuration paths via a MySQL
+// table to all processes. Use it if no Redis server is available.
+//
+// Each broadcast message gets inserted as a row. Each process polls the table
+// for new rows in an interval, default one second. Rows older than the
+// retention, default one hour, get deleted. The auto increment ID defines the
+// order of the messages. Rows committed out of order get delivered, if their
+// ID is one of the last 100 IDs. The table must be created before:
+//
+//		CREATE TABLE `core_config_pubsub` (
+//		  `message_id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+//		  `node_id` VARCHAR(255) NOT NULL,
+//		  `seq` BIGINT UNSIGNED NOT NULL,
+//		  `path` VARCHAR(511) NOT NULL,
+//		  `created_at` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+//		  PRIMARY KEY (`message_id`),
+//		  KEY `IDX_CORE_CONFIG_PUBSUB_CREATED_AT` (`created_at`)
+//		) ENGINE=InnoDB DEFAULT CHARSET=utf8;
+//
+// Setup of a config.Service:
+//
+//		tp := cfgmysql.MustNew(dbc)
+//		defer tp.Close()
+//		cfgSrv := config.MustNewService(ccdStorage,
+//			config.WithPubSub(),
+//			config.WithTransport(tp, ""),
+//		)
+//		err := cfgSrv.ListenTransport(ctx, errFn)
+package cfgmysql
@@ -0,0 +1,355 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmysql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/dml"
+)
+
+// TableNameConfigPubSub default name of the table for the messages.
+const TableNameConfigPubSub = "core_config_pubsub"
+
+// pollOverlap defines the number of already read IDs which get queried
+// again to find rows committed out of order.
+const pollOverlap = 100
+
+// Option applies options to the Transport.
+type Option func(*Transport) error
+
+// WithTableName sets a custom table name. Useful if a table prefix is in use.
+func WithTableName(name string) Option {
+	return func(t *Transport) error {
+		if err := dml.IsValidIdentifier(name); err != nil {
+			return errors.WithStack(err)
+		}
+		t.tableName = name
+		return nil
+	}
+}
+
+// WithPollInterval sets the interval to query the table for new messages.
+// Default one second.
+func WithPollInterval(d time.Duration) Option {
+	return func(t *Transport) error {
+		if d <= 0 {
+			return errors.NotValid.Newf("[cfgmysql] Poll interval %s must be greater than zero", d)
+		}
+		t.interval = d
+		return nil
+	}
+}
+
+// WithRetention sets the duration after which the messages get deleted.
+// Default one hour. Zero disables the deletion.
+func WithRetention(d time.Duration) Option {
+	return func(t *Transport) error {
+		t.retention = d
+		return nil
+	}
+}
+
+// WithLogger sets a custom logger. Default logger is a black hole.
+func WithLogger(l log.Logger) Option {
+	return func(t *Transport) error {
+		t.log = l
+		return nil
+	}
+}
+
+// Transport implements interface config.Transport via polling a MySQL table.
+// All SQL statements get prepared once.
+type Transport struct {
+	log       log.Logger
+	db        *dml.ConnPool
+	tableName string
+	interval  time.Duration
+	retention time.Duration
+
+	// stmtInsert inserts one message.
+	stmtInsert *dml.Stmt
+	// stmtLastID selects the highest message ID.
+	stmtLastID *dml.Stmt
+	// stmtPoll selects all messages with a higher ID than the argument.
+	stmtPoll *dml.Stmt
+	// stmtCleanup deletes the messages older than the argument.
+	stmtCleanup *dml.Stmt
+
+	// mu protects field resume.
+	mu sync.Mutex
+	// resume contains the state of the last returned Listen call. The next
+	// Listen call continues with it to not lose messages inserted during a
+	// reconnect.
+	resume *poller
+}
+
+// New creates a new Transport and prepares all SQL statements. Don't forget to
+// call Close. The ConnPool gets not closed by the Transport.
+func New(db *dml.ConnPool, opts ...Option) (*Transport, error) {
+	t := &Transport{
+		log:       log.BlackHole{}, // skip debug and info level via init with empty fields
+		db:        db,
+		tableName: TableNameConfigPubSub,
+		interval:  time.Second,
+		retention: time.Hour,
+	}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if err := t.prepare(context.Background()); err != nil {
+		_ = t.Close()
+		return nil, errors.WithStack(err)
+	}
+	return t, nil
+}
+
+// MustNew same as New but panics on error.
+func MustNew(db *dml.ConnPool, opts ...Option) *Transport {
+	t, err := New(db, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t *Transport) prepare(ctx context.Context) (err error) {
+	t.stmtInsert, err = t.db.InsertInto(t.tableName).
+		AddColumns("node_id", "seq", "path").
+		SetRowCount(1).BuildValues().Prepare(ctx)
+	if err != nil {
+		return errors.Wrap(err, "[cfgmysql] Prepare Insert")
+	}
+
+	t.stmtLastID, err = t.db.SelectFrom(t.tableName).AddColumns("message_id").
+		OrderByDesc("message_id").Limit(0, 1).Prepare(ctx)
+	if err != nil {
+		return errors.Wrap(err, "[cfgmysql] Prepare LastID")
+	}
+
+	t.stmtPoll, err = t.db.SelectFrom(t.tableName).AddColumns("message_id", "node_id", "seq", "path").
+		Where(dml.Column("message_id").Greater().PlaceHolder()).
+		OrderBy("message_id").Prepare(ctx)
+	if err != nil {
+		return errors.Wrap(err, "[cfgmysql] Prepare Poll")
+	}
+
+	t.stmtCleanup, err = t.db.DeleteFrom(t.tableName).
+		Where(dml.Column("created_at").Less().PlaceHolder()).Prepare(ctx)
+	return errors.Wrap(err, "[cfgmysql] Prepare Cleanup")
+}
+
+// Close closes the prepared statements. It returns the first occurring error.
+func (t *Transport) Close() error {
+	var firstErr error
+	for _, st := range [...]*dml.Stmt{t.stmtInsert, t.stmtLastID, t.stmtPoll, t.stmtCleanup} {
+		if st == nil {
+			continue
+		}
+		if err := st.Close(); err != nil && firstErr == nil {
+			firstErr = errors.Wrap(err, "[cfgmysql] Close")
+		}
+	}
+	return firstErr
+}
+
+// Broadcast inserts the message into the table. Implements interface
+// config.Transport.
+func (t *Transport) Broadcast(m config.Message) error {
+	fq, err := m.Path.FQ()
+	if err != nil {
+		return errors.Wrapf(err, "[cfgmysql] Broadcast.FQ Path %q", m.Path)
+	}
+	_, err = t.stmtInsert.WithArgs().ExecContext(context.Background(), m.NodeID, m.Seq, fq.String())
+	return errors.Wrapf(err, "[cfgmysql] Broadcast Path %q", fq)
+}
+
+// Listen polls the table in the configured interval and calls fn for each new
+// message. Messages inserted before the first Listen call get skipped. A
+// following Listen call, for example after a connection error, continues with
+// the last read message ID, so no message gets lost during a reconnect.
+// Invalid messages get logged and skipped. Listen returns nil after ctx has
+// been cancelled. Implements interface config.Transport.
+func (t *Transport) Listen(ctx context.Context, fn func(config.Message)) error {
+	pl, err := t.loadPoller(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	defer t.storePoller(pl)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	var lastCleanup time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := pl.poll(ctx, fn); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.WithStack(err)
+		}
+		if t.retention > 0 && time.Since(lastCleanup) > t.retention/2 {
+			lastCleanup = time.Now()
+			t.cleanup(ctx, lastCleanup.Add(-t.retention))
+		}
+	}
+}
+
+// loadPoller returns the state of the previous Listen call. The first call
+// starts after the highest message ID in the table.
+func (t *Transport) loadPoller(ctx context.Context) (*poller, error) {
+	t.mu.Lock()
+	pl := t.resume
+	t.resume = nil
+	t.mu.Unlock()
+	if pl != nil {
+		return pl, nil
+	}
+
+	lastID, _, err := t.stmtLastID.WithArgs().LoadNullUint64(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "[cfgmysql] Listen.LastID")
+	}
+	return &poller{
+		Transport: t,
+		startID:   lastID.Uint64,
+		lastID:    lastID.Uint64,
+		seen:      make(map[uint64]bool),
+	}, nil
+}
+
+func (t *Transport) storePoller(pl *poller) {
+	t.mu.Lock()
+	t.resume = pl
+	t.mu.Unlock()
+}
+
+func (t *Transport) cleanup(ctx context.Context, before time.Time) {
+	res, err := t.stmtCleanup.WithArgs().ExecContext(ctx, before)
+	if err != nil {
+		if t.log.IsInfo() {
+			t.log.Info("cfgmysql.Transport.Listen.Cleanup", log.Err(err), log.String("table", t.tableName))
+		}
+		return
+	}
+	if t.log.IsDebug() {
+		ra, err := res.RowsAffected()
+		t.log.Debug("cfgmysql.Transport.Listen.Cleanup",
+			log.Int64("rows_affected", ra), log.ErrWithKey("rows_affected_error", err),
+			log.String("table", t.tableName))
+	}
+}
+
+// poller contains the state of the Listen calls.
+type poller struct {
+	*Transport
+	// startID highest message ID before the first Listen has been called.
+	startID uint64
+	// lastID highest read message ID.
+	lastID uint64
+	// seen contains the delivered IDs of the overlap.
+	seen map[uint64]bool
+}
+
+func (pl *poller) poll(ctx context.Context, fn func(config.Message)) error {
+	var from uint64
+	if pl.lastID > pollOverlap {
+		from = pl.lastID - pollOverlap
+	}
+	var rows messageRows
+	if _, err := pl.stmtPoll.WithArgs().Load(ctx, &rows, from); err != nil {
+		return errors.Wrap(err, "[cfgmysql] Listen.Poll")
+	}
+
+	for _, r := range rows {
+		if r.id <= pl.startID || pl.seen[r.id] {
+			continue
+		}
+		pl.seen[r.id] = true
+		if r.id > pl.lastID {
+			pl.lastID = r.id
+		}
+		p, err := cfgpath.SplitFQ(r.path)
+		if err != nil || r.nodeID == "" {
+			if pl.log.IsInfo() {
+				pl.log.Info("cfgmysql.Transport.Listen.SplitFQ", log.Err(err), log.Uint64("message_id", r.id), log.String("path", r.path))
+			}
+			continue
+		}
+		fn(config.Message{NodeID: r.nodeID, Seq: r.seq, Path: p})
+	}
+
+	for id := range pl.seen {
+		if id+pollOverlap < pl.lastID {
+			delete(pl.seen, id)
+		}
+	}
+	return nil
+}
+
+type messageRow struct {
+	id     uint64
+	nodeID string
+	seq    uint64
+	path   string
+}
+
+// messageRows implements dml.ColumnMapper to load the polled rows.
+type messageRows []messageRow
+
+// MapColumns implements interface dml.ColumnMapper.
+func (rs *messageRows) MapColumns(cm *dml.ColumnMap) error {
+	if m := cm.Mode(); m != dml.ColumnMapScan {
+		return errors.NotSupported.Newf("[cfgmysql] Unknown Mode: %q", string(m))
+	}
+	if cm.Count == 0 {
+		*rs = (*rs)[:0]
+	}
+	var r messageRow
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "message_id":
+			cm.Uint64(&r.id)
+		case "node_id":
+			cm.String(&r.nodeID)
+		case "seq":
+			cm.Uint64(&r.seq)
+		case "path":
+			cm.String(&r.path)
+		default:
+			return errors.NotFound.Newf("[cfgmysql] Column %q not found", c)
+		}
+	}
+	if err := cm.Err(); err != nil {
+		return errors.WithStack(err)
+	}
+	*rs = append(*rs, r)
+	return nil
+}
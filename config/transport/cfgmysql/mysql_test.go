@@ -0,0 +1,217 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgmysql_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/transport/cfgmysql"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Transport = (*cfgmysql.Transport)(nil)
+
+const (
+	sqlInsert  = "INSERT INTO `core_config_pubsub` (`node_id`,`seq`,`path`) VALUES (?,?,?)"
+	sqlLastID  = "SELECT `message_id` FROM `core_config_pubsub` ORDER BY `message_id` DESC LIMIT 0,1"
+	sqlPoll    = "SELECT `message_id`, `node_id`, `seq`, `path` FROM `core_config_pubsub` WHERE (`message_id` > ?) ORDER BY `message_id`"
+	sqlCleanup = "DELETE FROM `core_config_pubsub` WHERE (`created_at` < ?)"
+)
+
+type transportMocks struct {
+	insert, lastID, poll, cleanup *sqlmock.ExpectedPrepare
+}
+
+func expectPrepares(dbMock sqlmock.Sqlmock) (m transportMocks) {
+	m.insert = dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlInsert))
+	m.lastID = dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlLastID))
+	m.poll = dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlPoll))
+	m.cleanup = dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlCleanup))
+	m.insert.WillBeClosed()
+	m.lastID.WillBeClosed()
+	m.poll.WillBeClosed()
+	m.cleanup.WillBeClosed()
+	return m
+}
+
+func messageRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"message_id", "node_id", "seq", "path"})
+}
+
+func TestTransport_Broadcast(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	m := expectPrepares(dbMock)
+	tp := cfgmysql.MustNew(dbc)
+
+	m.insert.ExpectExec().WithArgs("web-01", int64(7), "stores/2/web/unsecure/base_url").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	require.NoError(t, tp.Broadcast(config.Message{
+		NodeID: "web-01",
+		Seq:    7,
+		Path:   cfgpath.MustNewByParts("web/unsecure/base_url").BindStore(2),
+	}))
+
+	m.insert.ExpectExec().WillReturnError(errors.ConnectionFailed.Newf("DB away"))
+	err := tp.Broadcast(config.Message{NodeID: "web-01", Seq: 8, Path: cfgpath.MustNewByParts("web/unsecure/base_url")})
+	assert.True(t, errors.ConnectionFailed.Match(err), "%+v", err)
+
+	require.NoError(t, tp.Close())
+}
+
+func TestTransport_Listen(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	m := expectPrepares(dbMock)
+	tp := cfgmysql.MustNew(dbc, cfgmysql.WithPollInterval(time.Millisecond))
+
+	m.lastID.ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"message_id"}).AddRow(10))
+	m.poll.ExpectQuery().WithArgs(int64(0)).WillReturnRows(messageRows().
+		AddRow(9, "b", 4, "default/0/web/unsecure/base_url"). // before Listen
+		AddRow(11, "b", 5, "stores/2/web/unsecure/base_url").
+		AddRow(12, "b", 6, "web/unsecure/base_url"). // invalid path
+		AddRow(14, "c", 1, "websites/1/web/secure/base_url"))
+	m.cleanup.ExpectExec().WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 3))
+	m.poll.ExpectQuery().WithArgs(int64(0)).WillReturnRows(messageRows().
+		AddRow(11, "b", 5, "stores/2/web/unsecure/base_url"). // already delivered
+		AddRow(13, "b", 7, "stores/2/web/unsecure/base_url"). // committed out of order
+		AddRow(14, "c", 1, "websites/1/web/secure/base_url").
+		AddRow(15, "c", 2, "default/0/web/cookie/cookie_path"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var got []string
+	err := tp.Listen(ctx, func(m config.Message) {
+		got = append(got, m.NodeID+" "+m.Path.String())
+		if len(got) == 4 {
+			cancel()
+		}
+	})
+	assert.NoError(t, err)
+	assert.Exactly(t, []string{
+		"b stores/2/web/unsecure/base_url",
+		"c websites/1/web/secure/base_url",
+		"b stores/2/web/unsecure/base_url",
+		"c default/0/web/cookie/cookie_path",
+	}, got)
+
+	require.NoError(t, tp.Close())
+}
+
+func TestTransport_Listen_Error(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	m := expectPrepares(dbMock)
+	tp := cfgmysql.MustNew(dbc, cfgmysql.WithPollInterval(time.Millisecond), cfgmysql.WithRetention(0))
+
+	m.lastID.ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"message_id"}))
+	m.poll.ExpectQuery().WithArgs(int64(0)).WillReturnError(errors.ConnectionFailed.Newf("DB away"))
+
+	err := tp.Listen(context.Background(), func(m config.Message) {
+		t.Fatalf("Unexpected message %#v", m)
+	})
+	assert.True(t, errors.ConnectionFailed.Match(err), "%+v", err)
+
+	require.NoError(t, tp.Close())
+}
+
+func TestTransport_Listen_Reconnect(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	m := expectPrepares(dbMock)
+	tp := cfgmysql.MustNew(dbc, cfgmysql.WithPollInterval(time.Millisecond), cfgmysql.WithRetention(0))
+
+	m.lastID.ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"message_id"}).AddRow(10))
+	m.poll.ExpectQuery().WithArgs(int64(0)).WillReturnRows(messageRows().
+		AddRow(11, "b", 5, "stores/2/web/unsecure/base_url"))
+	m.poll.ExpectQuery().WithArgs(int64(0)).WillReturnError(errors.ConnectionFailed.Newf("DB away"))
+	// The second Listen call must not select the highest ID again, otherwise
+	// message 12, inserted during the reconnect, gets lost.
+	m.poll.ExpectQuery().WithArgs(int64(0)).WillReturnRows(messageRows().
+		AddRow(11, "b", 5, "stores/2/web/unsecure/base_url"). // already delivered
+		AddRow(12, "c", 1, "websites/1/web/secure/base_url"))
+
+	var got []string
+	err := tp.Listen(context.Background(), func(m config.Message) {
+		got = append(got, m.NodeID+" "+m.Path.String())
+	})
+	assert.True(t, errors.ConnectionFailed.Match(err), "%+v", err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	err = tp.Listen(ctx, func(m config.Message) {
+		got = append(got, m.NodeID+" "+m.Path.String())
+		cancel()
+	})
+	assert.NoError(t, err)
+	assert.Exactly(t, []string{
+		"b stores/2/web/unsecure/base_url",
+		"c websites/1/web/secure/base_url",
+	}, got)
+
+	require.NoError(t, tp.Close())
+}
+
+func TestNew_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("invalid table name", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		tp, err := cfgmysql.New(dbc, cfgmysql.WithTableName("core config"))
+		assert.Nil(t, tp)
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+
+	t.Run("invalid poll interval", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		tp, err := cfgmysql.New(dbc, cfgmysql.WithPollInterval(0))
+		assert.Nil(t, tp)
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+
+	t.Run("prepare fails", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlInsert)).WillBeClosed()
+		dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(sqlLastID)).WillReturnError(errors.ConnectionFailed.Newf("DB away"))
+
+		tp, err := cfgmysql.New(dbc)
+		assert.Nil(t, tp)
+		assert.True(t, errors.ConnectionFailed.Match(err), "%+v", err)
+	})
+}
@@ -0,0 +1,32 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgredis propagates the written configuration paths via Redis
+// pub/sub to all processes.
+//
+// The Transport publishes each config.Message into one Redis channel and
+// subscribes to the same channel. Redis delivers the messages of a channel in
+// the published order, but only to the currently connected subscribers. A
+// process which loses its connection misses the messages until it has
+// subscribed again.
+//
+//		pool := &redis.Pool{Dial: func() (redis.Conn, error) {
+//			return redis.Dial("tcp", "localhost:6379")
+//		}}
+//		cfgSrv := config.MustNewService(ccdStorage,
+//			config.WithPubSub(),
+//			config.WithTransport(cfgredis.MustNew(pool), ""),
+//		)
+//		err := cfgSrv.ListenTransport(ctx, errFn)
+package cfgredis
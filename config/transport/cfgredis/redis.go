@@ -0,0 +1,150 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgredis
+
+import (
+	"context"
+	"sync"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/config"
+	"github.com/garyburd/redigo/redis"
+)
+
+// DefaultChannel name of the Redis channel for the messages.
+const DefaultChannel = "corestore/config"
+
+// Option applies options to the Transport.
+type Option func(*Transport) error
+
+// WithChannel sets a custom channel name. Useful if several configurations
+// share the same Redis server.
+func WithChannel(name string) Option {
+	return func(t *Transport) error {
+		if name == "" {
+			return errors.Empty.Newf("[cfgredis] Channel name cannot be empty")
+		}
+		t.channel = name
+		return nil
+	}
+}
+
+// WithLogger sets a custom logger. Default logger is a black hole.
+func WithLogger(l log.Logger) Option {
+	return func(t *Transport) error {
+		t.log = l
+		return nil
+	}
+}
+
+// Transport implements interface config.Transport via Redis pub/sub.
+type Transport struct {
+	log     log.Logger
+	pool    *redis.Pool
+	channel string
+}
+
+// New creates a new Transport. Listen uses one connection of the pool for the
+// whole subscription. The pool gets not closed by the Transport.
+func New(pool *redis.Pool, opts ...Option) (*Transport, error) {
+	t := &Transport{
+		log:     log.BlackHole{}, // skip debug and info level via init with empty fields
+		pool:    pool,
+		channel: DefaultChannel,
+	}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return t, nil
+}
+
+// MustNew same as New but panics on error.
+func MustNew(pool *redis.Pool, opts ...Option) *Transport {
+	t, err := New(pool, opts...)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Broadcast publishes the message into the channel. Implements interface
+// config.Transport.
+func (t *Transport) Broadcast(m config.Message) error {
+	data, err := m.MarshalText()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	conn := t.pool.Get()
+	defer conn.Close()
+	_, err = conn.Do("PUBLISH", t.channel, data)
+	return errors.Wrapf(err, "[cfgredis] Publish Channel %q", t.channel)
+}
+
+// Listen subscribes to the channel and calls fn for each message. Invalid
+// messages get logged and skipped. Listen returns nil after ctx has been
+// cancelled. Implements interface config.Transport.
+func (t *Transport) Listen(ctx context.Context, fn func(config.Message)) error {
+	psc := redis.PubSubConn{Conn: t.pool.Get()}
+	defer psc.Close()
+
+	if err := psc.Subscribe(t.channel); err != nil {
+		return errors.Wrapf(err, "[cfgredis] Subscribe Channel %q", t.channel)
+	}
+
+	// A connection supports one concurrent caller of Receive and one of
+	// Send and Flush. Close must wait until the goroutine has terminated.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	defer wg.Wait()
+	defer close(done)
+	go func() {
+		defer wg.Done()
+		select {
+		case <-ctx.Done():
+			// Receive returns the confirmation of the unsubscription.
+			if err := psc.Unsubscribe(t.channel); err != nil && t.log.IsInfo() {
+				t.log.Info("cfgredis.Transport.Listen.Unsubscribe", log.Err(err), log.String("channel", t.channel))
+			}
+		case <-done:
+		}
+	}()
+
+	for {
+		switch v := psc.Receive().(type) {
+		case redis.Message:
+			var m config.Message
+			if err := m.UnmarshalText(v.Data); err != nil {
+				if t.log.IsInfo() {
+					t.log.Info("cfgredis.Transport.Listen.UnmarshalText", log.Err(err), log.String("channel", t.channel))
+				}
+				continue
+			}
+			fn(m)
+		case redis.Subscription:
+			if v.Kind == "unsubscribe" && v.Count == 0 {
+				return nil
+			}
+		case error:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrapf(v, "[cfgredis] Receive Channel %q", t.channel)
+		}
+	}
+}
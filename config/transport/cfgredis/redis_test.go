@@ -0,0 +1,177 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgredis_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/config/transport/cfgredis"
+	"github.com/garyburd/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ config.Transport = (*cfgredis.Transport)(nil)
+
+func newPool(t *testing.T) (*miniredis.Miniredis, *redis.Pool) {
+	mr := miniredis.NewMiniRedis()
+	require.NoError(t, mr.Start())
+	addr := mr.Addr()
+	return mr, &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			return redis.Dial("tcp", addr)
+		},
+	}
+}
+
+// waitForSubscribers blocks until the channel has count subscribers.
+func waitForSubscribers(t *testing.T, mr *miniredis.Miniredis, channel string, count int) {
+	deadline := time.Now().Add(2 * time.Second)
+	for mr.PubSubNumSub(channel)[channel] < count {
+		if time.Now().After(deadline) {
+			t.Fatalf("Channel %q has not %d subscribers", channel, count)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type recorder chan string
+
+func (r recorder) MessageConfig(p cfgpath.Path) error {
+	r <- p.String()
+	return nil
+}
+
+func (r recorder) next(t *testing.T) string {
+	select {
+	case p := <-r:
+		return p
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for message")
+	}
+	return ""
+}
+
+func (r recorder) assertEmpty(t *testing.T) {
+	select {
+	case p := <-r:
+		t.Fatalf("Unexpected message %q", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestTransport_Nodes(t *testing.T) {
+	t.Parallel()
+
+	mr, pool := newPool(t)
+	defer mr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := config.NewInMemoryStore() // shared by both nodes
+	newNode := func(nodeID string) (*config.Service, recorder) {
+		srv := config.MustNewService(storage, config.WithPubSub(), config.WithTransport(cfgredis.MustNew(pool), nodeID))
+		rec := make(recorder, 10)
+		_, err := srv.Subscribe(cfgpath.NewRoute("web"), rec)
+		require.NoError(t, err)
+		require.NoError(t, srv.ListenTransport(ctx, func(err error) { t.Errorf("%+v", err) }))
+		return srv, rec
+	}
+	nodeA, recA := newNode("a")
+	defer func() { assert.NoError(t, nodeA.Close()) }()
+	nodeB, recB := newNode("b")
+	defer func() { assert.NoError(t, nodeB.Close()) }()
+	waitForSubscribers(t, mr, cfgredis.DefaultChannel, 2)
+
+	p1 := cfgpath.MustNewByParts("web/unsecure/base_url").BindStore(2)
+	p2 := cfgpath.MustNewByParts("web/secure/base_url").BindStore(2)
+	require.NoError(t, nodeA.Write(p1, "http://a.io/"))
+	require.NoError(t, nodeA.Write(p2, "https://a.io/"))
+	assert.Exactly(t, p1.String(), recA.next(t))
+	assert.Exactly(t, p2.String(), recA.next(t))
+	assert.Exactly(t, p1.String(), recB.next(t), "from node a")
+	assert.Exactly(t, p2.String(), recB.next(t), "from node a")
+
+	require.NoError(t, nodeB.Write(p1, "http://b.io/"))
+	assert.Exactly(t, p1.String(), recB.next(t))
+	assert.Exactly(t, p1.String(), recA.next(t), "from node b")
+
+	recA.assertEmpty(t)
+	recB.assertEmpty(t)
+
+	v, err := nodeA.String(p1)
+	require.NoError(t, err)
+	assert.Exactly(t, "http://b.io/", v)
+}
+
+func TestTransport_Listen(t *testing.T) {
+	t.Parallel()
+
+	mr, pool := newPool(t)
+	defer mr.Close()
+	tp := cfgredis.MustNew(pool, cfgredis.WithChannel("shop1/config"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	msgs := make(chan config.Message, 10)
+	listenErr := make(chan error)
+	go func() {
+		listenErr <- tp.Listen(ctx, func(m config.Message) { msgs <- m })
+	}()
+	waitForSubscribers(t, mr, "shop1/config", 1)
+
+	mr.Publish("shop1/config", "invalid message")
+	want := config.Message{NodeID: "a", Seq: 7, Path: cfgpath.MustNewByParts("web/unsecure/base_url").BindWebsite(1)}
+	require.NoError(t, tp.Broadcast(want))
+
+	select {
+	case m := <-msgs:
+		assert.Exactly(t, want.NodeID, m.NodeID)
+		assert.Exactly(t, want.Seq, m.Seq)
+		assert.Exactly(t, want.Path.String(), m.Path.String())
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timeout waiting for message")
+	}
+
+	cancel()
+	select {
+	case err := <-listenErr:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Listen does not return after cancel")
+	}
+	assert.Len(t, msgs, 0)
+}
+
+func TestTransport_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := cfgredis.New(nil, cfgredis.WithChannel(""))
+	assert.True(t, errors.Empty.Match(err), "%+v", err)
+
+	mr, pool := newPool(t)
+	tp := cfgredis.MustNew(pool)
+	mr.Close()
+
+	err = tp.Broadcast(config.Message{NodeID: "a", Seq: 1, Path: cfgpath.MustNewByParts("aa/bb/cc")})
+	assert.Error(t, err)
+	assert.Error(t, tp.Listen(context.Background(), func(config.Message) {}))
+}
@@ -0,0 +1,49 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeSync_accept_Eviction(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1500000000, 0)
+	ns := &nodeSync{
+		nodeID:  "a",
+		now:     func() time.Time { return now },
+		lastSeq: make(map[string]seqEntry),
+	}
+	p := cfgpath.MustNewByParts("web/unsecure/base_url").BindStore(2)
+
+	assert.True(t, ns.accept(Message{NodeID: "b", Seq: 5, Path: p}))
+	assert.False(t, ns.accept(Message{NodeID: "b", Seq: 5, Path: p}), "duplicate")
+
+	now = now.Add(lastSeqTTL / 2)
+	assert.True(t, ns.accept(Message{NodeID: "c", Seq: 1, Path: p}))
+	assert.Len(t, ns.lastSeq, 2)
+
+	// Node b has been stopped, its entry gets removed.
+	now = now.Add(lastSeqTTL / 2)
+	assert.True(t, ns.accept(Message{NodeID: "c", Seq: 2, Path: p}))
+	assert.Len(t, ns.lastSeq, 1)
+	assert.Exactly(t, uint64(2), ns.lastSeq["c "+p.String()].seq)
+}
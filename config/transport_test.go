@@ -0,0 +1,246 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// replayTransport delivers its messages once and records the broadcast
+// messages.
+type replayTransport struct {
+	mu         sync.Mutex
+	replay     []config.Message
+	broadcasts []config.Message
+}
+
+func (rt *replayTransport) Broadcast(m config.Message) error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.broadcasts = append(rt.broadcasts, m)
+	return nil
+}
+
+func (rt *replayTransport) Listen(ctx context.Context, fn func(config.Message)) error {
+	for _, m := range rt.replay {
+		fn(m)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func TestMessage_Text(t *testing.T) {
+	t.Parallel()
+
+	m := config.Message{NodeID: "web-01", Seq: 1500000000000000001, Path: cfgpath.MustNewByParts("web/unsecure/base_url").BindStore(3)}
+	text, err := m.MarshalText()
+	require.NoError(t, err)
+	assert.Exactly(t, "web-01 1500000000000000001 stores/3/web/unsecure/base_url", string(text))
+
+	var m2 config.Message
+	require.NoError(t, m2.UnmarshalText(text))
+	assert.Exactly(t, m.NodeID, m2.NodeID)
+	assert.Exactly(t, m.Seq, m2.Seq)
+	assert.Exactly(t, m.Path.String(), m2.Path.String())
+
+	for _, invalid := range []string{
+		"",
+		"web-01 1",
+		" 1 default/0/web/unsecure/base_url",
+		"web-01 x default/0/web/unsecure/base_url",
+		"web-01 1 web/unsecure/base_url",
+	} {
+		err := m2.UnmarshalText([]byte(invalid))
+		assert.True(t, errors.NotValid.Match(err), "%q: %+v", invalid, err)
+	}
+}
+
+func TestWithTransport(t *testing.T) {
+	t.Parallel()
+
+	p1 := cfgpath.MustNewByParts("web/unsecure/base_url").BindStore(2)
+	p2 := cfgpath.MustNewByParts("web/secure/base_url").BindStore(2)
+	rt := &replayTransport{
+		replay: []config.Message{
+			{NodeID: "a", Seq: 1, Path: p1}, // own message
+			{NodeID: "b", Seq: 5, Path: p1},
+			{NodeID: "b", Seq: 5, Path: p1}, // duplicate
+			{NodeID: "b", Seq: 4, Path: p1}, // outdated
+			{NodeID: "b", Seq: 4, Path: p2},
+			{NodeID: "c", Seq: 1, Path: p1},
+		},
+	}
+	srv := config.MustNewService(config.NewInMemoryStore(), config.WithPubSub(), config.WithTransport(rt, "a"))
+	defer func() { assert.NoError(t, srv.Close()) }()
+	assert.Exactly(t, "a", srv.NodeID())
+
+	received := make(chan string, 10)
+	_, err := srv.Subscribe(cfgpath.NewRoute("web"), &testSubscriber{
+		t: t,
+		f: func(p cfgpath.Path) error {
+			received <- p.String()
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, srv.ListenTransport(ctx, nil))
+
+	for _, want := range []cfgpath.Path{p1, p2, p1} {
+		select {
+		case p := <-received:
+			assert.Exactly(t, want.String(), p)
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for message")
+		}
+	}
+
+	require.NoError(t, srv.Write(p1, "http://a.io/"))
+	require.NoError(t, srv.Write(p2, "https://a.io/"))
+	assert.Exactly(t, p1.String(), <-received)
+	assert.Exactly(t, p2.String(), <-received)
+	select {
+	case p := <-received:
+		t.Fatalf("Unexpected message %q", p)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	require.Len(t, rt.broadcasts, 2)
+	assert.Exactly(t, "a", rt.broadcasts[0].NodeID)
+	assert.Exactly(t, p1.String(), rt.broadcasts[0].Path.String())
+	assert.Exactly(t, p2.String(), rt.broadcasts[1].Path.String())
+	assert.Exactly(t, rt.broadcasts[0].Seq+1, rt.broadcasts[1].Seq)
+}
+
+func TestWithTransport_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := config.NewService(config.NewInMemoryStore(), config.WithTransport(nil, ""))
+	assert.True(t, errors.Empty.Match(err), "%+v", err)
+
+	_, err = config.NewService(config.NewInMemoryStore(), config.WithTransport(&replayTransport{}, "web 01"))
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+
+	srv := config.MustNewService(config.NewInMemoryStore(), config.WithTransport(&replayTransport{}, ""))
+	assert.NotEmpty(t, srv.NodeID())
+
+	srv = config.MustNewService(config.NewInMemoryStore())
+	assert.Empty(t, srv.NodeID())
+	err = srv.ListenTransport(context.Background(), nil)
+	assert.True(t, errors.Empty.Match(err), "%+v", err)
+}
+
+// cachingStorage caches the values of a storage shared by all nodes, like
+// ccd.DBStorage caches the database.
+type cachingStorage struct {
+	config.Storager
+	mu    sync.Mutex
+	cache map[string]interface{}
+}
+
+func newCachingStorage(shared config.Storager) *cachingStorage {
+	return &cachingStorage{Storager: shared, cache: make(map[string]interface{})}
+}
+
+func (cs *cachingStorage) Set(p cfgpath.Path, v interface{}) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.cache[p.String()] = v
+	return cs.Storager.Set(p, v)
+}
+
+func (cs *cachingStorage) Get(p cfgpath.Path) (interface{}, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if v, ok := cs.cache[p.String()]; ok {
+		return v, nil
+	}
+	v, err := cs.Storager.Get(p)
+	if err == nil {
+		cs.cache[p.String()] = v
+	}
+	return v, err
+}
+
+func (cs *cachingStorage) Invalidate(p cfgpath.Path) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	delete(cs.cache, p.String())
+	return nil
+}
+
+func TestWithTransport_Invalidate(t *testing.T) {
+	t.Parallel()
+
+	shared := config.NewInMemoryStore() // the database
+	p := cfgpath.MustNewByParts("web/unsecure/base_url").BindStore(2)
+	require.NoError(t, shared.Set(p, "http://old.io/"))
+
+	rtA := &replayTransport{}
+	nodeA := config.MustNewService(newCachingStorage(shared), config.WithTransport(rtA, "a"))
+	defer func() { assert.NoError(t, nodeA.Close()) }()
+
+	rtB := &replayTransport{}
+	nodeB := config.MustNewService(newCachingStorage(shared), config.WithPubSub(), config.WithTransport(rtB, "b"))
+	defer func() { assert.NoError(t, nodeB.Close()) }()
+
+	v, err := nodeB.String(p)
+	require.NoError(t, err)
+	assert.Exactly(t, "http://old.io/", v)
+
+	require.NoError(t, nodeA.Write(p, "http://new.io/"))
+	v, err = nodeB.String(p)
+	require.NoError(t, err)
+	assert.Exactly(t, "http://old.io/", v, "node b reads from its cache")
+
+	rtA.mu.Lock()
+	rtB.replay = rtA.broadcasts
+	rtA.mu.Unlock()
+
+	received := make(chan string, 1)
+	_, err = nodeB.Subscribe(cfgpath.NewRoute("web"), &testSubscriber{
+		t: t,
+		f: func(p cfgpath.Path) error {
+			v, err := nodeB.String(p)
+			received <- v
+			return err
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, nodeB.ListenTransport(ctx, nil))
+
+	select {
+	case v := <-received:
+		assert.Exactly(t, "http://new.io/", v, "subscriber of node b must read the new value")
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for message")
+	}
+}
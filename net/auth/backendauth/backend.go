@@ -16,6 +16,7 @@ package backendauth
 
 import (
 	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/config/cfgsource"
 	"github.com/corestoreio/pkg/config/element"
 	"github.com/corestoreio/pkg/net/auth"
@@ -81,3 +82,12 @@ func New(cfgStruct element.SectionSlice, opts ...cfgmodel.Option) *Configuration
 
 	return be
 }
+
+// Routes returns the routes of all configuration values read by the
+// OptionFactoryFunc. Use them with auth.WithConfigSubscriber() to invalidate
+// the cached scoped configurations after a change.
+func (be *Configuration) Routes() []cfgpath.Route {
+	return []cfgpath.Route{
+		be.Disabled.Route(),
+	}
+}
@@ -19,3 +19,4 @@ package auth
 const errConfigNotFound = `[auth] ScopedConfig for %s not available`
 const errConfigScopeIDNotSet = `[auth] ScopeID not set`
 const errConfigMarkedAsPartiallyLoaded = `[auth] Scoped configuration %s marked as partially loaded.`
+const errConfigInvalidated = `[auth] Scoped configuration %s invalidated by a configuration change.`
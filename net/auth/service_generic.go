@@ -22,6 +22,7 @@ import (
 	"sync"
 
 	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/net/mw"
 	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/pkg/sync/singleflight"
@@ -113,10 +114,69 @@ func (s *Service) Options(opts ...Option) error {
 // must reapply all functional options.
 // TODO(CyS) all previously applied options will be automatically reapplied.
 func (s *Service) ClearCache() error {
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
 	s.scopeCache = make(map[scope.TypeID]*ScopedConfig)
 	return nil
 }
 
+// MessageConfig implements interface config.MessageReceiver. It gets called
+// after a value of a subscribed route has been written, see option
+// WithConfigSubscriber(). It invalidates all cached configurations affected by
+// the scope of the path and the OptionFactoryFunc reloads them with the next
+// request. A store scope affects only the store, a website scope affects the
+// website and all stores and the default scope affects all cached
+// configurations. Options previously applied via source code are kept.
+// Without an OptionFactoryFunc nothing happens because the configuration
+// cannot be reloaded.
+func (s *Service) MessageConfig(p cfgpath.Path) error {
+	s.rwmu.Lock()
+	defer s.rwmu.Unlock()
+
+	if s.optionFactory == nil {
+		if s.Log.IsDebug() {
+			s.Log.Debug("auth.Service.MessageConfig.OptionFactoryNotSet", log.Stringer("path", p))
+		}
+		return nil
+	}
+
+	var invalidated scope.TypeIDs
+	for id, sc := range s.scopeCache {
+		if !isAffectedScope(p.ScopeID, id) {
+			continue
+		}
+		invalidated = append(invalidated, id)
+		if sc == nil || sc.ScopeID != id {
+			// the entry points to the configuration of a parent scope and
+			// gets looked up again.
+			delete(s.scopeCache, id)
+			continue
+		}
+		sc.invalidated = true
+	}
+	if s.Log.IsDebug() {
+		sort.Sort(invalidated)
+		s.Log.Debug("auth.Service.MessageConfig.Invalidated",
+			log.Stringer("path", p),
+			log.Stringer("invalidated_scopes", invalidated),
+		)
+	}
+	return nil
+}
+
+// isAffectedScope reports whether the cached configuration of scope `cached`
+// depends on a value written to scope `changed`. The relation of a store to its
+// website is unknown, so a website change affects all stores.
+func isAffectedScope(changed, cached scope.TypeID) bool {
+	switch changed.Type() {
+	case scope.Default:
+		return true
+	case scope.Website:
+		return cached == changed || cached.Type() == scope.Store
+	}
+	return cached == changed
+}
+
 // DebugCache uses Sprintf to write an ordered list (by scope.TypeID) into a
 // writer. Only usable for debugging.
 func (s *Service) DebugCache(w io.Writer) error {
@@ -201,10 +261,23 @@ func (s *Service) ConfigByScopedGetter(scpGet config.Scoped) (ScopedConfig, erro
 	// returned to all waiting goroutines.
 	if s.optionFactory != nil {
 		res, ok := <-s.optionInflight.DoChan(current.String(), func() (interface{}, error) {
+			// the flag gets cleared before reloading, so that a MessageConfig
+			// received during the reload invalidates the configuration again.
+			s.rwmu.Lock()
+			if sc := s.scopeCache[current]; sc != nil && sc.ScopeID == current {
+				sc.invalidated = false
+			}
+			s.rwmu.Unlock()
 			if err := s.Options(s.optionFactory(scpGet)...); err != nil {
 				return ScopedConfig{}, errors.Wrap(err, "[auth] Options applied by OptionFactoryFunc")
 			}
 			sCfg, err := s.ConfigByScopeID(current, parent)
+			if errors.IsTemporary(err) && sCfg.invalidated {
+				// invalidated during the reload: the waiting requests get the
+				// loaded configuration and the next request reloads it.
+				sCfg.invalidated = false
+				err = sCfg.isValid()
+			}
 			if s.Log.IsDebug() {
 				s.Log.Debug("auth.Service.ConfigByScopedGetter.Inflight.Do",
 					log.ErrWithKey("responded_scope_valid", err),
@@ -16,6 +16,7 @@ package backendcors
 
 import (
 	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/config/cfgsource"
 	"github.com/corestoreio/pkg/config/element"
 	"github.com/corestoreio/pkg/net/cors"
@@ -109,3 +110,19 @@ func New(cfgStruct element.SectionSlice, opts ...cfgmodel.Option) *Configuration
 	be.MaxAge = cfgmodel.NewStr(`net/cors/max_age`, opts...)
 	return be
 }
+
+// Routes returns the routes of all configuration values read by the
+// OptionFactoryFunc. Use them with cors.WithConfigSubscriber() to invalidate
+// the cached scoped configurations after a change.
+func (be *Configuration) Routes() []cfgpath.Route {
+	return []cfgpath.Route{
+		be.ExposedHeaders.Route(),
+		be.AllowedOrigins.Route(),
+		be.AllowOriginRegex.Route(),
+		be.AllowedMethods.Route(),
+		be.AllowedHeaders.Route(),
+		be.AllowCredentials.Route(),
+		be.OptionsPassthrough.Route(),
+		be.MaxAge.Route(),
+	}
+}
@@ -20,7 +20,9 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/corestoreio/pkg/config"
 	"github.com/corestoreio/pkg/config/cfgmock"
 	"github.com/corestoreio/pkg/net/cors"
 	corstest "github.com/corestoreio/pkg/net/cors/internal"
@@ -81,6 +83,36 @@ func TestConfiguration_HierarchicalConfig(t *testing.T) {
 	assert.Exactly(t, []string{"PUT", "DEL", "CUT"}, scpCfg.AllowedMethods)
 }
 
+func TestConfiguration_WithConfigSubscriber(t *testing.T) {
+	cfgSrv := config.MustNewService(config.NewInMemoryStore(), config.WithPubSub())
+	defer func() { assert.NoError(t, cfgSrv.Close()) }()
+
+	pOrigins, err := backend.AllowedOrigins.ToPath(scope.Website.Pack(3))
+	assert.NoError(t, err, "%+v", err)
+	assert.NoError(t, cfgSrv.Write(pOrigins, "x.com\ny.com"))
+
+	srv := cors.MustNew(
+		cors.WithOptionFactory(backend.PrepareOptionFactory()),
+		cors.WithConfigSubscriber(cfgSrv, backend.Routes()...),
+	)
+	scpCfg, err := srv.ConfigByScopedGetter(cfgSrv.NewScoped(3, 0))
+	assert.NoError(t, err, "%+v", err)
+	assert.Exactly(t, []string{`x.com`, `y.com`}, scpCfg.AllowedOrigins)
+
+	assert.NoError(t, cfgSrv.Write(pOrigins, "z.com"))
+	// the message gets delivered asynchronously
+	deadline := time.Now().Add(time.Second)
+	for {
+		scpCfg, err = srv.ConfigByScopedGetter(cfgSrv.NewScoped(3, 0))
+		assert.NoError(t, err, "%+v", err)
+		if len(scpCfg.AllowedOrigins) == 1 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Exactly(t, []string{`z.com`}, scpCfg.AllowedOrigins)
+}
+
 type fataler interface {
 	Fatal(args ...interface{})
 }
@@ -19,3 +19,4 @@ package cors
 const errConfigNotFound = `[cors] ScopedConfig for %s not available`
 const errConfigScopeIDNotSet = `[cors] ScopeID not set`
 const errConfigMarkedAsPartiallyLoaded = `[cors] Scoped configuration %s marked as partially loaded.`
+const errConfigInvalidated = `[cors] Scoped configuration %s invalidated by a configuration change.`
@@ -19,6 +19,7 @@ import (
 	"sync"
 
 	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/net/mw"
 	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/pkg/sync/singleflight"
@@ -188,6 +189,29 @@ func WithOptionFactory(f OptionFactoryFunc) Option {
 	}
 }
 
+// WithConfigSubscriber subscribes the Service to the routes read by the
+// backend package. Writing a value to one of those routes invalidates the
+// cached configurations of the affected scopes, which the OptionFactoryFunc
+// reloads with the next request. See Service.MessageConfig() for details.
+// Only useful in combination with WithOptionFactory().
+//
+//	be := backendcors.New(cfgStruct)
+//
+//	srv := cors.MustNew(
+//		cors.WithOptionFactory(be.PrepareOptionFactory()),
+//		cors.WithConfigSubscriber(cfgSrv, be.Routes()...),
+//	)
+func WithConfigSubscriber(sub config.Subscriber, routes ...cfgpath.Route) Option {
+	return func(s *Service) error {
+		for _, r := range routes {
+			if _, err := sub.Subscribe(r, s); err != nil {
+				return errors.Wrapf(err, "[cors] WithConfigSubscriber.Subscribe Route %q", r)
+			}
+		}
+		return nil
+	}
+}
+
 // NewOptionFactories creates a new struct and initializes the internal map for
 // the registration of different option factories.
 func NewOptionFactories() *OptionFactories {
@@ -16,6 +16,7 @@ package backendgeoip
 
 import (
 	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/config/cfgsource"
 	"github.com/corestoreio/pkg/config/element"
 	"github.com/corestoreio/pkg/net/geoip"
@@ -104,6 +105,23 @@ func New(cfgStruct element.SectionSlice, opts ...cfgmodel.Option) *Configuration
 	return be
 }
 
+// Routes returns the routes of all configuration values read by the
+// OptionFactoryFunc. Use them with geoip.WithConfigSubscriber() to invalidate
+// the cached scoped configurations after a change.
+func (be *Configuration) Routes() []cfgpath.Route {
+	return []cfgpath.Route{
+		be.AllowedCountries.Route(),
+		be.AlternativeRedirect.Route(),
+		be.AlternativeRedirectCode.Route(),
+		be.DataSource.Route(),
+		be.MaxmindLocalFile.Route(),
+		be.MaxmindWebserviceUserID.Route(),
+		be.MaxmindWebserviceLicense.Route(),
+		be.MaxmindWebserviceTimeout.Route(),
+		be.MaxmindWebserviceRedisURL.Route(),
+	}
+}
+
 // Load creates the configuration models for each PkgBackend field. Internal
 // mutex will protect the fields during loading. The argument SectionSlice will
 // be applied to all models.
@@ -19,3 +19,4 @@ package geoip
 const errConfigNotFound = `[geoip] ScopedConfig for %s not available`
 const errConfigScopeIDNotSet = `[geoip] ScopeID not set`
 const errConfigMarkedAsPartiallyLoaded = `[geoip] Scoped configuration %s marked as partially loaded.`
+const errConfigInvalidated = `[geoip] Scoped configuration %s invalidated by a configuration change.`
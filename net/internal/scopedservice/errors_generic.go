@@ -19,3 +19,4 @@ package scopedservice
 const errConfigNotFound = `[scopedservice] ScopedConfig for %s not available`
 const errConfigScopeIDNotSet = `[scopedservice] ScopeID not set`
 const errConfigMarkedAsPartiallyLoaded = `[scopedservice] Scoped configuration %s marked as partially loaded.`
+const errConfigInvalidated = `[scopedservice] Scoped configuration %s invalidated by a configuration change.`
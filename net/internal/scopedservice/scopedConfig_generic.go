@@ -32,8 +32,12 @@ var defaultErrorHandler = mw.ErrorWithStatusCode(http.StatusServiceUnavailable)
 type scopedConfigGeneric struct {
 	// lastErr used during selecting the config from the scopeCache map and
 	// singleflight package.
-	lastErr  error
-	ParentID scope.TypeID
+	lastErr error
+	// invalidated gets set to true once a configuration value of this scope
+	// has been changed. The OptionFactoryFunc reloads the configuration with
+	// the next request.
+	invalidated bool
+	ParentID    scope.TypeID
 	// ScopeID defines the scope to which this configuration is bound to.
 	ScopeID scope.TypeID
 	// Disabled set to true to disable the Service for this scope.
@@ -63,6 +67,8 @@ func (sc *ScopedConfig) isValidPreCheck() (err error) {
 		err = errors.Wrap(sc.lastErr, "[scopedservice] ScopedConfig.isValid has an lastErr")
 	case sc.ScopeID == 0:
 		err = errors.NewNotValidf(errConfigScopeIDNotSet)
+	case sc.invalidated:
+		err = errors.NewTemporaryf(errConfigInvalidated, sc.ScopeID)
 	}
 	return err
 }
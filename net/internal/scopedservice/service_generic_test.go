@@ -27,6 +27,7 @@ import (
 
 	"github.com/corestoreio/pkg/config"
 	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/pkg/util/cstesting"
 	"github.com/corestoreio/errors"
@@ -339,3 +340,106 @@ func TestWithOptionFactory_Error(t *testing.T) {
 	assert.Exactly(t, scope.TypeID(0), scpCfg.ParentID)
 	assert.Exactly(t, ``, scpCfg.string)
 }
+
+type testSubscriber struct {
+	routes []string
+	err    error
+}
+
+func (ts *testSubscriber) Subscribe(r cfgpath.Route, mr config.MessageReceiver) (int, error) {
+	ts.routes = append(ts.routes, r.String())
+	return len(ts.routes), ts.err
+}
+
+func TestService_MessageConfig(t *testing.T) {
+	logBuf := new(log.MutexBuffer)
+	loads := make(map[scope.TypeID]int)
+	var off OptionFactoryFunc = func(sg config.Scoped) []Option {
+		loads[sg.ScopeID()]++
+		return []Option{
+			withString(fmt.Sprintf("%s loaded %d", sg.ScopeID(), loads[sg.ScopeID()]), sg.ScopeIDs()...),
+		}
+	}
+	sub := new(testSubscriber)
+	s := MustNew(
+		WithRootConfig(cfgmock.NewService()),
+		WithOptionFactory(off),
+		withInt(33, scope.DefaultTypeID), // set via code and must be kept
+		WithConfigSubscriber(sub, cfgpath.NewRoute("aa/bb/cc"), cfgpath.NewRoute("aa/bb/dd")),
+		WithDebugLog(logBuf),
+	)
+	assert.Exactly(t, []string{"aa/bb/cc", "aa/bb/dd"}, sub.routes)
+
+	assertConfig := func(websiteID, storeID int64, wantString string) {
+		scpCfg, err := s.ConfigByScopedGetter(cfgmock.NewService().NewScoped(websiteID, storeID))
+		assert.NoError(t, err, "%+v", err)
+		assert.Exactly(t, wantString, scpCfg.string)
+		assert.Exactly(t, 33, scpCfg.int)
+	}
+	pathStore := cfgpath.MustNewByParts("aa/bb/cc").BindStore(1)
+	pathWebsite := cfgpath.MustNewByParts("aa/bb/cc").BindWebsite(2)
+	pathDefault := cfgpath.MustNewByParts("aa/bb/dd")
+
+	assertConfig(2, 1, "Type(Store) ID(1) loaded 1")
+	assertConfig(2, 0, "Type(Website) ID(2) loaded 1")
+	assertConfig(3, 0, "Type(Website) ID(3) loaded 1")
+
+	assert.NoError(t, s.MessageConfig(pathStore))
+	assertConfig(2, 1, "Type(Store) ID(1) loaded 2")
+	assertConfig(2, 0, "Type(Website) ID(2) loaded 1")
+
+	assert.NoError(t, s.MessageConfig(pathWebsite))
+	assertConfig(2, 1, "Type(Store) ID(1) loaded 3")
+	assertConfig(2, 0, "Type(Website) ID(2) loaded 2")
+	assertConfig(3, 0, "Type(Website) ID(3) loaded 1")
+
+	assert.NoError(t, s.MessageConfig(pathDefault))
+	assertConfig(2, 1, "Type(Store) ID(1) loaded 4")
+	assertConfig(2, 0, "Type(Website) ID(2) loaded 3")
+	assertConfig(3, 0, "Type(Website) ID(3) loaded 2")
+
+	assert.Contains(t, logBuf.String(), `scopedservice.Service.MessageConfig.Invalidated`)
+}
+
+func TestService_MessageConfig_DuringLoad(t *testing.T) {
+	pathStore := cfgpath.MustNewByParts("aa/bb/cc").BindStore(1)
+	var s *Service
+	loads := 0
+	var off OptionFactoryFunc = func(sg config.Scoped) []Option {
+		loads++
+		if loads == 2 {
+			// a value gets written while the configuration gets reloaded.
+			assert.NoError(t, s.MessageConfig(pathStore))
+		}
+		return []Option{
+			withString(fmt.Sprintf("loaded %d", loads), sg.ScopeIDs()...),
+		}
+	}
+	s = MustNew(WithRootConfig(cfgmock.NewService()), WithOptionFactory(off))
+
+	assertConfig := func(wantString string) {
+		scpCfg, err := s.ConfigByScopedGetter(cfgmock.NewService().NewScoped(2, 1))
+		assert.NoError(t, err, "%+v", err)
+		assert.Exactly(t, wantString, scpCfg.string)
+	}
+	assertConfig("loaded 1")
+	assert.NoError(t, s.MessageConfig(pathStore))
+	assertConfig("loaded 2")
+	assertConfig("loaded 3")
+	assertConfig("loaded 3")
+}
+
+func TestService_MessageConfig_NoOptionFactory(t *testing.T) {
+	s := MustNew(withString("Gopher", scope.Website.Pack(2)), WithRootConfig(cfgmock.NewService()))
+	assert.NoError(t, s.MessageConfig(cfgpath.MustNewByParts("aa/bb/cc").BindWebsite(2)))
+
+	scpCfg, err := s.ConfigByScopeID(scope.Website.Pack(2), 0)
+	assert.NoError(t, err, "%+v", err)
+	assert.Exactly(t, "Gopher", scpCfg.string)
+}
+
+func TestWithConfigSubscriber_Error(t *testing.T) {
+	sub := &testSubscriber{err: errors.NewEmptyf("Route empty")}
+	_, err := New(WithConfigSubscriber(sub, cfgpath.NewRoute("aa/bb/cc")))
+	assert.True(t, errors.IsEmpty(err), "%+v", err)
+}
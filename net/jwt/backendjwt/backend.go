@@ -16,6 +16,7 @@ package backendjwt
 
 import (
 	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/config/cfgsource"
 	"github.com/corestoreio/pkg/config/element"
 	"github.com/corestoreio/pkg/net/jwt"
@@ -103,3 +104,22 @@ func New(cfgStruct element.SectionSlice, opts ...cfgmodel.Option) *Configuration
 
 	return be
 }
+
+// Routes returns the routes of all configuration values read by the
+// OptionFactoryFunc. Use them with jwt.WithConfigSubscriber() to invalidate
+// the cached scoped configurations after a change.
+func (be *Configuration) Routes() []cfgpath.Route {
+	return []cfgpath.Route{
+		be.Disabled.Route(),
+		be.SigningMethod.Route(),
+		be.Expiration.Route(),
+		be.Skew.Route(),
+		be.SingleTokenUsage.Route(),
+		be.HmacPassword.Route(),
+		be.HmacPasswordPerUser.Route(),
+		be.RSAKey.Route(),
+		be.RSAKeyPassword.Route(),
+		be.ECDSAKey.Route(),
+		be.ECDSAKeyPassword.Route(),
+	}
+}
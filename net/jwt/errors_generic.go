@@ -19,3 +19,4 @@ package jwt
 const errConfigNotFound = `[jwt] ScopedConfig for %s not available`
 const errConfigScopeIDNotSet = `[jwt] ScopeID not set`
 const errConfigMarkedAsPartiallyLoaded = `[jwt] Scoped configuration %s marked as partially loaded.`
+const errConfigInvalidated = `[jwt] Scoped configuration %s invalidated by a configuration change.`
@@ -16,6 +16,7 @@ package backendratelimit
 
 import (
 	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/config/element"
 	"github.com/corestoreio/pkg/net/ratelimit"
 )
@@ -104,3 +105,18 @@ func New(cfgStruct element.SectionSlice, opts ...cfgmodel.Option) *Configuration
 
 	return be
 }
+
+// Routes returns the routes of all configuration values read by the
+// OptionFactoryFunc. Use them with ratelimit.WithConfigSubscriber() to invalidate
+// the cached scoped configurations after a change.
+func (be *Configuration) Routes() []cfgpath.Route {
+	return []cfgpath.Route{
+		be.Disabled.Route(),
+		be.Burst.Route(),
+		be.Requests.Route(),
+		be.Duration.Route(),
+		be.GCRAName.Route(),
+		be.StorageGCRAMaxMemoryKeys.Route(),
+		be.StorageGCRARedis.Route(),
+	}
+}
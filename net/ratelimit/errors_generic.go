@@ -19,3 +19,4 @@ package ratelimit
 const errConfigNotFound = `[ratelimit] ScopedConfig for %s not available`
 const errConfigScopeIDNotSet = `[ratelimit] ScopeID not set`
 const errConfigMarkedAsPartiallyLoaded = `[ratelimit] Scoped configuration %s marked as partially loaded.`
+const errConfigInvalidated = `[ratelimit] Scoped configuration %s invalidated by a configuration change.`
@@ -16,6 +16,7 @@ package backendsigned
 
 import (
 	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/config/element"
 	"github.com/corestoreio/pkg/net/signed"
 )
@@ -101,3 +102,18 @@ func New(cfgStruct element.SectionSlice, opts ...cfgmodel.Option) *Configuration
 
 	return be
 }
+
+// Routes returns the routes of all configuration values read by the
+// OptionFactoryFunc. Use them with signed.WithConfigSubscriber() to invalidate
+// the cached scoped configurations after a change.
+func (be *Configuration) Routes() []cfgpath.Route {
+	return []cfgpath.Route{
+		be.Disabled.Route(),
+		be.InTrailer.Route(),
+		be.AllowedMethods.Route(),
+		be.Key.Route(),
+		be.Algorithm.Route(),
+		be.HTTPHeaderType.Route(),
+		be.KeyID.Route(),
+	}
+}
@@ -19,3 +19,4 @@ package signed
 const errConfigNotFound = `[signed] ScopedConfig for %s not available`
 const errConfigScopeIDNotSet = `[signed] ScopeID not set`
 const errConfigMarkedAsPartiallyLoaded = `[signed] Scoped configuration %s marked as partially loaded.`
+const errConfigInvalidated = `[signed] Scoped configuration %s invalidated by a configuration change.`
@@ -4,22 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"net"
-	"regexp"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/corestoreio/pkg/config"
-	"github.com/corestoreio/pkg/config/cfgmodel"
 	"github.com/corestoreio/pkg/sql/ddl"
 	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/sql/myreplicator"
-	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/pkg/sync/singleflight"
 	"github.com/corestoreio/pkg/util/conv"
 	"github.com/corestoreio/errors"
 	"github.com/corestoreio/log"
 	"github.com/go-sql-driver/mysql"
+	gomysql "github.com/siddontang/go-mysql/mysql"
 )
 
 // Use flavor for different MySQL versions,
@@ -30,25 +28,32 @@ const (
 
 // Canal can sync your MySQL data. MySQL must use the binlog format ROW.
 type Canal struct {
-	// BackendPosition initial idea. writing supported but not loading
-	BackendPosition cfgmodel.Str
-
 	// mclose acts only during the call to Close().
 	mclose sync.Mutex
 	// DSN contains the parsed DSN
 	DSN         *mysql.Config
 	canalParams map[string]string
 
-	cfgw config.Writer
+	// checkpoint persists the master status after all RowsEventHandler have
+	// successfully completed. Can be nil.
+	checkpoint Checkpointer
 
 	masterMu           sync.RWMutex
 	masterStatus       ddl.MasterStatus
 	masterLastSaveTime time.Time
-
-	// expAlterTable defines the regex to be used to detect ALTER TABLE
-	// statements to reinitialize the internal table structure cache.
-	expAlterTable *regexp.Regexp
-	syncer        *myreplicator.BinlogSyncer
+	// masterGTIDSet contains the executed GTIDs, if GTIDs are in use. Gets
+	// updated once a transaction has been committed.
+	masterGTIDSet gomysql.GTIDSet
+	// pendingGTIDs contains the GTID of the currently running transaction and
+	// the GTIDs of previous transactions whose RowsEventHandler failed to
+	// complete. They get added to masterGTIDSet with the next successful
+	// commit.
+	pendingGTIDs []string
+	// resumeGTID if true starts the syncer from masterGTIDSet instead of the
+	// file and position.
+	resumeGTID bool
+
+	syncer *myreplicator.BinlogSyncer
 
 	rsMu       sync.RWMutex
 	rsHandlers []RowsEventHandler
@@ -98,11 +103,23 @@ func WithDB(db *sql.DB) Option {
 	}
 }
 
-// WithConfigurationWriter used to persists the current binlog position.
+// WithConfigurationWriter used to persists the current binlog position. If w
+// implements also the config.Getter interface, the canal resumes from the
+// stored position.
 func WithConfigurationWriter(w config.Writer) Option {
-	//Write(p cfgpath.Path, value interface{}) error
 	return func(c *Canal) error {
-		c.cfgw = w
+		g, _ := w.(config.Getter)
+		c.checkpoint = NewConfigCheckpoint(w, g)
+		return nil
+	}
+}
+
+// WithCheckpointer sets a custom storage to persist the binlog position. The
+// canal resumes during initialization from the loaded checkpoint. DSN
+// parameters BinlogStartFile and BinlogStartPosition have a higher precedence.
+func WithCheckpointer(cp Checkpointer) Option {
+	return func(c *Canal) error {
+		c.checkpoint = cp
 		return nil
 	}
 }
@@ -119,14 +136,40 @@ func withUpdateBinlogStart(c *Canal) error {
 
 	c.masterStatus = ms
 
+	if c.checkpoint != nil {
+		cp, err := c.checkpoint.LoadCheckpoint(ctx)
+		switch {
+		case err == nil:
+			c.masterStatus.File = cp.File
+			c.masterStatus.Position = cp.Position
+			c.masterStatus.ExecutedGTIDSet = cp.ExecutedGTIDSet
+			c.resumeGTID = cp.ExecutedGTIDSet != ""
+			if c.Log.IsInfo() {
+				c.Log.Info("[binlogsync] Resuming from checkpoint", log.Stringer("position", cp), log.String("gtid_set", cp.ExecutedGTIDSet))
+			}
+		case errors.NotFound.Match(err):
+			// start from the current master status
+		default:
+			return errors.Wrap(err, "[binlogsync] LoadCheckpoint")
+		}
+	}
+
 	if v, ok := c.canalParams["BinlogStartFile"]; ok && v != "" {
 		c.masterStatus.File = v
+		c.resumeGTID = false
 	}
 	if v, ok := c.canalParams["BinlogStartPosition"]; ok && v != "" {
 		if hasPos := conv.ToUint(v); hasPos >= 4 {
 			c.masterStatus.Position = hasPos
+			c.resumeGTID = false
 		}
 	}
+
+	gs, err := parseGTIDSet(c.flavor(), c.masterStatus.ExecutedGTIDSet)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.masterGTIDSet = gs
 	return nil
 }
 
@@ -177,9 +220,6 @@ func NewCanal(dsn *mysql.Config, db Option, opts ...Option) (*Canal, error) {
 	c.DSN = dsn
 	c.closed = new(int32)
 	atomic.StoreInt32(c.closed, 0)
-	c.expAlterTable = regexp.MustCompile("(?i)^ALTER\\sTABLE\\s.*?`{0,1}(.*?)`{0,1}\\.{0,1}`{0,1}([^`\\.]+?)`{0,1}\\s.*")
-
-	c.BackendPosition = cfgmodel.NewStr("sql/binlogsync/position")
 
 	// remove custom parameters from DSN and copy them into our own map because
 	// otherwise MySQL connection fails due to unknown connection parameters.
@@ -212,26 +252,27 @@ func NewCanal(dsn *mysql.Config, db Option, opts ...Option) (*Canal, error) {
 	return c, nil
 }
 
-func (c *Canal) masterSave() error {
+// masterSave persists the current master status via the Checkpointer. Saving
+// gets throttled to once per second unless force is true.
+func (c *Canal) masterSave(ctx context.Context, force bool) error {
+	c.masterMu.Lock()
+	defer c.masterMu.Unlock()
 
 	n := time.Now()
-	if n.Sub(c.masterLastSaveTime) < time.Second {
+	if !force && n.Sub(c.masterLastSaveTime) < time.Second {
 		return nil
 	}
-	c.masterMu.Lock()
-	defer c.masterMu.Unlock()
 
-	if c.cfgw == nil {
+	if c.checkpoint == nil {
 		if c.Log.IsDebug() {
-			c.Log.Debug("[binlogsync] Master Status cannot be saved because config.Writer is nil",
+			c.Log.Debug("[binlogsync] Master Status cannot be saved because Checkpointer is nil",
 				log.String("database", c.DSN.DBName), log.Stringer("master_status", c.masterStatus))
 		}
 		return nil
 	}
 
-	// todo refactor to find a different way by not importing package config and scope
-	if err := c.BackendPosition.Write(c.cfgw, c.masterStatus.String(), scope.DefaultTypeID); err != nil {
-		return errors.Wrap(err, "[binlogsync] failed to write into config")
+	if err := c.checkpoint.SaveCheckpoint(ctx, c.masterStatus); err != nil {
+		return errors.Wrap(err, "[binlogsync] failed to save checkpoint")
 	}
 
 	c.masterLastSaveTime = n
@@ -246,6 +287,37 @@ func (c *Canal) masterUpdate(fileName string, pos uint) {
 	c.masterStatus.Position = pos
 }
 
+// masterBeginGTID remembers the GTID of a transaction which has just started.
+func (c *Canal) masterBeginGTID(gtid string) {
+	c.masterMu.Lock()
+	defer c.masterMu.Unlock()
+	c.pendingGTIDs = append(c.pendingGTIDs, gtid)
+}
+
+// masterCommitGTID adds the pending GTIDs to the executed GTID set.
+func (c *Canal) masterCommitGTID() error {
+	c.masterMu.Lock()
+	defer c.masterMu.Unlock()
+
+	if len(c.pendingGTIDs) == 0 {
+		return nil
+	}
+	for _, gtid := range c.pendingGTIDs {
+		if c.masterGTIDSet == nil {
+			gs, err := gomysql.ParseGTIDSet(c.flavor(), gtid)
+			if err != nil {
+				return errors.NotValid.New(err, "[binlogsync] Failed to parse GTID %q", gtid)
+			}
+			c.masterGTIDSet = gs
+		} else if err := c.masterGTIDSet.Update(gtid); err != nil {
+			return errors.NotValid.New(err, "[binlogsync] Failed to update GTID set with %q", gtid)
+		}
+	}
+	c.pendingGTIDs = c.pendingGTIDs[:0]
+	c.masterStatus.ExecutedGTIDSet = c.masterGTIDSet.String()
+	return nil
+}
+
 // SyncedPosition returns the current synced position as retrieved from the SQl
 // server.
 func (c *Canal) SyncedPosition() ddl.MasterStatus {
@@ -296,18 +368,23 @@ func (c *Canal) Close() error {
 		c.syncer.Close()
 		c.syncer = nil
 	}
+	c.wg.Wait()
+
+	if err := c.masterSave(context.Background(), true); err != nil {
+		c.Log.Info("[binlogsync] Close: Failed to save master position", log.Err(err), log.Stringer("position", c.SyncedPosition()))
+	}
 
 	if err := c.db.Close(); err != nil {
 		return errors.Wrap(err, "[binlogsync] DB close error")
 	}
-	c.wg.Wait()
 	return nil
 }
 
 // FindTable tries to find a table by its ID. If the table cannot be found by
 // the first search, it will add the table to the internal map and performs a
 // column load from the information_schema and then returns the fully defined
-// table.
+// table. A NotFound error gets returned if the table does not exist in the
+// database, in that case the table does not get cached.
 func (c *Canal) FindTable(ctx context.Context, tableName string) (ddl.Table, error) {
 	// deference the table pointer to avoid race conditions and devs modifying the
 	// table ;-)
@@ -328,6 +405,12 @@ func (c *Canal) FindTable(ctx context.Context, tableName string) (ddl.Table, err
 		if err != nil {
 			return ddl.Table{}, errors.Wrapf(err, "[binlogsync] FindTable.Table2 error")
 		}
+		// WithTableLoadColumns caches a table without columns if the table
+		// does not exist, for example because it has already been dropped.
+		if len(t.Columns) == 0 {
+			c.tables.DeleteFromCache(tableName)
+			return ddl.Table{}, errors.NotFound.Newf("[binlogsync] FindTable: Table %q.%q does not exist", c.tables.Schema, tableName)
+		}
 		return *t, nil
 	})
 
@@ -338,14 +421,14 @@ func (c *Canal) FindTable(ctx context.Context, tableName string) (ddl.Table, err
 	return val.(ddl.Table), nil
 }
 
-// ClearTableCache clear table cache
+// ClearTableCache removes the table structure from the internal cache. The
+// next call to FindTable loads the table again from the information_schema.
+// Tables of other databases than the one in the DSN are not cached.
 func (c *Canal) ClearTableCache(db string, table string) {
-	// TODO implement
-	// c.tables.DeleteAllFromCache()
-	//key := fmt.Sprintf("%s.%s", db, table)
-	//c.tableLock.Lock()
-	//delete(c.tables, key)
-	//c.tableLock.Unlock()
+	if db != c.DSN.DBName {
+		return
+	}
+	c.tables.DeleteFromCache(table)
 }
 
 // CheckBinlogRowImage checks MySQL binlog row image, must be in FULL, MINIMAL, NOBLOB
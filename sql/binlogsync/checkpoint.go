@@ -0,0 +1,277 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgmodel"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store/scope"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+)
+
+// Checkpointer persists the binlog position up to which all registered
+// RowsEventHandler have successfully completed. The Canal loads the checkpoint
+// during initialization and resumes from it after a restart. Implementations
+// must be safe for concurrent use.
+type Checkpointer interface {
+	// SaveCheckpoint stores the file, position and, if available, the executed
+	// GTID set of the master status.
+	SaveCheckpoint(ctx context.Context, ms ddl.MasterStatus) error
+	// LoadCheckpoint returns the last saved master status. It must return an
+	// error with behaviour NotFound if no checkpoint has been saved yet.
+	LoadCheckpoint(ctx context.Context) (ddl.MasterStatus, error)
+}
+
+// FileCheckpoint stores the checkpoint in a file on the local file system.
+// The first line contains the file name and the position separated by a
+// semi-colon, the optional second line contains the executed GTID set.
+type FileCheckpoint struct {
+	Path string
+	// Perm defines the file permissions, defaults to 0600.
+	Perm os.FileMode
+}
+
+// NewFileCheckpoint creates a new file based checkpointer.
+func NewFileCheckpoint(path string) *FileCheckpoint {
+	return &FileCheckpoint{
+		Path: path,
+		Perm: 0600,
+	}
+}
+
+// SaveCheckpoint writes the master status first into a temporary file and
+// renames it afterwards to avoid corrupted checkpoints.
+func (fc *FileCheckpoint) SaveCheckpoint(_ context.Context, ms ddl.MasterStatus) error {
+	var buf bytes.Buffer
+	buf.WriteString(ms.String())
+	buf.WriteByte('\n')
+	if ms.ExecutedGTIDSet != "" {
+		buf.WriteString(ms.ExecutedGTIDSet)
+		buf.WriteByte('\n')
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(fc.Path), filepath.Base(fc.Path)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "[binlogsync] FileCheckpoint.TempFile %q", fc.Path)
+	}
+	tmpName := f.Name()
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		os.Remove(tmpName)
+		return errors.Wrapf(err, "[binlogsync] FileCheckpoint.Write %q", tmpName)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpName)
+		return errors.Wrapf(err, "[binlogsync] FileCheckpoint.Sync %q", tmpName)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpName)
+		return errors.Wrapf(err, "[binlogsync] FileCheckpoint.Close %q", tmpName)
+	}
+	if err := os.Chmod(tmpName, fc.Perm); err != nil {
+		os.Remove(tmpName)
+		return errors.Wrapf(err, "[binlogsync] FileCheckpoint.Chmod %q", tmpName)
+	}
+	return errors.Wrapf(os.Rename(tmpName, fc.Path), "[binlogsync] FileCheckpoint.Rename %q", fc.Path)
+}
+
+// LoadCheckpoint reads the checkpoint file.
+func (fc *FileCheckpoint) LoadCheckpoint(_ context.Context) (ms ddl.MasterStatus, err error) {
+	data, err := ioutil.ReadFile(fc.Path)
+	switch {
+	case os.IsNotExist(err):
+		return ms, errors.NotFound.Newf("[binlogsync] FileCheckpoint %q does not exists", fc.Path)
+	case err != nil:
+		return ms, errors.Wrapf(err, "[binlogsync] FileCheckpoint.ReadFile %q", fc.Path)
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	if !sc.Scan() || strings.TrimSpace(sc.Text()) == "" {
+		return ms, errors.NotFound.Newf("[binlogsync] FileCheckpoint %q is empty", fc.Path)
+	}
+	if err := ms.FromString(strings.TrimSpace(sc.Text())); err != nil {
+		return ms, errors.Wrapf(err, "[binlogsync] FileCheckpoint %q", fc.Path)
+	}
+	if sc.Scan() {
+		ms.ExecutedGTIDSet = strings.TrimSpace(sc.Text())
+	}
+	return ms, errors.WithStack(sc.Err())
+}
+
+// DBCheckpoint stores the checkpoint in a MySQL table. Multiple canals can
+// share the same table when they use a different Name.
+type DBCheckpoint struct {
+	DB interface {
+		dml.Execer
+		dml.Querier
+	}
+	// TableName defaults to `binlogsync_checkpoint`.
+	TableName string
+	// Name identifies the row of the canal, defaults to `default`.
+	Name string
+}
+
+// NewDBCheckpoint creates a new database table based checkpointer.
+func NewDBCheckpoint(db *sql.DB, name string) *DBCheckpoint {
+	if name == "" {
+		name = "default"
+	}
+	return &DBCheckpoint{
+		DB:        db,
+		TableName: "binlogsync_checkpoint",
+		Name:      name,
+	}
+}
+
+// CreateTable creates the checkpoint table if it does not yet exists.
+func (dc *DBCheckpoint) CreateTable(ctx context.Context) error {
+	_, err := dc.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+dml.Quoter.Name(dc.TableName)+` (
+  `+"`name`"+` VARCHAR(64) NOT NULL,
+  `+"`file`"+` VARCHAR(255) NOT NULL,
+  `+"`position`"+` INT UNSIGNED NOT NULL,
+  `+"`gtid_set`"+` TEXT NULL,
+  `+"`updated_at`"+` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (`+"`name`"+`)
+) ENGINE=InnoDB`)
+	return errors.Wrapf(err, "[binlogsync] DBCheckpoint.CreateTable %q", dc.TableName)
+}
+
+// SaveCheckpoint upserts the master status.
+func (dc *DBCheckpoint) SaveCheckpoint(ctx context.Context, ms ddl.MasterStatus) error {
+	_, err := dc.DB.ExecContext(ctx, "INSERT INTO "+dml.Quoter.Name(dc.TableName)+
+		" (`name`,`file`,`position`,`gtid_set`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE"+
+		" `file`=VALUES(`file`), `position`=VALUES(`position`), `gtid_set`=VALUES(`gtid_set`)",
+		dc.Name, ms.File, uint64(ms.Position), ms.ExecutedGTIDSet)
+	return errors.Wrapf(err, "[binlogsync] DBCheckpoint.SaveCheckpoint %q", dc.Name)
+}
+
+// LoadCheckpoint selects the master status.
+func (dc *DBCheckpoint) LoadCheckpoint(ctx context.Context) (ms ddl.MasterStatus, err error) {
+	rows, err := dc.DB.QueryContext(ctx, "SELECT `file`,`position`,`gtid_set` FROM "+dml.Quoter.Name(dc.TableName)+
+		" WHERE `name` = ?", dc.Name)
+	if err != nil {
+		return ms, errors.Wrapf(err, "[binlogsync] DBCheckpoint.LoadCheckpoint %q", dc.Name)
+	}
+	defer func() {
+		if err2 := rows.Close(); err2 != nil && err == nil {
+			err = errors.WithStack(err2)
+		}
+	}()
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return ms, errors.WithStack(err)
+		}
+		return ms, errors.NotFound.Newf("[binlogsync] DBCheckpoint %q not found", dc.Name)
+	}
+	var pos uint64
+	var gtid sql.NullString
+	if err = rows.Scan(&ms.File, &pos, &gtid); err != nil {
+		return ms, errors.WithStack(err)
+	}
+	ms.Position = uint(pos)
+	ms.ExecutedGTIDSet = gtid.String
+	return ms, nil
+}
+
+// ConfigCheckpoint stores the checkpoint in the default scope of the
+// configuration service.
+type ConfigCheckpoint struct {
+	// Position stores the file name and the position, separated by a
+	// semi-colon. Path: sql/binlogsync/position
+	Position cfgmodel.Str
+	// GTIDSet stores the executed GTID set. Path: sql/binlogsync/gtid_set
+	GTIDSet cfgmodel.Str
+	Writer  config.Writer
+	// Getter can be nil, then loading the checkpoint returns a NotFound error.
+	Getter config.Getter
+}
+
+// NewConfigCheckpoint creates a new configuration based checkpointer.
+func NewConfigCheckpoint(w config.Writer, g config.Getter) *ConfigCheckpoint {
+	return &ConfigCheckpoint{
+		Position: cfgmodel.NewStr("sql/binlogsync/position"),
+		GTIDSet:  cfgmodel.NewStr("sql/binlogsync/gtid_set"),
+		Writer:   w,
+		Getter:   g,
+	}
+}
+
+// SaveCheckpoint writes the master status into the configuration.
+func (cc *ConfigCheckpoint) SaveCheckpoint(_ context.Context, ms ddl.MasterStatus) error {
+	if err := cc.Position.Write(cc.Writer, ms.String(), scope.DefaultTypeID); err != nil {
+		return errors.Wrap(err, "[binlogsync] ConfigCheckpoint failed to write position")
+	}
+	if err := cc.GTIDSet.Write(cc.Writer, ms.ExecutedGTIDSet, scope.DefaultTypeID); err != nil {
+		return errors.Wrap(err, "[binlogsync] ConfigCheckpoint failed to write GTID set")
+	}
+	return nil
+}
+
+// LoadCheckpoint reads the master status from the configuration.
+func (cc *ConfigCheckpoint) LoadCheckpoint(_ context.Context) (ms ddl.MasterStatus, err error) {
+	if cc.Getter == nil {
+		return ms, errors.NotFound.Newf("[binlogsync] ConfigCheckpoint has no config.Getter")
+	}
+	sg := config.NewScoped(cc.Getter, 0, 0)
+	pos, err := cc.Position.Get(sg)
+	if err != nil {
+		return ms, errors.Wrap(err, "[binlogsync] ConfigCheckpoint failed to read position")
+	}
+	if pos == "" {
+		return ms, errors.NotFound.Newf("[binlogsync] ConfigCheckpoint position not found")
+	}
+	if err := ms.FromString(pos); err != nil {
+		return ms, errors.Wrap(err, "[binlogsync] ConfigCheckpoint")
+	}
+	if ms.ExecutedGTIDSet, err = cc.GTIDSet.Get(sg); err != nil {
+		return ms, errors.Wrap(err, "[binlogsync] ConfigCheckpoint failed to read GTID set")
+	}
+	return ms, nil
+}
+
+// parseGTIDSet parses the executed GTID set for the given flavor. An empty set
+// returns nil.
+func parseGTIDSet(flavor, set string) (gomysql.GTIDSet, error) {
+	if set = strings.TrimSpace(set); set == "" {
+		return nil, nil
+	}
+	gs, err := gomysql.ParseGTIDSet(flavor, set)
+	if err != nil {
+		return nil, errors.NotValid.New(err, "[binlogsync] Failed to parse %s GTID set %q", flavor, set)
+	}
+	return gs, nil
+}
+
+// formatMySQLGTID creates the textual UUID:GNO representation of a GTID event.
+func formatMySQLGTID(sid []byte, gno int64) string {
+	if len(sid) != 16 {
+		return ""
+	}
+	return fmt.Sprintf("%x-%x-%x-%x-%x:%d", sid[0:4], sid[4:6], sid[6:8], sid[8:10], sid[10:16], gno)
+}
@@ -0,0 +1,193 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/corestoreio/pkg/util/cstesting"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ binlogsync.Checkpointer = (*binlogsync.FileCheckpoint)(nil)
+	_ binlogsync.Checkpointer = (*binlogsync.DBCheckpoint)(nil)
+	_ binlogsync.Checkpointer = (*binlogsync.ConfigCheckpoint)(nil)
+)
+
+func TestFileCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	t.Run("load fixture with GTID set", func(t *testing.T) {
+		ms, err := binlogsync.NewFileCheckpoint(filepath.Join("testdata", "checkpoint_mysql_gtid.txt")).LoadCheckpoint(context.TODO())
+		require.NoError(t, err, "%+v", err)
+		assert.Exactly(t, ddl.MasterStatus{
+			File:            "mysql-bin.000004",
+			Position:        2048,
+			ExecutedGTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-23,5c3a1d0e-82fc-11e1-9e33-c80aa9429562:1-7",
+		}, ms)
+	})
+
+	t.Run("load fixture without GTID set", func(t *testing.T) {
+		ms, err := binlogsync.NewFileCheckpoint(filepath.Join("testdata", "checkpoint_position.txt")).LoadCheckpoint(context.TODO())
+		require.NoError(t, err, "%+v", err)
+		assert.Exactly(t, ddl.MasterStatus{File: "mariadb-bin.000012", Position: 1936}, ms)
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "binlogsync")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		fc := binlogsync.NewFileCheckpoint(filepath.Join(dir, "checkpoint.txt"))
+		_, err = fc.LoadCheckpoint(context.TODO())
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+
+		want := ddl.MasterStatus{File: "mysql-bin.000002", Position: 236423, ExecutedGTIDSet: "0-1-270"}
+		require.NoError(t, fc.SaveCheckpoint(context.TODO(), want))
+		want.Position = 236999
+		require.NoError(t, fc.SaveCheckpoint(context.TODO(), want))
+
+		have, err := fc.LoadCheckpoint(context.TODO())
+		require.NoError(t, err, "%+v", err)
+		assert.Exactly(t, want, have)
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Len(t, files, 1, "Temporary files should have been renamed")
+	})
+}
+
+func TestDBCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	t.Run("save", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `binlogsync_checkpoint` (`name`,`file`,`position`,`gtid_set`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE")).
+			WithArgs("default", "mysql-bin.000002", uint64(4711), "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := binlogsync.NewDBCheckpoint(dbc.DB, "").SaveCheckpoint(context.TODO(), ddl.MasterStatus{File: "mysql-bin.000002", Position: 4711})
+		require.NoError(t, err, "%+v", err)
+	})
+
+	t.Run("load", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `file`,`position`,`gtid_set` FROM `binlogsync_checkpoint` WHERE `name` = ?")).
+			WithArgs("canal01").
+			WillReturnRows(sqlmock.NewRows([]string{"file", "position", "gtid_set"}).AddRow("mysql-bin.000003", 815, "0-1-42"))
+
+		ms, err := binlogsync.NewDBCheckpoint(dbc.DB, "canal01").LoadCheckpoint(context.TODO())
+		require.NoError(t, err, "%+v", err)
+		assert.Exactly(t, ddl.MasterStatus{File: "mysql-bin.000003", Position: 815, ExecutedGTIDSet: "0-1-42"}, ms)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `file`,`position`,`gtid_set` FROM `binlogsync_checkpoint` WHERE `name` = ?")).
+			WithArgs("default").
+			WillReturnRows(sqlmock.NewRows([]string{"file", "position", "gtid_set"}))
+
+		_, err := binlogsync.NewDBCheckpoint(dbc.DB, "").LoadCheckpoint(context.TODO())
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+}
+
+func TestConfigCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	t.Run("save", func(t *testing.T) {
+		w := new(cfgmock.Write)
+		cc := binlogsync.NewConfigCheckpoint(w, nil)
+		err := cc.SaveCheckpoint(context.TODO(), ddl.MasterStatus{File: "mysql-bin.000002", Position: 4711, ExecutedGTIDSet: "0-1-42"})
+		require.NoError(t, err, "%+v", err)
+		assert.Exactly(t, "default/0/sql/binlogsync/gtid_set", w.ArgPath)
+		assert.Exactly(t, "0-1-42", w.ArgValue)
+	})
+
+	t.Run("load", func(t *testing.T) {
+		srv := cfgmock.NewService(cfgmock.PathValue{
+			"default/0/sql/binlogsync/position": "mysql-bin.000002;4711",
+			"default/0/sql/binlogsync/gtid_set": "0-1-42",
+		})
+		ms, err := binlogsync.NewConfigCheckpoint(nil, srv).LoadCheckpoint(context.TODO())
+		require.NoError(t, err, "%+v", err)
+		assert.Exactly(t, ddl.MasterStatus{File: "mysql-bin.000002", Position: 4711, ExecutedGTIDSet: "0-1-42"}, ms)
+	})
+
+	t.Run("without getter", func(t *testing.T) {
+		_, err := binlogsync.NewConfigCheckpoint(new(cfgmock.Write), nil).LoadCheckpoint(context.TODO())
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+}
+
+func TestNewCanal_ResumeFromCheckpoint(t *testing.T) {
+	dsn := &mysql.Config{
+		User:   "root",
+		Passwd: "",
+		Net:    "x'err",
+		Addr:   "localhost:3306",
+		DBName: "TestDB",
+	}
+	dbc, dbMock := cstesting.MockDB(t)
+	defer func() {
+		dbMock.ExpectClose()
+		assert.NoError(t, dbc.Close())
+		if err := dbMock.ExpectationsWereMet(); err != nil {
+			t.Error("there were unfulfilled expections", err)
+		}
+	}()
+
+	dbMock.ExpectQuery(`SHOW MASTER STATUS`).
+		WithArgs().
+		WillReturnRows(
+			sqlmock.NewRows([]string{"File", "Position", "Binlog_Do_DB", "Binlog_Ignore_DB", "Executed_Gtid_Set"}).
+				FromCSVString(`mysql-bin.000009,4711,,,`),
+		)
+	dbMock.ExpectQuery(cstesting.SQLMockQuoteMeta("SHOW VARIABLES WHERE (`Variable_name` LIKE 'binlog_format')")).
+		WithArgs().
+		WillReturnRows(
+			sqlmock.NewRows([]string{"Variable_Name", "Value"}).
+				FromCSVString(`binlog_format,row`),
+		)
+
+	c, err := binlogsync.NewCanal(dsn, binlogsync.WithDB(dbc.DB),
+		binlogsync.WithCheckpointer(binlogsync.NewFileCheckpoint(filepath.Join("testdata", "checkpoint_mysql_gtid.txt"))))
+	require.NoError(t, err, "%+v", err)
+
+	assert.Exactly(t, ddl.MasterStatus{
+		File:            "mysql-bin.000004",
+		Position:        2048,
+		ExecutedGTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-23,5c3a1d0e-82fc-11e1-9e33-c80aa9429562:1-7",
+	}, c.SyncedPosition())
+}
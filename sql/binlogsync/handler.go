@@ -2,6 +2,8 @@ package binlogsync
 
 import (
 	"context"
+	"sync/atomic"
+	"time"
 
 	"github.com/corestoreio/pkg/sql/ddl"
 	"github.com/corestoreio/errors"
@@ -17,21 +19,52 @@ import (
 // RowsEventHandler calls your code when an event gets dispatched.
 type RowsEventHandler interface {
 	// Do function handles a RowsEvent bound to a specific database. If it
-	// returns an error behaviour of "Interrupted", the canal type will stop the
-	// syncer. Binlog has three update event version, v0, v1 and v2. For v1 and
+	// returns an error, the canal type will stop the syncer without saving the
+	// position of the current transaction, so the event gets dispatched again
+	// after a restart. Binlog has three update event version, v0, v1 and v2. For v1 and
 	// v2, the rows number must be even. Two rows for one event, format is
 	// [before update row, after update row] for update v0, only one row for a
 	// event, and we don't support this version yet. The Do function will run in
 	// its own Goroutine.
 	Do(ctx context.Context, action string, t ddl.Table, rows [][]interface{}) error
-	// Complete runs before a binlog rotation event happens. Same error rules
-	// apply here like for function Do(). The Complete function will run in its
-	// own Goroutine.
+	// Complete runs after a transaction or a statement outside of a
+	// transaction has been committed and before a binlog rotation event
+	// happens. Same error rules apply here like for function Do(). Only once
+	// all handlers returned successfully, the binlog position gets persisted
+	// via the Checkpointer. A handler which returns a non-interrupting error
+	// must keep its pending work and retry it during the next call to
+	// Complete. The Complete function will run in its own Goroutine.
 	Complete(context.Context) error
 	// String returns the name of the handler
 	String() string
 }
 
+// EventInfo describes the origin of a rows event. It gets passed via the
+// context to RowsEventHandler.Do.
+type EventInfo struct {
+	Schema string
+	// Position points to the end of the rows event in the binary log.
+	Position ddl.MasterStatus
+	// Timestamp when the statement has been executed on the master.
+	Timestamp time.Time
+}
+
+type keyEventInfo struct{}
+
+// WithEventInfo adds the EventInfo to the context. The Canal calls it before
+// dispatching a rows event. Use it to call RowsEventHandler.Do outside of a
+// Canal, for example in tests.
+func WithEventInfo(ctx context.Context, ei EventInfo) context.Context {
+	return context.WithValue(ctx, keyEventInfo{}, ei)
+}
+
+// EventInfoFromContext returns the EventInfo of the currently processed rows
+// event. Returns false if not available.
+func EventInfoFromContext(ctx context.Context) (EventInfo, bool) {
+	ei, ok := ctx.Value(keyEventInfo{}).(EventInfo)
+	return ei, ok
+}
+
 // RegisterRowsEventHandler adds a new event handler to the internal list.
 func (c *Canal) RegisterRowsEventHandler(h RowsEventHandler) {
 	c.rsMu.Lock()
@@ -53,32 +86,39 @@ func (c *Canal) travelRowsEventHandler(ctx context.Context, action string, table
 		h := h
 		erg.Go(func() error {
 			err := h.Do(ctx, action, table, rows)
-			isInterr := errors.IsInterrupted(err)
-			if err != nil && !isInterr {
-				c.Log.Info("[binlogsync] Handler.Do error", log.Err(err), log.Stringer("handler_name", h),
-					log.String("action", action), log.String("schema", c.DSN.DBName), log.String("table", table.Name))
-			} else if isInterr {
+			if err == nil {
+				return nil
+			}
+			if errors.IsInterrupted(err) {
 				c.Log.Info("[binlogsync] Handler.Do Interrupt", log.Err(err), log.Stringer("handler_name", h),
 					log.String("action", action), log.String("schema", c.DSN.DBName), log.String("table", table.Name))
 				return errors.Wrap(err, "[binlogsync] travelRowsEventHandler interrupted")
 			}
-			return nil
+			c.Log.Info("[binlogsync] Handler.Do error", log.Err(err), log.Stringer("handler_name", h),
+				log.String("action", action), log.String("schema", c.DSN.DBName), log.String("table", table.Name))
+			return errors.Wrapf(err, "[binlogsync] travelRowsEventHandler Handler %q", h)
 		})
 	}
 	return errors.Wrap(erg.Wait(), "[binlogsync] travelRowsEventHandler errgroup Wait")
 }
 
-func (c *Canal) flushEventHandlers(ctx context.Context) error {
+// flushEventHandlers calls Complete on all handlers. It reports completed as
+// true if none of the handlers has returned an error.
+func (c *Canal) flushEventHandlers(ctx context.Context) (completed bool, _ error) {
 	c.rsMu.RLock()
 	defer c.rsMu.RUnlock()
 
 	erg, ctx := errgroup.WithContext(ctx)
+	var failed int32
 
 	for _, h := range c.rsHandlers {
 		h := h
 		erg.Go(func() error {
 			err := h.Complete(ctx)
 			isInterr := errors.IsInterrupted(err)
+			if err != nil {
+				atomic.StoreInt32(&failed, 1)
+			}
 			if err != nil && !isInterr {
 				c.Log.Info("[binlogsync] flushEventHandlers.Handler.Complete error", log.Err(err), log.Stringer("handler_name", h))
 			} else if isInterr {
@@ -88,5 +128,8 @@ func (c *Canal) flushEventHandlers(ctx context.Context) error {
 			return nil
 		})
 	}
-	return errors.Wrap(erg.Wait(), "[binlogsync] flushEventHandlers errgroup Wait")
+	if err := erg.Wait(); err != nil {
+		return false, errors.Wrap(err, "[binlogsync] flushEventHandlers errgroup Wait")
+	}
+	return atomic.LoadInt32(&failed) == 0, nil
 }
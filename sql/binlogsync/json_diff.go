@@ -0,0 +1,257 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+)
+
+// applyPartialRows replaces in the after image of an update all partially
+// updated JSON columns with the full document. The document gets created by
+// applying the JSON diffs to the before image. The rows slice does not get
+// modified.
+func applyPartialRows(rows [][]interface{}) ([][]interface{}, error) {
+	var ret [][]interface{}
+	for i := 1; i < len(rows); i += 2 {
+		var after []interface{}
+		for j, v := range rows[i] {
+			diffs, ok := v.(myreplicator.JsonDiffs)
+			if !ok {
+				continue
+			}
+			if ret == nil {
+				ret = make([][]interface{}, len(rows))
+				copy(ret, rows)
+			}
+			if after == nil {
+				after = append([]interface{}(nil), rows[i]...)
+				ret[i] = after
+			}
+			var before interface{}
+			if j < len(rows[i-1]) {
+				before = rows[i-1][j]
+			}
+			doc, err := applyJSONDiffs(before, diffs)
+			if err != nil {
+				return nil, errors.Wrapf(err, "[binlogsync] Row %d column %d", i/2, j)
+			}
+			after[j] = doc
+		}
+	}
+	if ret == nil {
+		return rows, nil
+	}
+	return ret, nil
+}
+
+// applyJSONDiffs applies the modifications of a partial JSON update to the
+// JSON document of the before image. Supported paths contain member names and
+// array indexes, e.g. $.a."b c"[2]. Wildcards and ranges return a NotSupported
+// error.
+func applyJSONDiffs(before interface{}, diffs myreplicator.JsonDiffs) ([]byte, error) {
+	var raw []byte
+	switch val := before.(type) {
+	case []byte:
+		raw = val
+	case string:
+		raw = []byte(val)
+	default:
+		return nil, errors.NotSupported.Newf("[binlogsync] Partial JSON update requires the full before image, got %T. Set binlog_row_image=FULL", before)
+	}
+
+	doc, err := decodeJSON(raw)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[binlogsync] Failed to decode before image %q", raw)
+	}
+
+	for _, d := range diffs {
+		legs, err := parseJSONPath(d.Path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var val interface{}
+		if d.Op != myreplicator.JsonDiffOperationRemove {
+			if val, err = decodeJSON([]byte(d.Value)); err != nil {
+				return nil, errors.Wrapf(err, "[binlogsync] Failed to decode value of %s", d)
+			}
+		}
+		if doc, err = applyJSONDiff(doc, legs, d.Op, val); err != nil {
+			return nil, errors.Wrapf(err, "[binlogsync] Failed to apply %s", d)
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	return v, errors.WithStack(err)
+}
+
+// jsonPathLeg is either a member name or an array index.
+type jsonPathLeg struct {
+	member  string
+	index   int
+	isIndex bool
+}
+
+func parseJSONPath(path string) ([]jsonPathLeg, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, errors.NotValid.Newf("[binlogsync] JSON path %q must start with $", path)
+	}
+	var legs []jsonPathLeg
+	p := path[1:]
+	for p != "" {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			if strings.HasPrefix(p, `"`) {
+				end := 1
+				for end < len(p) && (p[end] != '"' || p[end-1] == '\\') {
+					end++
+				}
+				if end == len(p) {
+					return nil, errors.NotValid.Newf("[binlogsync] JSON path %q contains an unterminated member name", path)
+				}
+				m, err := strconv.Unquote(p[:end+1])
+				if err != nil {
+					return nil, errors.NotValid.New(err, "[binlogsync] JSON path %q contains an invalid member name", path)
+				}
+				legs = append(legs, jsonPathLeg{member: m})
+				p = p[end+1:]
+				continue
+			}
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 || p[:end] == "*" {
+				return nil, errors.NotSupported.Newf("[binlogsync] JSON path %q not supported", path)
+			}
+			legs = append(legs, jsonPathLeg{member: p[:end]})
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, errors.NotValid.Newf("[binlogsync] JSON path %q contains an unterminated array index", path)
+			}
+			idx, err := strconv.Atoi(strings.TrimSpace(p[1:end]))
+			if err != nil {
+				return nil, errors.NotSupported.Newf("[binlogsync] JSON path %q not supported", path)
+			}
+			legs = append(legs, jsonPathLeg{index: idx, isIndex: true})
+			p = p[end+1:]
+		default:
+			return nil, errors.NotSupported.Newf("[binlogsync] JSON path %q not supported", path)
+		}
+	}
+	return legs, nil
+}
+
+// applyJSONDiff modifies the document at the path and returns the new
+// document. Replacing the root returns the value.
+func applyJSONDiff(doc interface{}, legs []jsonPathLeg, op myreplicator.JsonDiffOperation, val interface{}) (interface{}, error) {
+	if len(legs) == 0 {
+		if op == myreplicator.JsonDiffOperationRemove {
+			return nil, errors.NotValid.Newf("[binlogsync] The JSON root cannot be removed")
+		}
+		return val, nil
+	}
+
+	leg := legs[0]
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		if leg.isIndex {
+			return nil, errors.NotFound.Newf("[binlogsync] JSON object does not contain index %d", leg.index)
+		}
+		child, ok := d[leg.member]
+		if len(legs) > 1 {
+			if !ok {
+				return nil, errors.NotFound.Newf("[binlogsync] JSON object does not contain member %q", leg.member)
+			}
+			nc, err := applyJSONDiff(child, legs[1:], op, val)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			d[leg.member] = nc
+			return d, nil
+		}
+		switch op {
+		case myreplicator.JsonDiffOperationRemove:
+			delete(d, leg.member)
+		case myreplicator.JsonDiffOperationReplace:
+			if !ok {
+				return nil, errors.NotFound.Newf("[binlogsync] JSON object does not contain member %q", leg.member)
+			}
+			d[leg.member] = val
+		default:
+			d[leg.member] = val
+		}
+		return d, nil
+
+	case []interface{}:
+		if !leg.isIndex {
+			return nil, errors.NotFound.Newf("[binlogsync] JSON array does not contain member %q", leg.member)
+		}
+		if len(legs) > 1 {
+			if leg.index < 0 || leg.index >= len(d) {
+				return nil, errors.NotFound.Newf("[binlogsync] JSON array does not contain index %d", leg.index)
+			}
+			nc, err := applyJSONDiff(d[leg.index], legs[1:], op, val)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			d[leg.index] = nc
+			return d, nil
+		}
+		switch op {
+		case myreplicator.JsonDiffOperationInsert:
+			if leg.index >= len(d) {
+				return append(d, val), nil
+			}
+			if leg.index < 0 {
+				return nil, errors.NotFound.Newf("[binlogsync] JSON array does not contain index %d", leg.index)
+			}
+			d = append(d, nil)
+			copy(d[leg.index+1:], d[leg.index:])
+			d[leg.index] = val
+			return d, nil
+		}
+		if leg.index < 0 || leg.index >= len(d) {
+			return nil, errors.NotFound.Newf("[binlogsync] JSON array does not contain index %d", leg.index)
+		}
+		if op == myreplicator.JsonDiffOperationRemove {
+			return append(d[:leg.index], d[leg.index+1:]...), nil
+		}
+		d[leg.index] = val
+		return d, nil
+	}
+	return nil, errors.NotFound.Newf("[binlogsync] JSON path leg %v cannot be applied to a scalar", leg)
+}
@@ -0,0 +1,64 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyJSONDiffs(t *testing.T) {
+	t.Parallel()
+
+	const (
+		replace = myreplicator.JsonDiffOperationReplace
+		insert  = myreplicator.JsonDiffOperationInsert
+		remove  = myreplicator.JsonDiffOperationRemove
+	)
+
+	tests := []struct {
+		before  interface{}
+		diffs   myreplicator.JsonDiffs
+		want    string
+		errKind errors.Kind
+	}{
+		{[]byte(`{"color":"red"}`), myreplicator.JsonDiffs{{Op: replace, Path: "$.color", Value: `"blue"`}}, `{"color":"blue"}`, errors.NoKind},
+		{`{"a":{"b c":[1,2]}}`, myreplicator.JsonDiffs{{Op: insert, Path: `$.a."b c"[1]`, Value: `1.50`}}, `{"a":{"b c":[1,1.50,2]}}`, errors.NoKind},
+		{`[1,2]`, myreplicator.JsonDiffs{{Op: insert, Path: `$[5]`, Value: `{"x":null}`}}, `[1,2,{"x":null}]`, errors.NoKind},
+		{`{"a":[1,2,3],"b":true}`, myreplicator.JsonDiffs{
+			{Op: remove, Path: "$.a[1]"},
+			{Op: remove, Path: "$.b"},
+			{Op: insert, Path: "$.c", Value: `"<&>"`},
+		}, `{"a":[1,3],"c":"<&>"}`, errors.NoKind},
+		{`{"a":1}`, myreplicator.JsonDiffs{{Op: replace, Path: "$", Value: `[]`}}, `[]`, errors.NoKind},
+		{nil, myreplicator.JsonDiffs{{Op: replace, Path: "$.a", Value: `1`}}, ``, errors.NotSupported},
+		{`{"a":[1]}`, myreplicator.JsonDiffs{{Op: replace, Path: "$.a[*]", Value: `1`}}, ``, errors.NotSupported},
+		{`{"a":1}`, myreplicator.JsonDiffs{{Op: replace, Path: "$.b", Value: `1`}}, ``, errors.NotFound},
+		{`{"a":1}`, myreplicator.JsonDiffs{{Op: replace, Path: "$.a.b", Value: `1`}}, ``, errors.NotFound},
+	}
+	for i, test := range tests {
+		have, err := applyJSONDiffs(test.before, test.diffs)
+		if test.errKind != errors.NoKind {
+			assert.True(t, test.errKind.Match(err), "IDX %d: %+v", i, err)
+			assert.Nil(t, have, "IDX %d", i)
+			continue
+		}
+		assert.NoError(t, err, "IDX %d: %+v", i, err)
+		assert.Exactly(t, test.want, string(have), "IDX %d", i)
+	}
+}
@@ -0,0 +1,632 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+)
+
+// Replayer applies the row events of binary log files to a target database,
+// also known as point-in-time recovery. Each row gets converted back into an
+// INSERT, UPDATE or DELETE statement. UPDATE and DELETE statements identify
+// the row by its primary key columns or, if the table has no primary key, by
+// all columns of the before image. Hence the binlog must have been written
+// with binlog_format=ROW and binlog_row_image=FULL.
+//
+// All statements of a binlog transaction run within one database transaction.
+// A transaction which is not complete when the stop condition has been reached
+// gets rolled back. Statement based query events, for example DDL, are not
+// applied and only counted as skipped.
+//
+// The statements contain the raw values of the binlog, converted only to the
+// unsigned integer of the column type, so the replay does not lose data.
+// DECIMAL values must have been decoded as string, see
+// myreplicator.BinlogParser.SetUseDecimal, which Replay does automatically.
+//
+// The table structure gets loaded from the target database. Table names are
+// not qualified with the database name, the target ConnPool must hence point
+// to the database which should be restored. Use the include rules to restrict
+// the replay to the events of one database.
+type Replayer struct {
+	Log log.Logger
+
+	db     *dml.ConnPool
+	tables *ddl.Tables
+
+	start      ddl.MasterStatus
+	stop       ddl.MasterStatus
+	stopTime   time.Time
+	gtidSet    gomysql.GTIDSet
+	gtidFlavor string
+	include    []string
+	exclude    []string
+	dryRun     io.Writer
+
+	// current transaction state
+	file     string
+	tx       *dml.Tx
+	trxGTID  string
+	skipTrx  bool
+	inTrx    bool
+	trxStmts int
+	result   ReplayResult
+}
+
+// ReplayResult contains the statistics and the position of the last applied
+// transaction.
+type ReplayResult struct {
+	// Transactions number of committed transactions.
+	Transactions int
+	// Statements number of executed, or in dry-run mode written, statements.
+	Statements int
+	// Skipped number of events which have been skipped because of the table
+	// rules, the GTID set or because they are not supported.
+	Skipped int
+	// Position of the last applied transaction. Can be used as start position
+	// for the next replay.
+	Position ddl.MasterStatus
+}
+
+// ReplayOption applies options to the Replayer.
+type ReplayOption func(*Replayer) error
+
+// WithReplayStart starts the replay in the binlog file at the given position.
+// All files before ms.File get skipped. If ms.ExecutedGTIDSet is not empty,
+// it gets applied like WithReplayGTIDSet with the MySQL flavor.
+func WithReplayStart(ms ddl.MasterStatus) ReplayOption {
+	return func(r *Replayer) error {
+		r.start = ms
+		if ms.ExecutedGTIDSet == "" {
+			return nil
+		}
+		return WithReplayGTIDSet(MySQLFlavor, ms.ExecutedGTIDSet)(r)
+	}
+}
+
+// WithReplayGTIDSet skips all transactions whose GTID is contained in the
+// provided set. Flavor must be either MySQLFlavor or MariaDBFlavor.
+func WithReplayGTIDSet(flavor, set string) ReplayOption {
+	return func(r *Replayer) (err error) {
+		r.gtidSet, err = parseGTIDSet(flavor, set)
+		r.gtidFlavor = flavor
+		return err
+	}
+}
+
+// WithReplayStopTime stops the replay at the first event which has been
+// written after the time t.
+func WithReplayStopTime(t time.Time) ReplayOption {
+	return func(r *Replayer) error {
+		r.stopTime = t
+		return nil
+	}
+}
+
+// WithReplayStopPosition stops the replay at the first event which starts at
+// or after the position in the binlog file ms.File. All files after ms.File
+// get skipped.
+func WithReplayStopPosition(ms ddl.MasterStatus) ReplayOption {
+	return func(r *Replayer) error {
+		r.stop = ms
+		return nil
+	}
+}
+
+// WithReplayIncludeTables applies only the events of the matching tables. A
+// pattern has the form "database.table" and supports the wildcards of
+// path.Match, for example "shop.*" or "shop.catalog_product_*".
+func WithReplayIncludeTables(patterns ...string) ReplayOption {
+	return func(r *Replayer) error {
+		r.include = append(r.include, patterns...)
+		return validateTablePatterns(patterns)
+	}
+}
+
+// WithReplayExcludeTables skips the events of the matching tables. Exclude
+// rules take precedence over include rules. For the pattern syntax see
+// WithReplayIncludeTables.
+func WithReplayExcludeTables(patterns ...string) ReplayOption {
+	return func(r *Replayer) error {
+		r.exclude = append(r.exclude, patterns...)
+		return validateTablePatterns(patterns)
+	}
+}
+
+// WithReplayDryRun writes the interpolated SQL statements to w instead of
+// executing them. The target database is still required to load the table
+// structure.
+func WithReplayDryRun(w io.Writer) ReplayOption {
+	return func(r *Replayer) error {
+		r.dryRun = w
+		return nil
+	}
+}
+
+// WithReplayTables sets the table structures. Tables which cannot be found get
+// loaded from the target database.
+func WithReplayTables(tables *ddl.Tables) ReplayOption {
+	return func(r *Replayer) error {
+		r.tables = tables
+		return nil
+	}
+}
+
+func validateTablePatterns(patterns []string) error {
+	for _, p := range patterns {
+		if strings.Count(p, ".") != 1 {
+			return errors.NotValid.Newf("[binlogsync] Table pattern %q must have the form database.table", p)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.NotValid.New(err, "[binlogsync] Table pattern %q is malformed", p)
+		}
+	}
+	return nil
+}
+
+// NewReplayer creates a new Replayer which applies the events to db.
+func NewReplayer(db *dml.ConnPool, opts ...ReplayOption) (*Replayer, error) {
+	r := &Replayer{
+		Log: log.BlackHole{},
+		db:  db,
+	}
+	for _, o := range opts {
+		if err := o(r); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	if r.tables == nil {
+		var err error
+		if r.tables, err = ddl.NewTables(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return r, nil
+}
+
+var errStopReplay = errors.New("[binlogsync] stop replay")
+
+// Replay reads the binlog files in the provided order and applies the events.
+// Files must be sorted ascending, as SHOW BINARY LOGS does it.
+func (r *Replayer) Replay(ctx context.Context, files ...string) (ReplayResult, error) {
+	files = r.filterFiles(files)
+	if len(files) == 0 {
+		return r.result, nil
+	}
+
+	var ef myreplicator.EventFilter
+	if filepath.Base(files[0]) == r.start.File {
+		ef.StartPosition = uint32(r.start.Position)
+	}
+	if filepath.Base(files[len(files)-1]) == r.stop.File {
+		ef.StopPosition = uint32(r.stop.Position)
+	}
+
+	fr := myreplicator.NewFileReader(ef)
+	fr.SetUseDecimal(true)
+	err := fr.ReadFiles(func(file string, e *myreplicator.BinlogEvent) error {
+		if !r.stopTime.IsZero() && time.Unix(int64(e.Header.Timestamp), 0).After(r.stopTime) {
+			return errStopReplay
+		}
+		return r.ApplyEvent(ctx, file, e)
+	}, files...)
+	if err != nil && errors.Cause(err) != errStopReplay {
+		return r.result, errors.WithStack(r.rollback(err))
+	}
+	return r.result, errors.WithStack(r.Finish())
+}
+
+func (r *Replayer) filterFiles(files []string) []string {
+	ret := make([]string, 0, len(files))
+	for _, f := range files {
+		base := filepath.Base(f)
+		if r.start.File != "" && base < r.start.File {
+			continue
+		}
+		if r.stop.File != "" && base > r.stop.File {
+			continue
+		}
+		ret = append(ret, f)
+	}
+	return ret
+}
+
+// Finish rolls back an incomplete transaction. It must be called after the
+// last ApplyEvent call. Replay calls it automatically.
+func (r *Replayer) Finish() error {
+	if !r.inTrx {
+		return nil
+	}
+	if r.Log.IsInfo() {
+		r.Log.Info("[binlogsync] Replayer rolls back incomplete transaction", log.Int("statements", r.trxStmts), log.String("gtid", r.trxGTID))
+	}
+	return r.rollback(nil)
+}
+
+// ApplyEvent applies a single event. The events must be provided in the order
+// of the binlog. ApplyEvent allows to replay events from other sources than
+// files, for example from a BinlogStreamer.
+func (r *Replayer) ApplyEvent(ctx context.Context, file string, e *myreplicator.BinlogEvent) error {
+	r.file = file
+	switch ev := e.Event.(type) {
+	case *myreplicator.GTIDEvent:
+		return r.beginTrx(formatMySQLGTID(ev.SID, ev.GNO))
+
+	case *myreplicator.MariadbGTIDEvent:
+		ev.GTID.ServerID = e.Header.ServerID
+		return r.beginTrx(ev.GTID.String())
+
+	case *myreplicator.QueryEvent:
+		switch q := string(bytes.TrimSpace(ev.Query)); {
+		case strings.EqualFold(q, "BEGIN"):
+			if !r.inTrx {
+				return r.beginTrx("")
+			}
+			return nil
+		case strings.EqualFold(q, "COMMIT"):
+			return r.commit(e)
+		}
+		r.result.Skipped++
+		if r.Log.IsInfo() {
+			r.Log.Info("[binlogsync] Replayer skips query event", log.String("schema", string(ev.Schema)), log.String("query", string(ev.Query)))
+		}
+		return nil
+
+	case *myreplicator.XIDEvent:
+		return r.commit(e)
+
+	case *myreplicator.RowsEvent:
+		return r.applyRows(ctx, e, ev)
+	}
+	return nil
+}
+
+func (r *Replayer) beginTrx(gtid string) error {
+	if r.inTrx {
+		return errors.NotValid.Newf("[binlogsync] Replayer: transaction %q has not been committed before the next one begins", r.trxGTID)
+	}
+	r.inTrx = true
+	r.trxGTID = gtid
+	r.trxStmts = 0
+	r.skipTrx = false
+	if gtid == "" || r.gtidSet == nil {
+		return nil
+	}
+	gs, err := gomysql.ParseGTIDSet(r.gtidFlavor, gtid)
+	if err != nil {
+		return errors.NotValid.New(err, "[binlogsync] Replayer failed to parse GTID %q", gtid)
+	}
+	r.skipTrx = r.gtidSet.Contain(gs)
+	return nil
+}
+
+func (r *Replayer) commit(e *myreplicator.BinlogEvent) error {
+	defer func() {
+		r.inTrx = false
+		r.trxGTID = ""
+	}()
+	if r.skipTrx {
+		r.result.Skipped++
+		r.skipTrx = false
+		return nil
+	}
+	if r.tx != nil {
+		if err := r.tx.Commit(); err != nil {
+			r.tx = nil
+			return errors.Wrapf(err, "[binlogsync] Replayer failed to commit transaction at %s:%d", r.file, e.Header.LogPos)
+		}
+		r.tx = nil
+	}
+	if r.dryRun != nil && r.trxStmts > 0 {
+		if _, err := io.WriteString(r.dryRun, "COMMIT;\n"); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if r.trxStmts > 0 {
+		r.result.Transactions++
+	}
+	r.result.Position = ddl.MasterStatus{
+		File:     r.file,
+		Position: uint(e.Header.LogPos),
+	}
+	if r.gtidSet != nil && r.trxGTID != "" {
+		if err := r.gtidSet.Update(r.trxGTID); err != nil {
+			return errors.NotValid.New(err, "[binlogsync] Replayer failed to update GTID set with %q", r.trxGTID)
+		}
+	}
+	if r.gtidSet != nil {
+		r.result.Position.ExecutedGTIDSet = r.gtidSet.String()
+	}
+	return nil
+}
+
+// rollback discards the current transaction and returns the cause, if any.
+func (r *Replayer) rollback(cause error) error {
+	r.inTrx = false
+	r.skipTrx = false
+	if r.tx == nil {
+		return cause
+	}
+	err := r.tx.Rollback()
+	r.tx = nil
+	if cause != nil {
+		return cause
+	}
+	return errors.WithStack(err)
+}
+
+// matchTable applies the include and exclude rules.
+func (r *Replayer) matchTable(schema, table string) bool {
+	name := schema + "." + table
+	for _, p := range r.exclude {
+		if ok, _ := path.Match(p, name); ok {
+			return false
+		}
+	}
+	if len(r.include) == 0 {
+		return true
+	}
+	for _, p := range r.include {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Replayer) findTable(ctx context.Context, name string) (ddl.Table, error) {
+	t, err := r.tables.Table(name)
+	if errors.IsNotFound(err) {
+		if err := r.tables.Options(ddl.WithTableLoadColumns(ctx, r.db.DB, name)); err != nil {
+			return ddl.Table{}, errors.Wrapf(err, "[binlogsync] Replayer failed to load table %q", name)
+		}
+		t, err = r.tables.Table(name)
+	}
+	if err != nil {
+		return ddl.Table{}, errors.WithStack(err)
+	}
+	return *t, nil
+}
+
+func (r *Replayer) applyRows(ctx context.Context, e *myreplicator.BinlogEvent, ev *myreplicator.RowsEvent) error {
+	if r.skipTrx || ev.Table == nil {
+		return nil
+	}
+	schema, table := string(ev.Table.Schema), string(ev.Table.Table)
+	if !r.matchTable(schema, table) {
+		r.result.Skipped++
+		return nil
+	}
+
+	var action string
+	switch e.Header.EventType {
+	case myreplicator.WRITE_ROWS_EVENTv0, myreplicator.WRITE_ROWS_EVENTv1, myreplicator.WRITE_ROWS_EVENTv2:
+		action = InsertAction
+	case myreplicator.UPDATE_ROWS_EVENTv0, myreplicator.UPDATE_ROWS_EVENTv1, myreplicator.UPDATE_ROWS_EVENTv2, myreplicator.PARTIAL_UPDATE_ROWS_EVENT:
+		action = UpdateAction
+	case myreplicator.DELETE_ROWS_EVENTv0, myreplicator.DELETE_ROWS_EVENTv1, myreplicator.DELETE_ROWS_EVENTv2:
+		action = DeleteAction
+	default:
+		return errors.NotSupported.Newf("[binlogsync] Replayer: EventType %v not supported. Table %q.%q", e.Header.EventType, schema, table)
+	}
+
+	t, err := r.findTable(ctx, table)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	rows := ev.Rows
+	if action == UpdateAction {
+		if len(rows)%2 != 0 {
+			return errors.NotValid.Newf("[binlogsync] Replayer: update event of table %q.%q requires an even number of rows, got %d", schema, table, len(rows))
+		}
+		if rows, err = applyPartialRows(rows); err != nil {
+			return errors.Wrapf(err, "[binlogsync] Replayer table %q.%q", schema, table)
+		}
+	}
+	// Non-transactional tables or binlogs without BEGIN: each event forms its
+	// own transaction.
+	autoCommit := !r.inTrx
+	if autoCommit {
+		if err := r.beginTrx(""); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	step := 1
+	if action == UpdateAction {
+		step = 2
+	}
+	for i := 0; i < len(rows); i += step {
+		var before, after []interface{}
+		switch action {
+		case InsertAction:
+			after = rows[i]
+		case UpdateAction:
+			before, after = rows[i], rows[i+1]
+		case DeleteAction:
+			before = rows[i]
+		}
+		sqlStr, args, err := replayStatement(action, t, before, after)
+		if err != nil {
+			return errors.Wrapf(err, "[binlogsync] Replayer table %q.%q", schema, table)
+		}
+		if err := r.exec(ctx, sqlStr, args); err != nil {
+			return errors.Wrapf(err, "[binlogsync] Replayer table %q.%q at position %d", schema, table, e.Header.LogPos)
+		}
+	}
+	if autoCommit {
+		return r.commit(e)
+	}
+	return nil
+}
+
+func (r *Replayer) exec(ctx context.Context, sqlStr string, args []interface{}) error {
+	r.trxStmts++
+	r.result.Statements++
+
+	if r.dryRun != nil {
+		ip := dml.Interpolate(sqlStr)
+		for _, a := range args {
+			ip.Unsafe(a)
+		}
+		str, _, err := ip.ToSQL()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if r.trxStmts == 1 {
+			str = "BEGIN;\n" + str
+		}
+		_, err = fmt.Fprintf(r.dryRun, "%s;\n", str)
+		return errors.WithStack(err)
+	}
+
+	if r.tx == nil {
+		tx, err := r.db.BeginTx(ctx, nil)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		r.tx = tx
+	}
+	_, err := r.tx.WithRawSQL(sqlStr).ExecContext(ctx, args...)
+	return errors.WithStack(err)
+}
+
+// replayStatement creates the SQL statement with place holders and its
+// arguments for a single row change. Before and after contain the raw values
+// of the binlog row images.
+func replayStatement(action string, t ddl.Table, before, after []interface{}) (string, []interface{}, error) {
+	switch action {
+	case InsertAction:
+		cols, args, err := rowArguments(t.Columns, after)
+		if err != nil {
+			return "", nil, errors.WithStack(err)
+		}
+		sqlStr, _, err := dml.NewInsert(t.Name).AddColumns(cols...).BuildValues().ToSQL()
+		return sqlStr, args, errors.WithStack(err)
+
+	case UpdateAction:
+		cols, args, err := rowArguments(t.Columns, after)
+		if err != nil {
+			return "", nil, errors.WithStack(err)
+		}
+		wheres, whereArgs, err := rowConditions(t.Columns, before)
+		if err != nil {
+			return "", nil, errors.WithStack(err)
+		}
+		sqlStr, _, err := dml.NewUpdate(t.Name).AddColumns(cols...).Where(wheres...).ToSQL()
+		return sqlStr, append(args, whereArgs...), errors.WithStack(err)
+
+	case DeleteAction:
+		wheres, whereArgs, err := rowConditions(t.Columns, before)
+		if err != nil {
+			return "", nil, errors.WithStack(err)
+		}
+		sqlStr, _, err := dml.NewDelete(t.Name).Where(wheres...).ToSQL()
+		return sqlStr, whereArgs, errors.WithStack(err)
+	}
+	return "", nil, errors.NotSupported.Newf("[binlogsync] Action %q not supported", action)
+}
+
+// rowArguments returns the column names and driver values of a row in column
+// order.
+func rowArguments(cols ddl.Columns, row []interface{}) ([]string, []interface{}, error) {
+	if len(row) > len(cols) {
+		return nil, nil, errors.Mismatch.Newf("[binlogsync] Row has %d values but table has only %d columns", len(row), len(cols))
+	}
+	names := make([]string, 0, len(row))
+	args := make([]interface{}, 0, len(row))
+	for i, v := range row {
+		dv, err := rawDriverValue(cols[i], v)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "[binlogsync] Column %q", cols[i].Field)
+		}
+		names = append(names, cols[i].Field)
+		args = append(args, dv)
+	}
+	return names, args, nil
+}
+
+// rowConditions identifies a row by its primary key or, without primary key,
+// by all columns.
+func rowConditions(cols ddl.Columns, row []interface{}) (dml.Conditions, []interface{}, error) {
+	if len(row) > len(cols) {
+		return nil, nil, errors.Mismatch.Newf("[binlogsync] Row has %d values but table has only %d columns", len(row), len(cols))
+	}
+	keys := cols.PrimaryKeys()
+	if len(keys) == 0 {
+		keys = cols
+	}
+	wheres := make(dml.Conditions, 0, len(keys))
+	args := make([]interface{}, 0, len(keys))
+	for _, c := range keys {
+		idx := -1
+		for i, col := range cols {
+			if col == c {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 || idx >= len(row) {
+			return nil, nil, errors.NotFound.Newf("[binlogsync] Column %q not found in row image. binlog_row_image must be FULL.", c.Field)
+		}
+		dv, err := rawDriverValue(c, row[idx])
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "[binlogsync] Column %q", c.Field)
+		}
+		if dv == nil {
+			wheres = append(wheres, dml.Column(c.Field).Null())
+			continue
+		}
+		wheres = append(wheres, dml.Column(c.Field).PlaceHolder())
+		args = append(args, dv)
+	}
+	return wheres, args, nil
+}
+
+// rawDriverValue converts a raw binlog value into a driver value without losing
+// data. The binlog stores all integers signed, so unsigned columns get
+// converted back. Other values stay untouched besides widening to int64 and
+// float64.
+func rawDriverValue(c *ddl.Column, v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case int8, int16, int32, int64:
+		if c.IsUnsigned() {
+			u, _ := toUint64(val, c.DataType)
+			return u, nil
+		}
+		i, _ := toInt64(val)
+		return i, nil
+	case float32:
+		return float64(val), nil
+	case myreplicator.JsonDiffs:
+		return nil, errors.NotSupported.Newf("[binlogsync] Partial JSON update %v can only be replayed as part of an update", val)
+	}
+	return v, nil
+}
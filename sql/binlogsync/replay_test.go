@@ -0,0 +1,214 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func replayTables() *ddl.Tables {
+	return ddl.MustNewTables(ddl.WithTable("customer_entity",
+		&ddl.Column{Field: "entity_id", Pos: 1, DataType: "int", ColumnType: "int(10) unsigned", Key: "PRI"},
+		&ddl.Column{Field: "email", Pos: 2, DataType: "varchar", ColumnType: "varchar(255)"},
+		&ddl.Column{Field: "is_active", Pos: 3, DataType: "smallint", ColumnType: "smallint(5) unsigned"},
+	))
+}
+
+// replayEvents creates one transaction with an insert and an update.
+func replayEvents(gtid *myreplicator.GTIDEvent) []*myreplicator.BinlogEvent {
+	table := &myreplicator.TableMapEvent{Schema: []byte("shop"), Table: []byte("customer_entity")}
+	ev := func(et myreplicator.EventType, pos uint32, e myreplicator.Event) *myreplicator.BinlogEvent {
+		return &myreplicator.BinlogEvent{
+			Header: &myreplicator.EventHeader{Timestamp: 1500000000, EventType: et, LogPos: pos, EventSize: 10},
+			Event:  e,
+		}
+	}
+	var evs []*myreplicator.BinlogEvent
+	if gtid != nil {
+		evs = append(evs, ev(myreplicator.GTID_EVENT, 110, gtid))
+	}
+	return append(evs,
+		ev(myreplicator.QUERY_EVENT, 120, &myreplicator.QueryEvent{Schema: []byte("shop"), Query: []byte("BEGIN")}),
+		ev(myreplicator.WRITE_ROWS_EVENTv1, 130, &myreplicator.RowsEvent{Table: table, Rows: [][]interface{}{
+			{int32(3), "a@b.c", int16(1)},
+		}}),
+		ev(myreplicator.UPDATE_ROWS_EVENTv1, 140, &myreplicator.RowsEvent{Table: table, Rows: [][]interface{}{
+			{int32(4), "d@e.f", int16(1)},
+			{int32(4), "d@e.f", int16(0)},
+		}}),
+		ev(myreplicator.XID_EVENT, 150, &myreplicator.XIDEvent{XID: 7}),
+	)
+}
+
+func applyEvents(t *testing.T, r *binlogsync.Replayer, evs []*myreplicator.BinlogEvent) {
+	for _, e := range evs {
+		require.NoError(t, r.ApplyEvent(context.TODO(), "mysql-bin.000004", e))
+	}
+	require.NoError(t, r.Finish())
+}
+
+func TestReplayer_ApplyEvent(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `customer_entity` (`entity_id`,`email`,`is_active`) VALUES (?,?,?)")).
+		WithArgs(uint64(3), "a@b.c", uint64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("UPDATE `customer_entity` SET `entity_id`=?, `email`=?, `is_active`=? WHERE (`entity_id` = ?)")).
+		WithArgs(uint64(4), "d@e.f", uint64(0), uint64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	r, err := binlogsync.NewReplayer(dbc, binlogsync.WithReplayTables(replayTables()))
+	require.NoError(t, err)
+	applyEvents(t, r, replayEvents(nil))
+
+	res, err := r.Replay(context.TODO()) // no files, returns only the result
+	require.NoError(t, err)
+	assert.Exactly(t, 1, res.Transactions)
+	assert.Exactly(t, 2, res.Statements)
+	assert.Exactly(t, "mysql-bin.000004;150", res.Position.String())
+}
+
+func TestReplayer_DryRun(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	var buf bytes.Buffer
+	r, err := binlogsync.NewReplayer(dbc,
+		binlogsync.WithReplayTables(replayTables()),
+		binlogsync.WithReplayDryRun(&buf),
+	)
+	require.NoError(t, err)
+	applyEvents(t, r, replayEvents(nil))
+
+	assert.Exactly(t,
+		"BEGIN;\n"+
+			"INSERT INTO `customer_entity` (`entity_id`,`email`,`is_active`) VALUES (3,'a@b.c',1);\n"+
+			"UPDATE `customer_entity` SET `entity_id`=4, `email`='d@e.f', `is_active`=0 WHERE (`entity_id` = 4);\n"+
+			"COMMIT;\n",
+		buf.String())
+}
+
+func TestReplayer_Rules(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	sid, err := hex.DecodeString("3e11fa4771ca11e19e33c80aa9429562")
+	require.NoError(t, err)
+
+	t.Run("excluded table", func(t *testing.T) {
+		var buf bytes.Buffer
+		r, err := binlogsync.NewReplayer(dbc,
+			binlogsync.WithReplayTables(replayTables()),
+			binlogsync.WithReplayDryRun(&buf),
+			binlogsync.WithReplayIncludeTables("shop.*"),
+			binlogsync.WithReplayExcludeTables("shop.customer_*"),
+		)
+		require.NoError(t, err)
+		applyEvents(t, r, replayEvents(nil))
+		assert.Empty(t, buf.String())
+	})
+
+	t.Run("GTID already executed", func(t *testing.T) {
+		var buf bytes.Buffer
+		r, err := binlogsync.NewReplayer(dbc,
+			binlogsync.WithReplayTables(replayTables()),
+			binlogsync.WithReplayDryRun(&buf),
+			binlogsync.WithReplayGTIDSet(binlogsync.MySQLFlavor, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"),
+		)
+		require.NoError(t, err)
+		applyEvents(t, r, replayEvents(&myreplicator.GTIDEvent{SID: sid, GNO: 5}))
+		assert.Empty(t, buf.String())
+
+		applyEvents(t, r, replayEvents(&myreplicator.GTIDEvent{SID: sid, GNO: 6}))
+		assert.Contains(t, buf.String(), "INSERT INTO `customer_entity`")
+		res, err := r.Replay(context.TODO())
+		require.NoError(t, err)
+		assert.Exactly(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6", res.Position.ExecutedGTIDSet)
+	})
+
+	t.Run("incomplete transaction", func(t *testing.T) {
+		var buf bytes.Buffer
+		r, err := binlogsync.NewReplayer(dbc,
+			binlogsync.WithReplayTables(replayTables()),
+			binlogsync.WithReplayDryRun(&buf),
+		)
+		require.NoError(t, err)
+		evs := replayEvents(nil)
+		applyEvents(t, r, evs[:len(evs)-1])
+		res, err := r.Replay(context.TODO())
+		require.NoError(t, err)
+		assert.Exactly(t, 0, res.Transactions)
+		assert.Exactly(t, "", res.Position.File)
+	})
+
+	t.Run("malformed pattern", func(t *testing.T) {
+		_, err := binlogsync.NewReplayer(dbc, binlogsync.WithReplayIncludeTables("customer_entity"))
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+}
+
+func TestReplayer_RawValues(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	tables := ddl.MustNewTables(ddl.WithTable("catalog_eav_attribute",
+		&ddl.Column{Field: "attribute_id", Pos: 1, DataType: "smallint", ColumnType: "smallint(5) unsigned", Key: "PRI"},
+		&ddl.Column{Field: "is_global", Pos: 2, DataType: "int", ColumnType: "int(10) unsigned"},
+		&ddl.Column{Field: "is_wysiwyg_enabled", Pos: 3, DataType: "tinyint", ColumnType: "tinyint(1)"},
+		&ddl.Column{Field: "flags", Pos: 4, DataType: "bit", ColumnType: "bit(8)"},
+		&ddl.Column{Field: "weight", Pos: 5, DataType: "decimal", ColumnType: "decimal(20,4)"},
+		&ddl.Column{Field: "position", Pos: 6, DataType: "int", ColumnType: "int(11)"},
+	))
+
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `catalog_eav_attribute` (`attribute_id`,`is_global`,`is_wysiwyg_enabled`,`flags`,`weight`,`position`) VALUES (?,?,?,?,?,?)")).
+		WithArgs(uint64(65535), uint64(2), int64(1), int64(129), "1234567890123456.7891", int64(-3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	dbMock.ExpectCommit()
+
+	r, err := binlogsync.NewReplayer(dbc, binlogsync.WithReplayTables(tables))
+	require.NoError(t, err)
+
+	table := &myreplicator.TableMapEvent{Schema: []byte("shop"), Table: []byte("catalog_eav_attribute")}
+	applyEvents(t, r, []*myreplicator.BinlogEvent{{
+		Header: &myreplicator.EventHeader{Timestamp: 1500000000, EventType: myreplicator.WRITE_ROWS_EVENTv2, LogPos: 230, EventSize: 10},
+		Event: &myreplicator.RowsEvent{Table: table, Rows: [][]interface{}{
+			{int16(-1), int32(2), int8(1), int64(129), "1234567890123456.7891", int32(-3)},
+		}},
+	}})
+}
@@ -0,0 +1,406 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+)
+
+// Row contains the values of a table row keyed by the column name. The values
+// are of type dml.NullString, dml.NullInt64, dml.NullUint64, dml.NullFloat64,
+// dml.NullBool for tinyint(1), dml.NullTime, dml.Decimal or []byte for binary,
+// blob and JSON columns.
+type Row map[string]interface{}
+
+// RowChange represents a single changed row. Before is nil for inserts and
+// After is nil for deletes.
+type RowChange struct {
+	Before Row
+	After  Row
+	// Changed contains, in column order, the names of the columns which differ
+	// between Before and After. Only set for updates.
+	Changed []string
+}
+
+// IsChanged returns true if the column has been modified by an update.
+func (rc RowChange) IsChanged(column string) bool {
+	for _, c := range rc.Changed {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// RowsChangeEvent contains all changed rows of a table for a single binlog rows
+// event.
+type RowsChangeEvent struct {
+	// Action is one of the constants InsertAction, UpdateAction or
+	// DeleteAction.
+	Action  string
+	Table   ddl.Table
+	Changes []RowChange
+}
+
+// RowsChangeFilter restricts the delivery of RowsChangeEvent to a table and,
+// for updates, to a list of columns. An empty Table matches all tables. If
+// Columns is not empty, an updated row gets only delivered if at least one of
+// the columns has changed.
+type RowsChangeFilter struct {
+	Table   string
+	Columns []string
+}
+
+func (f RowsChangeFilter) matchTable(name string) bool {
+	return f.Table == "" || f.Table == name
+}
+
+func (f RowsChangeFilter) matchChange(action string, rc RowChange) bool {
+	if action != UpdateAction || len(f.Columns) == 0 {
+		return true
+	}
+	for _, c := range f.Columns {
+		if rc.IsChanged(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// RowsChangeHandler adapts a function which receives typed and column named
+// row changes to the RowsEventHandler interface. Register it via
+// Canal.RegisterRowsEventHandler.
+type RowsChangeHandler struct {
+	// Name gets returned by String.
+	Name string
+	// Filters if empty all tables get delivered. Otherwise at least one filter
+	// must match.
+	Filters []RowsChangeFilter
+	// OnChange gets called for each rows event which passes the filters.
+	OnChange func(context.Context, *RowsChangeEvent) error
+	// OnComplete optional function gets called after a committed transaction
+	// and before a binlog rotation.
+	OnComplete func(context.Context) error
+	// OnSchemaChange optional function gets called after a DDL statement has
+	// modified the structure of a table.
+	OnSchemaChange func(context.Context, SchemaChangeEvent) error
+}
+
+// NewRowsChangeHandler creates a new handler which converts the raw binlog
+// rows into RowsChangeEvent.
+func NewRowsChangeHandler(name string, fn func(context.Context, *RowsChangeEvent) error, filters ...RowsChangeFilter) *RowsChangeHandler {
+	return &RowsChangeHandler{
+		Name:     name,
+		Filters:  filters,
+		OnChange: fn,
+	}
+}
+
+// Do implements RowsEventHandler.
+func (h *RowsChangeHandler) Do(ctx context.Context, action string, t ddl.Table, rows [][]interface{}) error {
+	var filters []RowsChangeFilter
+	for _, f := range h.Filters {
+		if f.matchTable(t.Name) {
+			filters = append(filters, f)
+		}
+	}
+	if len(h.Filters) > 0 && len(filters) == 0 {
+		return nil
+	}
+
+	ev, err := NewRowsChangeEvent(action, t, rows)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(filters) > 0 {
+		changes := ev.Changes[:0]
+		for _, rc := range ev.Changes {
+			for _, f := range filters {
+				if f.matchChange(action, rc) {
+					changes = append(changes, rc)
+					break
+				}
+			}
+		}
+		ev.Changes = changes
+	}
+	if len(ev.Changes) == 0 {
+		return nil
+	}
+	return h.OnChange(ctx, ev)
+}
+
+// Complete implements RowsEventHandler.
+func (h *RowsChangeHandler) Complete(ctx context.Context) error {
+	if h.OnComplete == nil {
+		return nil
+	}
+	return h.OnComplete(ctx)
+}
+
+// SchemaChange implements SchemaChangeHandler.
+func (h *RowsChangeHandler) SchemaChange(ctx context.Context, ev SchemaChangeEvent) error {
+	if h.OnSchemaChange == nil {
+		return nil
+	}
+	for _, f := range h.Filters {
+		if f.matchTable(ev.Table) || f.matchTable(ev.NewTable) {
+			return h.OnSchemaChange(ctx, ev)
+		}
+	}
+	if len(h.Filters) > 0 {
+		return nil
+	}
+	return h.OnSchemaChange(ctx, ev)
+}
+
+// String implements RowsEventHandler.
+func (h *RowsChangeHandler) String() string { return h.Name }
+
+// NewRowsChangeEvent converts the raw binlog rows into column named rows. For
+// updates the rows slice must contain pairs of before and after rows.
+func NewRowsChangeEvent(action string, t ddl.Table, rows [][]interface{}) (*RowsChangeEvent, error) {
+	ev := &RowsChangeEvent{
+		Action: action,
+		Table:  t,
+	}
+	switch action {
+	case InsertAction, DeleteAction:
+		ev.Changes = make([]RowChange, 0, len(rows))
+		for _, r := range rows {
+			row, err := makeRow(t.Columns, r)
+			if err != nil {
+				return nil, errors.Wrapf(err, "[binlogsync] Table %q", t.Name)
+			}
+			if action == InsertAction {
+				ev.Changes = append(ev.Changes, RowChange{After: row})
+			} else {
+				ev.Changes = append(ev.Changes, RowChange{Before: row})
+			}
+		}
+	case UpdateAction:
+		if len(rows)%2 != 0 {
+			return nil, errors.NotValid.Newf("[binlogsync] Table %q: update rows must be pairs of before and after rows, got %d rows", t.Name, len(rows))
+		}
+		rows, err := applyPartialRows(rows)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[binlogsync] Table %q", t.Name)
+		}
+		ev.Changes = make([]RowChange, 0, len(rows)/2)
+		for i := 0; i < len(rows); i += 2 {
+			before, err := makeRow(t.Columns, rows[i])
+			if err != nil {
+				return nil, errors.Wrapf(err, "[binlogsync] Table %q", t.Name)
+			}
+			after, err := makeRow(t.Columns, rows[i+1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "[binlogsync] Table %q", t.Name)
+			}
+			rc := RowChange{Before: before, After: after}
+			for _, c := range t.Columns {
+				if !equalValue(before[c.Field], after[c.Field]) {
+					rc.Changed = append(rc.Changed, c.Field)
+				}
+			}
+			ev.Changes = append(ev.Changes, rc)
+		}
+	default:
+		return nil, errors.NotSupported.Newf("[binlogsync] Action %q not supported", action)
+	}
+	return ev, nil
+}
+
+func makeRow(cols ddl.Columns, raw []interface{}) (Row, error) {
+	if len(raw) > len(cols) {
+		return nil, errors.Mismatch.Newf("[binlogsync] Row has %d values but table has only %d columns", len(raw), len(cols))
+	}
+	row := make(Row, len(raw))
+	for i, v := range raw {
+		cv, err := convertValue(cols[i], v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[binlogsync] Column %q", cols[i].Field)
+		}
+		row[cols[i].Field] = cv
+	}
+	return row, nil
+}
+
+// convertValue converts a decoded binlog value into the dml Null type
+// according to the column definition. NULL values result in the invalid Null
+// type.
+func convertValue(c *ddl.Column, v interface{}) (interface{}, error) {
+	switch c.DataType {
+	case "tinyint", "smallint", "mediumint", "int", "integer", "bigint", "year", "bit":
+		if isBool(c) {
+			i, ok := toInt64(v)
+			return dml.MakeNullBool(i != 0, ok), nil
+		}
+		if c.IsUnsigned() {
+			u, ok := toUint64(v, c.DataType)
+			return dml.MakeNullUint64(u, ok), nil
+		}
+		i, ok := toInt64(v)
+		return dml.MakeNullInt64(i, ok), nil
+
+	case "float", "double", "real":
+		switch val := v.(type) {
+		case float32:
+			return dml.MakeNullFloat64(float64(val)), nil
+		case float64:
+			return dml.MakeNullFloat64(val), nil
+		}
+		return dml.NullFloat64{}, nil
+
+	case "decimal", "numeric":
+		switch val := v.(type) {
+		case float64:
+			d, err := dml.MakeDecimalFloat64(val)
+			return d, errors.WithStack(err)
+		case string:
+			d, err := dml.MakeDecimalBytes([]byte(val))
+			return d, errors.WithStack(err)
+		}
+		return dml.Decimal{}, nil
+
+	case "date", "datetime", "timestamp":
+		switch val := v.(type) {
+		case time.Time:
+			return dml.MakeNullTime(val), nil
+		case string:
+			return parseNullTime(val), nil
+		case interface{ String() string }:
+			return parseNullTime(val.String()), nil
+		}
+		return dml.NullTime{}, nil
+
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob", "json", "geometry":
+		switch val := v.(type) {
+		case []byte:
+			return val, nil
+		case string:
+			return []byte(val), nil
+		case myreplicator.JsonDiffs:
+			return nil, errors.NotSupported.Newf("[binlogsync] Partial JSON update %v can only be converted as part of an update", val)
+		}
+		return []byte(nil), nil
+	}
+
+	switch val := v.(type) {
+	case nil:
+		return dml.NullString{}, nil
+	case string:
+		return dml.MakeNullString(val), nil
+	case []byte:
+		return dml.MakeNullString(string(val)), nil
+	case interface{ String() string }:
+		return dml.MakeNullString(val.String()), nil
+	}
+	i, ok := toInt64(v)
+	if !ok {
+		return nil, errors.NotSupported.Newf("[binlogsync] Type %T of column type %q not supported", v, c.DataType)
+	}
+	// for example ENUM and SET columns get transmitted as integer
+	return dml.MakeNullString(strconv.FormatInt(i, 10)), nil
+}
+
+// isBool reports whether the column has been declared as BOOL or BOOLEAN,
+// which MySQL stores as tinyint(1). The column name does not matter and BIT
+// columns can contain more than one bit, so both stay integers.
+func isBool(c *ddl.Column) bool {
+	return c.DataType == "tinyint" && strings.HasPrefix(c.ColumnType, "tinyint(1)")
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch val := v.(type) {
+	case int8:
+		return int64(val), true
+	case int16:
+		return int64(val), true
+	case int32:
+		return int64(val), true
+	case int64:
+		return val, true
+	case int:
+		return int64(val), true
+	case uint8:
+		return int64(val), true
+	case uint16:
+		return int64(val), true
+	case uint32:
+		return int64(val), true
+	case uint64:
+		return int64(val), true
+	}
+	return 0, false
+}
+
+// toUint64 reinterprets the signed integers from the binlog for unsigned
+// columns.
+func toUint64(v interface{}, dataType string) (uint64, bool) {
+	switch val := v.(type) {
+	case int8:
+		return uint64(uint8(val)), true
+	case int16:
+		return uint64(uint16(val)), true
+	case int32:
+		if dataType == "mediumint" {
+			return uint64(uint32(val) & 0xFFFFFF), true
+		}
+		return uint64(uint32(val)), true
+	case int64:
+		return uint64(val), true
+	}
+	i, ok := toInt64(v)
+	return uint64(i), ok
+}
+
+var timeLayouts = [...]string{
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseNullTime parses the string representation of a date or datetime. Zero
+// dates like 0000-00-00 result in an invalid NullTime.
+func parseNullTime(s string) dml.NullTime {
+	for _, l := range timeLayouts {
+		if t, err := time.ParseInLocation(l, s, time.UTC); err == nil {
+			return dml.MakeNullTime(t)
+		}
+	}
+	return dml.NullTime{}
+}
+
+func equalValue(a, b interface{}) bool {
+	switch av := a.(type) {
+	case []byte:
+		bv, ok := b.([]byte)
+		return ok && bytes.Equal(av, bv)
+	case dml.NullTime:
+		bv, ok := b.(dml.NullTime)
+		return ok && av.Valid == bv.Valid && av.Time.Equal(bv.Time)
+	}
+	return a == b
+}
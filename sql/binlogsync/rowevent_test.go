@@ -0,0 +1,144 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ binlogsync.RowsEventHandler = (*binlogsync.RowsChangeHandler)(nil)
+
+var customerTable = ddl.Table{
+	Name: "customer_entity",
+	Columns: ddl.Columns{
+		&ddl.Column{Field: "entity_id", Pos: 1, DataType: "int", ColumnType: "int(10) unsigned"},
+		&ddl.Column{Field: "email", Pos: 2, DataType: "varchar", ColumnType: "varchar(255)"},
+		&ddl.Column{Field: "is_active", Pos: 3, DataType: "smallint", ColumnType: "smallint(5) unsigned"},
+		&ddl.Column{Field: "created_at", Pos: 4, DataType: "timestamp", ColumnType: "timestamp"},
+		&ddl.Column{Field: "dob", Pos: 5, DataType: "date", ColumnType: "date"},
+		&ddl.Column{Field: "credit", Pos: 6, DataType: "decimal", ColumnType: "decimal(12,4)"},
+	},
+}
+
+var flagsTable = ddl.Table{
+	Name: "catalog_eav_attribute",
+	Columns: ddl.Columns{
+		&ddl.Column{Field: "attribute_id", Pos: 1, DataType: "smallint", ColumnType: "smallint(5) unsigned"},
+		&ddl.Column{Field: "is_global", Pos: 2, DataType: "int", ColumnType: "int(10) unsigned"},
+		&ddl.Column{Field: "used_in_product_listing", Pos: 3, DataType: "smallint", ColumnType: "smallint(5) unsigned"},
+		&ddl.Column{Field: "is_wysiwyg_enabled", Pos: 4, DataType: "tinyint", ColumnType: "tinyint(1)"},
+		&ddl.Column{Field: "has_options", Pos: 5, DataType: "tinyint", ColumnType: "tinyint(4)"},
+		&ddl.Column{Field: "flags", Pos: 6, DataType: "bit", ColumnType: "bit(8)"},
+	},
+}
+
+func TestNewRowsChangeEvent(t *testing.T) {
+	t.Parallel()
+
+	created := time.Date(2018, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	t.Run("insert", func(t *testing.T) {
+		ev, err := binlogsync.NewRowsChangeEvent(binlogsync.InsertAction, customerTable, [][]interface{}{
+			{int32(-1), "a@b.c", int16(1), created, nil, float64(12.5)},
+		})
+		require.NoError(t, err, "%+v", err)
+		require.Len(t, ev.Changes, 1)
+		assert.Nil(t, ev.Changes[0].Before)
+		assert.Exactly(t, binlogsync.Row{
+			"entity_id":  dml.MakeNullUint64(4294967295),
+			"email":      dml.MakeNullString("a@b.c"),
+			"is_active":  dml.MakeNullUint64(1),
+			"created_at": dml.MakeNullTime(created),
+			"dob":        dml.NullTime{},
+			"credit":     dml.Decimal{Precision: 125, Scale: 1, Valid: true},
+		}, ev.Changes[0].After)
+	})
+
+	t.Run("update with changed columns", func(t *testing.T) {
+		ev, err := binlogsync.NewRowsChangeEvent(binlogsync.UpdateAction, customerTable, [][]interface{}{
+			{int32(3), "a@b.c", int16(1), created, "1980-01-02", float64(10)},
+			{int32(3), "x@y.z", int16(0), created, "1980-01-02", float64(10)},
+		})
+		require.NoError(t, err, "%+v", err)
+		require.Len(t, ev.Changes, 1)
+		assert.Exactly(t, []string{"email", "is_active"}, ev.Changes[0].Changed)
+		assert.Exactly(t, dml.MakeNullString("a@b.c"), ev.Changes[0].Before["email"])
+		assert.Exactly(t, dml.MakeNullString("x@y.z"), ev.Changes[0].After["email"])
+		assert.Exactly(t, dml.MakeNullTime(time.Date(1980, 1, 2, 0, 0, 0, 0, time.UTC)), ev.Changes[0].After["dob"])
+		assert.True(t, ev.Changes[0].IsChanged("is_active"))
+		assert.False(t, ev.Changes[0].IsChanged("dob"))
+	})
+
+	t.Run("only tinyint(1) is bool", func(t *testing.T) {
+		ev, err := binlogsync.NewRowsChangeEvent(binlogsync.InsertAction, flagsTable, [][]interface{}{
+			{int16(94), int32(2), int16(1), int8(1), int8(2), int64(5)},
+		})
+		require.NoError(t, err, "%+v", err)
+		require.Len(t, ev.Changes, 1)
+		assert.Exactly(t, binlogsync.Row{
+			"attribute_id":            dml.MakeNullUint64(94),
+			"is_global":               dml.MakeNullUint64(2),
+			"used_in_product_listing": dml.MakeNullUint64(1),
+			"is_wysiwyg_enabled":      dml.MakeNullBool(true),
+			"has_options":             dml.MakeNullInt64(2),
+			"flags":                   dml.MakeNullInt64(5),
+		}, ev.Changes[0].After)
+	})
+
+	t.Run("update with odd rows", func(t *testing.T) {
+		_, err := binlogsync.NewRowsChangeEvent(binlogsync.UpdateAction, customerTable, [][]interface{}{{int32(3)}})
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+
+	t.Run("too many values", func(t *testing.T) {
+		_, err := binlogsync.NewRowsChangeEvent(binlogsync.DeleteAction, ddl.Table{Name: "x"}, [][]interface{}{{int32(3)}})
+		assert.True(t, errors.Mismatch.Match(err), "%+v", err)
+	})
+}
+
+func TestRowsChangeHandler_Filters(t *testing.T) {
+	t.Parallel()
+
+	rows := [][]interface{}{
+		{int32(3), "a@b.c", int16(1), nil, nil, nil},
+		{int32(3), "x@y.z", int16(1), nil, nil, nil},
+		{int32(4), "d@e.f", int16(1), nil, nil, nil},
+		{int32(4), "d@e.f", int16(0), nil, nil, nil},
+	}
+
+	var got []*binlogsync.RowsChangeEvent
+	h := binlogsync.NewRowsChangeHandler("test", func(_ context.Context, ev *binlogsync.RowsChangeEvent) error {
+		got = append(got, ev)
+		return nil
+	}, binlogsync.RowsChangeFilter{Table: "customer_entity", Columns: []string{"email"}})
+
+	require.NoError(t, h.Do(context.TODO(), binlogsync.UpdateAction, customerTable, rows))
+	require.NoError(t, h.Do(context.TODO(), binlogsync.UpdateAction, ddl.Table{Name: "sales_order"}, rows))
+	require.NoError(t, h.Do(context.TODO(), binlogsync.UpdateAction, customerTable, rows[2:]))
+
+	require.Len(t, got, 1)
+	require.Len(t, got[0].Changes, 1)
+	assert.Exactly(t, dml.MakeNullUint64(3), got[0].Changes[0].After["entity_id"])
+	assert.Exactly(t, "test", h.String())
+}
@@ -0,0 +1,329 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"golang.org/x/sync/errgroup"
+)
+
+// Schema action constants describe the kind of a SchemaChangeEvent.
+const (
+	SchemaCreateAction = "create"
+	SchemaAlterAction  = "alter"
+	SchemaRenameAction = "rename"
+	SchemaDropAction   = "drop"
+)
+
+// SchemaChangeEvent describes a DDL statement which has modified the
+// structure of a table.
+type SchemaChangeEvent struct {
+	// Action is one of the constants SchemaCreateAction, SchemaAlterAction,
+	// SchemaRenameAction or SchemaDropAction.
+	Action string
+	Schema string
+	Table  string
+	// NewTable contains for renamed tables the new name.
+	NewTable string
+	// Query contains the full DDL statement.
+	Query string
+	// Position points to the end of the query event in the binary log.
+	Position ddl.MasterStatus
+	// Timestamp when the statement has been executed on the master.
+	Timestamp time.Time
+}
+
+// SchemaChangeHandler can be implemented additionally by a RowsEventHandler to
+// get notified once the structure of a table has changed. At the time of the
+// call, the table cache of the Canal has already been refreshed. Same error
+// rules apply here like for the function RowsEventHandler.Do.
+type SchemaChangeHandler interface {
+	SchemaChange(ctx context.Context, ev SchemaChangeEvent) error
+}
+
+const ddlIdentifier = "(?:`[^`]+`|[\\w$]+)"
+const ddlTableName = ddlIdentifier + "(?:\\s*\\.\\s*" + ddlIdentifier + ")?"
+
+var (
+	ddlComments   = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	ddlAlter      = regexp.MustCompile("(?is)^ALTER\\s+(?:ONLINE\\s+|OFFLINE\\s+|IGNORE\\s+)*TABLE\\s+(?:IF\\s+EXISTS\\s+)?(" + ddlTableName + ")(.*)$")
+	ddlAlterRen   = regexp.MustCompile("(?is)\\bRENAME\\s+(?:TO\\s+|AS\\s+)?(" + ddlTableName + ")")
+	ddlCreate     = regexp.MustCompile("(?is)^CREATE\\s+(?:OR\\s+REPLACE\\s+)?TABLE\\s+(?:IF\\s+NOT\\s+EXISTS\\s+)?(" + ddlTableName + ")")
+	ddlDrop       = regexp.MustCompile("(?is)^DROP\\s+TABLES?\\s+(?:IF\\s+EXISTS\\s+)?(.+)$")
+	ddlRename     = regexp.MustCompile("(?is)^RENAME\\s+TABLES?\\s+(.+)$")
+	ddlRenamePair = regexp.MustCompile("(?is)(" + ddlTableName + ")\\s+TO\\s+(" + ddlTableName + ")")
+	ddlTableList  = regexp.MustCompile("(?is)" + ddlTableName)
+)
+
+// splitTableName splits a possible qualified and quoted table name into the
+// database and the table name. If the name is not qualified, defaultSchema
+// gets returned.
+func splitTableName(defaultSchema, name string) (schema, table string) {
+	schema = defaultSchema
+	if i := strings.LastIndex(name, "."); i > 0 && strings.Count(name[:i], "`")%2 == 0 {
+		schema, name = strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+1:])
+		schema = strings.Trim(schema, "`")
+	}
+	return schema, strings.Trim(name, "`")
+}
+
+// parseSchemaChanges detects table structure modifying DDL statements. The
+// fields Query, Position and Timestamp of the returned events are empty.
+// Temporary tables and non-DDL statements return nil.
+func parseSchemaChanges(defaultSchema, query string) []SchemaChangeEvent {
+	q := strings.TrimSpace(ddlComments.ReplaceAllString(query, " "))
+	q = strings.TrimSuffix(q, ";")
+
+	newEvent := func(action, name string) SchemaChangeEvent {
+		s, t := splitTableName(defaultSchema, name)
+		return SchemaChangeEvent{Action: action, Schema: s, Table: t}
+	}
+
+	switch {
+	case ddlAlter.MatchString(q):
+		m := ddlAlter.FindStringSubmatch(q)
+		ev := newEvent(SchemaAlterAction, m[1])
+		for _, mr := range ddlAlterRen.FindAllStringSubmatch(m[2], -1) {
+			switch strings.ToUpper(mr[1]) {
+			case "COLUMN", "INDEX", "KEY":
+				continue // RENAME COLUMN a TO b
+			}
+			if s, t := splitTableName(ev.Schema, mr[1]); s == ev.Schema {
+				ev.Action = SchemaRenameAction
+				ev.NewTable = t
+			} else {
+				ev.Action = SchemaDropAction
+			}
+		}
+		return []SchemaChangeEvent{ev}
+
+	case ddlCreate.MatchString(q):
+		return []SchemaChangeEvent{newEvent(SchemaCreateAction, ddlCreate.FindStringSubmatch(q)[1])}
+
+	case ddlDrop.MatchString(q):
+		list := ddlDrop.FindStringSubmatch(q)[1]
+		var evs []SchemaChangeEvent
+		for _, name := range ddlTableList.FindAllString(list, -1) {
+			if u := strings.ToUpper(name); u == "RESTRICT" || u == "CASCADE" {
+				continue
+			}
+			evs = append(evs, newEvent(SchemaDropAction, name))
+		}
+		return evs
+
+	case ddlRename.MatchString(q):
+		list := ddlRename.FindStringSubmatch(q)[1]
+		var evs []SchemaChangeEvent
+		for _, m := range ddlRenamePair.FindAllStringSubmatch(list, -1) {
+			ev := newEvent(SchemaRenameAction, m[1])
+			s, t := splitTableName(defaultSchema, m[2])
+			if s != ev.Schema {
+				// moved into another database, from our point of view a drop
+				ev.Action = SchemaDropAction
+			} else {
+				ev.NewTable = t
+			}
+			evs = append(evs, ev)
+		}
+		return evs
+	}
+	return nil
+}
+
+// handleQueryEvent invalidates and reloads the cached table structures if the
+// query event contains a DDL statement for the database of the DSN. Afterwards
+// all RowsEventHandler implementing SchemaChangeHandler get notified. As the
+// events get processed in binlog order, all following rows events use the new
+// table structure.
+//
+// Limitation: The table gets reloaded from the current information_schema and
+// not from the schema at the position of the event. If the Canal lags behind
+// and a later ALTER TABLE has already been executed, the reloaded structure is
+// too new. Rows events get corrected via tableFromMetadata, but only if the
+// master writes the column names into the TABLE_MAP event
+// (binlog_row_metadata=FULL, MySQL >= 8.0.1).
+func (c *Canal) handleQueryEvent(ctx context.Context, e *myreplicator.BinlogEvent, pos ddl.MasterStatus) error {
+	qe, ok := e.Event.(*myreplicator.QueryEvent)
+	if !ok {
+		return errors.NewFatalf("[binlogsync] handleQueryEvent: Failed to cast to *myreplicator.QueryEvent type")
+	}
+
+	for _, sc := range parseSchemaChanges(string(qe.Schema), string(qe.Query)) {
+		if sc.Schema != c.DSN.DBName {
+			continue
+		}
+		sc.Query = string(qe.Query)
+		sc.Position = pos
+		sc.Timestamp = time.Unix(int64(e.Header.Timestamp), 0)
+
+		c.ClearTableCache(sc.Schema, sc.Table)
+		reload := sc.Table
+		switch sc.Action {
+		case SchemaRenameAction:
+			c.ClearTableCache(sc.Schema, sc.NewTable)
+			reload = sc.NewTable
+		case SchemaDropAction:
+			reload = ""
+		}
+
+		if c.Log.IsInfo() {
+			c.Log.Info("[binlogsync] Table structure changed, clear table cache",
+				log.String("action", sc.Action), log.String("database", sc.Schema),
+				log.String("table", sc.Table), log.String("new_table", sc.NewTable), log.Stringer("position", pos))
+		}
+
+		if reload != "" {
+			// The table might have already been dropped by a later statement.
+			// It must not stay in the cache, otherwise the next rows event
+			// would use an outdated or empty column set.
+			_, err := c.FindTable(ctx, reload)
+			switch {
+			case errors.IsNotFound(err):
+				c.ClearTableCache(sc.Schema, reload)
+			case err != nil:
+				return errors.Wrapf(err, "[binlogsync] handleQueryEvent failed to reload table %q", reload)
+			}
+		}
+
+		if err := c.travelSchemaChangeHandler(ctx, sc); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// tableFromMetadata compares the cached table structure with the column names
+// of the TABLE_MAP event. If they differ, the returned table contains the
+// columns in the order of the binlog. Columns unknown to the cached structure
+// get derived from the binlog column types. Without column names in the
+// TABLE_MAP event the cached table gets returned unchanged.
+func (c *Canal) tableFromMetadata(t ddl.Table, tme *myreplicator.TableMapEvent) ddl.Table {
+	names := tme.ColumnNameString()
+	if names == nil || strings.Join(names, ",") == t.Columns.JoinFields(",") {
+		return t
+	}
+
+	unsigned := tme.UnsignedMap()
+	cols := make(ddl.Columns, len(names))
+	for i, n := range names {
+		if t.Columns.Contains(n) {
+			cols[i] = t.Columns.ByField(n)
+			continue
+		}
+		col := &ddl.Column{Field: n, Pos: uint64(i + 1)}
+		if i < len(tme.ColumnType) {
+			col.DataType = binlogDataType(tme.ColumnType[i])
+		}
+		col.ColumnType = col.DataType
+		if unsigned[i] {
+			col.ColumnType += " unsigned"
+		}
+		cols[i] = col
+	}
+
+	if c.Log.IsDebug() {
+		c.Log.Debug("[binlogsync] Cached table structure differs from binlog metadata",
+			log.String("table", t.Name), log.Strings("cached_columns", t.Columns.FieldNames()...),
+			log.Strings("binlog_columns", names...))
+	}
+
+	nt := ddl.NewTable(t.Name, cols...)
+	nt.DB = t.DB
+	nt.Schema = t.Schema
+	nt.Listeners = t.Listeners
+	nt.IsView = t.IsView
+	return *nt
+}
+
+// binlogDataType maps the column type of a TABLE_MAP event to the
+// information_schema DATA_TYPE.
+func binlogDataType(typ byte) string {
+	switch typ {
+	case gomysql.MYSQL_TYPE_TINY:
+		return "tinyint"
+	case gomysql.MYSQL_TYPE_SHORT:
+		return "smallint"
+	case gomysql.MYSQL_TYPE_INT24:
+		return "mediumint"
+	case gomysql.MYSQL_TYPE_LONG:
+		return "int"
+	case gomysql.MYSQL_TYPE_LONGLONG:
+		return "bigint"
+	case gomysql.MYSQL_TYPE_FLOAT:
+		return "float"
+	case gomysql.MYSQL_TYPE_DOUBLE:
+		return "double"
+	case gomysql.MYSQL_TYPE_DECIMAL, gomysql.MYSQL_TYPE_NEWDECIMAL:
+		return "decimal"
+	case gomysql.MYSQL_TYPE_YEAR:
+		return "year"
+	case gomysql.MYSQL_TYPE_BIT:
+		return "bit"
+	case gomysql.MYSQL_TYPE_DATE, gomysql.MYSQL_TYPE_NEWDATE:
+		return "date"
+	case gomysql.MYSQL_TYPE_DATETIME, gomysql.MYSQL_TYPE_DATETIME2:
+		return "datetime"
+	case gomysql.MYSQL_TYPE_TIMESTAMP, gomysql.MYSQL_TYPE_TIMESTAMP2:
+		return "timestamp"
+	case gomysql.MYSQL_TYPE_TIME, gomysql.MYSQL_TYPE_TIME2:
+		return "time"
+	case gomysql.MYSQL_TYPE_JSON:
+		return "json"
+	case gomysql.MYSQL_TYPE_GEOMETRY:
+		return "geometry"
+	case gomysql.MYSQL_TYPE_TINY_BLOB, gomysql.MYSQL_TYPE_MEDIUM_BLOB, gomysql.MYSQL_TYPE_LONG_BLOB, gomysql.MYSQL_TYPE_BLOB:
+		return "blob"
+	}
+	// VARCHAR, STRING, ENUM and SET get decoded as string or integer.
+	return "varchar"
+}
+
+func (c *Canal) travelSchemaChangeHandler(ctx context.Context, sc SchemaChangeEvent) error {
+	c.rsMu.RLock()
+	defer c.rsMu.RUnlock()
+
+	erg, ctx := errgroup.WithContext(ctx)
+
+	for _, h := range c.rsHandlers {
+		sh, ok := h.(SchemaChangeHandler)
+		if !ok {
+			continue
+		}
+		h := h
+		erg.Go(func() error {
+			err := sh.SchemaChange(ctx, sc)
+			isInterr := errors.IsInterrupted(err)
+			if err != nil && !isInterr {
+				c.Log.Info("[binlogsync] Handler.SchemaChange error", log.Err(err), log.Stringer("handler_name", h),
+					log.String("action", sc.Action), log.String("schema", sc.Schema), log.String("table", sc.Table))
+			} else if isInterr {
+				c.Log.Info("[binlogsync] Handler.SchemaChange Interrupt", log.Err(err), log.Stringer("handler_name", h),
+					log.String("action", sc.Action), log.String("schema", sc.Schema), log.String("table", sc.Table))
+				return errors.Wrap(err, "[binlogsync] travelSchemaChangeHandler interrupted")
+			}
+			return nil
+		})
+	}
+	return errors.Wrap(erg.Wait(), "[binlogsync] travelSchemaChangeHandler errgroup Wait")
+}
@@ -0,0 +1,236 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	"github.com/corestoreio/pkg/sync/singleflight"
+	"github.com/go-sql-driver/mysql"
+	gomysql "github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchemaChanges(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query string
+		want  []SchemaChangeEvent
+	}{
+		{"ALTER TABLE `customer_entity` ADD COLUMN x int", []SchemaChangeEvent{
+			{Action: SchemaAlterAction, Schema: "shop", Table: "customer_entity"}}},
+		{"/* pt-osc */ alter online table `shop2` . `a` drop x;", []SchemaChangeEvent{
+			{Action: SchemaAlterAction, Schema: "shop2", Table: "a"}}},
+		{"ALTER TABLE a RENAME TO b", []SchemaChangeEvent{
+			{Action: SchemaRenameAction, Schema: "shop", Table: "a", NewTable: "b"}}},
+		{"ALTER TABLE a RENAME COLUMN x TO y, RENAME INDEX i TO j", []SchemaChangeEvent{
+			{Action: SchemaAlterAction, Schema: "shop", Table: "a"}}},
+		{"CREATE TABLE IF NOT EXISTS `b` (id int)", []SchemaChangeEvent{
+			{Action: SchemaCreateAction, Schema: "shop", Table: "b"}}},
+		{"DROP TABLE IF EXISTS a, `other`.`b` RESTRICT", []SchemaChangeEvent{
+			{Action: SchemaDropAction, Schema: "shop", Table: "a"},
+			{Action: SchemaDropAction, Schema: "other", Table: "b"}}},
+		{"RENAME TABLE a TO a_old, c TO other.c", []SchemaChangeEvent{
+			{Action: SchemaRenameAction, Schema: "shop", Table: "a", NewTable: "a_old"},
+			{Action: SchemaDropAction, Schema: "shop", Table: "c"}}},
+		{"CREATE TEMPORARY TABLE t (id int)", nil},
+		{"DROP TEMPORARY TABLE t", nil},
+		{"TRUNCATE TABLE a", nil},
+		{"INSERT INTO a VALUES (1)", nil},
+		{"BEGIN", nil},
+	}
+	for _, test := range tests {
+		assert.Exactly(t, test.want, parseSchemaChanges("shop", test.query), "%s", test.query)
+	}
+}
+
+func TestCanal_handleQueryEvent(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	c := &Canal{
+		DSN:      &mysql.Config{DBName: "TestDB"},
+		db:       dbc.DB,
+		tables:   ddl.MustNewTables(),
+		tableSFG: new(singleflight.Group),
+		Log:      log.BlackHole{},
+	}
+	c.tables.Schema = c.DSN.DBName
+	require.NoError(t, c.tables.Options(
+		ddl.WithTable("customer_entity", &ddl.Column{Field: "entity_id"}, &ddl.Column{Field: "email"}),
+		ddl.WithTable("sales_order", &ddl.Column{Field: "entity_id"}),
+		ddl.WithTable("quote", &ddl.Column{Field: "entity_id"}),
+		ddl.WithTable("tmp_import", &ddl.Column{Field: "id"}),
+	))
+
+	var (
+		mu     sync.Mutex
+		events []SchemaChangeEvent
+	)
+	rch := NewRowsChangeHandler("test", func(context.Context, *RowsChangeEvent) error { return nil })
+	rch.OnSchemaChange = func(_ context.Context, ev SchemaChangeEvent) error {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+		return nil
+	}
+	c.RegisterRowsEventHandler(rch)
+
+	dbMock.ExpectQuery("SELECT.+FROM information_schema.COLUMNS WHERE.+TABLE_NAME IN.+'customer_entity'").
+		WillReturnRows(dmltest.MustMockRows(dmltest.WithFile("testdata/customer_entity_columns.csv")))
+	dbMock.ExpectQuery("SELECT.+FROM information_schema.COLUMNS WHERE.+TABLE_NAME IN.+'sales_order_archive'").
+		WillReturnRows(dmltest.MustMockRows(dmltest.WithFile("testdata/sales_order_archive_columns.csv")))
+	dbMock.ExpectQuery("SELECT.+FROM information_schema.COLUMNS WHERE.+TABLE_NAME IN.+'customer_entity'").
+		WillReturnRows(dmltest.MustMockRows(dmltest.WithFile("testdata/customer_entity_columns.csv")))
+
+	// The fixture contains the DDL statements of a Magento upgrade. It mirrors
+	// a MySQL 8.0.23 binlog with binlog_checksum=CRC32 and gtid_mode=OFF,
+	// because RENAME COLUMN requires MySQL 8. It has been generated and not
+	// captured from a server, replace it with a capture once one is available.
+	err := myreplicator.NewFileReader(myreplicator.EventFilter{}).ReadFiles(func(file string, e *myreplicator.BinlogEvent) error {
+		if _, ok := e.Event.(*myreplicator.QueryEvent); !ok {
+			return nil
+		}
+		return c.handleQueryEvent(context.TODO(), e, ddl.MasterStatus{File: file, Position: uint(e.Header.LogPos)})
+	}, "testdata/ddl-bin.000001")
+	require.NoError(t, err, "%+v", err)
+
+	require.Len(t, events, 6)
+	assert.Exactly(t, SchemaAlterAction, events[0].Action)
+	assert.Exactly(t, "customer_entity", events[0].Table)
+	assert.Exactly(t, "ddl-bin.000001", events[0].Position.File)
+	assert.Exactly(t, "ALTER TABLE `customer_entity` ADD COLUMN `nickname` varchar(64) NULL", events[0].Query)
+	assert.Exactly(t, int64(1615900010), events[0].Timestamp.Unix())
+
+	assert.Exactly(t, SchemaRenameAction, events[1].Action)
+	assert.Exactly(t, "sales_order_archive", events[1].NewTable)
+	assert.Exactly(t, SchemaDropAction, events[2].Action)
+	assert.Exactly(t, "quote", events[2].Table)
+	assert.Exactly(t, "tmp_import", events[3].Table)
+	assert.Exactly(t, "tmp_import2", events[4].Table)
+	assert.Exactly(t, SchemaAlterAction, events[5].Action, "RENAME COLUMN is no table rename")
+	assert.True(t, events[0].Position.Compare(events[5].Position) < 0)
+
+	tbl, err := c.FindTable(context.TODO(), "customer_entity")
+	require.NoError(t, err)
+	assert.Exactly(t, []string{"entity_id", "email", "nickname"}, tbl.Columns.FieldNames())
+
+	tbl, err = c.FindTable(context.TODO(), "sales_order_archive")
+	require.NoError(t, err)
+	assert.Exactly(t, []string{"entity_id", "grand_total"}, tbl.Columns.FieldNames())
+
+	for _, name := range []string{"sales_order", "quote", "tmp_import"} {
+		_, err := c.tables.Table(name)
+		assert.True(t, errors.NotFound.Match(err), "Table %q should have been removed from the cache: %+v", name, err)
+	}
+}
+
+func TestCanal_handleQueryEvent_DroppedTable(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	c := &Canal{
+		DSN:      &mysql.Config{DBName: "TestDB"},
+		db:       dbc.DB,
+		tables:   ddl.MustNewTables(),
+		tableSFG: new(singleflight.Group),
+		Log:      log.BlackHole{},
+	}
+	c.tables.Schema = c.DSN.DBName
+	require.NoError(t, c.tables.Options(
+		ddl.WithTable("customer_entity", &ddl.Column{Field: "entity_id"}),
+	))
+
+	// The ALTER TABLE gets processed after the table has already been dropped
+	// by a later statement, so the information_schema returns no columns.
+	dbMock.ExpectQuery("SELECT.+FROM information_schema.COLUMNS WHERE.+TABLE_NAME IN.+'customer_entity'").
+		WillReturnRows(sqlmock.NewRows([]string{"TABLE_NAME", "COLUMN_NAME", "ORDINAL_POSITION", "COLUMN_DEFAULT", "IS_NULLABLE", "DATA_TYPE", "CHARACTER_MAXIMUM_LENGTH", "NUMERIC_PRECISION", "NUMERIC_SCALE", "COLUMN_TYPE", "COLUMN_KEY", "EXTRA", "COLUMN_COMMENT"}))
+
+	e := &myreplicator.BinlogEvent{
+		Header: &myreplicator.EventHeader{Timestamp: 1520000010, LogPos: 4711},
+		Event: &myreplicator.QueryEvent{
+			Schema: []byte("TestDB"),
+			Query:  []byte("ALTER TABLE `customer_entity` ADD COLUMN `nickname` varchar(64) NULL"),
+		},
+	}
+	err := c.handleQueryEvent(context.TODO(), e, ddl.MasterStatus{File: "mysql-bin.000001", Position: 4711})
+	require.NoError(t, err, "%+v", err)
+
+	_, err = c.tables.Table("customer_entity")
+	assert.True(t, errors.NotFound.Match(err), "Table should have been removed from the cache: %+v", err)
+}
+
+func TestCanal_tableFromMetadata(t *testing.T) {
+	t.Parallel()
+
+	c := &Canal{Log: log.BlackHole{}}
+	cached := *ddl.NewTable("customer_entity",
+		&ddl.Column{Field: "entity_id", Pos: 1, DataType: "int", ColumnType: "int(10) unsigned"},
+		&ddl.Column{Field: "email", Pos: 2, DataType: "varchar", ColumnType: "varchar(255)"},
+		&ddl.Column{Field: "alias", Pos: 3, DataType: "varchar", ColumnType: "varchar(64)"},
+	)
+	cached.Schema = "TestDB"
+
+	t.Run("no metadata", func(t *testing.T) {
+		tme := &myreplicator.TableMapEvent{ColumnCount: 2, ColumnType: []byte{gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR}}
+		nt := c.tableFromMetadata(cached, tme)
+		assert.Exactly(t, []string{"entity_id", "email", "alias"}, nt.Columns.FieldNames())
+	})
+
+	t.Run("metadata equal", func(t *testing.T) {
+		tme := &myreplicator.TableMapEvent{
+			ColumnCount: 3,
+			ColumnType:  []byte{gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_VARCHAR},
+			ColumnName:  [][]byte{[]byte("entity_id"), []byte("email"), []byte("alias")},
+		}
+		nt := c.tableFromMetadata(cached, tme)
+		assert.Exactly(t, []string{"entity_id", "email", "alias"}, nt.Columns.FieldNames())
+	})
+
+	t.Run("cached structure too new", func(t *testing.T) {
+		// The binlog has been written before the column got renamed from
+		// nickname to alias and before the column points got added.
+		tme := &myreplicator.TableMapEvent{
+			ColumnCount:      4,
+			ColumnType:       []byte{gomysql.MYSQL_TYPE_LONG, gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_VARCHAR, gomysql.MYSQL_TYPE_LONGLONG},
+			ColumnName:       [][]byte{[]byte("entity_id"), []byte("email"), []byte("nickname"), []byte("points")},
+			SignednessBitmap: []byte{0xc0},
+		}
+		nt := c.tableFromMetadata(cached, tme)
+		assert.Exactly(t, []string{"entity_id", "email", "nickname", "points"}, nt.Columns.FieldNames())
+		assert.Exactly(t, "TestDB", nt.Schema)
+		assert.Exactly(t, "int(10) unsigned", nt.Columns.ByField("entity_id").ColumnType)
+		assert.Exactly(t, "varchar", nt.Columns.ByField("nickname").DataType)
+		assert.Exactly(t, uint64(3), nt.Columns.ByField("nickname").Pos)
+		assert.Exactly(t, "bigint unsigned", nt.Columns.ByField("points").ColumnType)
+		assert.True(t, nt.Columns.ByField("points").IsUnsigned())
+		// The cached table must not be modified.
+		assert.Exactly(t, []string{"entity_id", "email", "alias"}, cached.Columns.FieldNames())
+	})
+}
@@ -0,0 +1,155 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+)
+
+// EnvelopeVersion defines the current version of the Envelope JSON format.
+// It gets increased on incompatible changes.
+const EnvelopeVersion = 1
+
+// Envelope represents a single changed row as shipped by a Sink.
+type Envelope struct {
+	Version int    `json:"version"`
+	Schema  string `json:"schema"`
+	Table   string `json:"table"`
+	Action  string `json:"action"`
+	Before  Row    `json:"before,omitempty"`
+	After   Row    `json:"after,omitempty"`
+	// Changed contains the modified columns of an update.
+	Changed []string `json:"changed,omitempty"`
+	// Position in the format file;position, see ddl.MasterStatus.
+	Position  string    `json:"position"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewEnvelopes creates for each row change a new envelope. The EventInfo gets
+// extracted from the context.
+func NewEnvelopes(ctx context.Context, ev *RowsChangeEvent) []*Envelope {
+	ei, _ := EventInfoFromContext(ctx)
+	schema := ev.Table.Schema
+	if schema == "" {
+		schema = ei.Schema
+	}
+	envs := make([]*Envelope, 0, len(ev.Changes))
+	for _, rc := range ev.Changes {
+		envs = append(envs, &Envelope{
+			Version:   EnvelopeVersion,
+			Schema:    schema,
+			Table:     ev.Table.Name,
+			Action:    ev.Action,
+			Before:    rc.Before,
+			After:     rc.After,
+			Changed:   rc.Changed,
+			Position:  ei.Position.String(),
+			Timestamp: ei.Timestamp,
+		})
+	}
+	return envs
+}
+
+// Sink ships envelopes to an external system. Implement this interface to
+// connect custom message brokers. Publish gets called with all envelopes of a
+// committed transaction. A Sink must either deliver all envelopes or return an
+// error. Due to the at-least-once delivery, envelopes can be published more
+// than once.
+type Sink interface {
+	Publish(ctx context.Context, envs []*Envelope) error
+	// String returns the name of the sink.
+	String() string
+}
+
+// SinkHandler buffers the changed rows of a transaction and publishes them to
+// the Sink once the transaction has been committed. If publishing fails, the
+// Canal stops saving the binlog position and the SinkHandler retries
+// publishing the buffered envelopes during the next Complete call. After a
+// restart the canal replays all events since the last saved checkpoint. This
+// guarantees at-least-once delivery.
+type SinkHandler struct {
+	*RowsChangeHandler
+	Sink Sink
+
+	mu  sync.Mutex
+	buf []*Envelope
+}
+
+// NewSinkHandler creates a new RowsEventHandler which ships all matching row
+// changes to the sink. Register it via Canal.RegisterRowsEventHandler.
+func NewSinkHandler(s Sink, filters ...RowsChangeFilter) *SinkHandler {
+	sh := &SinkHandler{
+		Sink: s,
+	}
+	sh.RowsChangeHandler = NewRowsChangeHandler("sink:"+s.String(), sh.onChange, filters...)
+	sh.RowsChangeHandler.OnComplete = sh.flush
+	return sh
+}
+
+func (sh *SinkHandler) onChange(ctx context.Context, ev *RowsChangeEvent) error {
+	envs := NewEnvelopes(ctx, ev)
+	sh.mu.Lock()
+	sh.buf = append(sh.buf, envs...)
+	sh.mu.Unlock()
+	return nil
+}
+
+func (sh *SinkHandler) flush(ctx context.Context) error {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if len(sh.buf) == 0 {
+		return nil
+	}
+	if err := sh.Sink.Publish(ctx, sh.buf); err != nil {
+		return errors.Wrapf(err, "[binlogsync] Sink %q failed to publish %d envelopes", sh.Sink, len(sh.buf))
+	}
+	for i := range sh.buf {
+		sh.buf[i] = nil // GC
+	}
+	sh.buf = sh.buf[:0]
+	return nil
+}
+
+// ChanSink sends the envelopes of a transaction to a channel. The receiver
+// must process the envelopes before the next transaction gets published.
+type ChanSink struct {
+	Name string
+	C    chan<- []*Envelope
+}
+
+// NewChanSink creates a new channel based sink.
+func NewChanSink(name string, c chan<- []*Envelope) *ChanSink {
+	return &ChanSink{Name: name, C: c}
+}
+
+// Publish sends a copy of envs to the channel or aborts when the context gets
+// cancelled.
+func (cs *ChanSink) Publish(ctx context.Context, envs []*Envelope) error {
+	cp := make([]*Envelope, len(envs))
+	copy(cp, envs)
+	select {
+	case cs.C <- cp:
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// String returns the name.
+func (cs *ChanSink) String() string { return cs.Name }
@@ -0,0 +1,137 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/util/bufferpool"
+)
+
+// JSONLinesSink writes each envelope as a JSON object in its own line into a
+// file. The file gets rotated when it exceeds MaxBytes or when it is older
+// than MaxAge. File names have the format: Dir/Prefix-20060102T150405.000000000.jsonl
+type JSONLinesSink struct {
+	Dir    string
+	Prefix string
+	// MaxBytes rotates the file when its size exceeds the value. Zero disables
+	// the size based rotation.
+	MaxBytes int64
+	// MaxAge rotates the file when it is older than the duration. Zero
+	// disables the time based rotation.
+	MaxAge time.Duration
+	// Perm defines the file permissions, defaults to 0600.
+	Perm os.FileMode
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	created time.Time
+	now     func() time.Time
+}
+
+// NewJSONLinesSink creates a new JSON Lines sink. The directory must exist.
+func NewJSONLinesSink(dir, prefix string, maxBytes int64, maxAge time.Duration) *JSONLinesSink {
+	return &JSONLinesSink{
+		Dir:      dir,
+		Prefix:   prefix,
+		MaxBytes: maxBytes,
+		MaxAge:   maxAge,
+		Perm:     0600,
+		now:      time.Now,
+	}
+}
+
+// Publish appends the envelopes to the current file and syncs the file to the
+// disk.
+func (js *JSONLinesSink) Publish(_ context.Context, envs []*Envelope) error {
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+
+	enc := json.NewEncoder(buf) // Encode appends a new line
+	for _, e := range envs {
+		if err := enc.Encode(e); err != nil {
+			return errors.Wrapf(err, "[binlogsync] JSONLinesSink failed to encode %s.%s at %q", e.Schema, e.Table, e.Position)
+		}
+	}
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	if err := js.rotate(); err != nil {
+		return errors.WithStack(err)
+	}
+	n, err := js.f.Write(buf.Bytes())
+	js.size += int64(n)
+	if err != nil {
+		return errors.Wrapf(err, "[binlogsync] JSONLinesSink.Write %q", js.f.Name())
+	}
+	return errors.Wrapf(js.f.Sync(), "[binlogsync] JSONLinesSink.Sync %q", js.f.Name())
+}
+
+// rotate opens a new file if required. Must be called with a lock.
+func (js *JSONLinesSink) rotate() error {
+	now := js.now()
+	if js.f != nil {
+		switch {
+		case js.MaxBytes > 0 && js.size >= js.MaxBytes:
+		case js.MaxAge > 0 && now.Sub(js.created) >= js.MaxAge:
+		default:
+			return nil
+		}
+		if err := js.f.Close(); err != nil {
+			return errors.Wrapf(err, "[binlogsync] JSONLinesSink.Close %q", js.f.Name())
+		}
+		js.f = nil
+	}
+
+	name := filepath.Join(js.Dir, js.Prefix+"-"+now.UTC().Format("20060102T150405.000000000")+".jsonl")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, js.Perm)
+	if err != nil {
+		return errors.Wrapf(err, "[binlogsync] JSONLinesSink.OpenFile %q", name)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.Wrapf(err, "[binlogsync] JSONLinesSink.Stat %q", name)
+	}
+	js.f = f
+	js.size = fi.Size()
+	js.created = now
+	return nil
+}
+
+// Close closes the current file.
+func (js *JSONLinesSink) Close() error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+	if js.f == nil {
+		return nil
+	}
+	err := js.f.Close()
+	js.f = nil
+	return errors.WithStack(err)
+}
+
+// String returns the name of the sink.
+func (js *JSONLinesSink) String() string {
+	return "jsonl:" + filepath.Join(js.Dir, js.Prefix)
+}
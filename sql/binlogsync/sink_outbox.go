@@ -0,0 +1,98 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+)
+
+// OutboxSink writes the envelopes of a transaction into an outbox table. All
+// envelopes of one Publish call get inserted within a single database
+// transaction. A relay process reads the table ordered by the auto increment
+// ID, forwards the payload to a message broker and deletes the forwarded rows.
+// Due to the at-least-once delivery the relay must tolerate duplicate
+// envelopes, for example by comparing the position column.
+type OutboxSink struct {
+	DB *sql.DB
+	// TableName defaults to `binlogsync_outbox`.
+	TableName string
+}
+
+// NewOutboxSink creates a new outbox table sink. The database can be a
+// different server than the master.
+func NewOutboxSink(db *sql.DB, tableName string) *OutboxSink {
+	if tableName == "" {
+		tableName = "binlogsync_outbox"
+	}
+	return &OutboxSink{
+		DB:        db,
+		TableName: tableName,
+	}
+}
+
+// CreateTable creates the outbox table if it does not yet exists.
+func (ob *OutboxSink) CreateTable(ctx context.Context) error {
+	_, err := ob.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+dml.Quoter.Name(ob.TableName)+` (
+  `+"`id`"+` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `+"`schema`"+` VARCHAR(64) NOT NULL,
+  `+"`table`"+` VARCHAR(64) NOT NULL,
+  `+"`action`"+` VARCHAR(16) NOT NULL,
+  `+"`position`"+` VARCHAR(255) NOT NULL,
+  `+"`payload`"+` LONGTEXT NOT NULL,
+  `+"`created_at`"+` TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`+"`id`"+`)
+) ENGINE=InnoDB`)
+	return errors.Wrapf(err, "[binlogsync] OutboxSink.CreateTable %q", ob.TableName)
+}
+
+// Publish inserts the JSON encoded envelopes. Either all or none of the
+// envelopes get written.
+func (ob *OutboxSink) Publish(ctx context.Context, envs []*Envelope) (err error) {
+	tx, err := ob.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrapf(err, "[binlogsync] OutboxSink.BeginTx %q", ob.TableName)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO "+dml.Quoter.Name(ob.TableName)+
+		" (`schema`,`table`,`action`,`position`,`payload`) VALUES (?,?,?,?,?)")
+	if err != nil {
+		return errors.Wrapf(err, "[binlogsync] OutboxSink.Prepare %q", ob.TableName)
+	}
+	defer stmt.Close()
+
+	for _, e := range envs {
+		payload, err := json.Marshal(e)
+		if err != nil {
+			return errors.Wrapf(err, "[binlogsync] OutboxSink failed to encode %s.%s at %q", e.Schema, e.Table, e.Position)
+		}
+		if _, err := stmt.ExecContext(ctx, e.Schema, e.Table, e.Action, e.Position, string(payload)); err != nil {
+			return errors.Wrapf(err, "[binlogsync] OutboxSink failed to insert %s.%s at %q", e.Schema, e.Table, e.Position)
+		}
+	}
+	return errors.Wrapf(tx.Commit(), "[binlogsync] OutboxSink.Commit %q", ob.TableName)
+}
+
+// String returns the name of the sink.
+func (ob *OutboxSink) String() string { return "outbox:" + ob.TableName }
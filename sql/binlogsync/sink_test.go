@@ -0,0 +1,238 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync_test
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/corestoreio/pkg/util/hashpool"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ binlogsync.Sink             = (*binlogsync.ChanSink)(nil)
+	_ binlogsync.Sink             = (*binlogsync.JSONLinesSink)(nil)
+	_ binlogsync.Sink             = (*binlogsync.WebhookSink)(nil)
+	_ binlogsync.Sink             = (*binlogsync.OutboxSink)(nil)
+	_ binlogsync.RowsEventHandler = (*binlogsync.SinkHandler)(nil)
+)
+
+func init() {
+	if err := hashpool.Register("sha256", sha256.New); err != nil {
+		panic(err)
+	}
+}
+
+var sinkTestRows = [][]interface{}{
+	{int32(3), "a@b.c", int16(1), nil, nil, nil},
+	{int32(4), "d@e.f", int16(0), nil, nil, nil},
+}
+
+func TestSinkHandler(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan []*binlogsync.Envelope, 1)
+	sh := binlogsync.NewSinkHandler(binlogsync.NewChanSink("test", ch))
+	assert.Exactly(t, "sink:test", sh.String())
+
+	ctx := binlogsync.WithEventInfo(context.TODO(), binlogsync.EventInfo{
+		Schema:    "shop",
+		Position:  ddl.MasterStatus{File: "mysql-bin.000001", Position: 4711},
+		Timestamp: time.Unix(1500000000, 0),
+	})
+	require.NoError(t, sh.Do(ctx, binlogsync.InsertAction, customerTable, sinkTestRows))
+	select {
+	case <-ch:
+		t.Fatal("Envelopes must not be published before Complete")
+	default:
+	}
+
+	require.NoError(t, sh.Complete(context.TODO()))
+	envs := <-ch
+	require.Len(t, envs, 2)
+	assert.Exactly(t, binlogsync.EnvelopeVersion, envs[0].Version)
+	assert.Exactly(t, "customer_entity", envs[0].Table)
+	assert.Exactly(t, binlogsync.InsertAction, envs[1].Action)
+	assert.Exactly(t, "mysql-bin.000001;4711", envs[1].Position)
+	assert.Exactly(t, "shop", envs[1].Schema)
+
+	// nothing buffered, nothing published
+	require.NoError(t, sh.Complete(context.TODO()))
+	assert.Len(t, ch, 0)
+}
+
+func TestSinkHandler_RetryBufferOnError(t *testing.T) {
+	t.Parallel()
+
+	ch := make(chan []*binlogsync.Envelope) // unbuffered, blocks
+	sh := binlogsync.NewSinkHandler(binlogsync.NewChanSink("test", ch))
+	require.NoError(t, sh.Do(context.TODO(), binlogsync.DeleteAction, customerTable, sinkTestRows))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, sh.Complete(ctx))
+
+	go func() { assert.NoError(t, sh.Complete(context.TODO())) }()
+	envs := <-ch
+	assert.Len(t, envs, 2, "buffered envelopes must be published again")
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "binlogsync")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	js := binlogsync.NewJSONLinesSink(dir, "customer", 10, 0)
+	env := &binlogsync.Envelope{Version: 1, Schema: "db", Table: "customer_entity", Action: "insert", Position: "mysql-bin.000001;4", Timestamp: time.Unix(1500000000, 0).UTC()}
+
+	require.NoError(t, js.Publish(context.TODO(), []*binlogsync.Envelope{env, env}))
+	time.Sleep(time.Millisecond) // file names have nano second precision
+	require.NoError(t, js.Publish(context.TODO(), []*binlogsync.Envelope{env}))
+	require.NoError(t, js.Close())
+
+	files, err := filepath.Glob(filepath.Join(dir, "customer-*.jsonl"))
+	require.NoError(t, err)
+	require.Len(t, files, 2, "File should have been rotated")
+
+	f, err := os.Open(files[0])
+	require.NoError(t, err)
+	defer f.Close()
+	var lines int
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var have binlogsync.Envelope
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &have))
+		assert.Exactly(t, "mysql-bin.000001;4", have.Position)
+		lines++
+	}
+	assert.Exactly(t, 2, lines)
+}
+
+func TestWebhookSink(t *testing.T) {
+	t.Parallel()
+
+	key := []byte("s3cr3t")
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		mac := hmac.New(sha256.New, key)
+		mac.Write(body)
+		assert.Exactly(t, "sha256 "+hex.EncodeToString(mac.Sum(nil)), r.Header.Get(binlogsync.HeaderContentHMAC))
+
+		var envs []*binlogsync.Envelope
+		require.NoError(t, json.Unmarshal(body, &envs))
+		assert.Len(t, envs, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ws, err := binlogsync.NewWebhookSink(srv.URL, binlogsync.WithWebhookHMAC("sha256", key))
+	require.NoError(t, err)
+	ws.Backoff = time.Millisecond
+
+	err = ws.Publish(context.TODO(), []*binlogsync.Envelope{{Version: 1, Table: "customer_entity"}})
+	require.NoError(t, err, "%+v", err)
+	assert.Exactly(t, int32(2), atomic.LoadInt32(&calls))
+
+	t.Run("client error does not retry", func(t *testing.T) {
+		var calls int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		ws, err := binlogsync.NewWebhookSink(srv.URL)
+		require.NoError(t, err)
+		err = ws.Publish(context.TODO(), []*binlogsync.Envelope{{Version: 1}})
+		assert.True(t, errors.NotAcceptable.Match(err), "%+v", err)
+		assert.Exactly(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("unregistered hash", func(t *testing.T) {
+		_, err := binlogsync.NewWebhookSink(srv.URL, binlogsync.WithWebhookHMAC("md4711", key))
+		assert.True(t, errors.NotFound.Match(err), "%+v", err)
+	})
+}
+
+func TestOutboxSink(t *testing.T) {
+	t.Parallel()
+
+	envs := []*binlogsync.Envelope{
+		{Version: 1, Schema: "shop", Table: "customer_entity", Action: "insert", Position: "mysql-bin.000001;4711", Timestamp: time.Unix(1500000000, 0).UTC()},
+		{Version: 1, Schema: "shop", Table: "customer_entity", Action: "delete", Position: "mysql-bin.000001;4711", Timestamp: time.Unix(1500000000, 0).UTC()},
+	}
+	const insertSQL = "INSERT INTO `binlogsync_outbox` (`schema`,`table`,`action`,`position`,`payload`) VALUES (?,?,?,?,?)"
+
+	t.Run("insert in one transaction", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		prep := dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(insertSQL))
+		for _, e := range envs {
+			payload, err := json.Marshal(e)
+			require.NoError(t, err)
+			prep.ExpectExec().WithArgs("shop", "customer_entity", e.Action, "mysql-bin.000001;4711", string(payload)).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+		}
+		dbMock.ExpectCommit()
+
+		ob := binlogsync.NewOutboxSink(dbc.DB, "")
+		assert.Exactly(t, "outbox:binlogsync_outbox", ob.String())
+		err := ob.Publish(context.TODO(), envs)
+		require.NoError(t, err, "%+v", err)
+	})
+
+	t.Run("rollback on error", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectBegin()
+		prep := dbMock.ExpectPrepare(dmltest.SQLMockQuoteMeta(insertSQL))
+		prep.ExpectExec().WillReturnResult(sqlmock.NewResult(1, 1))
+		prep.ExpectExec().WillReturnError(errors.New("Lock wait timeout exceeded"))
+		dbMock.ExpectRollback()
+
+		err := binlogsync.NewOutboxSink(dbc.DB, "").Publish(context.TODO(), envs)
+		assert.Error(t, err)
+	})
+}
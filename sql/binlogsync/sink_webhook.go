@@ -0,0 +1,160 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/util/hashpool"
+)
+
+// HeaderContentHMAC defines the HTTP header which contains the signature of
+// the webhook body. The format is compatible with package net/signed:
+//		Content-HMAC: <hash name> <hex encoded HMAC>
+const HeaderContentHMAC = `Content-Hmac`
+
+// WebhookSink POSTs the envelopes of a transaction as a JSON array to an URL.
+// Requests which fail due to network errors or with a status code of 429 or
+// 5xx get retried with an exponential back off.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+	// MaxRetries defines how often a failed request gets repeated. Default 5.
+	MaxRetries int
+	// Backoff initial waiting time between two retries, doubles with each
+	// retry. Default 500ms.
+	Backoff time.Duration
+	// Header optional additional HTTP headers, for example Authorization.
+	Header http.Header
+
+	hashName string
+	hashPool hashpool.Tank
+	signed   bool
+}
+
+// WebhookOption applies options to the WebhookSink.
+type WebhookOption func(*WebhookSink) error
+
+// WithWebhookHMAC signs the request body with a HMAC. The hash name must have
+// been registered before via hashpool.Register, for example:
+//		hashpool.Register(`sha256`, sha256.New)
+func WithWebhookHMAC(hashName string, key []byte) WebhookOption {
+	return func(ws *WebhookSink) (err error) {
+		ws.hashPool, err = hashpool.FromRegistryHMAC(hashName, key)
+		if err != nil {
+			return errors.Wrapf(err, "[binlogsync] The hash %q has not yet been registered via hashpool.Register() function.", hashName)
+		}
+		ws.hashName = hashName
+		ws.signed = true
+		return nil
+	}
+}
+
+// WithWebhookClient sets a custom HTTP client.
+func WithWebhookClient(c *http.Client) WebhookOption {
+	return func(ws *WebhookSink) error {
+		ws.Client = c
+		return nil
+	}
+}
+
+// NewWebhookSink creates a new HTTP webhook sink.
+func NewWebhookSink(url string, opts ...WebhookOption) (*WebhookSink, error) {
+	ws := &WebhookSink{
+		URL:        url,
+		Client:     &http.Client{Timeout: 30 * time.Second},
+		MaxRetries: 5,
+		Backoff:    500 * time.Millisecond,
+	}
+	for _, o := range opts {
+		if err := o(ws); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return ws, nil
+}
+
+// Publish sends the envelopes. It returns the last error once all retries
+// have been exhausted.
+func (ws *WebhookSink) Publish(ctx context.Context, envs []*Envelope) error {
+	body, err := json.Marshal(envs)
+	if err != nil {
+		return errors.Wrap(err, "[binlogsync] WebhookSink failed to encode envelopes")
+	}
+	var signature string
+	if ws.signed {
+		signature = ws.hashName + " " + hex.EncodeToString(ws.hashPool.Sum(body, nil))
+	}
+
+	backoff := ws.Backoff
+	for try := 0; ; try++ {
+		retry, err := ws.send(ctx, body, signature)
+		if err == nil {
+			return nil
+		}
+		if !retry || try >= ws.MaxRetries {
+			return errors.Wrapf(err, "[binlogsync] WebhookSink %q failed after %d tries", ws.URL, try+1)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// send reports if a failed request can be retried.
+func (ws *WebhookSink) send(ctx context.Context, body []byte, signature string) (retry bool, _ error) {
+	req, err := http.NewRequest("POST", ws.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	req = req.WithContext(ctx)
+	for k, v := range ws.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if signature != "" {
+		req.Header.Set(HeaderContentHMAC, signature)
+	}
+
+	resp, err := ws.Client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, errors.WithStack(err)
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	switch sc := resp.StatusCode; {
+	case sc >= 200 && sc < 300:
+		return false, nil
+	case sc == http.StatusTooManyRequests || sc >= 500:
+		return true, errors.Unavailable.Newf("[binlogsync] WebhookSink %q responded with status %d", ws.URL, sc)
+	default:
+		return false, errors.NotAcceptable.Newf("[binlogsync] WebhookSink %q responded with status %d", ws.URL, sc)
+	}
+}
+
+// String returns the URL.
+func (ws *WebhookSink) String() string { return "webhook:" + ws.URL }
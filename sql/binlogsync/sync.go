@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/corestoreio/pkg/sql/ddl"
 	"github.com/corestoreio/pkg/sql/myreplicator"
 	"github.com/corestoreio/errors"
 	"github.com/corestoreio/log"
@@ -17,29 +18,22 @@ const (
 	DeleteAction = "delete"
 )
 
-func (c *Canal) clearTableCacheOnAlterTableStatement(schema, query []byte) {
-	if mb := c.expAlterTable.FindSubmatch(query); mb != nil {
-		if len(mb[1]) == 0 {
-			mb[1] = schema
+func (c *Canal) startSyncBinlog(ctxArg context.Context) error {
+	pos := c.SyncedPosition()
+
+	var s *myreplicator.BinlogStreamer
+	var err error
+	if c.resumeGTID && c.masterGTIDSet != nil {
+		if c.Log.IsInfo() {
+			c.Log.Info("[binlogsync] Start syncing of binlog", log.Stringer("gtid_set", c.masterGTIDSet))
 		}
-		scma := string(mb[1])
-		tbl := string(mb[2])
-		c.ClearTableCache(scma, tbl)
+		s, err = c.syncer.StartSyncGTID(c.masterGTIDSet)
+	} else {
 		if c.Log.IsInfo() {
-			c.Log.Info("[binlogsync] Table structure changed, clear table cache",
-				log.String("database", scma), log.String("table", tbl))
+			c.Log.Info("[binlogsync] Start syncing of binlog", log.Stringer("position", pos))
 		}
+		s, err = c.syncer.StartSync(pos)
 	}
-}
-
-func (c *Canal) startSyncBinlog(ctxArg context.Context) error {
-	pos := c.masterStatus
-
-	if c.Log.IsInfo() {
-		c.Log.Info("[binlogsync] Start syncing of binlog", log.Stringer("position", pos))
-	}
-
-	s, err := c.syncer.StartSync(pos)
 	if err != nil {
 		return errors.NewFatalf("[binlogsync] Start sync replication at %s error %v", pos, err)
 	}
@@ -61,56 +55,91 @@ func (c *Canal) startSyncBinlog(ctxArg context.Context) error {
 		timeout = time.Second
 
 		//next binlog pos
-		pos.Position = uint(ev.Header.LogPos)
+		if ev.Header.LogPos > 0 {
+			pos.Position = uint(ev.Header.LogPos)
+		}
 
 		switch e := ev.Event.(type) {
 		case *myreplicator.RotateEvent:
-			if err := c.flushEventHandlers(ctxArg); err != nil {
-				// todo maybe better err handling ...
-				return errors.Wrap(err, "[binlogsync] startSyncBinlog.flushEventHandlers")
-			}
 			pos.File = string(e.NextLogName)
 			pos.Position = uint(e.Position)
-			// r.ev <- pos
-
 			if c.Log.IsInfo() {
 				c.Log.Info("[binlogsync] Rotate binlog to a new position", log.Stringer("position", pos))
 			}
+			if err := c.commit(ctxArg, pos, true); err != nil {
+				return errors.Wrap(err, "[binlogsync] startSyncBinlog.RotateEvent")
+			}
 
 		case *myreplicator.RowsEvent:
-			// we only focus row based event.
-			// NotFound errors get ignores. For example table has been deleted
-			// and an old event pops in.
+			// we only focus row based event. A handler error stops the
+			// syncer, so the transaction does not get committed and its
+			// position does not get saved.
 			if err = c.handleRowsEvent(ctxArg, ev); err != nil {
-				isNotFound := errors.IsNotFound(err)
-				if c.Log.IsInfo() {
-					c.Log.Info("[binlogsync] Rotate binlog to a new position", log.Err(err), log.Stringer("position", pos), log.Bool("ignore_not_found_error", isNotFound))
-				}
-				if !isNotFound {
-					return errors.Wrap(err, "[binlogsync] handleRowsEvent")
-				}
-				continue
+				return errors.Wrap(err, "[binlogsync] handleRowsEvent")
 			}
+			// The position gets saved once the transaction has been committed.
+
+		case *myreplicator.GTIDEvent:
+			c.masterBeginGTID(formatMySQLGTID(e.SID, e.GNO))
+
+		case *myreplicator.MariadbGTIDEvent:
+			e.GTID.ServerID = ev.Header.ServerID
+			c.masterBeginGTID(e.GTID.String())
+
 		case *myreplicator.XIDEvent:
-			// try to save the position later
+			// A transaction has been committed.
+			if err := c.commit(ctxArg, pos, false); err != nil {
+				return errors.Wrap(err, "[binlogsync] startSyncBinlog.XIDEvent")
+			}
+
 		case *myreplicator.QueryEvent:
-			// handle alert table query
-			c.clearTableCacheOnAlterTableStatement(e.Schema, e.Query)
-			// save master position, so no continue
-		case
-			*myreplicator.TableMapEvent,
-			*myreplicator.FormatDescriptionEvent:
-			// maybe add: *replication.XIDEvent
-			// don't update Master with file and position
+			if string(e.Query) == "BEGIN" {
+				// never save a position in the middle of a transaction
+				continue
+			}
+			// DDL statements refresh the table cache before the next rows
+			// event gets decoded.
+			if err := c.handleQueryEvent(ctxArg, ev, pos); err != nil {
+				return errors.Wrap(err, "[binlogsync] startSyncBinlog.handleQueryEvent")
+			}
+			// DDL statements and transactions on non-transactional tables
+			// end with a QueryEvent, for example COMMIT, instead of a
+			// XIDEvent.
+			if err := c.commit(ctxArg, pos, false); err != nil {
+				return errors.Wrap(err, "[binlogsync] startSyncBinlog.QueryEvent")
+			}
+
 		default:
-			continue
+			// *myreplicator.TableMapEvent, *myreplicator.FormatDescriptionEvent
+			// don't update Master with file and position
 		}
+	}
+}
 
-		c.masterUpdate(pos.File, pos.Position)
-		if err := c.masterSave(); err != nil {
-			c.Log.Info("[binlogsync] startSyncBinlog: Failed to save master position", log.Err(err), log.Stringer("position", pos))
-		}
+// commit gets called once a transaction or a single statement has been
+// committed. It calls Complete on all RowsEventHandler and updates and saves
+// the master status only after all of them have returned successfully. If a
+// handler fails to complete, the master status and the pending GTIDs stay
+// untouched and get saved with the next successful commit. Saving gets
+// throttled unless force is true.
+func (c *Canal) commit(ctx context.Context, pos ddl.MasterStatus, force bool) error {
+	completed, err := c.flushEventHandlers(ctx)
+	if err != nil {
+		return errors.Wrap(err, "[binlogsync] commit.flushEventHandlers")
+	}
+	if !completed {
+		c.Log.Info("[binlogsync] A RowsEventHandler failed to complete. The master status does not get saved.",
+			log.Stringer("position", pos), log.Stringer("master_status", c.SyncedPosition()))
+		return nil
+	}
+	if err := c.masterCommitGTID(); err != nil {
+		return errors.Wrap(err, "[binlogsync] commit.masterCommitGTID")
+	}
+	c.masterUpdate(pos.File, pos.Position)
+	if err := c.masterSave(ctx, force); err != nil {
+		c.Log.Info("[binlogsync] commit: Failed to save master position", log.Err(err), log.Stringer("position", pos))
 	}
+	return nil
 }
 
 // handleRowsEvent handles an event on the rows and calls all registered rows
@@ -121,8 +150,6 @@ func (c *Canal) handleRowsEvent(ctx context.Context, e *myreplicator.BinlogEvent
 		return errors.NewFatalf("[binlogsync] handleRowsEvent: Failed to cast to *myreplicator.RowsEvent type")
 	}
 
-	// Caveat: table may be altered at runtime.
-
 	if in := string(ev.Table.Schema); c.DSN.DBName != in {
 		if c.Log.IsDebug() {
 			c.Log.Debug("[binlogsync] Skipping database", log.String("database_have", in), log.String("database_want", c.DSN.DBName), log.Int("table_id", int(ev.TableID)))
@@ -133,20 +160,35 @@ func (c *Canal) handleRowsEvent(ctx context.Context, e *myreplicator.BinlogEvent
 	table := string(ev.Table.Table)
 
 	t, err := c.FindTable(ctx, table)
+	if errors.IsNotFound(err) {
+		// For example table has been deleted and an old event pops in.
+		if c.Log.IsInfo() {
+			c.Log.Info("[binlogsync] Skipping rows event of unknown table", log.Err(err), log.String("database", c.DSN.DBName), log.String("table", table))
+		}
+		return nil
+	}
 	if err != nil {
 		return errors.Wrapf(err, "[binlogsync] GetTable %q.%q", c.DSN.DBName, table)
 	}
+	t = c.tableFromMetadata(t, ev.Table)
 	var a string
 	switch e.Header.EventType {
 	case myreplicator.WRITE_ROWS_EVENTv1, myreplicator.WRITE_ROWS_EVENTv2:
 		a = InsertAction
 	case myreplicator.DELETE_ROWS_EVENTv1, myreplicator.DELETE_ROWS_EVENTv2:
 		a = DeleteAction
-	case myreplicator.UPDATE_ROWS_EVENTv1, myreplicator.UPDATE_ROWS_EVENTv2:
+	case myreplicator.UPDATE_ROWS_EVENTv1, myreplicator.UPDATE_ROWS_EVENTv2, myreplicator.PARTIAL_UPDATE_ROWS_EVENT:
 		a = UpdateAction
 	default:
 		return errors.NewNotSupportedf("[binlogsync] EventType %v not yet supported. Table %q.%q", e.Header.EventType, c.DSN.DBName, table)
 	}
+	ms := c.SyncedPosition()
+	ms.Position = uint(e.Header.LogPos)
+	ctx = WithEventInfo(ctx, EventInfo{
+		Schema:    c.DSN.DBName,
+		Position:  ms,
+		Timestamp: time.Unix(int64(e.Header.Timestamp), 0),
+	})
 	return c.travelRowsEventHandler(ctx, a, t, ev.Rows)
 }
 
@@ -0,0 +1,223 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package binlogsync
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/myreplicator"
+	"github.com/corestoreio/pkg/sync/singleflight"
+	"github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanal_handleRowsEvent_PartialJSON(t *testing.T) {
+	t.Parallel()
+
+	c := &Canal{
+		DSN:      &mysql.Config{DBName: "shop"},
+		tables:   ddl.MustNewTables(),
+		tableSFG: new(singleflight.Group),
+		Log:      log.BlackHole{},
+	}
+	c.tables.Schema = c.DSN.DBName
+	require.NoError(t, c.tables.Options(
+		ddl.WithTable("customer_entity",
+			&ddl.Column{Field: "entity_id", Pos: 1, DataType: "int", ColumnType: "int(10) unsigned", Key: "PRI"},
+			&ddl.Column{Field: "email", Pos: 2, DataType: "varchar", ColumnType: "varchar(255)"},
+			&ddl.Column{Field: "attributes", Pos: 3, DataType: "json", ColumnType: "json"},
+			&ddl.Column{Field: "is_active", Pos: 4, DataType: "tinyint", ColumnType: "tinyint(1)"},
+		),
+	))
+
+	var (
+		mu     sync.Mutex
+		events []*RowsChangeEvent
+	)
+	c.RegisterRowsEventHandler(NewRowsChangeHandler("test", func(_ context.Context, ev *RowsChangeEvent) error {
+		mu.Lock()
+		events = append(events, ev)
+		mu.Unlock()
+		return nil
+	}))
+
+	// The fixture contains an UPDATE with binlog_row_value_options=PARTIAL_JSON
+	// which gets written as PARTIAL_UPDATE_ROWS_EVENT.
+	var partialRows [][]interface{}
+	err := myreplicator.NewFileReader(myreplicator.EventFilter{}).ReadFiles(func(_ string, e *myreplicator.BinlogEvent) error {
+		re, ok := e.Event.(*myreplicator.RowsEvent)
+		if !ok {
+			return nil
+		}
+		if e.Header.EventType == myreplicator.PARTIAL_UPDATE_ROWS_EVENT {
+			partialRows = re.Rows
+		}
+		return c.handleRowsEvent(context.TODO(), e)
+	}, "../myreplicator/testdata/mysql8-bin.000001")
+	require.NoError(t, err, "%+v", err)
+
+	// The second event is the INSERT of the compressed transaction.
+	require.Len(t, events, 2)
+	assert.Exactly(t, InsertAction, events[1].Action)
+	ev := events[0]
+	assert.Exactly(t, UpdateAction, ev.Action)
+	require.Len(t, ev.Changes, 1)
+	rc := ev.Changes[0]
+	assert.Exactly(t, []string{"attributes"}, rc.Changed)
+	assert.Exactly(t, []byte(`{"color":"red"}`), rc.Before["attributes"])
+	assert.Exactly(t, []byte(`{"color":"blue"}`), rc.After["attributes"])
+	assert.Exactly(t, dml.MakeNullUint64(3), rc.After["entity_id"])
+	assert.Exactly(t, dml.MakeNullBool(true), rc.After["is_active"])
+
+	// The raw rows of the binlog event must not be modified.
+	require.Len(t, partialRows, 2)
+	assert.IsType(t, myreplicator.JsonDiffs{}, partialRows[1][2])
+}
+
+type recordingCheckpoint struct {
+	mu    sync.Mutex
+	saved []ddl.MasterStatus
+}
+
+func (rc *recordingCheckpoint) SaveCheckpoint(_ context.Context, ms ddl.MasterStatus) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.saved = append(rc.saved, ms)
+	return nil
+}
+
+func (rc *recordingCheckpoint) LoadCheckpoint(_ context.Context) (ms ddl.MasterStatus, err error) {
+	return ms, errors.NotFound.Newf("not saved")
+}
+
+func (rc *recordingCheckpoint) Saved() []ddl.MasterStatus {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]ddl.MasterStatus(nil), rc.saved...)
+}
+
+type funcHandler struct {
+	do       func(context.Context, string, ddl.Table, [][]interface{}) error
+	complete func(context.Context) error
+}
+
+func (fh funcHandler) Do(ctx context.Context, action string, t ddl.Table, rows [][]interface{}) error {
+	if fh.do == nil {
+		return nil
+	}
+	return fh.do(ctx, action, t, rows)
+}
+
+func (fh funcHandler) Complete(ctx context.Context) error {
+	if fh.complete == nil {
+		return nil
+	}
+	return fh.complete(ctx)
+}
+
+func (fh funcHandler) String() string { return "func" }
+
+func TestCanal_commit(t *testing.T) {
+	t.Parallel()
+
+	newCanal := func(complete func(context.Context) error) (*Canal, *recordingCheckpoint) {
+		cp := new(recordingCheckpoint)
+		c := &Canal{
+			DSN:        &mysql.Config{DBName: "shop"},
+			checkpoint: cp,
+			Log:        log.BlackHole{},
+		}
+		c.masterStatus = ddl.MasterStatus{File: "mysql-bin.000001", Position: 4}
+		c.RegisterRowsEventHandler(funcHandler{complete: complete})
+		return c, cp
+	}
+
+	t.Run("saves after Complete returns", func(t *testing.T) {
+		entered := make(chan struct{})
+		release := make(chan struct{})
+		c, cp := newCanal(func(context.Context) error {
+			close(entered)
+			<-release
+			return nil
+		})
+
+		pos := ddl.MasterStatus{File: "mysql-bin.000001", Position: 1234}
+		done := make(chan error)
+		go func() { done <- c.commit(context.TODO(), pos, true) }()
+
+		<-entered
+		assert.Empty(t, cp.Saved(), "position must not be saved while Complete runs")
+		assert.Exactly(t, uint(4), c.SyncedPosition().Position)
+		close(release)
+
+		require.NoError(t, <-done)
+		assert.Exactly(t, []ddl.MasterStatus{pos}, cp.Saved())
+		assert.Exactly(t, pos, c.SyncedPosition())
+	})
+
+	t.Run("failed Complete skips save until next success", func(t *testing.T) {
+		var calls int
+		c, cp := newCanal(func(context.Context) error {
+			calls++
+			if calls == 1 {
+				return errors.New("sink unavailable")
+			}
+			return nil
+		})
+		c.masterBeginGTID("3e11fa47-71ca-11e1-9e33-c80aa9429562:23")
+
+		require.NoError(t, c.commit(context.TODO(), ddl.MasterStatus{File: "mysql-bin.000001", Position: 400}, true))
+		assert.Empty(t, cp.Saved())
+		assert.Exactly(t, uint(4), c.SyncedPosition().Position)
+
+		c.masterBeginGTID("3e11fa47-71ca-11e1-9e33-c80aa9429562:24")
+		require.NoError(t, c.commit(context.TODO(), ddl.MasterStatus{File: "mysql-bin.000001", Position: 800}, true))
+		saved := cp.Saved()
+		require.Len(t, saved, 1)
+		assert.Exactly(t, uint(800), saved[0].Position)
+		assert.Exactly(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:23-24", saved[0].ExecutedGTIDSet)
+	})
+
+	t.Run("interrupted Complete returns error", func(t *testing.T) {
+		c, cp := newCanal(func(context.Context) error {
+			return errors.Interrupted.Newf("stop")
+		})
+		err := c.commit(context.TODO(), ddl.MasterStatus{File: "mysql-bin.000001", Position: 400}, true)
+		assert.True(t, errors.Interrupted.Match(err), "%+v", err)
+		assert.Empty(t, cp.Saved())
+	})
+}
+
+func TestCanal_travelRowsEventHandler_Error(t *testing.T) {
+	t.Parallel()
+
+	c := &Canal{
+		DSN: &mysql.Config{DBName: "shop"},
+		Log: log.BlackHole{},
+	}
+	c.RegisterRowsEventHandler(funcHandler{do: func(context.Context, string, ddl.Table, [][]interface{}) error {
+		return errors.New("broker unavailable")
+	}})
+
+	err := c.travelRowsEventHandler(context.TODO(), InsertAction, ddl.Table{Name: "customer_entity"}, nil)
+	assert.Error(t, err, "Do errors must be returned to stop the syncer before the position gets saved")
+}
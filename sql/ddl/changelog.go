@@ -0,0 +1,402 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/util/bufferpool"
+)
+
+// ChangelogStateTableName defines the name of the table which stores the
+// subscription state of all changelogs. The table structure matches the
+// Magento 2 table `mview_state`.
+var ChangelogStateTableName = "mview_state"
+
+// Changelog status values stored in the state table.
+const (
+	ChangelogStatusIdle    = "idle"
+	ChangelogStatusWorking = "working"
+)
+
+// ChangelogSubscription defines a watched table. Each INSERT, UPDATE or DELETE
+// on the table writes the value of Column into the changelog table.
+type ChangelogSubscription struct {
+	// Table defines the watched table.
+	Table string
+	// Column defines the column whose value gets written into the changelog
+	// table, usually the primary key or the entity_id.
+	Column string
+	// WatchColumns restricts the UPDATE trigger to only write into the
+	// changelog table when one of the columns has been changed. An empty slice
+	// watches all columns.
+	WatchColumns []string
+}
+
+// Changelog implements trigger based change tracking, similar to the Magento 2
+// mview. A changelog table with the name `<ViewID>_cl` stores auto incremented
+// versions and the changed entity IDs. AFTER INSERT, UPDATE and DELETE triggers
+// on all subscribed tables write into the changelog table. The current version
+// of a consumer gets stored in the state table. A changelog works without
+// binlog access.
+type Changelog struct {
+	// Schema represents the name of the database. Might be empty.
+	Schema string
+	// ViewID defines the unique name of the changelog. It gets used as prefix
+	// for the changelog table and the trigger names.
+	ViewID        string
+	Subscriptions []ChangelogSubscription
+}
+
+// NewChangelog creates a new changelog for the view ID and its subscriptions.
+func NewChangelog(viewID string, subs ...ChangelogSubscription) *Changelog {
+	return &Changelog{
+		ViewID:        viewID,
+		Subscriptions: subs,
+	}
+}
+
+// TableName returns the name of the changelog table.
+func (c *Changelog) TableName() string {
+	return TableName("", c.ViewID, "cl")
+}
+
+func (c *Changelog) validate() error {
+	if err := dml.IsValidIdentifier(c.TableName()); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, s := range c.Subscriptions {
+		for _, id := range append([]string{s.Table, s.Column}, s.WatchColumns...) {
+			if err := dml.IsValidIdentifier(id); err != nil {
+				return errors.Wrapf(err, "[ddl] Changelog %q invalid subscription", c.ViewID)
+			}
+		}
+	}
+	return nil
+}
+
+// Triggers returns the triggers which write into the changelog table. The
+// triggers get not created in the database.
+func (c *Changelog) Triggers() []*Trigger {
+	cl := c.TableName()
+	trgs := make([]*Trigger, 0, len(c.Subscriptions)*3)
+	for _, s := range c.Subscriptions {
+		for _, event := range [...]string{"insert", "update", "delete"} {
+			rowAlias := "NEW"
+			if event == "delete" {
+				rowAlias = "OLD"
+			}
+
+			buf := bufferpool.Get()
+			buf.WriteString("BEGIN ")
+			if event == "update" && len(s.WatchColumns) > 0 {
+				buf.WriteString("IF (")
+				for i, wc := range s.WatchColumns {
+					if i > 0 {
+						buf.WriteString(" OR ")
+					}
+					buf.WriteString("NOT(NEW.")
+					dml.Quoter.WriteIdentifier(buf, wc)
+					buf.WriteString(" <=> OLD.")
+					dml.Quoter.WriteIdentifier(buf, wc)
+					buf.WriteByte(')')
+				}
+				buf.WriteString(") THEN ")
+			}
+			buf.WriteString("INSERT IGNORE INTO ")
+			dml.Quoter.WriteQualifierName(buf, c.Schema, cl)
+			buf.WriteString(" (`entity_id`) VALUES (")
+			buf.WriteString(rowAlias)
+			buf.WriteByte('.')
+			dml.Quoter.WriteIdentifier(buf, s.Column)
+			buf.WriteString("); ")
+			if event == "update" && len(s.WatchColumns) > 0 {
+				buf.WriteString("END IF; ")
+			}
+			buf.WriteString("END")
+
+			trgs = append(trgs, &Trigger{
+				Schema:    c.Schema,
+				Name:      TriggerName(c.ViewID+"_"+s.Table, "after", event),
+				Event:     event,
+				Table:     s.Table,
+				Statement: buf.String(),
+				Timing:    "after",
+			})
+			bufferpool.Put(buf)
+		}
+	}
+	return trgs
+}
+
+// Create creates the changelog table, if not exists, and (re)creates all
+// triggers for the subscribed tables.
+func (c *Changelog) Create(ctx context.Context, execer dml.Execer) error {
+	if err := c.validate(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	buf.WriteString("CREATE TABLE IF NOT EXISTS ")
+	dml.Quoter.WriteQualifierName(buf, c.Schema, c.TableName())
+	buf.WriteString(" (`version_id` INT UNSIGNED NOT NULL AUTO_INCREMENT COMMENT 'Version ID', ")
+	buf.WriteString("`entity_id` INT UNSIGNED NOT NULL DEFAULT 0 COMMENT 'Entity ID', ")
+	buf.WriteString("PRIMARY KEY (`version_id`)) ENGINE=InnoDB COMMENT='")
+	buf.WriteString(c.ViewID)
+	buf.WriteString(" changelog'")
+
+	if _, err := execer.ExecContext(ctx, buf.String()); err != nil {
+		return errors.Wrapf(err, "[ddl] Failed to create changelog table %q", buf.String())
+	}
+
+	for _, trg := range c.Triggers() {
+		if err := trg.Create(ctx, execer); err != nil {
+			return errors.Wrapf(err, "[ddl] Changelog %q", c.ViewID)
+		}
+	}
+	return nil
+}
+
+// Drop drops all triggers and the changelog table. The entry in the state
+// table stays untouched.
+func (c *Changelog) Drop(ctx context.Context, execer dml.Execer) error {
+	if err := c.validate(); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, trg := range c.Triggers() {
+		if err := trg.Drop(ctx, execer); err != nil {
+			return errors.Wrapf(err, "[ddl] Changelog %q", c.ViewID)
+		}
+	}
+	_, err := execer.ExecContext(ctx, "DROP TABLE IF EXISTS "+dml.Quoter.QualifierName(c.Schema, c.TableName()))
+	return errors.Wrapf(err, "[ddl] Failed to drop changelog table %q", c.TableName())
+}
+
+// LoadTriggers loads all existing triggers of the subscribed tables which
+// belong to this changelog. Useful to check if the triggers are installed.
+func (c *Changelog) LoadTriggers(ctx context.Context, db dml.Querier) ([]*Trigger, error) {
+	tables := make([]string, 0, len(c.Subscriptions))
+	for _, s := range c.Subscriptions {
+		tables = append(tables, s.Table)
+	}
+	names := map[string]bool{}
+	for _, trg := range c.Triggers() {
+		names[trg.Name] = true
+	}
+	if len(tables) == 0 {
+		return nil, nil
+	}
+	tc, err := LoadTriggers(ctx, db, tables...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var ret []*Trigger
+	for _, tbl := range c.Subscriptions {
+		for _, trg := range tc[tbl.Table] {
+			if names[trg.Name] {
+				ret = append(ret, trg)
+				names[trg.Name] = false // avoid duplicates when a table has been subscribed twice
+			}
+		}
+	}
+	return ret, nil
+}
+
+// CurrentVersion returns the highest version ID of the changelog table.
+func (c *Changelog) CurrentVersion(ctx context.Context, db dml.Querier) (uint64, error) {
+	var v sql.NullInt64
+	err := queryRow(ctx, db, "SELECT MAX(`version_id`) FROM "+dml.Quoter.QualifierName(c.Schema, c.TableName()), &v)
+	if err != nil {
+		return 0, errors.Wrapf(err, "[ddl] Changelog %q CurrentVersion", c.ViewID)
+	}
+	return uint64(v.Int64), nil
+}
+
+// EntityIDs returns the unique entity IDs which have been changed between
+// fromVersion (exclusive) and toVersion (inclusive).
+func (c *Changelog) EntityIDs(ctx context.Context, db dml.Querier, fromVersion, toVersion uint64) (ids []uint64, err error) {
+	rows, err := db.QueryContext(ctx, "SELECT DISTINCT `entity_id` FROM "+dml.Quoter.QualifierName(c.Schema, c.TableName())+
+		" WHERE `version_id` > ? AND `version_id` <= ? ORDER BY `entity_id`", fromVersion, toVersion)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[ddl] Changelog %q EntityIDs", c.ViewID)
+	}
+	defer func() {
+		if err2 := rows.Close(); err2 != nil && err == nil {
+			err = errors.Wrap(err2, "[ddl] Changelog.EntityIDs.Rows.Close")
+		}
+	}()
+	for rows.Next() {
+		var id uint64
+		if err = rows.Scan(&id); err != nil {
+			return nil, errors.Wrapf(err, "[ddl] Changelog %q EntityIDs Scan", c.ViewID)
+		}
+		ids = append(ids, id)
+	}
+	return ids, errors.WithStack(rows.Err())
+}
+
+// Clear deletes all rows from the changelog table whose version is lower or
+// equal than argument version.
+func (c *Changelog) Clear(ctx context.Context, execer dml.Execer, version uint64) error {
+	_, err := execer.ExecContext(ctx, "DELETE FROM "+dml.Quoter.QualifierName(c.Schema, c.TableName())+" WHERE `version_id` <= ?", version)
+	return errors.Wrapf(err, "[ddl] Changelog %q Clear", c.ViewID)
+}
+
+// ChangelogState represents a row in the state table. It stores the already
+// processed version of a changelog.
+type ChangelogState struct {
+	ViewID    string       // view_id varchar(255) NULL
+	Mode      string       // mode varchar(16) NULL DEFAULT 'disabled'
+	Status    string       // status varchar(16) NULL DEFAULT 'idle'
+	Updated   dml.NullTime // updated datetime NULL
+	VersionID uint64       // version_id int(10) unsigned NULL
+}
+
+// MapColumns implements dml.ColumnMapper interface to scan a row returned from
+// a database query.
+func (cs *ChangelogState) MapColumns(cm *dml.ColumnMap) error {
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "view_id":
+			cm.String(&cs.ViewID)
+		case "mode":
+			cm.String(&cs.Mode)
+		case "status":
+			cm.String(&cs.Status)
+		case "updated":
+			cm.NullTime(&cs.Updated)
+		case "version_id":
+			cm.Uint64(&cs.VersionID)
+		default:
+			return errors.NotFound.Newf("[ddl] ChangelogState Column %q not found", c)
+		}
+	}
+	return errors.WithStack(cm.Err())
+}
+
+// State loads the subscription state. If no state has been stored, an empty
+// state with version zero gets returned.
+func (c *Changelog) State(ctx context.Context, db dml.Querier) (cs ChangelogState, err error) {
+	cs.ViewID = c.ViewID
+	var mode, status sql.NullString
+	var updated dml.NullTime
+	var version sql.NullInt64
+	err = queryRow(ctx, db, "SELECT `mode`,`status`,`updated`,`version_id` FROM "+dml.Quoter.Name(ChangelogStateTableName)+
+		" WHERE `view_id` = ?", []interface{}{&mode, &status, &updated, &version}, c.ViewID)
+	switch {
+	case err == sql.ErrNoRows:
+		cs.Status = ChangelogStatusIdle
+		return cs, nil
+	case err != nil:
+		return cs, errors.Wrapf(err, "[ddl] Changelog %q State", c.ViewID)
+	}
+	cs.Mode = mode.String
+	cs.Status = status.String
+	cs.Updated = updated
+	cs.VersionID = uint64(version.Int64)
+	return cs, nil
+}
+
+// SaveState writes the state into the state table. Mode gets set to
+// `enabled`.
+func (c *Changelog) SaveState(ctx context.Context, execer dml.Execer, status string, version uint64) error {
+	_, err := execer.ExecContext(ctx, "INSERT INTO "+dml.Quoter.Name(ChangelogStateTableName)+
+		" (`view_id`,`mode`,`status`,`updated`,`version_id`) VALUES (?,'enabled',?,?,?)"+
+		" ON DUPLICATE KEY UPDATE `mode`=VALUES(`mode`), `status`=VALUES(`status`), `updated`=VALUES(`updated`), `version_id`=VALUES(`version_id`)",
+		c.ViewID, status, time.Now().UTC(), version)
+	return errors.Wrapf(err, "[ddl] Changelog %q SaveState", c.ViewID)
+}
+
+// Update reads incrementally all changed entity IDs since the last stored
+// version and calls `fn` for each batch of versions. The batch size defines the
+// maximum amount of versions per batch. After `fn` returns successfully the new
+// version gets stored in the state table. On error, processing stops and the
+// state keeps the last successfully processed version. Update provides an
+// indexer feed which works without binlog access.
+func (c *Changelog) Update(ctx context.Context, db interface {
+	dml.Execer
+	dml.Querier
+}, batchSize uint64, fn func(entityIDs []uint64) error) error {
+	if batchSize == 0 {
+		batchSize = 1000
+	}
+	state, err := c.State(ctx, db)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	current, err := c.CurrentVersion(ctx, db)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if current <= state.VersionID {
+		return nil
+	}
+
+	if err := c.SaveState(ctx, db, ChangelogStatusWorking, state.VersionID); err != nil {
+		return errors.WithStack(err)
+	}
+	from := state.VersionID
+	for from < current {
+		to := from + batchSize
+		if to > current {
+			to = current
+		}
+		ids, err := c.EntityIDs(ctx, db, from, to)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(ids) > 0 {
+			if err := fn(ids); err != nil {
+				if err2 := c.SaveState(ctx, db, ChangelogStatusIdle, from); err2 != nil {
+					return errors.Wrapf(err2, "[ddl] Changelog %q failed to save state after error: %s", c.ViewID, err)
+				}
+				return errors.Wrapf(err, "[ddl] Changelog %q Update callback failed for versions %d-%d", c.ViewID, from, to)
+			}
+		}
+		from = to
+	}
+	return errors.WithStack(c.SaveState(ctx, db, ChangelogStatusIdle, current))
+}
+
+// queryRow scans a single row. Argument dest can be a single pointer or a
+// slice of pointers.
+func queryRow(ctx context.Context, db dml.Querier, query string, dest interface{}, args ...interface{}) (err error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() {
+		if err2 := rows.Close(); err2 != nil && err == nil {
+			err = errors.WithStack(err2)
+		}
+	}()
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return errors.WithStack(err)
+		}
+		return sql.ErrNoRows
+	}
+	if d, ok := dest.([]interface{}); ok {
+		err = rows.Scan(d...)
+	} else {
+		err = rows.Scan(dest)
+	}
+	return errors.WithStack(err)
+}
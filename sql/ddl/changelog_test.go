@@ -0,0 +1,130 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ dml.ColumnMapper = (*ddl.Trigger)(nil)
+var _ dml.ColumnMapper = (*ddl.ChangelogState)(nil)
+
+func newTestChangelog() *ddl.Changelog {
+	return ddl.NewChangelog("cms_page_idx", ddl.ChangelogSubscription{
+		Table:        "cms_page",
+		Column:       "page_id",
+		WatchColumns: []string{"title", "is_active"},
+	})
+}
+
+func TestChangelog_Triggers(t *testing.T) {
+	t.Parallel()
+
+	trgs := newTestChangelog().Triggers()
+	require.Len(t, trgs, 3)
+
+	assert.Exactly(t, "cms_page_idx_cms_page_after_insert", trgs[0].Name)
+	assert.Exactly(t, "BEGIN INSERT IGNORE INTO `cms_page_idx_cl` (`entity_id`) VALUES (NEW.`page_id`); END", trgs[0].Statement)
+	assert.Exactly(t, "BEGIN IF (NOT(NEW.`title` <=> OLD.`title`) OR NOT(NEW.`is_active` <=> OLD.`is_active`)) THEN INSERT IGNORE INTO `cms_page_idx_cl` (`entity_id`) VALUES (NEW.`page_id`); END IF; END", trgs[1].Statement)
+	assert.Exactly(t, "BEGIN INSERT IGNORE INTO `cms_page_idx_cl` (`entity_id`) VALUES (OLD.`page_id`); END", trgs[2].Statement)
+}
+
+func TestChangelog_Create(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ok", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("CREATE TABLE IF NOT EXISTS `cms_page_idx_cl` (`version_id` INT UNSIGNED NOT NULL AUTO_INCREMENT")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		for _, event := range []string{"insert", "update", "delete"} {
+			dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DROP TRIGGER IF EXISTS `cms_page_idx_cms_page_after_" + event + "`")).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("CREATE TRIGGER `cms_page_idx_cms_page_after_" + event + "` AFTER " + strings.ToUpper(event) + " ON `cms_page` FOR EACH ROW BEGIN")).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+		err := newTestChangelog().Create(context.TODO(), dbc.DB)
+		assert.NoError(t, err, "%+v", err)
+	})
+
+	t.Run("invalid identifier", func(t *testing.T) {
+		cl := ddl.NewChangelog("cms_page_idx", ddl.ChangelogSubscription{Table: "cms page", Column: "page_id"})
+		err := cl.Create(context.TODO(), nil)
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+}
+
+func TestChangelog_Update(t *testing.T) {
+	t.Parallel()
+
+	t.Run("two batches", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `mode`,`status`,`updated`,`version_id` FROM `mview_state` WHERE `view_id` = ?")).
+			WithArgs("cms_page_idx").
+			WillReturnRows(sqlmock.NewRows([]string{"mode", "status", "updated", "version_id"}).AddRow("enabled", "idle", nil, 3))
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT MAX(`version_id`) FROM `cms_page_idx_cl`")).
+			WillReturnRows(sqlmock.NewRows([]string{"MAX(`version_id`)"}).AddRow(7))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `mview_state`")).
+			WithArgs("cms_page_idx", "working", sqlmock.AnyArg(), 3).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT DISTINCT `entity_id` FROM `cms_page_idx_cl` WHERE `version_id` > ? AND `version_id` <= ?")).
+			WithArgs(3, 6).
+			WillReturnRows(sqlmock.NewRows([]string{"entity_id"}).AddRow(11).AddRow(12))
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT DISTINCT `entity_id` FROM `cms_page_idx_cl` WHERE `version_id` > ? AND `version_id` <= ?")).
+			WithArgs(6, 7).
+			WillReturnRows(sqlmock.NewRows([]string{"entity_id"}).AddRow(13))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `mview_state`")).
+			WithArgs("cms_page_idx", "idle", sqlmock.AnyArg(), 7).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		var got [][]uint64
+		err := newTestChangelog().Update(context.TODO(), dbc.DB, 3, func(ids []uint64) error {
+			got = append(got, ids)
+			return nil
+		})
+		require.NoError(t, err, "%+v", err)
+		assert.Exactly(t, [][]uint64{{11, 12}, {13}}, got)
+	})
+
+	t.Run("nothing changed", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `mode`,`status`,`updated`,`version_id` FROM `mview_state` WHERE `view_id` = ?")).
+			WithArgs("cms_page_idx").
+			WillReturnRows(sqlmock.NewRows([]string{"mode", "status", "updated", "version_id"}))
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT MAX(`version_id`) FROM `cms_page_idx_cl`")).
+			WillReturnRows(sqlmock.NewRows([]string{"MAX(`version_id`)"}).AddRow(nil))
+
+		err := newTestChangelog().Update(context.TODO(), dbc.DB, 0, func(ids []uint64) error {
+			t.Fatal("Should not get called")
+			return nil
+		})
+		require.NoError(t, err, "%+v", err)
+	})
+}
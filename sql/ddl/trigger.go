@@ -0,0 +1,177 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/util/bufferpool"
+)
+
+// Trigger represents a single row of table information_schema.TRIGGERS. It
+// can also be used to create or drop a trigger.
+type Trigger struct {
+	Schema string // TRIGGER_SCHEMA varchar(64) NOT NULL  DEFAULT ''''
+	Name   string // TRIGGER_NAME varchar(64) NOT NULL  DEFAULT ''''
+	// Event can be INSERT, UPDATE or DELETE.
+	Event string // EVENT_MANIPULATION varchar(6) NOT NULL  DEFAULT ''''
+	Table string // EVENT_OBJECT_TABLE varchar(64) NOT NULL  DEFAULT ''''
+	// Statement contains the trigger body, usually starting with BEGIN and
+	// ending with END.
+	Statement   string // ACTION_STATEMENT longtext NOT NULL
+	ActionOrder int64  // ACTION_ORDER bigint(4) NOT NULL  DEFAULT '0'
+	// Timing can be BEFORE or AFTER.
+	Timing  string       // ACTION_TIMING varchar(6) NOT NULL  DEFAULT ''''
+	Created dml.NullTime // CREATED datetime(2) NULL  DEFAULT 'NULL'
+}
+
+// MapColumns implements dml.ColumnMapper interface to scan a row returned from
+// a database query.
+func (t *Trigger) MapColumns(cm *dml.ColumnMap) error {
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "TRIGGER_SCHEMA":
+			cm.String(&t.Schema)
+		case "TRIGGER_NAME":
+			cm.String(&t.Name)
+		case "EVENT_MANIPULATION":
+			cm.String(&t.Event)
+		case "EVENT_OBJECT_TABLE":
+			cm.String(&t.Table)
+		case "ACTION_STATEMENT":
+			cm.String(&t.Statement)
+		case "ACTION_ORDER":
+			cm.Int64(&t.ActionOrder)
+		case "ACTION_TIMING":
+			cm.String(&t.Timing)
+		case "CREATED":
+			cm.NullTime(&t.Created)
+		default:
+			return errors.NotFound.Newf("[ddl] Trigger Column %q not found", c)
+		}
+	}
+	return errors.WithStack(cm.Err())
+}
+
+// Create creates the trigger. An existing trigger with the same name gets
+// dropped beforehand. Timing must be BEFORE or AFTER, Event must be INSERT,
+// UPDATE or DELETE.
+func (t *Trigger) Create(ctx context.Context, execer dml.Execer) error {
+	if err := dml.IsValidIdentifier(t.Name); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := dml.IsValidIdentifier(t.Table); err != nil {
+		return errors.WithStack(err)
+	}
+	timing, event := strings.ToUpper(t.Timing), strings.ToUpper(t.Event)
+	switch timing {
+	case "BEFORE", "AFTER":
+	default:
+		return errors.NotValid.Newf("[ddl] Trigger %q: invalid timing %q", t.Name, t.Timing)
+	}
+	switch event {
+	case "INSERT", "UPDATE", "DELETE":
+	default:
+		return errors.NotValid.Newf("[ddl] Trigger %q: invalid event %q", t.Name, t.Event)
+	}
+
+	if err := t.Drop(ctx, execer); err != nil {
+		return errors.WithStack(err)
+	}
+
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	buf.WriteString("CREATE TRIGGER ")
+	dml.Quoter.WriteQualifierName(buf, t.Schema, t.Name)
+	buf.WriteByte(' ')
+	buf.WriteString(timing)
+	buf.WriteByte(' ')
+	buf.WriteString(event)
+	buf.WriteString(" ON ")
+	dml.Quoter.WriteQualifierName(buf, t.Schema, t.Table)
+	buf.WriteString(" FOR EACH ROW ")
+	buf.WriteString(t.Statement)
+
+	if _, err := execer.ExecContext(ctx, buf.String()); err != nil {
+		return errors.Wrapf(err, "[ddl] Failed to create trigger %q", buf.String())
+	}
+	return nil
+}
+
+// Drop drops, if exists, the trigger.
+func (t *Trigger) Drop(ctx context.Context, execer dml.Execer) error {
+	if err := dml.IsValidIdentifier(t.Name); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err := execer.ExecContext(ctx, "DROP TRIGGER IF EXISTS "+dml.Quoter.QualifierName(t.Schema, t.Name))
+	return errors.Wrapf(err, "[ddl] Failed to drop trigger %q", t.Name)
+}
+
+// LoadTriggers loads all triggers for the provided tables in the current
+// database. The returned map has as key the table name. All triggers of the
+// current database gets loaded when the argument `tables` has not been
+// provided.
+func LoadTriggers(ctx context.Context, db dml.Querier, tables ...string) (tc map[string][]*Trigger, err error) {
+	const selTriggers = `SELECT TRIGGER_SCHEMA, TRIGGER_NAME, EVENT_MANIPULATION, EVENT_OBJECT_TABLE,
+	ACTION_STATEMENT, ACTION_ORDER, ACTION_TIMING, CREATED
+	FROM information_schema.TRIGGERS WHERE TRIGGER_SCHEMA = DATABASE()`
+	const selTriggersWhere = ` AND EVENT_OBJECT_TABLE IN ?`
+	const selTriggersOrderBy = ` ORDER BY EVENT_OBJECT_TABLE, ACTION_TIMING, EVENT_MANIPULATION, ACTION_ORDER`
+
+	var rows *sql.Rows
+	if len(tables) == 0 {
+		rows, err = db.QueryContext(ctx, selTriggers+selTriggersOrderBy)
+		if err != nil {
+			return nil, errors.Wrap(err, "[ddl] LoadTriggers QueryContext")
+		}
+	} else {
+		sqlStr, _, err := dml.Interpolate(selTriggers + selTriggersWhere + selTriggersOrderBy).Strs(tables...).ToSQL()
+		if err != nil {
+			return nil, errors.Wrapf(err, "[ddl] LoadTriggers dml.Interpolate for tables %v", tables)
+		}
+		rows, err = db.QueryContext(ctx, sqlStr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "[ddl] LoadTriggers QueryContext for tables %v", tables)
+		}
+	}
+	defer func() {
+		if err2 := rows.Close(); err2 != nil && err == nil {
+			err = errors.Wrap(err2, "[ddl] LoadTriggers.Rows.Close")
+		}
+	}()
+
+	tc = make(map[string][]*Trigger)
+	rc := new(dml.ColumnMap)
+	for rows.Next() {
+		if err = rc.Scan(rows); err != nil {
+			err = errors.Wrapf(err, "[ddl] LoadTriggers Scan Query for tables: %v", tables)
+			return
+		}
+		trg := new(Trigger)
+		if err = trg.MapColumns(rc); err != nil {
+			err = errors.WithStack(err)
+			return
+		}
+		tc[trg.Table] = append(tc[trg.Table], trg)
+	}
+	if err = rows.Err(); err != nil {
+		err = errors.Wrap(err, "[ddl] LoadTriggers rows.Err Query")
+	}
+	return
+}
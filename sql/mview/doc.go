@@ -14,6 +14,19 @@
 
 // Package mview adds materialized views via events on the MySQL binary log.
 //
+// A View gets defined as a dml.Select on a single source table with a GROUP BY
+// clause and the aggregates SUM, COUNT, MIN and MAX. Function Refresh
+// materializes the query into the target table and Check compares the target
+// table with the source query. Registered as a binlogsync.RowsEventHandler the
+// View applies the row deltas of the source table incrementally to the target
+// table.
+//
+//		v, err := mview.NewView(dbc, "sales_order_aggr",
+//			dml.NewSelect().From("sales_order").GroupBy("store_id", "status"),
+//			mview.Sum("base_grand_total", "total"), mview.Count("", "orders"),
+//		)
+//		err = v.Refresh(ctx)
+//		canal.RegisterRowsEventHandler(v)
 //
 // https://de.slideshare.net/MySQLGeek/flexviews-materialized-views-for-my-sql
 // https://github.com/greenlion/swanhart-tools
@@ -0,0 +1,436 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mview
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/util/bufferpool"
+)
+
+// groupDelta collects the changes of one group.
+type groupDelta struct {
+	values []interface{} // group column values
+	rows   int64
+	// sums, counts and minMax have the same length as View.Aggregates. The
+	// index of the aggregate function decides which slice gets used. A SUM
+	// uses counts for the number of its non-NULL values.
+	sums   []*big.Rat
+	counts []int64
+	minMax []interface{}
+	// recalculate gets set when a row has been removed from a group with MIN
+	// or MAX aggregates or when the view has WHERE conditions.
+	recalculate bool
+}
+
+// Do applies the row changes of the source table to the target table.
+// Implements binlogsync.RowsEventHandler. SUM and COUNT get maintained by
+// adding the deltas. MIN and MAX get maintained for inserted rows, removed rows
+// trigger a recalculation of the affected groups from the source table. Views
+// with WHERE conditions recalculate all affected groups because the conditions
+// cannot be evaluated on the binlog rows.
+//
+// All changes of one call get applied in one transaction. If the context
+// contains a binlogsync.EventInfo, its binlog position gets stored in the
+// StateTable within the same transaction and an already applied position gets
+// skipped, so redelivered events do not get counted twice.
+func (v *View) Do(ctx context.Context, action string, t ddl.Table, rows [][]interface{}) error {
+	if t.Name != v.Source {
+		return nil
+	}
+
+	colIdx := make(map[string]int, len(t.Columns))
+	for i, c := range t.Columns {
+		colIdx[c.Field] = i
+	}
+	groupIdx := make([]int, len(v.GroupBy))
+	for i, g := range v.GroupBy {
+		idx, ok := colIdx[g]
+		if !ok {
+			return errors.NotFound.Newf("[mview] View %q: group column %q not found in table %q", v.Name, g, t.Name)
+		}
+		groupIdx[i] = idx
+	}
+	aggIdx := make([]int, len(v.Aggregates))
+	for i, a := range v.Aggregates {
+		aggIdx[i] = -1
+		if a.Column == "" {
+			continue
+		}
+		idx, ok := colIdx[a.Column]
+		if !ok {
+			return errors.NotFound.Newf("[mview] View %q: aggregate column %q not found in table %q", v.Name, a.Column, t.Name)
+		}
+		aggIdx[i] = idx
+	}
+
+	forceRecalc := len(v.Select.Wheres) > 0
+	hasMinMax := v.hasMinMax()
+	groups := map[string]*groupDelta{}
+	var groupOrder []string
+
+	apply := func(row []interface{}, sign int64) error {
+		if len(row) < len(t.Columns) {
+			return errors.Mismatch.Newf("[mview] View %q: row has %d columns but table %q has %d columns", v.Name, len(row), t.Name, len(t.Columns))
+		}
+		gVals := make([]interface{}, len(groupIdx))
+		keys := make([]string, len(groupIdx))
+		for i, idx := range groupIdx {
+			gVals[i] = row[idx]
+			keys[i] = "NULL"
+			if row[idx] != nil {
+				keys[i] = toString(row[idx])
+			}
+		}
+		key := strings.Join(keys, ",")
+		gd, ok := groups[key]
+		if !ok {
+			gd = &groupDelta{
+				values: gVals,
+				sums:   make([]*big.Rat, len(v.Aggregates)),
+				counts: make([]int64, len(v.Aggregates)),
+				minMax: make([]interface{}, len(v.Aggregates)),
+			}
+			groups[key] = gd
+			groupOrder = append(groupOrder, key)
+		}
+		gd.rows += sign
+		if forceRecalc || (sign < 0 && hasMinMax) {
+			gd.recalculate = true
+		}
+		if gd.recalculate {
+			return nil
+		}
+
+		for i, a := range v.Aggregates {
+			var val interface{}
+			if aggIdx[i] >= 0 {
+				val = row[aggIdx[i]]
+			}
+			switch a.Func {
+			case FuncCount:
+				if a.Column == "" || val != nil {
+					gd.counts[i] += sign
+				}
+			case FuncSum:
+				if val == nil {
+					continue
+				}
+				r, err := toRat(val)
+				if err != nil {
+					return errors.Wrapf(err, "[mview] View %q: column %q", v.Name, a.Column)
+				}
+				if sign < 0 {
+					r.Neg(r)
+				}
+				gd.counts[i] += sign
+				if gd.sums[i] == nil {
+					gd.sums[i] = new(big.Rat)
+				}
+				gd.sums[i].Add(gd.sums[i], r)
+			case FuncMin, FuncMax:
+				if val == nil {
+					continue
+				}
+				if gd.minMax[i] == nil || (a.Func == FuncMin && compareValues(val, gd.minMax[i]) < 0) ||
+					(a.Func == FuncMax && compareValues(val, gd.minMax[i]) > 0) {
+					gd.minMax[i] = val
+				}
+			}
+		}
+		return nil
+	}
+
+	switch action {
+	case binlogsync.InsertAction:
+		for _, row := range rows {
+			if err := apply(row, 1); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case binlogsync.DeleteAction:
+		for _, row := range rows {
+			if err := apply(row, -1); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case binlogsync.UpdateAction:
+		if len(rows)%2 != 0 {
+			return errors.NotValid.Newf("[mview] View %q: update event requires an even number of rows, got %d", v.Name, len(rows))
+		}
+		for i := 0; i < len(rows); i += 2 {
+			if err := apply(rows[i], -1); err != nil {
+				return errors.WithStack(err)
+			}
+			if err := apply(rows[i+1], 1); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	default:
+		return errors.NotSupported.Newf("[mview] View %q: action %q not supported", v.Name, action)
+	}
+
+	var recalc [][]interface{}
+	for _, key := range groupOrder {
+		if gd := groups[key]; gd.recalculate {
+			recalc = append(recalc, gd.values)
+		}
+	}
+
+	ei, hasPos := binlogsync.EventInfoFromContext(ctx)
+	var skipped bool
+	err := v.db.Transaction(ctx, nil, func(tx *dml.Tx) (err error) {
+		if hasPos {
+			if skipped, err = v.isApplied(ctx, tx, ei.Position); err != nil || skipped {
+				return errors.WithStack(err)
+			}
+		}
+		for _, key := range groupOrder {
+			if gd := groups[key]; !gd.recalculate {
+				if err := v.applyDelta(ctx, tx, gd); err != nil {
+					return errors.WithStack(err)
+				}
+			}
+		}
+		if err := v.refreshGroups(ctx, tx, recalc); err != nil {
+			return errors.WithStack(err)
+		}
+		if hasPos {
+			return errors.WithStack(v.saveApplied(ctx, tx, ei.Position))
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if v.Log.IsDebug() {
+		v.Log.Debug("mview.View.Do", log.String("view", v.Name), log.String("action", action), log.Stringer("position", ei.Position),
+			log.Bool("skipped", skipped), log.Int("rows", len(rows)), log.Int("groups", len(groups)), log.Int("groups_recalculated", len(recalc)))
+	}
+	return nil
+}
+
+// isApplied reports whether the changes at the binlog position have already
+// been applied. The row of the view in the state table stays locked until the
+// transaction ends.
+func (v *View) isApplied(ctx context.Context, tx *dml.Tx, pos ddl.MasterStatus) (bool, error) {
+	var last ddl.MasterStatus
+	var lastPos uint64
+	err := tx.DB.QueryRowContext(ctx, "SELECT `file`,`position` FROM "+dml.Quoter.Name(v.StateTable)+
+		" WHERE `view_name` = ? FOR UPDATE", v.Name).Scan(&last.File, &lastPos)
+	switch {
+	case err == sql.ErrNoRows:
+		return false, nil
+	case err != nil:
+		return false, errors.Wrapf(err, "[mview] View %q failed to load the applied position", v.Name)
+	}
+	last.Position = uint(lastPos)
+	return pos.Compare(last) <= 0, nil
+}
+
+// saveApplied stores the binlog position of the applied changes.
+func (v *View) saveApplied(ctx context.Context, tx *dml.Tx, pos ddl.MasterStatus) error {
+	_, err := tx.DB.ExecContext(ctx, "INSERT INTO "+dml.Quoter.Name(v.StateTable)+
+		" (`view_name`,`file`,`position`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE `file`=VALUES(`file`), `position`=VALUES(`position`)",
+		v.Name, pos.File, uint64(pos.Position))
+	return errors.Wrapf(err, "[mview] View %q failed to save the applied position %q", v.Name, pos)
+}
+
+// applyDelta upserts the group into the target table by adding the deltas and
+// removes the group when no source rows are left. A SUM gets NULL once its
+// group has no non-NULL values left, like in SQL.
+func (v *View) applyDelta(ctx context.Context, tx *dml.Tx, gd *groupDelta) error {
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+
+	cols := v.targetColumns()
+	args := make([]interface{}, 0, len(cols))
+	buf.WriteString("INSERT INTO ")
+	dml.Quoter.WriteIdentifier(buf, v.Name)
+	buf.WriteString(" (")
+	for i, c := range cols {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		dml.Quoter.WriteIdentifier(buf, c)
+	}
+	buf.WriteString(") VALUES (")
+	for i, val := range gd.values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('?')
+		args = append(args, val)
+	}
+	for i, a := range v.Aggregates {
+		buf.WriteString(",?")
+		switch a.Func {
+		case FuncCount:
+			args = append(args, gd.counts[i])
+		case FuncSum:
+			if gd.sums[i] == nil {
+				args = append(args, nil)
+			} else {
+				args = append(args, gd.sums[i].FloatString(ratPrecision))
+			}
+		default:
+			args = append(args, gd.minMax[i])
+		}
+	}
+	for i, a := range v.Aggregates {
+		if a.Func == FuncSum {
+			buf.WriteString(",?")
+			args = append(args, gd.counts[i])
+		}
+	}
+	buf.WriteString(",?) ON DUPLICATE KEY UPDATE ")
+	args = append(args, gd.rows)
+	// The counters of the SUMs must be updated first, because MySQL uses the
+	// updated values in the following assignments.
+	for _, a := range v.Aggregates {
+		if a.Func == FuncSum {
+			qc := dml.Quoter.Name(sumCountColumn(a.Alias))
+			buf.WriteString(qc + "=" + qc + " + VALUES(" + qc + "), ")
+		}
+	}
+	for _, a := range v.Aggregates {
+		qa := dml.Quoter.Name(a.Alias)
+		buf.WriteString(qa)
+		buf.WriteByte('=')
+		switch a.Func {
+		case FuncSum:
+			qc := dml.Quoter.Name(sumCountColumn(a.Alias))
+			buf.WriteString("IF(" + qc + " <= 0, NULL, COALESCE(" + qa + ",0) + COALESCE(VALUES(" + qa + "),0))")
+		case FuncCount:
+			buf.WriteString(qa + " + VALUES(" + qa + ")")
+		case FuncMin:
+			buf.WriteString("IF(VALUES(" + qa + ") IS NULL OR " + qa + " IS NULL, COALESCE(" + qa + ", VALUES(" + qa + ")), LEAST(" + qa + ", VALUES(" + qa + ")))")
+		case FuncMax:
+			buf.WriteString("IF(VALUES(" + qa + ") IS NULL OR " + qa + " IS NULL, COALESCE(" + qa + ", VALUES(" + qa + ")), GREATEST(" + qa + ", VALUES(" + qa + ")))")
+		}
+		buf.WriteString(", ")
+	}
+	qr := dml.Quoter.Name(RowCountColumn)
+	buf.WriteString(qr + "=" + qr + " + VALUES(" + qr + ")")
+
+	if _, err := tx.DB.ExecContext(ctx, buf.String(), args...); err != nil {
+		return errors.Wrapf(err, "[mview] View %q failed to apply delta: %q", v.Name, buf.String())
+	}
+	if gd.rows >= 0 {
+		return nil
+	}
+
+	del := dml.NewDelete(v.Name).Where(append(v.groupConditions([][]interface{}{gd.values}), dml.Column(RowCountColumn).LessOrEqual().Int(0))...)
+	delSQL, _, err := del.WithArgs().Interpolate().ToSQL()
+	if err != nil {
+		return errors.Wrapf(err, "[mview] View %q failed to build DELETE", v.Name)
+	}
+	if _, err := tx.DB.ExecContext(ctx, delSQL); err != nil {
+		return errors.Wrapf(err, "[mview] View %q failed to delete empty group", v.Name)
+	}
+	return nil
+}
+
+// Complete implements binlogsync.RowsEventHandler. It does nothing because all
+// changes get written in function Do.
+func (v *View) Complete(_ context.Context) error { return nil }
+
+// ratPrecision defines the number of digits after the decimal point when
+// converting a sum into a string.
+const ratPrecision = 12
+
+func toRat(val interface{}) (*big.Rat, error) {
+	r := new(big.Rat)
+	switch vt := val.(type) {
+	case float32:
+		return r.SetFloat64(float64(vt)), nil
+	case float64:
+		return r.SetFloat64(vt), nil
+	}
+	s := toString(val)
+	if _, ok := r.SetString(s); !ok {
+		return nil, errors.NotValid.Newf("[mview] Cannot convert %q (%T) into a number", s, val)
+	}
+	return r, nil
+}
+
+func toString(val interface{}) string {
+	switch vt := val.(type) {
+	case string:
+		return vt
+	case []byte:
+		return string(vt)
+	case int8:
+		return strconv.FormatInt(int64(vt), 10)
+	case int16:
+		return strconv.FormatInt(int64(vt), 10)
+	case int32:
+		return strconv.FormatInt(int64(vt), 10)
+	case int64:
+		return strconv.FormatInt(vt, 10)
+	case int:
+		return strconv.Itoa(vt)
+	case uint8:
+		return strconv.FormatUint(uint64(vt), 10)
+	case uint16:
+		return strconv.FormatUint(uint64(vt), 10)
+	case uint32:
+		return strconv.FormatUint(uint64(vt), 10)
+	case uint64:
+		return strconv.FormatUint(vt, 10)
+	case float32:
+		return strconv.FormatFloat(float64(vt), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(vt, 'f', -1, 64)
+	case time.Time:
+		return vt.Format("2006-01-02 15:04:05.999999")
+	case fmt.Stringer:
+		return vt.String()
+	}
+	return fmt.Sprintf("%v", val)
+}
+
+// compareValues compares numbers exactly and everything else as string.
+func compareValues(a, b interface{}) int {
+	ra, errA := toRat(a)
+	rb, errB := toRat(b)
+	if errA == nil && errB == nil {
+		return ra.Cmp(rb)
+	}
+	return strings.Compare(toString(a), toString(b))
+}
+
+// equalNumeric compares two database values. Numbers get compared exactly,
+// e.g. "1.50" equals "1.5".
+func equalNumeric(a, b sql.NullString) bool {
+	if a.Valid != b.Valid {
+		return false
+	}
+	if a.String == b.String {
+		return true
+	}
+	return compareValues(a.String, b.String) == 0
+}
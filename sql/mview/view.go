@@ -0,0 +1,442 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mview
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/util/bufferpool"
+)
+
+// RowCountColumn defines the name of the hidden column in the target table
+// which contains the number of source rows per group. A group gets deleted from
+// the target table once the count drops to zero.
+const RowCountColumn = "mview_row_count"
+
+// DefaultStateTable defines the name of the table which stores per view the
+// binlog position of the last applied changes.
+const DefaultStateTable = "mview_state"
+
+// sumCountColumn returns the name of the hidden column in the target table
+// which contains the number of non-NULL values of a SUM aggregate.
+func sumCountColumn(alias string) string {
+	return "mview_count_" + alias
+}
+
+// Aggregate functions supported by a view.
+const (
+	FuncSum   = "SUM"
+	FuncCount = "COUNT"
+	FuncMin   = "MIN"
+	FuncMax   = "MAX"
+)
+
+// Aggregate defines an aggregate function of a source column. The result gets
+// stored in the target column Alias.
+type Aggregate struct {
+	// Func can be SUM, COUNT, MIN or MAX.
+	Func string
+	// Column defines the source column. An empty column with function COUNT
+	// counts all rows.
+	Column string
+	// Alias defines the column name in the target table.
+	Alias string
+}
+
+// Sum creates a SUM aggregate.
+func Sum(column, alias string) Aggregate {
+	return Aggregate{Func: FuncSum, Column: column, Alias: alias}
+}
+
+// Count creates a COUNT aggregate. An empty column counts all rows.
+func Count(column, alias string) Aggregate {
+	return Aggregate{Func: FuncCount, Column: column, Alias: alias}
+}
+
+// Min creates a MIN aggregate.
+func Min(column, alias string) Aggregate {
+	return Aggregate{Func: FuncMin, Column: column, Alias: alias}
+}
+
+// Max creates a MAX aggregate.
+func Max(column, alias string) Aggregate {
+	return Aggregate{Func: FuncMax, Column: column, Alias: alias}
+}
+
+func (a Aggregate) expr() string {
+	if a.Func == FuncCount && a.Column == "" {
+		return "COUNT(*)"
+	}
+	return a.Func + "(" + dml.Quoter.Name(a.Column) + ")"
+}
+
+// View defines a materialized view. The source query must be a dml.Select on a
+// single table with a GROUP BY clause. The aggregates get appended to the
+// columns of the query. The result of the query gets stored in the target
+// table. The target table can be kept up to date incrementally by registering
+// the View as a binlogsync.RowsEventHandler.
+type View struct {
+	// Name defines the name of the target table.
+	Name string
+	// Source defines the name of the source table. Taken from the Select.
+	Source string
+	// GroupBy contains the grouping columns. Taken from the Select.
+	GroupBy    []string
+	Aggregates []Aggregate
+	// Select defines the source query including the aggregates.
+	Select *dml.Select
+	// Log can be set for debug logging. Defaults to a black hole.
+	Log log.Logger
+	// StateTable defines the table of the applied binlog positions. Defaults
+	// to DefaultStateTable and can be shared by all views.
+	StateTable string
+
+	db *dml.ConnPool
+}
+
+// NewView creates a new materialized view. Argument sel must contain a FROM
+// table without joins and GROUP BY columns. The aggregates get added to the
+// SELECT columns. The view does not get materialized by this function, call
+// Refresh.
+func NewView(db *dml.ConnPool, name string, sel *dml.Select, aggs ...Aggregate) (*View, error) {
+	if err := dml.IsValidIdentifier(name); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if sel == nil || sel.Table.Name == "" {
+		return nil, errors.Empty.Newf("[mview] View %q requires a Select with a FROM table", name)
+	}
+	if len(sel.Joins) > 0 {
+		return nil, errors.NotSupported.Newf("[mview] View %q: JOINs are not supported", name)
+	}
+	if len(sel.GroupBys) == 0 {
+		return nil, errors.Empty.Newf("[mview] View %q requires GROUP BY columns", name)
+	}
+	if len(aggs) == 0 {
+		return nil, errors.Empty.Newf("[mview] View %q requires at least one aggregate", name)
+	}
+
+	v := &View{
+		Name:       name,
+		Source:     sel.Table.Name,
+		Aggregates: aggs,
+		Select:     sel.Clone().DisableBuildCache(),
+		Log:        log.BlackHole{},
+		StateTable: DefaultStateTable,
+		db:         db,
+	}
+	for _, g := range sel.GroupBys {
+		if g.Expression != "" {
+			return nil, errors.NotSupported.Newf("[mview] View %q: GROUP BY expressions are not supported: %q", name, g.Expression)
+		}
+		if err := dml.IsValidIdentifier(g.Name); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		v.GroupBy = append(v.GroupBy, g.Name)
+	}
+
+	v.Select.Columns = nil
+	v.Select.AddColumns(v.GroupBy...)
+	for _, a := range aggs {
+		switch a.Func {
+		case FuncSum, FuncCount, FuncMin, FuncMax:
+		default:
+			return nil, errors.NotSupported.Newf("[mview] View %q: aggregate function %q not supported", name, a.Func)
+		}
+		if a.Func != FuncCount && a.Column == "" {
+			return nil, errors.Empty.Newf("[mview] View %q: aggregate %q requires a column", name, a.Alias)
+		}
+		if err := dml.IsValidIdentifier(a.Alias); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		v.Select.AddColumnsConditions(dml.Expr(a.expr()).Alias(a.Alias))
+	}
+	for _, a := range aggs {
+		if a.Func != FuncSum {
+			continue
+		}
+		if err := dml.IsValidIdentifier(sumCountColumn(a.Alias)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		v.Select.AddColumnsConditions(dml.Expr("COUNT(" + dml.Quoter.Name(a.Column) + ")").Alias(sumCountColumn(a.Alias)))
+	}
+	v.Select.AddColumnsConditions(dml.Expr("COUNT(*)").Alias(RowCountColumn))
+	return v, nil
+}
+
+// MustNewView same as NewView but panics on error.
+func MustNewView(db *dml.ConnPool, name string, sel *dml.Select, aggs ...Aggregate) *View {
+	v, err := NewView(db, name, sel, aggs...)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// String returns the name of the view. Implements binlogsync.RowsEventHandler.
+func (v *View) String() string {
+	return "mview." + v.Name
+}
+
+// targetColumns returns the columns of the target table in the order of the
+// source query: the group columns, the aggregates, the hidden counters of the
+// SUM aggregates and the row count.
+func (v *View) targetColumns() []string {
+	cols := make([]string, 0, len(v.GroupBy)+2*len(v.Aggregates)+1)
+	cols = append(cols, v.GroupBy...)
+	for _, a := range v.Aggregates {
+		cols = append(cols, a.Alias)
+	}
+	for _, a := range v.Aggregates {
+		if a.Func == FuncSum {
+			cols = append(cols, sumCountColumn(a.Alias))
+		}
+	}
+	return append(cols, RowCountColumn)
+}
+
+// hasMinMax reports whether the view contains a MIN or MAX aggregate. Those
+// cannot be maintained for deleted rows and require a recalculation of the
+// group.
+func (v *View) hasMinMax() bool {
+	for _, a := range v.Aggregates {
+		if a.Func == FuncMin || a.Func == FuncMax {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *View) selectSQL(groupValues ...[]interface{}) (string, error) {
+	sel := v.Select
+	if len(groupValues) > 0 {
+		sel = v.Select.Clone().DisableBuildCache()
+		sel.Where(v.groupConditions(groupValues)...)
+	}
+	sqlStr, _, err := sel.WithArgs().Interpolate().ToSQL()
+	return sqlStr, errors.Wrapf(err, "[mview] View %q failed to build SELECT", v.Name)
+}
+
+// groupConditions creates an OR condition list where each group gets matched
+// by all its group columns. The whole list gets wrapped in parenthesis to not
+// interfere with other WHERE conditions.
+func (v *View) groupConditions(groupValues [][]interface{}) dml.Conditions {
+	var cnds dml.Conditions
+	cnds = append(cnds, dml.ParenthesisOpen(), dml.ParenthesisOpen())
+	for gi, vals := range groupValues {
+		if gi > 0 {
+			cnds = append(cnds, dml.ParenthesisClose(), dml.ParenthesisOpen().Or())
+		}
+		for i, col := range v.GroupBy {
+			c := dml.Column(col)
+			if vals[i] == nil {
+				c = c.Null()
+			} else {
+				c = c.Equal().Str(toString(vals[i]))
+			}
+			cnds = append(cnds, c)
+		}
+	}
+	return append(cnds, dml.ParenthesisClose(), dml.ParenthesisClose())
+}
+
+// Refresh rebuilds the target table completely. The query result gets written
+// into a temporary table which then gets atomically swapped with the target
+// table. Refresh creates the target table and the state table if they do not
+// exist.
+func (v *View) Refresh(ctx context.Context) error {
+	selSQL, err := v.selectSQL()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := v.createStateTable(ctx); err != nil {
+		return errors.WithStack(err)
+	}
+	tmpName := ddl.TableName("", v.Name, "tmp")
+	tmp := ddl.NewTable(tmpName)
+	if err := tmp.Drop(ctx, v.db.DB); err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err := v.db.DB.ExecContext(ctx, "CREATE TABLE "+dml.Quoter.Name(tmpName)+" AS "+selSQL); err != nil {
+		return errors.Wrapf(err, "[mview] View %q failed to create temporary table", v.Name)
+	}
+
+	buf := bufferpool.Get()
+	defer bufferpool.Put(buf)
+	buf.WriteString("ALTER TABLE ")
+	dml.Quoter.WriteIdentifier(buf, tmpName)
+	buf.WriteString(" ADD UNIQUE KEY ")
+	dml.Quoter.WriteIdentifier(buf, ddl.IndexName("unique", v.Name, v.GroupBy...))
+	buf.WriteString(" (")
+	for i, g := range v.GroupBy {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		dml.Quoter.WriteIdentifier(buf, g)
+	}
+	buf.WriteByte(')')
+	if _, err := v.db.DB.ExecContext(ctx, buf.String()); err != nil {
+		return errors.Wrapf(err, "[mview] View %q failed to add unique key: %q", v.Name, buf.String())
+	}
+
+	var exists int
+	err = v.db.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?", v.Name).Scan(&exists)
+	if err != nil {
+		return errors.Wrapf(err, "[mview] View %q failed to check target table", v.Name)
+	}
+	if exists == 0 {
+		return errors.WithStack(tmp.Rename(ctx, v.db.DB, v.Name))
+	}
+	if err := tmp.Swap(ctx, v.db.DB, v.Name); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(tmp.Drop(ctx, v.db.DB))
+}
+
+// createStateTable creates the table of the applied binlog positions.
+func (v *View) createStateTable(ctx context.Context) error {
+	_, err := v.db.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+dml.Quoter.Name(v.StateTable)+` (
+  `+"`view_name`"+` VARCHAR(64) NOT NULL,
+  `+"`file`"+` VARCHAR(255) NOT NULL,
+  `+"`position`"+` BIGINT UNSIGNED NOT NULL,
+  PRIMARY KEY (`+"`view_name`"+`)
+) ENGINE=InnoDB`)
+	return errors.Wrapf(err, "[mview] View %q failed to create state table %q", v.Name, v.StateTable)
+}
+
+// refreshGroups recalculates the provided groups from the source table.
+func (v *View) refreshGroups(ctx context.Context, tx *dml.Tx, groupValues [][]interface{}) error {
+	if len(groupValues) == 0 {
+		return nil
+	}
+	selSQL, err := v.selectSQL(groupValues...)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	del := dml.NewDelete(v.Name).Where(v.groupConditions(groupValues)...)
+	delSQL, _, err := del.WithArgs().Interpolate().ToSQL()
+	if err != nil {
+		return errors.Wrapf(err, "[mview] View %q failed to build DELETE", v.Name)
+	}
+
+	if _, err := tx.DB.ExecContext(ctx, delSQL); err != nil {
+		return errors.Wrapf(err, "[mview] View %q failed to delete groups", v.Name)
+	}
+	if _, err := tx.DB.ExecContext(ctx, "INSERT INTO "+dml.Quoter.Name(v.Name)+" "+selSQL); err != nil {
+		return errors.Wrapf(err, "[mview] View %q failed to insert groups", v.Name)
+	}
+	return nil
+}
+
+// CheckResult contains the group keys of a consistency check. A group key
+// consists of all group column values joined by a comma.
+type CheckResult struct {
+	// Missing groups exists in the source but not in the target table.
+	Missing []string
+	// Surplus groups exists in the target table but not in the source.
+	Surplus []string
+	// Different groups have different aggregated values.
+	Different []string
+}
+
+// IsConsistent returns true if no differences have been found.
+func (cr CheckResult) IsConsistent() bool {
+	return len(cr.Missing) == 0 && len(cr.Surplus) == 0 && len(cr.Different) == 0
+}
+
+// Check compares the content of the target table with the result of the source
+// query. Numeric values get compared exactly.
+func (v *View) Check(ctx context.Context) (cr CheckResult, err error) {
+	selSQL, err := v.selectSQL()
+	if err != nil {
+		return cr, errors.WithStack(err)
+	}
+	want, err := v.loadGroups(ctx, selSQL)
+	if err != nil {
+		return cr, errors.WithStack(err)
+	}
+
+	tgtSQL, _, err := dml.NewSelect(v.targetColumns()...).From(v.Name).ToSQL()
+	if err != nil {
+		return cr, errors.WithStack(err)
+	}
+	have, err := v.loadGroups(ctx, tgtSQL)
+	if err != nil {
+		return cr, errors.WithStack(err)
+	}
+
+	for key, wantVals := range want {
+		haveVals, ok := have[key]
+		if !ok {
+			cr.Missing = append(cr.Missing, key)
+			continue
+		}
+		for i := range wantVals {
+			if !equalNumeric(wantVals[i], haveVals[i]) {
+				cr.Different = append(cr.Different, key)
+				break
+			}
+		}
+	}
+	for key := range have {
+		if _, ok := want[key]; !ok {
+			cr.Surplus = append(cr.Surplus, key)
+		}
+	}
+	return cr, nil
+}
+
+// loadGroups loads the result of a query into a map where the key is the
+// group key and the value the aggregated values.
+func (v *View) loadGroups(ctx context.Context, query string) (_ map[string][]sql.NullString, err error) {
+	rows, err := v.db.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrapf(err, "[mview] View %q failed to query: %q", v.Name, query)
+	}
+	defer func() {
+		if err2 := rows.Close(); err2 != nil && err == nil {
+			err = errors.WithStack(err2)
+		}
+	}()
+
+	gl := len(v.GroupBy)
+	cl := len(v.targetColumns())
+	ret := make(map[string][]sql.NullString)
+	for rows.Next() {
+		vals := make([]sql.NullString, cl)
+		ptrs := make([]interface{}, len(vals))
+		for i := range vals {
+			ptrs[i] = &vals[i]
+		}
+		if err = rows.Scan(ptrs...); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		keys := make([]string, gl)
+		for i := 0; i < gl; i++ {
+			keys[i] = vals[i].String
+			if !vals[i].Valid {
+				keys[i] = "NULL"
+			}
+		}
+		ret[strings.Join(keys, ",")] = vals[gl:]
+	}
+	return ret, errors.WithStack(rows.Err())
+}
@@ -0,0 +1,367 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mview_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
+	"github.com/corestoreio/pkg/sql/mview"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ binlogsync.RowsEventHandler = (*mview.View)(nil)
+
+var salesOrderTable = ddl.Table{
+	Name: "sales_order",
+	Columns: ddl.Columns{
+		&ddl.Column{Field: "entity_id", Pos: 1},
+		&ddl.Column{Field: "store_id", Pos: 2},
+		&ddl.Column{Field: "grand_total", Pos: 3},
+	},
+}
+
+func TestNewView_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing GROUP BY", func(t *testing.T) {
+		v, err := mview.NewView(nil, "sales_aggr", dml.NewSelect().From("sales_order"), mview.Count("", "orders"))
+		assert.Nil(t, v)
+		assert.True(t, errors.Empty.Match(err), "%+v", err)
+	})
+	t.Run("JOIN not supported", func(t *testing.T) {
+		sel := dml.NewSelect().From("sales_order").GroupBy("store_id").
+			Join(dml.MakeIdentifier("store"), dml.Column("store_id").Equal().Column("store_id"))
+		v, err := mview.NewView(nil, "sales_aggr", sel, mview.Count("", "orders"))
+		assert.Nil(t, v)
+		assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+	})
+	t.Run("SUM requires column", func(t *testing.T) {
+		v, err := mview.NewView(nil, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"), mview.Sum("", "total"))
+		assert.Nil(t, v)
+		assert.True(t, errors.Empty.Match(err), "%+v", err)
+	})
+}
+
+func TestView_Do(t *testing.T) {
+	t.Parallel()
+
+	t.Run("insert applies delta", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		v := mview.MustNewView(dbc, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"),
+			mview.Sum("grand_total", "total"), mview.Count("", "orders"))
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `sales_aggr` (`store_id`,`total`,`orders`,`mview_count_total`,`mview_row_count`) VALUES (?,?,?,?,?) ON DUPLICATE KEY UPDATE")).
+			WithArgs(int64(1), "35.750000000000", int64(2), int64(2), int64(2)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `sales_aggr` (`store_id`,`total`,`orders`,`mview_count_total`,`mview_row_count`) VALUES (?,?,?,?,?) ON DUPLICATE KEY UPDATE")).
+			WithArgs(int64(2), "1.000000000000", int64(1), int64(1), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectCommit()
+
+		err := v.Do(context.TODO(), binlogsync.InsertAction, salesOrderTable, [][]interface{}{
+			{int64(10), int64(1), "12.50"},
+			{int64(11), int64(1), "23.25"},
+			{int64(12), int64(2), float64(1)},
+		})
+		require.NoError(t, err, "%+v", err)
+	})
+
+	t.Run("update moves row between groups", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		v := mview.MustNewView(dbc, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"),
+			mview.Sum("grand_total", "total"))
+
+		// Once the last non-NULL value of a group has been removed, the SUM
+		// gets NULL.
+		const upsertSQL = "INSERT INTO `sales_aggr` (`store_id`,`total`,`mview_count_total`,`mview_row_count`) VALUES (?,?,?,?) ON DUPLICATE KEY UPDATE " +
+			"`mview_count_total`=`mview_count_total` + VALUES(`mview_count_total`), " +
+			"`total`=IF(`mview_count_total` <= 0, NULL, COALESCE(`total`,0) + COALESCE(VALUES(`total`),0)), " +
+			"`mview_row_count`=`mview_row_count` + VALUES(`mview_row_count`)"
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(upsertSQL)).
+			WithArgs(int64(1), "-12.500000000000", int64(-1), int64(-1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectExec("DELETE FROM `sales_aggr` WHERE .+`store_id` = '1'.+`mview_row_count` <= 0").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta(upsertSQL)).
+			WithArgs(int64(2), "12.500000000000", int64(1), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectCommit()
+
+		err := v.Do(context.TODO(), binlogsync.UpdateAction, salesOrderTable, [][]interface{}{
+			{int64(10), int64(1), "12.50"},
+			{int64(10), int64(2), "12.50"},
+		})
+		require.NoError(t, err, "%+v", err)
+	})
+
+	t.Run("NULL values do not count for SUM", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		v := mview.MustNewView(dbc, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"),
+			mview.Sum("grand_total", "total"))
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `sales_aggr` (`store_id`,`total`,`mview_count_total`,`mview_row_count`) VALUES (?,?,?,?)")).
+			WithArgs(int64(1), "-12.500000000000", int64(-1), int64(0)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectCommit()
+
+		err := v.Do(context.TODO(), binlogsync.UpdateAction, salesOrderTable, [][]interface{}{
+			{int64(10), int64(1), "12.50"},
+			{int64(10), int64(1), nil},
+		})
+		require.NoError(t, err, "%+v", err)
+	})
+
+	t.Run("stores binlog position", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		v := mview.MustNewView(dbc, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"),
+			mview.Count("", "orders"))
+		ctx := binlogsync.WithEventInfo(context.TODO(), binlogsync.EventInfo{
+			Position: ddl.MasterStatus{File: "mysql-bin.000002", Position: 500},
+		})
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `file`,`position` FROM `mview_state` WHERE `view_name` = ? FOR UPDATE")).
+			WithArgs("sales_aggr").
+			WillReturnRows(sqlmock.NewRows([]string{"file", "position"}).AddRow("mysql-bin.000002", 400))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `sales_aggr` (`store_id`,`orders`,`mview_row_count`) VALUES (?,?,?)")).
+			WithArgs(int64(1), int64(1), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `mview_state` (`view_name`,`file`,`position`) VALUES (?,?,?) ON DUPLICATE KEY UPDATE")).
+			WithArgs("sales_aggr", "mysql-bin.000002", uint64(500)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectCommit()
+
+		err := v.Do(ctx, binlogsync.InsertAction, salesOrderTable, [][]interface{}{
+			{int64(10), int64(1), "12.50"},
+		})
+		require.NoError(t, err, "%+v", err)
+	})
+
+	t.Run("redelivered position gets skipped", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		v := mview.MustNewView(dbc, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"),
+			mview.Count("", "orders"))
+		ctx := binlogsync.WithEventInfo(context.TODO(), binlogsync.EventInfo{
+			Position: ddl.MasterStatus{File: "mysql-bin.000002", Position: 500},
+		})
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT `file`,`position` FROM `mview_state` WHERE `view_name` = ? FOR UPDATE")).
+			WithArgs("sales_aggr").
+			WillReturnRows(sqlmock.NewRows([]string{"file", "position"}).AddRow("mysql-bin.000002", 500))
+		dbMock.ExpectCommit()
+
+		err := v.Do(ctx, binlogsync.InsertAction, salesOrderTable, [][]interface{}{
+			{int64(10), int64(1), "12.50"},
+		})
+		require.NoError(t, err, "%+v", err)
+	})
+
+	t.Run("failed delta rolls back", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		v := mview.MustNewView(dbc, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"),
+			mview.Count("", "orders"))
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `sales_aggr` (`store_id`,`orders`,`mview_row_count`) VALUES (?,?,?)")).
+			WithArgs(int64(1), int64(1), int64(1)).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `sales_aggr` (`store_id`,`orders`,`mview_row_count`) VALUES (?,?,?)")).
+			WithArgs(int64(2), int64(1), int64(1)).
+			WillReturnError(errors.ConnectionFailed.Newf("DB away"))
+		dbMock.ExpectRollback()
+
+		err := v.Do(context.TODO(), binlogsync.InsertAction, salesOrderTable, [][]interface{}{
+			{int64(10), int64(1), "12.50"},
+			{int64(11), int64(2), "1.00"},
+		})
+		assert.True(t, errors.ConnectionFailed.Match(err), "%+v", err)
+	})
+
+	t.Run("delete with MAX recalculates group", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		v := mview.MustNewView(dbc, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"),
+			mview.Max("grand_total", "max_total"))
+
+		dbMock.ExpectBegin()
+		dbMock.ExpectExec("DELETE FROM `sales_aggr` WHERE .+`store_id` = '1'").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `sales_aggr` SELECT")).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		dbMock.ExpectCommit()
+
+		err := v.Do(context.TODO(), binlogsync.DeleteAction, salesOrderTable, [][]interface{}{
+			{int64(10), int64(1), "12.50"},
+		})
+		require.NoError(t, err, "%+v", err)
+	})
+
+	t.Run("other table gets ignored", func(t *testing.T) {
+		v := mview.MustNewView(nil, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"),
+			mview.Count("", "orders"))
+		err := v.Do(context.TODO(), binlogsync.InsertAction, ddl.Table{Name: "catalog_product_entity"}, [][]interface{}{{1}})
+		require.NoError(t, err, "%+v", err)
+	})
+}
+
+func TestView_Refresh(t *testing.T) {
+	t.Parallel()
+
+	newView := func(dbc *dml.ConnPool) *mview.View {
+		return mview.MustNewView(dbc, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"),
+			mview.Sum("grand_total", "total"))
+	}
+	expectStateTable := func(dbMock sqlmock.Sqlmock) {
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("CREATE TABLE IF NOT EXISTS `mview_state`")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+	expectTmpTable := func(dbMock sqlmock.Sqlmock) {
+		expectStateTable(dbMock)
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DROP TABLE IF EXISTS `sales_aggr_tmp`")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("CREATE TABLE `sales_aggr_tmp` AS SELECT `store_id`, SUM(`grand_total`) AS `total`, COUNT(`grand_total`) AS `mview_count_total`, COUNT(*) AS `mview_row_count` FROM `sales_order` GROUP BY `store_id`")).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		dbMock.ExpectExec("ALTER TABLE `sales_aggr_tmp` ADD UNIQUE KEY `.+` \\(`store_id`\\)").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+	}
+
+	t.Run("creates target table", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		expectTmpTable(dbMock)
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT COUNT(*) FROM information_schema.TABLES")).
+			WithArgs("sales_aggr").
+			WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(0))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("RENAME TABLE `sales_aggr_tmp` TO `sales_aggr`")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		require.NoError(t, newView(dbc).Refresh(context.TODO()))
+	})
+
+	t.Run("swaps existing target table", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		expectTmpTable(dbMock)
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta("SELECT COUNT(*) FROM information_schema.TABLES")).
+			WithArgs("sales_aggr").
+			WillReturnRows(sqlmock.NewRows([]string{"cnt"}).AddRow(1))
+		dbMock.ExpectExec("RENAME TABLE `sales_aggr_tmp` TO `.+`, `sales_aggr` TO `sales_aggr_tmp`,`.+` TO `sales_aggr`").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DROP TABLE IF EXISTS `sales_aggr_tmp`")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		require.NoError(t, newView(dbc).Refresh(context.TODO()))
+	})
+
+	t.Run("create fails", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		expectStateTable(dbMock)
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DROP TABLE IF EXISTS `sales_aggr_tmp`")).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("CREATE TABLE `sales_aggr_tmp`")).
+			WillReturnError(errors.ConnectionFailed.Newf("DB away"))
+
+		err := newView(dbc).Refresh(context.TODO())
+		assert.True(t, errors.ConnectionFailed.Match(err), "%+v", err)
+	})
+}
+
+func TestView_Check(t *testing.T) {
+	t.Parallel()
+
+	newView := func(dbc *dml.ConnPool) *mview.View {
+		return mview.MustNewView(dbc, "sales_aggr", dml.NewSelect().From("sales_order").GroupBy("store_id"),
+			mview.Sum("grand_total", "total"))
+	}
+	const sqlSource = "SELECT `store_id`, SUM(`grand_total`) AS `total`, COUNT(`grand_total`) AS `mview_count_total`, COUNT(*) AS `mview_row_count` FROM `sales_order` GROUP BY `store_id`"
+	const sqlTarget = "SELECT `store_id`, `total`, `mview_count_total`, `mview_row_count` FROM `sales_aggr`"
+	groupRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"store_id", "total", "mview_count_total", "mview_row_count"})
+	}
+
+	t.Run("consistent", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlSource)).
+			WillReturnRows(groupRows().AddRow(1, "35.75", 2, 2).AddRow(nil, "1", 1, 1))
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlTarget)).
+			WillReturnRows(groupRows().AddRow(1, "35.7500", 2, 2).AddRow(nil, "1.0", 1, 1))
+
+		cr, err := newView(dbc).Check(context.TODO())
+		require.NoError(t, err, "%+v", err)
+		assert.True(t, cr.IsConsistent(), "%#v", cr)
+	})
+
+	t.Run("differences", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlSource)).
+			WillReturnRows(groupRows().AddRow(1, "35.75", 2, 2).AddRow(2, "10", 1, 1).AddRow(3, "7", 1, 1))
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlTarget)).
+			WillReturnRows(groupRows().AddRow(1, "35.75", 2, 2).AddRow(2, "10", 1, 2).AddRow(4, "1", 1, 1))
+
+		cr, err := newView(dbc).Check(context.TODO())
+		require.NoError(t, err, "%+v", err)
+		assert.False(t, cr.IsConsistent())
+		assert.Exactly(t, mview.CheckResult{
+			Missing:   []string{"3"},
+			Surplus:   []string{"4"},
+			Different: []string{"2"},
+		}, cr)
+	})
+
+	t.Run("query fails", func(t *testing.T) {
+		dbc, dbMock := dmltest.MockDB(t)
+		defer dmltest.MockClose(t, dbc, dbMock)
+
+		dbMock.ExpectQuery(dmltest.SQLMockQuoteMeta(sqlSource)).
+			WillReturnError(errors.ConnectionFailed.Newf("DB away"))
+
+		_, err := newView(dbc).Check(context.TODO())
+		assert.True(t, errors.ConnectionFailed.Match(err), "%+v", err)
+	})
+}
@@ -598,11 +598,23 @@ func (b *BinlogSyncer) parseEvent(s *BinlogStreamer, data []byte) error {
 		}
 	}
 
+	// A compressed transaction gets unwrapped, the consumer receives the
+	// events of the transaction.
+	events := []*BinlogEvent{e}
+	if pe, ok := e.Event.(*TransactionPayloadEvent); ok {
+		events = pe.Events
+	}
+
 	needStop := false
-	select {
-	case s.bleChan <- e:
-	case <-b.ctx.Done():
-		needStop = true
+	for _, e := range events {
+		select {
+		case s.bleChan <- e:
+		case <-b.ctx.Done():
+			needStop = true
+		}
+		if needStop {
+			break
+		}
 	}
 
 	if needACK {
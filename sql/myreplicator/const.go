@@ -77,6 +77,12 @@ const (
 	GTID_EVENT
 	ANONYMOUS_GTID_EVENT
 	PREVIOUS_GTIDS_EVENT
+	TRANSACTION_CONTEXT_EVENT
+	VIEW_CHANGE_EVENT
+	XA_PREPARE_LOG_EVENT
+	PARTIAL_UPDATE_ROWS_EVENT
+	TRANSACTION_PAYLOAD_EVENT
+	HEARTBEAT_LOG_EVENT_V2
 )
 
 const (
@@ -161,6 +167,18 @@ func (e EventType) String() string {
 		return "AnonymousGTIDEvent"
 	case PREVIOUS_GTIDS_EVENT:
 		return "PreviousGTIDsEvent"
+	case TRANSACTION_CONTEXT_EVENT:
+		return "TransactionContextEvent"
+	case VIEW_CHANGE_EVENT:
+		return "ViewChangeEvent"
+	case XA_PREPARE_LOG_EVENT:
+		return "XAPrepareLogEvent"
+	case PARTIAL_UPDATE_ROWS_EVENT:
+		return "PartialUpdateRowsEvent"
+	case TRANSACTION_PAYLOAD_EVENT:
+		return "TransactionPayloadEvent"
+	case HEARTBEAT_LOG_EVENT_V2:
+		return "HeartbeatLogEventV2"
 	case MARIADB_ANNOTATE_ROWS_EVENT:
 		return "MariadbAnnotateRowsEvent"
 	case MARIADB_BINLOG_CHECKPOINT_EVENT:
@@ -183,3 +201,46 @@ const (
 	BINLOG_CHECKSUM_ALG_UNDEF byte = 255 // special value to tag undetermined yet checksum
 	// or events from checksum-unaware servers
 )
+
+// Table map optional metadata field types, written with
+// binlog_row_metadata=FULL or MINIMAL since MySQL 8.0.1.
+const (
+	TABLE_MAP_OPT_META_SIGNEDNESS byte = iota + 1
+	TABLE_MAP_OPT_META_DEFAULT_CHARSET
+	TABLE_MAP_OPT_META_COLUMN_CHARSET
+	TABLE_MAP_OPT_META_COLUMN_NAME
+	TABLE_MAP_OPT_META_SET_STR_VALUE
+	TABLE_MAP_OPT_META_ENUM_STR_VALUE
+	TABLE_MAP_OPT_META_GEOMETRY_TYPE
+	TABLE_MAP_OPT_META_SIMPLE_PRIMARY_KEY
+	TABLE_MAP_OPT_META_PRIMARY_KEY_WITH_PREFIX
+	TABLE_MAP_OPT_META_ENUM_AND_SET_DEFAULT_CHARSET
+	TABLE_MAP_OPT_META_ENUM_AND_SET_COLUMN_CHARSET
+	TABLE_MAP_OPT_META_COLUMN_VISIBILITY
+)
+
+// Transaction payload field types and compression algorithms of the MySQL
+// 8.0.20+ binlog transaction compression.
+const (
+	OTW_PAYLOAD_HEADER_END_MARK         = 0
+	OTW_PAYLOAD_SIZE_FIELD              = 1
+	OTW_PAYLOAD_COMPRESSION_TYPE_FIELD  = 2
+	OTW_PAYLOAD_UNCOMPRESSED_SIZE_FIELD = 3
+
+	PAYLOAD_COMPRESSION_ZSTD = 0
+	PAYLOAD_COMPRESSION_NONE = 255
+)
+
+// BINLOG_ROW_VALUE_OPTION_PARTIAL_JSON_UPDATES gets set in the value options
+// of a PARTIAL_UPDATE_ROWS_EVENT if binlog_row_value_options=PARTIAL_JSON.
+const BINLOG_ROW_VALUE_OPTION_PARTIAL_JSON_UPDATES = 1
+
+// MariaDB GTID event flags.
+const (
+	MARIADB_GTID_FL_STANDALONE      byte = 1
+	MARIADB_GTID_FL_GROUP_COMMIT_ID byte = 2
+	MARIADB_GTID_FL_TRANSACTIONAL   byte = 4
+	MARIADB_GTID_FL_ALLOW_PARALLEL  byte = 8
+	MARIADB_GTID_FL_WAITED          byte = 16
+	MARIADB_GTID_FL_DDL             byte = 32
+)
@@ -411,19 +411,36 @@ func (e *MariadbBinlogCheckPointEvent) Dump(w io.Writer) {
 
 type MariadbGTIDEvent struct {
 	GTID mysql.MariadbGTID
+	// Flags see the MARIADB_GTID_FL_* constants.
+	Flags byte
+	// CommitID identifies transactions of the same group commit. Only set if
+	// the flag MARIADB_GTID_FL_GROUP_COMMIT_ID is present.
+	CommitID uint64
 }
 
 func (e *MariadbGTIDEvent) Decode(data []byte) error {
+	if len(data) < 13 {
+		return errors.NotValid.Newf("[myreplicator] MariadbGTIDEvent too short: %d bytes", len(data))
+	}
 	e.GTID.SequenceNumber = binary.LittleEndian.Uint64(data)
 	e.GTID.DomainID = binary.LittleEndian.Uint32(data[8:])
-
-	// we don't care commit id now, maybe later
-
+	e.Flags = data[12]
+	if e.Flags&MARIADB_GTID_FL_GROUP_COMMIT_ID > 0 && len(data) >= 21 {
+		e.CommitID = binary.LittleEndian.Uint64(data[13:])
+	}
 	return nil
 }
 
+// IsStandalone reports true if the event group contains no BEGIN/COMMIT, for
+// example a DDL statement.
+func (e *MariadbGTIDEvent) IsStandalone() bool {
+	return e.Flags&MARIADB_GTID_FL_STANDALONE > 0
+}
+
 func (e *MariadbGTIDEvent) Dump(w io.Writer) {
 	fmt.Fprintf(w, "GTID: %s\n", e.GTID)
+	fmt.Fprintf(w, "Flags: %d\n", e.Flags)
+	fmt.Fprintf(w, "CommitID: %d\n", e.CommitID)
 	fmt.Fprintln(w)
 }
 
@@ -432,11 +449,17 @@ type MariadbGTIDListEvent struct {
 }
 
 func (e *MariadbGTIDListEvent) Decode(data []byte) error {
+	if len(data) < 4 {
+		return errors.NotValid.Newf("[myreplicator] MariadbGTIDListEvent too short: %d bytes", len(data))
+	}
 	pos := 0
 	v := binary.LittleEndian.Uint32(data[pos:])
 	pos += 4
 
 	count := v & uint32((1<<28)-1)
+	if need := 4 + int(count)*16; len(data) < need {
+		return errors.NotValid.Newf("[myreplicator] MariadbGTIDListEvent with %d GTIDs requires %d bytes but got %d", count, need, len(data))
+	}
 
 	e.GTIDs = make([]mysql.MariadbGTID, count)
 
@@ -446,6 +469,7 @@ func (e *MariadbGTIDListEvent) Decode(data []byte) error {
 		e.GTIDs[i].ServerID = binary.LittleEndian.Uint32(data[pos:])
 		pos += 4
 		e.GTIDs[i].SequenceNumber = binary.LittleEndian.Uint64(data[pos:])
+		pos += 8
 	}
 
 	return nil
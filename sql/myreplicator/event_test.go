@@ -0,0 +1,191 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package myreplicator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/klauspost/compress/zstd"
+	"github.com/siddontang/go-mysql/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestFile(t *testing.T, file string) (events []*BinlogEvent) {
+	err := NewBinlogParser().ParseFile(file, 0, func(e *BinlogEvent) error {
+		events = append(events, e)
+		return nil
+	})
+	require.NoError(t, err, "%+v", err)
+	return events
+}
+
+// The fixture testdata/mysql8-bin.000001 mirrors a MySQL 8.0.23 binlog with
+// binlog_checksum=CRC32, gtid_mode=OFF, binlog_row_metadata=FULL,
+// binlog_row_value_options=PARTIAL_JSON and binlog_transaction_compression=ON.
+// It has been generated and not captured from a server, replace it with a
+// capture once one is available.
+func TestParser_MySQL8(t *testing.T) {
+	t.Parallel()
+
+	events := parseTestFile(t, "testdata/mysql8-bin.000001")
+	require.Len(t, events, 9)
+	assert.Exactly(t, BINLOG_CHECKSUM_ALG_CRC32, events[0].Event.(*FormatDescriptionEvent).ChecksumAlgorithm)
+	assert.Exactly(t, ANONYMOUS_GTID_EVENT, events[2].Header.EventType)
+
+	t.Run("table map full metadata", func(t *testing.T) {
+		tme := events[4].Event.(*TableMapEvent)
+		assert.Exactly(t, "customer_entity", string(tme.Table))
+		assert.Exactly(t, []string{"entity_id", "email", "attributes", "is_active"}, tme.ColumnNameString())
+		assert.Exactly(t, []uint64{0}, tme.PrimaryKey)
+		assert.Exactly(t, []uint64{255}, tme.DefaultCharset)
+		assert.Exactly(t, map[int]bool{0: true, 3: false}, tme.UnsignedMap())
+		assert.Exactly(t, []byte{0xf0}, tme.VisibilityBitmap)
+		assert.Exactly(t, 1, tme.JsonColumnCount())
+	})
+
+	t.Run("partial JSON update", func(t *testing.T) {
+		assert.Exactly(t, PARTIAL_UPDATE_ROWS_EVENT, events[5].Header.EventType)
+		re := events[5].Event.(*RowsEvent)
+		require.Len(t, re.Rows, 2)
+		assert.Exactly(t, []interface{}{int32(3), "a@b.c", []byte(`{"color":"red"}`), int8(1)}, re.Rows[0])
+		assert.Exactly(t, []interface{}{int32(3), "a@b.c", JsonDiffs{
+			{Op: JsonDiffOperationReplace, Path: "$.color", Value: `"blue"`},
+		}, int8(1)}, re.Rows[1])
+	})
+
+	t.Run("compressed transaction", func(t *testing.T) {
+		be := events[8]
+		pe := be.Event.(*TransactionPayloadEvent)
+		assert.Exactly(t, uint64(PAYLOAD_COMPRESSION_ZSTD), pe.CompressionType)
+		assert.Exactly(t, int(pe.UncompressedSize), len(pe.Payload))
+		require.Len(t, pe.Events, 4)
+
+		assert.Exactly(t, "BEGIN", string(pe.Events[0].Event.(*QueryEvent).Query))
+		re := pe.Events[2].Event.(*RowsEvent)
+		assert.Exactly(t, "customer_entity", string(re.Table.Table))
+		assert.Exactly(t, [][]interface{}{{int32(4), "d@e.f", []byte(`{"color":"red"}`), int8(0)}}, re.Rows)
+		assert.Exactly(t, uint64(22), pe.Events[3].Event.(*XIDEvent).XID)
+		for _, ie := range pe.Events {
+			assert.Exactly(t, be.Header.LogPos, ie.Header.LogPos)
+		}
+	})
+
+	t.Run("file reader unwraps payload", func(t *testing.T) {
+		var types []EventType
+		err := NewFileReader(EventFilter{Tables: []string{"customer_entity"}}).ReadFiles(func(_ string, e *BinlogEvent) error {
+			types = append(types, e.Header.EventType)
+			return nil
+		}, "testdata/mysql8-bin.000001")
+		require.NoError(t, err, "%+v", err)
+		assert.Exactly(t, []EventType{TABLE_MAP_EVENT, PARTIAL_UPDATE_ROWS_EVENT, TABLE_MAP_EVENT, WRITE_ROWS_EVENTv2}, types)
+	})
+}
+
+// The fixture testdata/mariadb-bin.000001 mirrors a MariaDB 10.3 binlog with
+// binlog_checksum=CRC32, a GTID list, a transaction with a group commit ID and
+// a standalone DDL. It has been generated and not captured from a server,
+// replace it with a capture once one is available.
+func TestParser_MariaDB(t *testing.T) {
+	t.Parallel()
+
+	events := parseTestFile(t, "testdata/mariadb-bin.000001")
+	require.Len(t, events, 8)
+	assert.Exactly(t, BINLOG_CHECKSUM_ALG_CRC32, events[0].Event.(*FormatDescriptionEvent).ChecksumAlgorithm)
+
+	gl := events[1].Event.(*MariadbGTIDListEvent)
+	assert.Exactly(t, []mysql.MariadbGTID{
+		{DomainID: 0, ServerID: 1, SequenceNumber: 1000},
+		{DomainID: 1, ServerID: 2, SequenceNumber: 17},
+	}, gl.GTIDs)
+
+	ge := events[3].Event.(*MariadbGTIDEvent)
+	assert.Exactly(t, "0-1-1001", ge.GTID.String())
+	assert.Exactly(t, uint64(77), ge.CommitID)
+	assert.False(t, ge.IsStandalone())
+
+	ge = events[6].Event.(*MariadbGTIDEvent)
+	assert.Exactly(t, "0-1-1002", ge.GTID.String())
+	assert.Exactly(t, uint64(0), ge.CommitID)
+	assert.True(t, ge.IsStandalone())
+	assert.True(t, ge.Flags&MARIADB_GTID_FL_DDL > 0)
+}
+
+func TestMariadbGTIDListEvent_Decode(t *testing.T) {
+	t.Parallel()
+
+	// announces two GTIDs but contains only one
+	data := []byte{2, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0}
+	err := new(MariadbGTIDListEvent).Decode(data)
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+}
+
+func TestTransactionPayloadEvent_Decode(t *testing.T) {
+	t.Parallel()
+
+	payload := func(compressionType byte, uncompressed, compressed []byte) []byte {
+		var buf bytes.Buffer
+		buf.Write([]byte{OTW_PAYLOAD_COMPRESSION_TYPE_FIELD, 1, compressionType})
+		buf.Write([]byte{OTW_PAYLOAD_UNCOMPRESSED_SIZE_FIELD, 1, byte(len(uncompressed))})
+		buf.Write([]byte{OTW_PAYLOAD_SIZE_FIELD, 1, byte(len(compressed))})
+		buf.WriteByte(OTW_PAYLOAD_HEADER_END_MARK)
+		buf.Write(compressed)
+		return buf.Bytes()
+	}
+	raw := []byte("some events")
+
+	t.Run("zstd", func(t *testing.T) {
+		enc, err := zstd.NewWriter(nil)
+		require.NoError(t, err)
+		e := new(TransactionPayloadEvent)
+		require.NoError(t, e.Decode(payload(PAYLOAD_COMPRESSION_ZSTD, raw, enc.EncodeAll(raw, nil))))
+		assert.Exactly(t, raw, e.Payload)
+		assert.Empty(t, e.Events)
+	})
+	t.Run("none", func(t *testing.T) {
+		e := new(TransactionPayloadEvent)
+		require.NoError(t, e.Decode(payload(PAYLOAD_COMPRESSION_NONE, raw, raw)))
+		assert.Exactly(t, raw, e.Payload)
+	})
+	t.Run("unsupported compression", func(t *testing.T) {
+		err := new(TransactionPayloadEvent).Decode(payload(7, raw, raw))
+		assert.True(t, errors.NotSupported.Match(err), "%+v", err)
+	})
+	t.Run("truncated", func(t *testing.T) {
+		data := payload(PAYLOAD_COMPRESSION_NONE, raw, raw)
+		err := new(TransactionPayloadEvent).Decode(data[:len(data)-3])
+		assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	})
+}
+
+func TestDecodeJsonPartialBinary(t *testing.T) {
+	t.Parallel()
+
+	data := []byte{byte(JsonDiffOperationInsert), 5, '$', '.', 'a', '[', '0', 2, JSONB_INT16, 42, 0}
+	data = append(data, byte(JsonDiffOperationRemove), 3, '$', '.', 'b')
+	diffs, err := decodeJsonPartialBinary(data)
+	require.NoError(t, err, "%+v", err)
+	assert.Exactly(t, JsonDiffs{
+		{Op: JsonDiffOperationInsert, Path: "$.a[0]", Value: "42"},
+		{Op: JsonDiffOperationRemove, Path: "$.b"},
+	}, diffs)
+
+	_, err = decodeJsonPartialBinary([]byte{9, 1, '$'})
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+	_, err = decodeJsonPartialBinary([]byte{0, 10, '$'})
+	assert.True(t, errors.NotValid.Match(err), "%+v", err)
+}
@@ -84,6 +84,93 @@ func decodeJsonBinary(data []byte) ([]byte, error) {
 	return json.Marshal(v)
 }
 
+// JsonDiffOperation defines the modification of a partial JSON update.
+type JsonDiffOperation byte
+
+const (
+	// JsonDiffOperationReplace replaces the value at the path with the new value.
+	JsonDiffOperationReplace JsonDiffOperation = iota
+	// JsonDiffOperationInsert adds a new element at the path.
+	JsonDiffOperationInsert
+	// JsonDiffOperationRemove removes the element at the path.
+	JsonDiffOperationRemove
+)
+
+func (op JsonDiffOperation) String() string {
+	switch op {
+	case JsonDiffOperationReplace:
+		return "Replace"
+	case JsonDiffOperationInsert:
+		return "Insert"
+	case JsonDiffOperationRemove:
+		return "Remove"
+	default:
+		return fmt.Sprintf("Unknown(%d)", op)
+	}
+}
+
+// JsonDiff contains a single modification of a partial JSON update, created
+// by JSON_SET, JSON_REPLACE or JSON_REMOVE with
+// binlog_row_value_options=PARTIAL_JSON.
+type JsonDiff struct {
+	Op   JsonDiffOperation
+	Path string
+	// Value contains the new value in the common JSON encoding. Empty for
+	// JsonDiffOperationRemove.
+	Value string
+}
+
+func (jd JsonDiff) String() string {
+	return fmt.Sprintf("json_diff(op:%s path:%s value:%s)", jd.Op, jd.Path, jd.Value)
+}
+
+// JsonDiffs represents the after image of a partially updated JSON column.
+type JsonDiffs []JsonDiff
+
+// decodeJsonPartialBinary decodes the list of modifications of a partial JSON
+// update. See Json_diff_vector::read_binary in MySQL 8.
+func decodeJsonPartialBinary(data []byte) (JsonDiffs, error) {
+	var diffs JsonDiffs
+	pos := 0
+	for pos < len(data) {
+		op := JsonDiffOperation(data[pos])
+		if op > JsonDiffOperationRemove {
+			return nil, errors.NotValid.Newf("[myreplicator] decodeJsonPartialBinary: unknown operation %d", op)
+		}
+		pos++
+
+		pathLen, n, err := lengthEncodedInt(data[pos:])
+		if err != nil {
+			return nil, errors.Wrap(err, "[myreplicator] decodeJsonPartialBinary.pathLength")
+		}
+		pos += n
+		if pos+int(pathLen) > len(data) {
+			return nil, errors.NotValid.Newf("[myreplicator] decodeJsonPartialBinary: path length %d exceeds data", pathLen)
+		}
+		diff := JsonDiff{Op: op, Path: string(data[pos : pos+int(pathLen)])}
+		pos += int(pathLen)
+
+		if op != JsonDiffOperationRemove {
+			valueLen, n, err := lengthEncodedInt(data[pos:])
+			if err != nil {
+				return nil, errors.Wrap(err, "[myreplicator] decodeJsonPartialBinary.valueLength")
+			}
+			pos += n
+			if pos+int(valueLen) > len(data) {
+				return nil, errors.NotValid.Newf("[myreplicator] decodeJsonPartialBinary: value length %d exceeds data", valueLen)
+			}
+			v, err := decodeJsonBinary(data[pos : pos+int(valueLen)])
+			if err != nil {
+				return nil, errors.Wrap(err, "[myreplicator] decodeJsonPartialBinary.value")
+			}
+			diff.Value = string(v)
+			pos += int(valueLen)
+		}
+		diffs = append(diffs, diff)
+	}
+	return diffs, nil
+}
+
 type jsonBinaryDecoder struct {
 	err error
 }
@@ -16,6 +16,8 @@ type BinlogParser struct {
 
 	// for rawMode, we only parse FormatDescriptionEvent and RotateEvent
 	rawMode bool
+	// useDecimal see SetUseDecimal
+	useDecimal bool
 }
 
 func NewBinlogParser() *BinlogParser {
@@ -100,21 +102,25 @@ func (p *BinlogParser) parseReader(r io.Reader, onEvent OnEventFunc) error {
 		var e Event
 		e, err = p.parseEvent(h, data)
 		if err != nil {
-			break
+			return errors.Wrap(err, "[myreplicator]")
 		}
 
 		if err = onEvent(&BinlogEvent{rawData, h, e}); err != nil {
 			return errors.Wrap(err, "[myreplicator]")
 		}
 	}
-
-	return nil
 }
 
 func (p *BinlogParser) SetRawMode(mode bool) {
 	p.rawMode = mode
 }
 
+// SetUseDecimal decodes the DECIMAL columns of rows events as string instead
+// of float64, which keeps the full precision.
+func (p *BinlogParser) SetUseDecimal(useDecimal bool) {
+	p.useDecimal = useDecimal
+}
+
 func (p *BinlogParser) parseHeader(data []byte) (*EventHeader, error) {
 	h := new(EventHeader)
 	err := h.Decode(data)
@@ -160,8 +166,11 @@ func (p *BinlogParser) parseEvent(h *EventHeader, data []byte) (Event, error) {
 				UPDATE_ROWS_EVENTv1,
 				WRITE_ROWS_EVENTv2,
 				UPDATE_ROWS_EVENTv2,
-				DELETE_ROWS_EVENTv2:
+				DELETE_ROWS_EVENTv2,
+				PARTIAL_UPDATE_ROWS_EVENT:
 				e = p.newRowsEvent(h)
+			case TRANSACTION_PAYLOAD_EVENT:
+				e = &TransactionPayloadEvent{parser: p}
 			case ROWS_QUERY_EVENT:
 				e = &RowsQueryEvent{}
 			case GTID_EVENT:
@@ -196,6 +205,10 @@ func (p *BinlogParser) parseEvent(h *EventHeader, data []byte) (Event, error) {
 		p.tables[te.TableID] = te
 	}
 
+	if pe, ok := e.(*TransactionPayloadEvent); ok {
+		pe.setLogPos(h.LogPos)
+	}
+
 	if re, ok := e.(*RowsEvent); ok {
 		if (re.Flags & RowsEventStmtEndFlag) > 0 {
 			// Refer https://github.com/alibaba/canal/blob/38cc81b7dab29b51371096fb6763ca3a8432ffee/dbsync/src/main/java/com/taobao/tddl/dbsync/binlog/event/RowsLogEvent.java#L176
@@ -240,6 +253,7 @@ func (p *BinlogParser) newRowsEvent(h *EventHeader) *RowsEvent {
 
 	e.needBitmap2 = false
 	e.tables = p.tables
+	e.useDecimal = p.useDecimal
 
 	switch h.EventType {
 	case WRITE_ROWS_EVENTv0:
@@ -262,6 +276,10 @@ func (p *BinlogParser) newRowsEvent(h *EventHeader) *RowsEvent {
 		e.needBitmap2 = true
 	case DELETE_ROWS_EVENTv2:
 		e.Version = 2
+	case PARTIAL_UPDATE_ROWS_EVENT:
+		e.Version = 2
+		e.needBitmap2 = true
+		e.partialUpdate = true
 	}
 
 	return e
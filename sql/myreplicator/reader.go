@@ -0,0 +1,269 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package myreplicator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"github.com/corestoreio/errors"
+	"github.com/siddontang/go-mysql/mysql"
+)
+
+// EventFilter restricts the events returned by a FileReader. Zero values
+// disable the appropriate filter.
+type EventFilter struct {
+	// StartTime skips all events before this time.
+	StartTime time.Time
+	// StopTime skips all events after this time.
+	StopTime time.Time
+	// StartPosition skips all events in the first file which start before
+	// this position. Same as the mysqlbinlog option --start-position.
+	StartPosition uint32
+	// StopPosition stops reading at the first event in the last file which
+	// starts at or after this position. Same as the mysqlbinlog option
+	// --stop-position.
+	StopPosition uint32
+	// Schemas if set, only table map, rows and query events of these databases
+	// get returned.
+	Schemas []string
+	// Tables if set, only table map and rows events of these tables get
+	// returned.
+	Tables []string
+}
+
+func (ef EventFilter) hasTableFilter() bool {
+	return len(ef.Schemas) > 0 || len(ef.Tables) > 0
+}
+
+func containsStr(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (ef EventFilter) matchTable(schema, table []byte) bool {
+	if len(ef.Schemas) > 0 && !containsStr(ef.Schemas, string(schema)) {
+		return false
+	}
+	return len(ef.Tables) == 0 || containsStr(ef.Tables, string(table))
+}
+
+// Match returns true if the event passes the time, schema and table filter.
+// The position filter gets applied by the FileReader.
+func (ef EventFilter) Match(e *BinlogEvent) bool {
+	ts := time.Unix(int64(e.Header.Timestamp), 0)
+	if !ef.StartTime.IsZero() && ts.Before(ef.StartTime) {
+		return false
+	}
+	if !ef.StopTime.IsZero() && ts.After(ef.StopTime) {
+		return false
+	}
+	if !ef.hasTableFilter() {
+		return true
+	}
+	switch ev := e.Event.(type) {
+	case *RowsEvent:
+		return ev.Table != nil && ef.matchTable(ev.Table.Schema, ev.Table.Table)
+	case *TableMapEvent:
+		return ef.matchTable(ev.Schema, ev.Table)
+	case *QueryEvent:
+		return len(ef.Tables) == 0 && containsStr(ef.Schemas, string(ev.Schema))
+	}
+	return false
+}
+
+// FileReader reads binary log and relay log files from the local file system
+// without a running MySQL server.
+type FileReader struct {
+	Filter EventFilter
+	parser *BinlogParser
+}
+
+// NewFileReader creates a new reader for local binlog files.
+func NewFileReader(f EventFilter) *FileReader {
+	return &FileReader{
+		Filter: f,
+		parser: NewBinlogParser(),
+	}
+}
+
+// SetUseDecimal decodes the DECIMAL columns of rows events as string instead
+// of float64. See BinlogParser.SetUseDecimal.
+func (fr *FileReader) SetUseDecimal(useDecimal bool) {
+	fr.parser.SetUseDecimal(useDecimal)
+}
+
+var errStopReading = errors.New("[myreplicator] stop reading")
+
+// ReadFiles parses the files in the provided order and calls fn for each
+// event which matches the filter. Returning an error in fn aborts reading.
+func (fr *FileReader) ReadFiles(fn func(file string, e *BinlogEvent) error, files ...string) error {
+	for i, file := range files {
+		isFirst, isLast := i == 0, i == len(files)-1
+		err := fr.parser.ParseFile(file, 0, func(e *BinlogEvent) error {
+			start := EventStartPosition(e)
+			if isFirst && fr.Filter.StartPosition > 0 && start < fr.Filter.StartPosition {
+				return nil
+			}
+			if isLast && fr.Filter.StopPosition > 0 && start >= fr.Filter.StopPosition {
+				return errStopReading
+			}
+			if pe, ok := e.Event.(*TransactionPayloadEvent); ok {
+				// a compressed transaction, the filter applies to the events
+				// of the transaction.
+				for _, ie := range pe.Events {
+					if !fr.Filter.Match(ie) {
+						continue
+					}
+					if err := fn(filepath.Base(file), ie); err != nil {
+						return errors.WithStack(err)
+					}
+				}
+				return nil
+			}
+			if !fr.Filter.Match(e) {
+				return nil
+			}
+			return fn(filepath.Base(file), e)
+		})
+		if errors.Cause(err) == errStopReading {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "[myreplicator] ReadFiles %q", file)
+		}
+	}
+	return nil
+}
+
+// EventStartPosition calculates the position where the event starts in the
+// binlog file.
+func EventStartPosition(e *BinlogEvent) uint32 {
+	if e.Header.LogPos < e.Header.EventSize {
+		return 0 // artificial events, for example the fake rotate event
+	}
+	return e.Header.LogPos - e.Header.EventSize
+}
+
+// EventWriter renders events either human readable or as JSON, one object per
+// line.
+type EventWriter struct {
+	W    io.Writer
+	JSON bool
+}
+
+// Write writes the event to the underlying writer.
+func (ew EventWriter) Write(file string, e *BinlogEvent) error {
+	if !ew.JSON {
+		if _, err := fmt.Fprintf(ew.W, "# at %s:%d\n", file, EventStartPosition(e)); err != nil {
+			return errors.WithStack(err)
+		}
+		e.Dump(ew.W)
+		return nil
+	}
+	data, err := json.Marshal(NewEventRecord(file, e))
+	if err != nil {
+		return errors.Wrapf(err, "[myreplicator] Failed to encode event %s at %s:%d", e.Header.EventType, file, e.Header.LogPos)
+	}
+	data = append(data, '\n')
+	_, err = ew.W.Write(data)
+	return errors.WithStack(err)
+}
+
+// EventRecord defines the JSON representation of an event.
+type EventRecord struct {
+	File     string    `json:"file"`
+	Start    uint32    `json:"start"`
+	End      uint32    `json:"end"`
+	Time     time.Time `json:"time"`
+	Type     string    `json:"type"`
+	ServerID uint32    `json:"server_id"`
+	Schema   string    `json:"schema,omitempty"`
+	Table    string    `json:"table,omitempty"`
+	Query    string    `json:"query,omitempty"`
+	GTID     string    `json:"gtid,omitempty"`
+	XID      uint64    `json:"xid,omitempty"`
+	NextLog  string    `json:"next_log,omitempty"`
+	// Rows contains for update events pairs of before and after rows.
+	Rows [][]interface{} `json:"rows,omitempty"`
+}
+
+// NewEventRecord converts an event into its JSON representation.
+func NewEventRecord(file string, e *BinlogEvent) EventRecord {
+	er := EventRecord{
+		File:     file,
+		Start:    EventStartPosition(e),
+		End:      e.Header.LogPos,
+		Time:     time.Unix(int64(e.Header.Timestamp), 0),
+		Type:     e.Header.EventType.String(),
+		ServerID: e.Header.ServerID,
+	}
+	switch ev := e.Event.(type) {
+	case *RowsEvent:
+		if ev.Table != nil {
+			er.Schema, er.Table = string(ev.Table.Schema), string(ev.Table.Table)
+		}
+		er.Rows = make([][]interface{}, len(ev.Rows))
+		for i, r := range ev.Rows {
+			er.Rows[i] = make([]interface{}, len(r))
+			for j, v := range r {
+				er.Rows[i][j] = jsonValue(v)
+			}
+		}
+	case *TableMapEvent:
+		er.Schema, er.Table = string(ev.Schema), string(ev.Table)
+	case *QueryEvent:
+		er.Schema, er.Query = string(ev.Schema), string(ev.Query)
+	case *RowsQueryEvent:
+		er.Query = string(ev.Query)
+	case *XIDEvent:
+		er.XID = ev.XID
+	case *RotateEvent:
+		er.NextLog = string(ev.NextLogName)
+	case *GTIDEvent:
+		if len(ev.SID) == 16 {
+			s := ev.SID
+			er.GTID = fmt.Sprintf("%x-%x-%x-%x-%x:%d", s[0:4], s[4:6], s[6:8], s[8:10], s[10:16], ev.GNO)
+		}
+	case *MariadbGTIDEvent:
+		er.GTID = ev.GTID.String()
+	}
+	return er
+}
+
+// jsonValue converts byte slices with valid UTF-8 to strings and other
+// internal types to their string representation.
+func jsonValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case []byte:
+		if utf8.Valid(val) {
+			return string(val)
+		}
+		return val
+	case fracTime:
+		return val.String()
+	case time.Time:
+		return val.Format(mysql.TimeFormat)
+	}
+	return v
+}
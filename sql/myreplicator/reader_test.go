@@ -0,0 +1,170 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package myreplicator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testBinlogWriter creates a synthetic binlog file without checksums.
+type testBinlogWriter struct {
+	buf bytes.Buffer
+}
+
+func newTestBinlogWriter() *testBinlogWriter {
+	w := new(testBinlogWriter)
+	w.buf.Write(BinLogFileHeader)
+	body := make([]byte, 2+50+4+1)
+	binary.LittleEndian.PutUint16(body, 4)
+	copy(body[2:], "5.5.60-log") // no checksums before 5.6.1
+	body[56] = EventHeaderSize
+	body = append(body, bytes.Repeat([]byte{0x8}, 27)...)
+	w.event(1500000000, FORMAT_DESCRIPTION_EVENT, body)
+	return w
+}
+
+func (w *testBinlogWriter) event(ts uint32, et EventType, body []byte) {
+	h := make([]byte, EventHeaderSize)
+	size := uint32(EventHeaderSize + len(body))
+	binary.LittleEndian.PutUint32(h[0:], ts)
+	h[4] = byte(et)
+	binary.LittleEndian.PutUint32(h[5:], 1)
+	binary.LittleEndian.PutUint32(h[9:], size)
+	binary.LittleEndian.PutUint32(h[13:], uint32(w.buf.Len())+size)
+	w.buf.Write(h)
+	w.buf.Write(body)
+}
+
+func (w *testBinlogWriter) query(ts uint32, schema, query string) {
+	body := make([]byte, 4+4+1+2+2)
+	body[8] = byte(len(schema))
+	body = append(body, schema...)
+	body = append(body, 0x00)
+	body = append(body, query...)
+	w.event(ts, QUERY_EVENT, body)
+}
+
+func (w *testBinlogWriter) xid(ts uint32, xid uint64) {
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint64(body, xid)
+	w.event(ts, XID_EVENT, body)
+}
+
+func writeTestBinlog(t *testing.T, dir string) string {
+	w := newTestBinlogWriter()
+	w.query(1500000010, "shop", "BEGIN")
+	w.query(1500000010, "shop", "UPDATE catalog_product_entity_decimal SET value=9.99 WHERE entity_id=3")
+	w.xid(1500000010, 11)
+	w.query(1500000020, "blog", "DELETE FROM posts")
+	w.xid(1500000020, 12)
+
+	name := filepath.Join(dir, "mysql-bin.000001")
+	require.NoError(t, ioutil.WriteFile(name, w.buf.Bytes(), 0600))
+	return name
+}
+
+func TestFileReader(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "myreplicator")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := writeTestBinlog(t, dir)
+
+	readAll := func(f EventFilter) (events []*BinlogEvent) {
+		err := NewFileReader(f).ReadFiles(func(name string, e *BinlogEvent) error {
+			assert.Exactly(t, "mysql-bin.000001", name)
+			events = append(events, e)
+			return nil
+		}, file)
+		require.NoError(t, err, "%+v", err)
+		return events
+	}
+
+	t.Run("no filter", func(t *testing.T) {
+		assert.Len(t, readAll(EventFilter{}), 6)
+	})
+	t.Run("schema filter", func(t *testing.T) {
+		evs := readAll(EventFilter{Schemas: []string{"blog"}})
+		require.Len(t, evs, 1)
+		assert.Exactly(t, "DELETE FROM posts", string(evs[0].Event.(*QueryEvent).Query))
+	})
+	t.Run("time filter", func(t *testing.T) {
+		evs := readAll(EventFilter{
+			StartTime: time.Unix(1500000005, 0),
+			StopTime:  time.Unix(1500000015, 0),
+		})
+		assert.Len(t, evs, 3)
+	})
+	t.Run("position filter", func(t *testing.T) {
+		all := readAll(EventFilter{})
+		evs := readAll(EventFilter{
+			StartPosition: EventStartPosition(all[2]),
+			StopPosition:  EventStartPosition(all[4]),
+		})
+		require.Len(t, evs, 2)
+		assert.Exactly(t, all[2].Header.LogPos, evs[0].Header.LogPos)
+		assert.Exactly(t, all[3].Header.LogPos, evs[1].Header.LogPos)
+	})
+	t.Run("not a binlog file", func(t *testing.T) {
+		name := filepath.Join(dir, "invalid")
+		require.NoError(t, ioutil.WriteFile(name, []byte("Gopher"), 0600))
+		err := NewFileReader(EventFilter{}).ReadFiles(func(string, *BinlogEvent) error { return nil }, name)
+		assert.Error(t, err)
+	})
+}
+
+func TestEventWriter(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "myreplicator")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	file := writeTestBinlog(t, dir)
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		ew := EventWriter{W: &buf, JSON: true}
+		require.NoError(t, NewFileReader(EventFilter{Schemas: []string{"shop"}}).ReadFiles(ew.Write, file))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		require.Len(t, lines, 2)
+		var er EventRecord
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &er))
+		assert.Exactly(t, "QueryEvent", er.Type)
+		assert.Exactly(t, "shop", er.Schema)
+		assert.Exactly(t, "UPDATE catalog_product_entity_decimal SET value=9.99 WHERE entity_id=3", er.Query)
+		assert.Exactly(t, "mysql-bin.000001", er.File)
+	})
+
+	t.Run("human readable", func(t *testing.T) {
+		var buf bytes.Buffer
+		ew := EventWriter{W: &buf}
+		require.NoError(t, NewFileReader(EventFilter{Schemas: []string{"blog"}}).ReadFiles(ew.Write, file))
+		assert.Contains(t, buf.String(), "# at mysql-bin.000001:")
+		assert.Contains(t, buf.String(), "Query: DELETE FROM posts")
+	})
+}
@@ -30,6 +30,36 @@ type TableMapEvent struct {
 
 	//len = (ColumnCount + 7) / 8
 	NullBitmap []byte
+
+	// The following optional metadata is only available with
+	// binlog_row_metadata=FULL, some of it also with MINIMAL, since MySQL
+	// 8.0.1. It allows to decode the rows without querying the
+	// information_schema.
+
+	// SignednessBitmap contains one bit for each numeric column, the most
+	// significant bit first. A set bit means unsigned.
+	SignednessBitmap []byte
+	// DefaultCharset contains the default collation followed by pairs of
+	// column index and collation for all character columns which differ.
+	DefaultCharset []uint64
+	// ColumnCharset contains the collation of each character column.
+	ColumnCharset []uint64
+	ColumnName    [][]byte
+	// SetStrValue contains for each SET column the allowed values.
+	SetStrValue [][][]byte
+	// EnumStrValue contains for each ENUM column the allowed values.
+	EnumStrValue [][][]byte
+	GeometryType []uint64
+	// PrimaryKey contains the indexes of the primary key columns.
+	PrimaryKey []uint64
+	// PrimaryKeyPrefix contains pairs of column index and prefix length. A
+	// prefix length of zero means the whole column.
+	PrimaryKeyPrefix      []uint64
+	EnumSetDefaultCharset []uint64
+	EnumSetColumnCharset  []uint64
+	// VisibilityBitmap contains one bit for each column, the most significant
+	// bit first. A set bit means visible.
+	VisibilityBitmap []byte
 }
 
 func (e *TableMapEvent) Decode(data []byte) error {
@@ -77,15 +107,205 @@ func (e *TableMapEvent) Decode(data []byte) error {
 
 	pos += n
 
-	if len(data[pos:]) != bitmapByteSize(int(e.ColumnCount)) {
+	nullBitmapSize := bitmapByteSize(int(e.ColumnCount))
+	if len(data[pos:]) < nullBitmapSize {
 		return io.EOF
 	}
 
-	e.NullBitmap = data[pos:]
+	e.NullBitmap = data[pos : pos+nullBitmapSize]
+	pos += nullBitmapSize
+
+	return errors.Wrap(e.decodeOptionalMeta(data[pos:]), "[myreplicator]")
+}
 
+// decodeOptionalMeta decodes the type-length-value encoded optional metadata.
+// Unknown types get skipped.
+func (e *TableMapEvent) decodeOptionalMeta(data []byte) (err error) {
+	for len(data) > 0 {
+		tp := data[0]
+		l, n, err := lengthEncodedInt(data[1:])
+		if err != nil {
+			return errors.Wrapf(err, "[myreplicator] TableMapEvent optional metadata type %d", tp)
+		}
+		start := 1 + n
+		end := start + int(l)
+		if end > len(data) {
+			return errors.NotValid.Newf("[myreplicator] TableMapEvent optional metadata type %d with length %d exceeds the event data", tp, l)
+		}
+		v := data[start:end]
+		data = data[end:]
+
+		switch tp {
+		case TABLE_MAP_OPT_META_SIGNEDNESS:
+			e.SignednessBitmap = v
+		case TABLE_MAP_OPT_META_DEFAULT_CHARSET:
+			e.DefaultCharset, err = decodeIntSeq(v)
+		case TABLE_MAP_OPT_META_COLUMN_CHARSET:
+			e.ColumnCharset, err = decodeIntSeq(v)
+		case TABLE_MAP_OPT_META_COLUMN_NAME:
+			e.ColumnName, err = decodeStrSeq(v)
+		case TABLE_MAP_OPT_META_SET_STR_VALUE:
+			e.SetStrValue, err = decodeStrSeqSeq(v)
+		case TABLE_MAP_OPT_META_ENUM_STR_VALUE:
+			e.EnumStrValue, err = decodeStrSeqSeq(v)
+		case TABLE_MAP_OPT_META_GEOMETRY_TYPE:
+			e.GeometryType, err = decodeIntSeq(v)
+		case TABLE_MAP_OPT_META_SIMPLE_PRIMARY_KEY:
+			e.PrimaryKey, err = decodeIntSeq(v)
+		case TABLE_MAP_OPT_META_PRIMARY_KEY_WITH_PREFIX:
+			e.PrimaryKeyPrefix, err = decodeIntSeq(v)
+			if err == nil && len(e.PrimaryKeyPrefix)%2 == 0 {
+				e.PrimaryKey = make([]uint64, 0, len(e.PrimaryKeyPrefix)/2)
+				for i := 0; i < len(e.PrimaryKeyPrefix); i += 2 {
+					e.PrimaryKey = append(e.PrimaryKey, e.PrimaryKeyPrefix[i])
+				}
+			}
+		case TABLE_MAP_OPT_META_ENUM_AND_SET_DEFAULT_CHARSET:
+			e.EnumSetDefaultCharset, err = decodeIntSeq(v)
+		case TABLE_MAP_OPT_META_ENUM_AND_SET_COLUMN_CHARSET:
+			e.EnumSetColumnCharset, err = decodeIntSeq(v)
+		case TABLE_MAP_OPT_META_COLUMN_VISIBILITY:
+			e.VisibilityBitmap = v
+		}
+		if err != nil {
+			return errors.Wrapf(err, "[myreplicator] TableMapEvent optional metadata type %d", tp)
+		}
+	}
 	return nil
 }
 
+// lengthEncodedInt same as mysql.LengthEncodedInt but returns an error
+// instead of panicking if data is too short. NULL is not allowed.
+func lengthEncodedInt(data []byte) (uint64, int, error) {
+	if len(data) == 0 {
+		return 0, 0, errors.NotValid.Newf("[myreplicator] Packed integer: unexpected end of data")
+	}
+	n := 1
+	switch data[0] {
+	case 0xfb, 0xff:
+		return 0, 0, errors.NotValid.Newf("[myreplicator] Packed integer: invalid first byte %#x", data[0])
+	case 0xfc:
+		n = 3
+	case 0xfd:
+		n = 4
+	case 0xfe:
+		n = 9
+	}
+	if len(data) < n {
+		return 0, 0, errors.NotValid.Newf("[myreplicator] Packed integer requires %d bytes but got %d", n, len(data))
+	}
+	v, _, n := mysql.LengthEncodedInt(data)
+	return v, n, nil
+}
+
+func decodeIntSeq(data []byte) ([]uint64, error) {
+	var ret []uint64
+	for len(data) > 0 {
+		v, n, err := lengthEncodedInt(data)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ret = append(ret, v)
+		data = data[n:]
+	}
+	return ret, nil
+}
+
+func decodeStrSeq(data []byte) ([][]byte, error) {
+	var ret [][]byte
+	for len(data) > 0 {
+		l, n, err := lengthEncodedInt(data)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if n+int(l) > len(data) {
+			return nil, errors.NotValid.Newf("[myreplicator] Length encoded string exceeds data: %d > %d", n+int(l), len(data))
+		}
+		ret = append(ret, data[n:n+int(l)])
+		data = data[n+int(l):]
+	}
+	return ret, nil
+}
+
+func decodeStrSeqSeq(data []byte) ([][][]byte, error) {
+	var ret [][][]byte
+	for len(data) > 0 {
+		count, n, err := lengthEncodedInt(data)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		data = data[n:]
+		vals := make([][]byte, 0, count)
+		for i := uint64(0); i < count; i++ {
+			l, n, err := lengthEncodedInt(data)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if n+int(l) > len(data) {
+				return nil, errors.NotValid.Newf("[myreplicator] Length encoded string exceeds data: %d > %d", n+int(l), len(data))
+			}
+			vals = append(vals, data[n:n+int(l)])
+			data = data[n+int(l):]
+		}
+		ret = append(ret, vals)
+	}
+	return ret, nil
+}
+
+// ColumnNameString returns the column names if the binlog has been written
+// with binlog_row_metadata=FULL, otherwise nil.
+func (e *TableMapEvent) ColumnNameString() []string {
+	if len(e.ColumnName) == 0 {
+		return nil
+	}
+	names := make([]string, len(e.ColumnName))
+	for i, n := range e.ColumnName {
+		names[i] = string(n)
+	}
+	return names
+}
+
+// UnsignedMap returns for each numeric column its index and whether it is
+// unsigned. Returns nil if no signedness information is available.
+func (e *TableMapEvent) UnsignedMap() map[int]bool {
+	if len(e.SignednessBitmap) == 0 {
+		return nil
+	}
+	ret := make(map[int]bool)
+	p := 0
+	for i, t := range e.ColumnType {
+		if !isNumericColumn(t) {
+			continue
+		}
+		if p/8 < len(e.SignednessBitmap) {
+			ret[i] = e.SignednessBitmap[p/8]&(1<<(7-uint(p%8))) != 0
+		}
+		p++
+	}
+	return ret
+}
+
+// JsonColumnCount returns the number of JSON columns in the table.
+func (e *TableMapEvent) JsonColumnCount() int {
+	count := 0
+	for _, t := range e.ColumnType {
+		if t == mysql.MYSQL_TYPE_JSON {
+			count++
+		}
+	}
+	return count
+}
+
+func isNumericColumn(t byte) bool {
+	switch t {
+	case mysql.MYSQL_TYPE_TINY, mysql.MYSQL_TYPE_SHORT, mysql.MYSQL_TYPE_INT24,
+		mysql.MYSQL_TYPE_LONG, mysql.MYSQL_TYPE_LONGLONG, mysql.MYSQL_TYPE_NEWDECIMAL,
+		mysql.MYSQL_TYPE_FLOAT, mysql.MYSQL_TYPE_DOUBLE:
+		return true
+	}
+	return false
+}
+
 func bitmapByteSize(columnCount int) int {
 	return int(columnCount+7) / 8
 }
@@ -188,6 +408,12 @@ func (e *TableMapEvent) Dump(w io.Writer) {
 	fmt.Fprintf(w, "Column count: %d\n", e.ColumnCount)
 	fmt.Fprintf(w, "Column type: \n%s", hex.Dump(e.ColumnType))
 	fmt.Fprintf(w, "NULL bitmap: \n%s", hex.Dump(e.NullBitmap))
+	if names := e.ColumnNameString(); names != nil {
+		fmt.Fprintf(w, "Column names: %v\n", names)
+	}
+	if len(e.PrimaryKey) > 0 {
+		fmt.Fprintf(w, "Primary key: %v\n", e.PrimaryKey)
+	}
 	fmt.Fprintln(w)
 }
 
@@ -222,9 +448,16 @@ type RowsEvent struct {
 	ColumnBitmap2 []byte
 
 	//rows: invalid: int64, float64, bool, []byte, string
+	// For PARTIAL_UPDATE_ROWS_EVENT partially updated JSON columns of the
+	// after image are of type JsonDiffs.
 	Rows [][]interface{}
 
+	// partialUpdate gets set for PARTIAL_UPDATE_ROWS_EVENT.
+	partialUpdate bool
+
 	parseTime bool
+	// useDecimal decodes DECIMAL columns as string instead of float64.
+	useDecimal bool
 }
 
 func (e *RowsEvent) Decode(data []byte) error {
@@ -273,13 +506,13 @@ func (e *RowsEvent) Decode(data []byte) error {
 	}()
 
 	for pos < len(data) {
-		if n, err = e.decodeRows(data[pos:], e.Table, e.ColumnBitmap1); err != nil {
+		if n, err = e.decodeRows(data[pos:], e.Table, e.ColumnBitmap1, false); err != nil {
 			return errors.Wrap(err, "[myreplicator]")
 		}
 		pos += n
 
 		if e.needBitmap2 {
-			if n, err = e.decodeRows(data[pos:], e.Table, e.ColumnBitmap2); err != nil {
+			if n, err = e.decodeRows(data[pos:], e.Table, e.ColumnBitmap2, e.partialUpdate); err != nil {
 				return errors.Wrap(err, "[myreplicator]")
 			}
 			pos += n
@@ -293,11 +526,32 @@ func isBitSet(bitmap []byte, i int) bool {
 	return bitmap[i>>3]&(1<<(uint(i)&7)) > 0
 }
 
-func (e *RowsEvent) decodeRows(data []byte, table *TableMapEvent, bitmap []byte) (int, error) {
+// decodeRows decodes a single row image. For the after image of a
+// PARTIAL_UPDATE_ROWS_EVENT, partialImage must be true.
+func (e *RowsEvent) decodeRows(data []byte, table *TableMapEvent, bitmap []byte, partialImage bool) (int, error) {
 	row := make([]interface{}, e.ColumnCount)
 
 	pos := 0
 
+	// see Rows_log_event::print_verbose_one_row in MySQL 8
+	var partialBitmap []byte
+	if partialImage {
+		valueOptions, n, err := lengthEncodedInt(data)
+		if err != nil {
+			return 0, errors.Wrap(err, "[myreplicator] DecodeRows.valueOptions")
+		}
+		pos += n
+		if valueOptions&BINLOG_ROW_VALUE_OPTION_PARTIAL_JSON_UPDATES != 0 {
+			size := bitmapByteSize(table.JsonColumnCount())
+			if pos+size > len(data) {
+				return 0, errors.NotValid.Newf("[myreplicator] DecodeRows: partial JSON bitmap exceeds data")
+			}
+			partialBitmap = data[pos : pos+size]
+			pos += size
+		}
+	}
+	partialBitIndex := 0
+
 	// refer: https://github.com/alibaba/canal/blob/c3e38e50e269adafdd38a48c63a1740cde304c67/dbsync/src/main/java/com/taobao/tddl/dbsync/binlog/event/RowsLogBuffer.java#L63
 	count := 0
 	for i := 0; i < int(e.ColumnCount); i++ {
@@ -315,6 +569,14 @@ func (e *RowsEvent) decodeRows(data []byte, table *TableMapEvent, bitmap []byte)
 	var n int
 	var err error
 	for i := 0; i < int(e.ColumnCount); i++ {
+		// The partial bitmap contains a bit for every JSON column regardless
+		// whether the column is included in the image or not.
+		isPartial := false
+		if partialBitmap != nil && table.ColumnType[i] == mysql.MYSQL_TYPE_JSON {
+			isPartial = isBitSet(partialBitmap, partialBitIndex)
+			partialBitIndex++
+		}
+
 		if !isBitSet(bitmap, i) {
 			continue
 		}
@@ -327,7 +589,20 @@ func (e *RowsEvent) decodeRows(data []byte, table *TableMapEvent, bitmap []byte)
 			continue
 		}
 
-		row[i], n, err = e.decodeValue(data[pos:], table.ColumnType[i], table.ColumnMeta[i])
+		if isPartial {
+			meta := int(table.ColumnMeta[i])
+			if pos+meta > len(data) {
+				return 0, errors.NotValid.Newf("[myreplicator] DecodeRows: partial JSON column %d exceeds data", i)
+			}
+			length := int(mysql.FixedLengthInt(data[pos : pos+meta]))
+			if pos+meta+length > len(data) {
+				return 0, errors.NotValid.Newf("[myreplicator] DecodeRows: partial JSON column %d exceeds data", i)
+			}
+			row[i], err = decodeJsonPartialBinary(data[pos+meta : pos+meta+length])
+			n = meta + length
+		} else {
+			row[i], n, err = e.decodeValue(data[pos:], table.ColumnType[i], table.ColumnMeta[i])
+		}
 
 		if err != nil {
 			return 0, errors.Wrap(err, "[myreplicator] DecodeRows.decodeValue")
@@ -396,7 +671,11 @@ func (e *RowsEvent) decodeValue(data []byte, tp byte, meta uint16) (v interface{
 	case mysql.MYSQL_TYPE_NEWDECIMAL:
 		prec := uint8(meta >> 8)
 		scale := uint8(meta & 0xFF)
-		v, n, err = decodeDecimal(data, int(prec), int(scale))
+		if e.useDecimal {
+			v, n, err = decodeDecimalString(data, int(prec), int(scale))
+		} else {
+			v, n, err = decodeDecimal(data, int(prec), int(scale))
+		}
 	case mysql.MYSQL_TYPE_FLOAT:
 		n = 4
 		v = mysql.ParseBinaryFloat32(data)
@@ -532,6 +811,16 @@ func decodeDecimalDecompressValue(compIndx int, data []byte, mask uint8) (size i
 }
 
 func decodeDecimal(data []byte, precision int, decimals int) (float64, int, error) {
+	str, pos, err := decodeDecimalString(data, precision, decimals)
+	if err != nil {
+		return 0, pos, err
+	}
+	f, err := strconv.ParseFloat(str, 64)
+	return f, pos, err
+}
+
+// decodeDecimalString decodes a DECIMAL without losing precision.
+func decodeDecimalString(data []byte, precision int, decimals int) (string, int, error) {
 	//see python mysql myreplicator and https://github.com/jeremycole/mysql_binlog
 	integral := (precision - decimals)
 	uncompIntegral := int(integral / digitsPerInteger)
@@ -562,16 +851,26 @@ func decodeDecimal(data []byte, precision int, decimals int) (float64, int, erro
 	//clear sign
 	data[0] ^= 0x80
 
+	var integralDigits bytes.Buffer
 	pos, value := decodeDecimalDecompressValue(compIntegral, data, uint8(mask))
-	res.WriteString(fmt.Sprintf("%d", value))
+	integralDigits.WriteString(fmt.Sprintf("%d", value))
 
 	for i := 0; i < uncompIntegral; i++ {
 		value = binary.BigEndian.Uint32(data[pos:]) ^ mask
 		pos += 4
-		res.WriteString(fmt.Sprintf("%09d", value))
+		integralDigits.WriteString(fmt.Sprintf("%09d", value))
+	}
+	// the uncompressed groups always have nine digits, even for a zero
+	// integral part
+	if digits := bytes.TrimLeft(integralDigits.Bytes(), "0"); len(digits) > 0 {
+		res.Write(digits)
+	} else {
+		res.WriteByte('0')
 	}
 
-	res.WriteString(".")
+	if decimals > 0 {
+		res.WriteString(".")
+	}
 
 	for i := 0; i < uncompFractional; i++ {
 		value = binary.BigEndian.Uint32(data[pos:]) ^ mask
@@ -584,8 +883,7 @@ func decodeDecimal(data []byte, precision int, decimals int) (float64, int, erro
 		pos += size
 	}
 
-	f, err := strconv.ParseFloat(string(res.Bytes()), 64)
-	return f, pos, err
+	return res.String(), pos, nil
 }
 
 func decodeBit(data []byte, nbits int, length int) (value int64, err error) {
@@ -327,6 +327,29 @@ func (_ *testDecodeSuite) TestDecodeDecimal(c *C) {
 	}
 }
 
+func (_ *testDecodeSuite) TestDecodeDecimalString(c *C) {
+	testcases := []struct {
+		Data        []byte
+		Precision   int
+		Decimals    int
+		Expected    string
+		ExpectedPos int
+	}{
+		{[]byte{127, 253, 205, 221, 109, 230, 255, 255, 255, 255, 255, 255, 255, 255, 255, 13, 0}, 30, 25, "-562.5800000000000000000000000", 15},
+		{[]byte{28, 156, 127, 241}, 4, 2, "-99.99", 2},
+		{[]byte{127, 248, 99, 120, 99}, 5, 0, "-1948", 3},
+		{[]byte{118, 196, 101, 54, 0, 254, 121, 96, 127, 255}, 15, 14, "-9.99999999999999", 8},
+		{[]byte{127, 255, 255, 248, 99, 247, 167, 196, 255, 255, 127, 255}, 20, 10, "-1948.1400000000", 10},
+		{[]byte{128, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, 20, 10, "0.0000000000", 10},
+	}
+	for i, tc := range testcases {
+		value, pos, err := decodeDecimalString(tc.Data, tc.Precision, tc.Decimals)
+		c.Assert(err, IsNil, Commentf("Test %d", i))
+		c.Assert(value, Equals, tc.Expected, Commentf("Test %d", i))
+		c.Assert(pos, Equals, tc.ExpectedPos, Commentf("Test %d", i))
+	}
+}
+
 func (_ *testDecodeSuite) TestLastNull(c *C) {
 	// Table format:
 	// desc funnytable;
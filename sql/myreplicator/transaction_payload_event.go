@@ -0,0 +1,156 @@
+package myreplicator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/corestoreio/errors"
+	"github.com/klauspost/compress/zstd"
+)
+
+// TransactionPayloadEvent contains a whole transaction compressed by MySQL
+// 8.0.20+ when binlog_transaction_compression=ON. The decoded events of the
+// transaction can be found in the field Events.
+type TransactionPayloadEvent struct {
+	Size             uint64
+	UncompressedSize uint64
+	CompressionType  uint64
+	// Payload contains the uncompressed data.
+	Payload []byte
+	// Events contains the events of the transaction, mostly a QueryEvent
+	// (BEGIN), TableMapEvents, RowsEvents and a XIDEvent.
+	Events []*BinlogEvent
+
+	parser *BinlogParser
+}
+
+func (e *TransactionPayloadEvent) Decode(data []byte) error {
+	pos, err := e.decodeHeader(data)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	data = data[pos:]
+	if uint64(len(data)) < e.Size {
+		return errors.NotValid.Newf("[myreplicator] TransactionPayloadEvent: payload size %d exceeds data length %d", e.Size, len(data))
+	}
+	data = data[:e.Size]
+
+	switch e.CompressionType {
+	case PAYLOAD_COMPRESSION_ZSTD:
+		dec, err := zstd.NewReader(nil)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		e.Payload, err = dec.DecodeAll(data, make([]byte, 0, e.UncompressedSize))
+		dec.Close()
+		if err != nil {
+			return errors.Wrap(err, "[myreplicator] TransactionPayloadEvent zstd.DecodeAll")
+		}
+	case PAYLOAD_COMPRESSION_NONE:
+		e.Payload = data
+	default:
+		return errors.NotSupported.Newf("[myreplicator] TransactionPayloadEvent: compression type %d not supported", e.CompressionType)
+	}
+
+	if e.parser == nil {
+		return nil
+	}
+	return errors.WithStack(e.decodeEvents())
+}
+
+// decodeHeader reads the type/length/value fields in front of the payload
+// and returns the start position of the payload.
+func (e *TransactionPayloadEvent) decodeHeader(data []byte) (int, error) {
+	pos := 0
+	for {
+		field, n, err := lengthEncodedInt(data[pos:])
+		if err != nil {
+			return 0, errors.Wrap(err, "[myreplicator] TransactionPayloadEvent.field")
+		}
+		pos += n
+		if field == OTW_PAYLOAD_HEADER_END_MARK {
+			return pos, nil
+		}
+
+		length, n, err := lengthEncodedInt(data[pos:])
+		if err != nil {
+			return 0, errors.Wrap(err, "[myreplicator] TransactionPayloadEvent.length")
+		}
+		pos += n
+		if pos+int(length) > len(data) {
+			return 0, errors.NotValid.Newf("[myreplicator] TransactionPayloadEvent: field %d length %d exceeds data", field, length)
+		}
+
+		var v uint64
+		if field == OTW_PAYLOAD_SIZE_FIELD || field == OTW_PAYLOAD_COMPRESSION_TYPE_FIELD || field == OTW_PAYLOAD_UNCOMPRESSED_SIZE_FIELD {
+			if v, _, err = lengthEncodedInt(data[pos : pos+int(length)]); err != nil {
+				return 0, errors.Wrapf(err, "[myreplicator] TransactionPayloadEvent.value of field %d", field)
+			}
+		}
+		switch field {
+		case OTW_PAYLOAD_SIZE_FIELD:
+			e.Size = v
+		case OTW_PAYLOAD_COMPRESSION_TYPE_FIELD:
+			e.CompressionType = v
+		case OTW_PAYLOAD_UNCOMPRESSED_SIZE_FIELD:
+			e.UncompressedSize = v
+		}
+		pos += int(length)
+	}
+}
+
+// decodeEvents parses the events of the payload. The events in the payload
+// have no checksum. Table map events get cached in the parser so that rows
+// events following the payload event can still find their table.
+func (e *TransactionPayloadEvent) decodeEvents() error {
+	p := e.parser
+	if p.format == nil {
+		return errors.NotValid.Newf("[myreplicator] TransactionPayloadEvent: missing format description event")
+	}
+	format := p.format
+	noChecksum := *format
+	noChecksum.ChecksumAlgorithm = BINLOG_CHECKSUM_ALG_OFF
+	p.format = &noChecksum
+	defer func() { p.format = format }()
+
+	e.Events = e.Events[:0]
+	for pos := 0; pos < len(e.Payload); {
+		if pos+EventHeaderSize > len(e.Payload) {
+			return errors.NotValid.Newf("[myreplicator] TransactionPayloadEvent: truncated event header at position %d", pos)
+		}
+		size := int(binary.LittleEndian.Uint32(e.Payload[pos+9:]))
+		if size < EventHeaderSize || pos+size > len(e.Payload) {
+			return errors.NotValid.Newf("[myreplicator] TransactionPayloadEvent: invalid event size %d at position %d", size, pos)
+		}
+		be, err := p.parse(e.Payload[pos : pos+size])
+		if err != nil {
+			return errors.Wrapf(err, "[myreplicator] TransactionPayloadEvent: failed to parse event at position %d", pos)
+		}
+		e.Events = append(e.Events, be)
+		pos += size
+	}
+	return nil
+}
+
+// setLogPos sets the log position of the inner events to the position of the
+// payload event. The inner events have a log position of zero.
+func (e *TransactionPayloadEvent) setLogPos(logPos uint32) {
+	for _, be := range e.Events {
+		if be.Header.LogPos == 0 {
+			be.Header.LogPos = logPos
+		}
+	}
+}
+
+func (e *TransactionPayloadEvent) Dump(w io.Writer) {
+	fmt.Fprintf(w, "Payload Size: %d\n", e.Size)
+	fmt.Fprintf(w, "Payload Uncompressed Size: %d\n", e.UncompressedSize)
+	fmt.Fprintf(w, "Payload CompressionType: %d\n", e.CompressionType)
+	fmt.Fprintf(w, "Payload Events: %d\n", len(e.Events))
+	for _, be := range e.Events {
+		be.Dump(w)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,68 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+)
+
+// Default Magento table names which contain the store hierarchy.
+const (
+	TableNameStoreWebsite = "store_website"
+	TableNameStoreGroup   = "store_group"
+	TableNameStore        = "store"
+)
+
+// NewBinlogHandler creates a binlogsync.RowsEventHandler which calls the
+// reload function once a committed transaction has modified one of the tables
+// store_website, store_group or store. Reloading happens after the commit, so
+// a transaction which creates a website, a group and a store triggers only one
+// reload. Argument tableNames can be empty and defaults to the Magento table
+// names, set them if a table prefix is in use.
+//
+//		h := store.NewBinlogHandler(func(context.Context) error {
+//			return srv.ReloadFromResource(twr, tgr, tsr)
+//		})
+//		canal.RegisterRowsEventHandler(h)
+func NewBinlogHandler(reload func(context.Context) error, tableNames ...string) *binlogsync.RowsChangeHandler {
+	if len(tableNames) == 0 {
+		tableNames = []string{TableNameStoreWebsite, TableNameStoreGroup, TableNameStore}
+	}
+	filters := make([]binlogsync.RowsChangeFilter, len(tableNames))
+	for i, tn := range tableNames {
+		filters[i].Table = tn
+	}
+
+	var changed int32
+	h := binlogsync.NewRowsChangeHandler("store.BinlogHandler", func(context.Context, *binlogsync.RowsChangeEvent) error {
+		atomic.StoreInt32(&changed, 1)
+		return nil
+	}, filters...)
+	h.OnComplete = func(ctx context.Context) error {
+		if !atomic.CompareAndSwapInt32(&changed, 1, 0) {
+			return nil
+		}
+		if err := reload(ctx); err != nil {
+			atomic.StoreInt32(&changed, 1) // try again after the next commit
+			return errors.Wrap(err, "[store] BinlogHandler.reload")
+		}
+		return nil
+	}
+	return h
+}
@@ -17,10 +17,10 @@ package store
 import (
 	"testing"
 
+	"github.com/corestoreio/errors"
 	"github.com/corestoreio/pkg/config/cfgmock"
-	"github.com/corestoreio/pkg/util/null"
+	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/util/slices"
-	"github.com/corestoreio/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -29,9 +29,9 @@ import (
 var testFactory = mustNewFactory(
 	cfgmock.NewService(),
 	WithTableWebsites(
-		&TableWebsite{WebsiteID: 0, Code: null.StringFrom("admin"), Name: null.StringFrom("Admin"), SortOrder: 0, DefaultGroupID: 0, IsDefault: null.BoolFrom(false)},
-		&TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)},
-		&TableWebsite{WebsiteID: 2, Code: null.StringFrom("oz"), Name: null.StringFrom("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: null.BoolFrom(false)},
+		&TableWebsite{WebsiteID: 0, Code: dml.MakeNullString("admin"), Name: dml.MakeNullString("Admin"), SortOrder: 0, DefaultGroupID: 0, IsDefault: dml.MakeNullBool(false)},
+		&TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)},
+		&TableWebsite{WebsiteID: 2, Code: dml.MakeNullString("oz"), Name: dml.MakeNullString("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: dml.MakeNullBool(false)},
 	),
 	WithTableGroups(
 		&TableGroup{GroupID: 3, WebsiteID: 2, Name: "Australia", RootCategoryID: 2, DefaultStoreID: 5},
@@ -40,13 +40,13 @@ var testFactory = mustNewFactory(
 		&TableGroup{GroupID: 2, WebsiteID: 1, Name: "UK Group", RootCategoryID: 2, DefaultStoreID: 4},
 	),
 	WithTableStores(
-		&TableStore{StoreID: 0, Code: null.StringFrom("admin"), WebsiteID: 0, GroupID: 0, Name: "Admin", SortOrder: 0, IsActive: true},
-		&TableStore{StoreID: 5, Code: null.StringFrom("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
-		&TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
-		&TableStore{StoreID: 4, Code: null.StringFrom("uk"), WebsiteID: 1, GroupID: 2, Name: "UK", SortOrder: 10, IsActive: true},
-		&TableStore{StoreID: 2, Code: null.StringFrom("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20, IsActive: true},
-		&TableStore{StoreID: 6, Code: null.StringFrom("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
-		&TableStore{StoreID: 3, Code: null.StringFrom("ch"), WebsiteID: 1, GroupID: 1, Name: "Schweiz", SortOrder: 30, IsActive: true},
+		&TableStore{StoreID: 0, Code: dml.MakeNullString("admin"), WebsiteID: 0, GroupID: 0, Name: "Admin", SortOrder: 0, IsActive: true},
+		&TableStore{StoreID: 5, Code: dml.MakeNullString("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
+		&TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
+		&TableStore{StoreID: 4, Code: dml.MakeNullString("uk"), WebsiteID: 1, GroupID: 2, Name: "UK", SortOrder: 10, IsActive: true},
+		&TableStore{StoreID: 2, Code: dml.MakeNullString("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20, IsActive: true},
+		&TableStore{StoreID: 6, Code: dml.MakeNullString("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
+		&TableStore{StoreID: 3, Code: dml.MakeNullString("ch"), WebsiteID: 1, GroupID: 1, Name: "Schweiz", SortOrder: 30, IsActive: true},
 	),
 )
 
@@ -197,14 +197,14 @@ func TestFactoryGroupNoWebsite(t *testing.T) {
 	var tst = mustNewFactory(
 		cfgmock.NewService(),
 		WithTableWebsites(
-			&TableWebsite{WebsiteID: 21, Code: null.StringFrom("oz"), Name: null.StringFrom("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: null.BoolFrom(false)},
+			&TableWebsite{WebsiteID: 21, Code: dml.MakeNullString("oz"), Name: dml.MakeNullString("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: dml.MakeNullBool(false)},
 		),
 		WithTableGroups(
 			&TableGroup{GroupID: 3, WebsiteID: 2, Name: "Australia", RootCategoryID: 2, DefaultStoreID: 5},
 		),
 		WithTableStores(
-			&TableStore{StoreID: 5, Code: null.StringFrom("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
-			&TableStore{StoreID: 6, Code: null.StringFrom("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
+			&TableStore{StoreID: 5, Code: dml.MakeNullString("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
+			&TableStore{StoreID: 6, Code: dml.MakeNullString("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
 		),
 	)
 	g, err := tst.Group(3)
@@ -291,14 +291,14 @@ func TestDefaultStoreView(t *testing.T) {
 	tst := mustNewFactory(
 		cfgmock.NewService(),
 		WithTableWebsites(
-			&TableWebsite{WebsiteID: 21, Code: null.StringFrom("oz"), Name: null.StringFrom("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: null.BoolFrom(false)},
+			&TableWebsite{WebsiteID: 21, Code: dml.MakeNullString("oz"), Name: dml.MakeNullString("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: dml.MakeNullBool(false)},
 		),
 		WithTableGroups(
 			&TableGroup{GroupID: 3, WebsiteID: 2, Name: "Australia", RootCategoryID: 2, DefaultStoreID: 5},
 		),
 		WithTableStores(
-			&TableStore{StoreID: 4, Code: null.StringFrom("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
-			&TableStore{StoreID: 6, Code: null.StringFrom("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
+			&TableStore{StoreID: 4, Code: dml.MakeNullString("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
+			&TableStore{StoreID: 6, Code: dml.MakeNullString("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
 		),
 	)
 	dSt, err := tst.DefaultStoreID()
@@ -308,7 +308,7 @@ func TestDefaultStoreView(t *testing.T) {
 	var tst2 = mustNewFactory(
 		cfgmock.NewService(),
 		WithTableWebsites(
-			&TableWebsite{WebsiteID: 21, Code: null.StringFrom("oz"), Name: null.StringFrom("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: null.BoolFrom(true)},
+			&TableWebsite{WebsiteID: 21, Code: dml.MakeNullString("oz"), Name: dml.MakeNullString("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: dml.MakeNullBool(true)},
 		),
 		WithTableGroups(
 			&TableGroup{GroupID: 33, WebsiteID: 2, Name: "Australia", RootCategoryID: 2, DefaultStoreID: 5},
@@ -327,8 +327,8 @@ func TestFactoryStoreErrors(t *testing.T) {
 		WithTableWebsites(),
 		WithTableGroups(),
 		WithTableStores(
-			&TableStore{StoreID: 4, Code: null.StringFrom("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
-			&TableStore{StoreID: 6, Code: null.StringFrom("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
+			&TableStore{StoreID: 4, Code: dml.MakeNullString("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
+			&TableStore{StoreID: 6, Code: dml.MakeNullString("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
 		),
 	)
 	stw, err := nsw.Store(6)
@@ -342,14 +342,14 @@ func TestFactoryStoreErrors(t *testing.T) {
 	var nsg = mustNewFactory(
 		cfgmock.NewService(),
 		WithTableWebsites(
-			&TableWebsite{WebsiteID: 2, Code: null.StringFrom("oz"), Name: null.StringFrom("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: null.BoolFrom(false)},
+			&TableWebsite{WebsiteID: 2, Code: dml.MakeNullString("oz"), Name: dml.MakeNullString("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: dml.MakeNullBool(false)},
 		),
 		WithTableGroups(
 			&TableGroup{GroupID: 13, WebsiteID: 12, Name: "Australia", RootCategoryID: 2, DefaultStoreID: 4},
 		),
 		WithTableStores(
-			&TableStore{StoreID: 4, Code: null.StringFrom("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
-			&TableStore{StoreID: 6, Code: null.StringFrom("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
+			&TableStore{StoreID: 4, Code: dml.MakeNullString("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
+			&TableStore{StoreID: 6, Code: dml.MakeNullString("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
 		),
 	)
 
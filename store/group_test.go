@@ -17,10 +17,10 @@ package store_test
 import (
 	"testing"
 
+	"github.com/corestoreio/errors"
 	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/store"
-	"github.com/corestoreio/pkg/util/null"
-	"github.com/corestoreio/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -48,7 +48,7 @@ func TestNewGroupErrorWebsiteIncorrect(t *testing.T) {
 	ng, err := store.NewGroup(
 		cfgmock.NewService(),
 		&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2},
-		&store.TableWebsite{WebsiteID: 2, Code: null.StringFrom("oz"), Name: null.StringFrom("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: null.BoolFrom(false)},
+		&store.TableWebsite{WebsiteID: 2, Code: dml.MakeNullString("oz"), Name: dml.MakeNullString("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: dml.MakeNullBool(false)},
 		nil,
 	)
 	assert.True(t, errors.IsNotValid(err), "Error: %+v", err)
@@ -61,7 +61,7 @@ func TestNewGroupSetStores_WebsiteIsNil(t *testing.T) {
 		&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2},
 		nil,
 		store.TableStoreSlice{
-			&store.TableStore{StoreID: 0, Code: null.StringFrom("admin"), WebsiteID: 0, GroupID: 0, Name: "Admin", SortOrder: 0, IsActive: true},
+			&store.TableStore{StoreID: 0, Code: dml.MakeNullString("admin"), WebsiteID: 0, GroupID: 0, Name: "Admin", SortOrder: 0, IsActive: true},
 		},
 	)
 	assert.False(t, errors.IsNotValid(err), "Error: %s", err)
@@ -75,9 +75,9 @@ func TestNewGroupSetStoresErrorWebsiteIncorrect(t *testing.T) {
 	g, err := store.NewGroup(
 		cfgmock.NewService(),
 		&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2},
-		&store.TableWebsite{WebsiteID: 2, Code: null.StringFrom("oz"), Name: null.StringFrom("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: null.BoolFrom(false)},
+		&store.TableWebsite{WebsiteID: 2, Code: dml.MakeNullString("oz"), Name: dml.MakeNullString("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: dml.MakeNullBool(false)},
 		store.TableStoreSlice{
-			&store.TableStore{StoreID: 0, Code: null.StringFrom("admin"), WebsiteID: 0, GroupID: 0, Name: "Admin", SortOrder: 0, IsActive: true},
+			&store.TableStore{StoreID: 0, Code: dml.MakeNullString("admin"), WebsiteID: 0, GroupID: 0, Name: "Admin", SortOrder: 0, IsActive: true},
 		},
 	)
 	assert.True(t, errors.IsNotValid(err), "Error: %s", err)
@@ -88,15 +88,15 @@ func TestNewGroupSetStores(t *testing.T) {
 	g := store.MustNewGroup(
 		cfgmock.NewService(),
 		&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2},
-		&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)},
+		&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)},
 		store.TableStoreSlice{
-			&store.TableStore{StoreID: 0, Code: null.StringFrom("admin"), WebsiteID: 0, GroupID: 0, Name: "Admin", SortOrder: 0, IsActive: true},
-			&store.TableStore{StoreID: 5, Code: null.StringFrom("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
-			&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
-			&store.TableStore{StoreID: 4, Code: null.StringFrom("uk"), WebsiteID: 1, GroupID: 2, Name: "UK", SortOrder: 10, IsActive: true},
-			&store.TableStore{StoreID: 2, Code: null.StringFrom("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20, IsActive: true},
-			&store.TableStore{StoreID: 6, Code: null.StringFrom("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
-			&store.TableStore{StoreID: 3, Code: null.StringFrom("ch"), WebsiteID: 1, GroupID: 1, Name: "Schweiz", SortOrder: 30, IsActive: true},
+			&store.TableStore{StoreID: 0, Code: dml.MakeNullString("admin"), WebsiteID: 0, GroupID: 0, Name: "Admin", SortOrder: 0, IsActive: true},
+			&store.TableStore{StoreID: 5, Code: dml.MakeNullString("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
+			&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
+			&store.TableStore{StoreID: 4, Code: dml.MakeNullString("uk"), WebsiteID: 1, GroupID: 2, Name: "UK", SortOrder: 10, IsActive: true},
+			&store.TableStore{StoreID: 2, Code: dml.MakeNullString("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20, IsActive: true},
+			&store.TableStore{StoreID: 6, Code: dml.MakeNullString("nz"), WebsiteID: 2, GroupID: 3, Name: "Kiwi", SortOrder: 30, IsActive: true},
+			&store.TableStore{StoreID: 3, Code: dml.MakeNullString("ch"), WebsiteID: 1, GroupID: 1, Name: "Schweiz", SortOrder: 30, IsActive: true},
 		},
 	)
 
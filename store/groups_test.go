@@ -18,8 +18,8 @@ import (
 	"testing"
 
 	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/store"
-	"github.com/corestoreio/pkg/util/null"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,17 +28,17 @@ func TestGroupSlice_Map_Each(t *testing.T) {
 		store.MustNewGroup(
 			cfgmock.NewService(),
 			&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2},
-			&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)},
+			&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)},
 			store.TableStoreSlice{
-				&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
+				&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
 			},
 		),
 		store.MustNewGroup(
 			cfgmock.NewService(),
 			&store.TableGroup{GroupID: 2, WebsiteID: 2, Name: "DACH2 Group", RootCategoryID: 2, DefaultStoreID: 2},
-			&store.TableWebsite{WebsiteID: 2, Code: null.StringFrom("euro2"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 2, IsDefault: null.BoolFrom(true)},
+			&store.TableWebsite{WebsiteID: 2, Code: dml.MakeNullString("euro2"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 2, IsDefault: dml.MakeNullBool(true)},
 			store.TableStoreSlice{
-				&store.TableStore{StoreID: 2, Code: null.StringFrom("de2"), WebsiteID: 2, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
+				&store.TableStore{StoreID: 2, Code: dml.MakeNullString("de2"), WebsiteID: 2, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
 			},
 		),
 	}
@@ -81,6 +81,9 @@ type Service struct {
 	cacheGroup       map[int64]Group
 	cacheStore       map[int64]Store
 	cacheSingleStore map[scope.TypeID]bool
+
+	// reloadObservers get called after a reload has swapped in new data.
+	reloadObservers []ReloadObserver
 }
 
 func newService() *Service {
@@ -127,7 +130,12 @@ func (s *Service) loadFromOptions(cfg config.Getter, opts ...Option) error {
 	if err != nil {
 		return errors.Wrap(err, "[store] NewService.NewFactory")
 	}
+	return s.loadFromFactory(be)
+}
 
+// loadFromFactory sets up the internal caches from the factory. The caller
+// must hold the lock or the Service must not yet be shared.
+func (s *Service) loadFromFactory(be *factory) error {
 	s.backend = be
 
 	ws, err := s.backend.Websites()
@@ -171,6 +179,8 @@ func (s *Service) loadFromOptions(cfg config.Getter, opts ...Option) error {
 // store can't be selected. An empty scope.Hash checks the default website with
 // its default group and its default stores.
 func (s *Service) IsAllowedStoreID(runMode scope.TypeID, storeID int64) (isAllowed bool, storeCode string, _ error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	scp, scpID := runMode.Unpack()
 
 	switch scp {
@@ -252,7 +262,9 @@ func (s *Service) DefaultStoreID(runMode scope.TypeID) (storeID, websiteID int64
 		}
 	} else {
 		var err error
+		s.mu.RLock()
 		w, err = s.websites.Default()
+		s.mu.RUnlock()
 		if err != nil {
 			return 0, 0, errors.Wrapf(err, "[store] DefaultStoreID.Website.Default Scope %s ID %d", scp, id)
 		}
@@ -322,6 +334,8 @@ func (s *Service) StoreIDbyCode(runMode scope.TypeID, storeCode string) (storeID
 // the current runMode. The returned slice and its pointers are owned by the
 // callee.
 func (s *Service) AllowedStores(runMode scope.TypeID) (StoreSlice, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	scp, scpID := runMode.Unpack()
 
 	switch scp {
@@ -459,17 +473,43 @@ func (s *Service) Stores() StoreSlice {
 	return s.stores
 }
 
+// IDbyCode returns the ID of a website or store code. Implements interface
+// config.ScopeCodeResolver. Error behaviour: NotFound or NotSupported.
+func (s *Service) IDbyCode(scp scope.Type, code string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	switch scp {
+	case scope.Website:
+		for _, w := range s.websites {
+			if w.Code() == code {
+				return w.ID(), nil
+			}
+		}
+	case scope.Store:
+		for _, st := range s.stores {
+			if st.Code() == code {
+				return st.ID(), nil
+			}
+		}
+	default:
+		return 0, errors.NewNotSupportedf("[store] Scope %s does not support codes", scp)
+	}
+	return 0, errors.NewNotFoundf("[store] Cannot find %s code %q", scp, code)
+}
+
 // DefaultStoreView returns the overall default store view.
 func (s *Service) DefaultStoreView() (Store, error) {
-	if s.defaultStoreID >= 0 {
-		s.mu.RLock()
-		defer s.mu.RUnlock() // bug
-		if cs, ok := s.cacheStore[atomic.LoadInt64(&s.defaultStoreID)]; ok {
+	s.mu.RLock()
+	if id := atomic.LoadInt64(&s.defaultStoreID); id >= 0 {
+		if cs, ok := s.cacheStore[id]; ok {
+			s.mu.RUnlock()
 			return cs, nil
 		}
 	}
+	be := s.backend
+	s.mu.RUnlock()
 
-	id, err := s.backend.DefaultStoreID()
+	id, err := be.DefaultStoreID()
 	if err != nil {
 		return Store{}, errors.Wrap(err, "[store] Service.storage.DefaultStoreView")
 	}
@@ -477,23 +517,11 @@ func (s *Service) DefaultStoreView() (Store, error) {
 	return s.Store(id)
 }
 
-// LoadFromDB reloads the website, store group and store view data from the database.
-// After reloading internal cache will be cleared if there are no errors.
+// LoadFromResource reloads the website, store group and store view data from
+// the database. The internal caches get only replaced if there are no errors.
+// Same as ReloadFromResource.
 func (s *Service) LoadFromResource(twr TableWebsitesResourcer, tgr TableGroupsResourcer, tsr TableStoresResourcer) error {
-
-	if err := s.backend.LoadFromResource(twr, tgr, tsr); err != nil {
-		return errors.Wrap(err, "[store] LoadFromDB.Backend")
-	}
-
-	s.ClearCache()
-
-	err := s.loadFromOptions(
-		s.backend.rootConfig,
-		WithTableWebsites(s.backend.websites...),
-		WithTableGroups(s.backend.groups...),
-		WithTableStores(s.backend.stores...),
-	)
-	return errors.Wrap(err, "[store] LoadFromDB.ApplyStorage")
+	return errors.Wrap(s.ReloadFromResource(twr, tgr, tsr), "[store] LoadFromResource")
 }
 
 // ClearCache resets the internal caches which stores the pointers to Websites,
@@ -526,6 +554,8 @@ func (s *Service) ClearCache() {
 
 // IsCacheEmpty returns true if the internal cache is empty.
 func (s *Service) IsCacheEmpty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return len(s.cacheWebsite) == 0 && len(s.cacheGroup) == 0 && len(s.cacheStore) == 0 &&
 		s.defaultStoreID == -1
 }
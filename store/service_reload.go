@@ -0,0 +1,207 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+)
+
+// ReloadObserver gets notified once a reload of the Service has swapped in
+// the new websites, groups and stores. For example scoped services in package
+// net can clear their caches to pick up newly created store views. The
+// middleware of package net/runmode queries the Service directly and needs no
+// notification.
+type ReloadObserver interface {
+	// StoresReloaded gets called after the new data has been activated. An
+	// error does not roll back the reload and does not prevent the call of
+	// the other observers.
+	StoresReloaded(s *Service) error
+}
+
+// ReloadObserverFunc type is an adapter to allow the use of ordinary
+// functions as ReloadObserver.
+//
+//		srv.RegisterReloadObserver(store.ReloadObserverFunc(func(*store.Service) error {
+//			return jwtService.ClearCache()
+//		}))
+type ReloadObserverFunc func(*Service) error
+
+// StoresReloaded calls f(s).
+func (f ReloadObserverFunc) StoresReloaded(s *Service) error { return f(s) }
+
+// RegisterReloadObserver adds observers which get called after each
+// successful reload.
+func (s *Service) RegisterReloadObserver(ros ...ReloadObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reloadObservers = append(s.reloadObservers, ros...)
+}
+
+// Reload creates from the options a new hierarchy of websites, groups and
+// stores without blocking the current readers. The new hierarchy gets
+// validated and then atomically swapped in. Afterwards all ReloadObserver get
+// notified. On any error before the swap, the current data stays active. The
+// config.Getter of the Service gets reused.
+func (s *Service) Reload(opts ...Option) error {
+	f, err := newFactory(s.rootConfig(), opts...)
+	if err != nil {
+		return errors.Wrap(err, "[store] Service.Reload.newFactory")
+	}
+	return errors.Wrap(s.swap(f), "[store] Service.Reload")
+}
+
+// ReloadFromResource same as Reload but loads the raw data concurrently from
+// the resources, mostly the database tables store_website, store_group and
+// store.
+func (s *Service) ReloadFromResource(twr TableWebsitesResourcer, tgr TableGroupsResourcer, tsr TableStoresResourcer) error {
+	f, err := newFactory(s.rootConfig())
+	if err != nil {
+		return errors.Wrap(err, "[store] Service.ReloadFromResource.newFactory")
+	}
+	if err := f.LoadFromResource(twr, tgr, tsr); err != nil {
+		return errors.Wrap(err, "[store] Service.ReloadFromResource.LoadFromResource")
+	}
+	return errors.Wrap(s.swap(f), "[store] Service.ReloadFromResource")
+}
+
+// PollResource loads every interval the data from the resources and reloads
+// the Service if the data has been changed. PollResource blocks until the
+// context gets cancelled and should run in its own goroutine. Errors do not
+// stop the polling and get passed to the optional errFn.
+func (s *Service) PollResource(ctx context.Context, interval time.Duration, errFn func(error), twr TableWebsitesResourcer, tgr TableGroupsResourcer, tsr TableStoresResourcer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.pollResource(twr, tgr, tsr); err != nil && errFn != nil {
+				errFn(err)
+			}
+		}
+	}
+}
+
+func (s *Service) pollResource(twr TableWebsitesResourcer, tgr TableGroupsResourcer, tsr TableStoresResourcer) error {
+	f, err := newFactory(s.rootConfig())
+	if err != nil {
+		return errors.Wrap(err, "[store] Service.PollResource.newFactory")
+	}
+	if err := f.LoadFromResource(twr, tgr, tsr); err != nil {
+		return errors.Wrap(err, "[store] Service.PollResource.LoadFromResource")
+	}
+	if s.isEqualFactory(f) {
+		return nil
+	}
+	return errors.Wrap(s.swap(f), "[store] Service.PollResource")
+}
+
+func (s *Service) rootConfig() config.Getter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.backend == nil {
+		return nil
+	}
+	return s.backend.rootConfig
+}
+
+// isEqualFactory reports whether the raw data of f equals the currently
+// active data.
+func (s *Service) isEqualFactory(f *factory) bool {
+	s.mu.RLock()
+	be := s.backend
+	s.mu.RUnlock()
+	if be == nil {
+		return false
+	}
+	be.mu.RLock()
+	defer be.mu.RUnlock()
+	return reflect.DeepEqual(be.websites, f.websites) &&
+		reflect.DeepEqual(be.groups, f.groups) &&
+		reflect.DeepEqual(be.stores, f.stores)
+}
+
+// swap builds and validates the new hierarchy from the factory and replaces
+// the current one.
+func (s *Service) swap(f *factory) error {
+	ns := newService()
+	if err := ns.loadFromFactory(f); err != nil {
+		return errors.Wrap(err, "[store] Service.swap.loadFromFactory")
+	}
+	if err := ns.validate(); err != nil {
+		return errors.Wrap(err, "[store] Service.swap.validate")
+	}
+
+	s.mu.Lock()
+	s.backend = ns.backend
+	s.websites = ns.websites
+	s.groups = ns.groups
+	s.stores = ns.stores
+	s.cacheWebsite = ns.cacheWebsite
+	s.cacheGroup = ns.cacheGroup
+	s.cacheStore = ns.cacheStore
+	s.cacheSingleStore = ns.cacheSingleStore
+	atomic.StoreInt64(&s.defaultStoreID, -1)
+	ros := make([]ReloadObserver, len(s.reloadObservers))
+	copy(ros, s.reloadObservers)
+	s.mu.Unlock()
+
+	// all observers get called, the first error gets returned.
+	var firstErr error
+	for _, ro := range ros {
+		if err := ro.StoresReloaded(s); err != nil && firstErr == nil {
+			firstErr = errors.Wrap(err, "[store] Service.swap.StoresReloaded")
+		}
+	}
+	return firstErr
+}
+
+// validate checks the integrity of all websites, groups and stores and that
+// a default store can be found.
+func (s *Service) validate() error {
+	for _, w := range s.websites {
+		if err := w.Validate(); err != nil {
+			return errors.Wrapf(err, "[store] Website %d", w.ID())
+		}
+	}
+	for _, g := range s.groups {
+		if err := g.Validate(); err != nil {
+			return errors.Wrapf(err, "[store] Group %d", g.ID())
+		}
+	}
+	for _, st := range s.stores {
+		if err := st.Validate(); err != nil {
+			return errors.Wrapf(err, "[store] Store %d", st.ID())
+		}
+	}
+	if len(s.websites) == 0 {
+		return nil
+	}
+	id, err := s.backend.DefaultStoreID()
+	if err != nil {
+		return errors.Wrap(err, "[store] DefaultStoreID")
+	}
+	if _, ok := s.cacheStore[id]; !ok {
+		return errors.NewNotFoundf("[store] Default Store ID %d not found", id)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/corestoreio/errors"
+	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/config/cfgmock"
+	"github.com/corestoreio/pkg/config/cfgpath"
+	"github.com/corestoreio/pkg/sql/binlogsync"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/store"
+	"github.com/corestoreio/pkg/store/scope"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var _ store.ReloadObserver = (store.ReloadObserverFunc)(nil)
+
+func reloadTestOptions(stores ...*store.TableStore) []store.Option {
+	return []store.Option{
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
+		store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 1}),
+		store.WithTableStores(stores...),
+	}
+}
+
+var (
+	reloadStoreDE = &store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}
+	reloadStoreAT = &store.TableStore{StoreID: 2, Code: dml.MakeNullString("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20, IsActive: true}
+)
+
+func TestService_Reload(t *testing.T) {
+	t.Parallel()
+
+	srv := store.MustNewService(cfgmock.NewService(), reloadTestOptions(reloadStoreDE)...)
+	var observed int32
+	srv.RegisterReloadObserver(store.ReloadObserverFunc(func(s *store.Service) error {
+		atomic.AddInt32(&observed, 1)
+		assert.Exactly(t, srv, s)
+		return nil
+	}))
+
+	_, err := srv.Store(2)
+	assert.True(t, errors.IsNotFound(err), "%+v", err)
+
+	t.Run("new store view", func(t *testing.T) {
+		require.NoError(t, srv.Reload(reloadTestOptions(reloadStoreDE, reloadStoreAT)...))
+		assert.Exactly(t, int32(1), atomic.LoadInt32(&observed))
+
+		st, err := srv.Store(2)
+		require.NoError(t, err)
+		assert.Exactly(t, "at", st.Code())
+		assert.Len(t, srv.Stores(), 2)
+
+		sID, wID, err := srv.StoreIDbyCode(scope.Website.Pack(1), "at")
+		require.NoError(t, err)
+		assert.Exactly(t, int64(2), sID)
+		assert.Exactly(t, int64(1), wID)
+	})
+
+	t.Run("invalid data keeps current data", func(t *testing.T) {
+		err := srv.Reload(
+			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
+			store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 3, Name: "DACH Group", DefaultStoreID: 1}),
+		)
+		assert.True(t, errors.IsNotFound(err), "%+v", err)
+		assert.Len(t, srv.Stores(), 2)
+		assert.Exactly(t, int32(1), atomic.LoadInt32(&observed))
+	})
+
+	t.Run("missing default store", func(t *testing.T) {
+		err := srv.Reload(reloadTestOptions(reloadStoreAT)...)
+		assert.True(t, errors.IsNotFound(err), "%+v", err)
+		assert.Len(t, srv.Stores(), 2)
+	})
+
+	t.Run("observer error", func(t *testing.T) {
+		srv := store.MustNewService(cfgmock.NewService(), reloadTestOptions(reloadStoreDE)...)
+		var called int32
+		srv.RegisterReloadObserver(
+			store.ReloadObserverFunc(func(*store.Service) error { return errors.NewAlreadyClosedf("closed") }),
+			store.ReloadObserverFunc(func(*store.Service) error { atomic.AddInt32(&called, 1); return nil }),
+		)
+		err := srv.Reload(reloadTestOptions(reloadStoreDE, reloadStoreAT)...)
+		assert.True(t, errors.IsAlreadyClosed(err), "%+v", err)
+		assert.Exactly(t, int32(1), atomic.LoadInt32(&called))
+		assert.Len(t, srv.Stores(), 2)
+	})
+}
+
+func TestService_Reload_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	srv := store.MustNewService(cfgmock.NewService(), reloadTestOptions(reloadStoreDE)...)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if i%2 == 0 {
+					assert.NoError(t, srv.Reload(reloadTestOptions(reloadStoreDE, reloadStoreAT)...))
+					continue
+				}
+				st, err := srv.DefaultStoreView()
+				assert.NoError(t, err)
+				assert.Exactly(t, int64(1), st.ID())
+				_, err = srv.Website(1)
+				assert.NoError(t, err)
+
+				sID, wID, err := srv.DefaultStoreID(scope.DefaultTypeID)
+				assert.NoError(t, err)
+				assert.Exactly(t, int64(1), sID)
+				assert.Exactly(t, int64(1), wID)
+				isAllowed, code, err := srv.IsAllowedStoreID(scope.Website.Pack(1), 1)
+				assert.NoError(t, err)
+				assert.True(t, isAllowed)
+				assert.Exactly(t, "de", code)
+				isAllowed, _, err = srv.IsAllowedStoreID(scope.DefaultTypeID, 1)
+				assert.NoError(t, err)
+				assert.True(t, isAllowed)
+				ss, err := srv.AllowedStores(scope.Group.Pack(1))
+				assert.NoError(t, err)
+				assert.NotEmpty(t, ss)
+				assert.False(t, srv.IsCacheEmpty())
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Len(t, srv.Stores(), 2)
+}
+
+func TestService_Reload_EnvOverride(t *testing.T) {
+	t.Parallel()
+
+	var srv *store.Service
+	cfgSrv := config.MustNewService(config.NewInMemoryStore(), config.WithEnvOverride(
+		[]string{"CONFIG__STORES__DE__GENERAL__LOCALE__CODE=de_DE"},
+		config.ScopeCodeResolverFunc(func(scp scope.Type, code string) (int64, error) {
+			return srv.IDbyCode(scp, code)
+		}),
+	))
+	srv = store.MustNewService(cfgSrv, reloadTestOptions(reloadStoreDE, reloadStoreAT)...)
+	srv.RegisterReloadObserver(store.ReloadObserverFunc(func(*store.Service) error {
+		cfgSrv.ClearEnvOverrideCache()
+		return nil
+	}))
+
+	ps, err := cfgSrv.EnvOverrides()
+	require.NoError(t, err)
+	assert.Exactly(t, "stores/1/general/locale/code", ps[0].String())
+
+	// The codes of the store views get swapped.
+	storeAT := *reloadStoreDE
+	storeAT.Code = dml.MakeNullString("at")
+	storeDE := *reloadStoreAT
+	storeDE.Code = dml.MakeNullString("de")
+	require.NoError(t, srv.Reload(reloadTestOptions(&storeAT, &storeDE)...))
+
+	ps, err = cfgSrv.EnvOverrides()
+	require.NoError(t, err)
+	assert.Exactly(t, "stores/2/general/locale/code", ps[0].String())
+	v, err := cfgSrv.String(cfgpath.MustNewByParts("general/locale/code").BindStore(2))
+	require.NoError(t, err)
+	assert.Exactly(t, "de_DE", v)
+}
+
+func TestNewBinlogHandler(t *testing.T) {
+	t.Parallel()
+
+	var reloads int32
+	h := store.NewBinlogHandler(func(context.Context) error {
+		atomic.AddInt32(&reloads, 1)
+		return nil
+	})
+	ctx := context.Background()
+	tblStore := ddl.Table{Name: "store", Columns: ddl.Columns{
+		&ddl.Column{Field: "store_id", Pos: 1, DataType: "smallint", ColumnType: "smallint(5) unsigned"},
+		&ddl.Column{Field: "code", Pos: 2, DataType: "varchar", ColumnType: "varchar(32)"},
+	}}
+
+	require.NoError(t, h.Complete(ctx))
+	assert.Exactly(t, int32(0), atomic.LoadInt32(&reloads), "nothing changed")
+
+	require.NoError(t, h.Do(ctx, binlogsync.InsertAction, tblStore, [][]interface{}{{int16(3), "ch"}}))
+	require.NoError(t, h.Do(ctx, binlogsync.InsertAction, tblStore, [][]interface{}{{int16(4), "fr"}}))
+	require.NoError(t, h.Complete(ctx))
+	assert.Exactly(t, int32(1), atomic.LoadInt32(&reloads), "one reload per transaction")
+
+	require.NoError(t, h.Do(ctx, binlogsync.InsertAction, ddl.Table{Name: "sales_order"}, [][]interface{}{{1}}))
+	require.NoError(t, h.Complete(ctx))
+	assert.Exactly(t, int32(1), atomic.LoadInt32(&reloads), "other tables")
+
+	t.Run("reload error retries", func(t *testing.T) {
+		var calls int32
+		h := store.NewBinlogHandler(func(context.Context) error {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return errors.NewNotValidf("invalid")
+			}
+			return nil
+		}, "mage_store")
+		require.NoError(t, h.Do(ctx, binlogsync.DeleteAction, ddl.Table{Name: "mage_store", Columns: tblStore.Columns}, [][]interface{}{{int16(3), "ch"}}))
+		assert.True(t, errors.IsNotValid(h.Complete(ctx)))
+		require.NoError(t, h.Complete(ctx))
+		assert.Exactly(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
@@ -18,16 +18,16 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/corestoreio/errors"
 	"github.com/corestoreio/pkg/config"
 	"github.com/corestoreio/pkg/config/cfgmock"
 	"github.com/corestoreio/pkg/config/cfgmodel"
 	"github.com/corestoreio/pkg/config/cfgpath"
 	"github.com/corestoreio/pkg/config/element"
+	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/store"
 	"github.com/corestoreio/pkg/store/scope"
 	"github.com/corestoreio/pkg/store/storemock"
-	"github.com/corestoreio/pkg/util/null"
-	"github.com/corestoreio/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -35,9 +35,9 @@ var _ store.Finder = (*store.Service)(nil)
 
 var serviceStoreSimpleTest = store.MustNewService(
 	cfgmock.NewService(),
-	store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+	store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 	store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2}),
-	store.WithTableStores(&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
+	store.WithTableStores(&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
 )
 
 func TestNewServiceStore_QueryInvalidStore(t *testing.T) {
@@ -65,7 +65,7 @@ func TestMustNewService_Panic(t *testing.T) {
 		}
 	}()
 	_ = store.MustNewService(cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 		store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 0, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2}),
 	)
 }
@@ -80,7 +80,7 @@ func TestMustNewService_NoPanic(t *testing.T) {
 		{0, errors.IsNotFound},
 	}
 	serviceEmpty := store.MustNewService(cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 	)
 	for i, test := range tests {
 		s, err := serviceEmpty.Store(test.have)
@@ -92,8 +92,8 @@ func TestMustNewService_NoPanic(t *testing.T) {
 func TestMustNewService_DefaultWebsiteCheck(t *testing.T) {
 
 	s, err := store.NewService(cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), IsDefault: null.BoolFrom(true)}),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 12, Code: null.StringFrom("euro2"), IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), IsDefault: dml.MakeNullBool(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 12, Code: dml.MakeNullString("euro2"), IsDefault: dml.MakeNullBool(true)}),
 	)
 	assert.Nil(t, s)
 	assert.True(t, errors.IsNotValid(err), "%+v", err)
@@ -103,9 +103,9 @@ func TestNewService_DefaultStoreView_OK(t *testing.T) {
 
 	serviceDefaultStore := store.MustNewService(
 		cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 		store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 1}),
-		store.WithTableStores(&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
+		store.WithTableStores(&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
 	)
 
 	// call it twice to test internal caching
@@ -127,9 +127,9 @@ func TestNewService_DefaultStoreView_NOK(t *testing.T) {
 
 	serviceDefaultStore := store.MustNewService(
 		cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 		store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2}),
-		store.WithTableStores(&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
+		store.WithTableStores(&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
 	)
 
 	// call it twice to test internal caching
@@ -143,12 +143,12 @@ func TestNewService_Stores(t *testing.T) {
 
 	serviceStores := store.MustNewService(
 		cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 		store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2}),
 		store.WithTableStores(
-			&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
-			&store.TableStore{StoreID: 2, Code: null.StringFrom("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20, IsActive: true},
-			&store.TableStore{StoreID: 3, Code: null.StringFrom("ch"), WebsiteID: 1, GroupID: 1, Name: "Schweiz", SortOrder: 30, IsActive: true},
+			&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
+			&store.TableStore{StoreID: 2, Code: dml.MakeNullString("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20, IsActive: true},
+			&store.TableStore{StoreID: 3, Code: dml.MakeNullString("ch"), WebsiteID: 1, GroupID: 1, Name: "Schweiz", SortOrder: 30, IsActive: true},
 		),
 	)
 
@@ -178,7 +178,7 @@ func TestMustNewService_Stores_Panic(t *testing.T) {
 	}()
 	_ = store.MustNewService(cfgmock.NewService(),
 		store.WithTableStores(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 		store.WithTableGroups(&store.TableGroup{GroupID: 10, WebsiteID: 21, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2}),
 	)
 }
@@ -186,9 +186,9 @@ func TestMustNewService_Stores_Panic(t *testing.T) {
 func TestNewService_Group(t *testing.T) {
 
 	serviceGroupSimpleTest := store.MustNewService(cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 		store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2}),
-		store.WithTableStores(&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
+		store.WithTableStores(&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
 	)
 
 	tests := []struct {
@@ -223,9 +223,9 @@ func TestNewService_Group(t *testing.T) {
 func TestNewService_Groups(t *testing.T) {
 
 	serviceGroups := store.MustNewService(cfgmock.NewService(),
-		store.WithTableStores(&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
+		store.WithTableStores(&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
 		store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 1}),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 	)
 	const iterations = 10
 	var wg sync.WaitGroup
@@ -248,9 +248,9 @@ func TestNewService_Groups(t *testing.T) {
 func TestNewService_Website(t *testing.T) {
 
 	serviceWebsite := store.MustNewService(cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 		store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2}),
-		store.WithTableStores(&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
+		store.WithTableStores(&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
 	)
 
 	tests := []struct {
@@ -284,8 +284,8 @@ func TestNewService_Website(t *testing.T) {
 func TestNewService_Websites(t *testing.T) {
 	srv := store.MustNewService(cfgmock.NewService(),
 		store.WithTableWebsites(
-			&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("European Union"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)},
-			&store.TableWebsite{WebsiteID: 2, Code: null.StringFrom("uk"), Name: null.StringFrom("Britain (without Scotland)"), SortOrder: 0, DefaultGroupID: 2},
+			&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("European Union"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)},
+			&store.TableWebsite{WebsiteID: 2, Code: dml.MakeNullString("uk"), Name: dml.MakeNullString("Britain (without Scotland)"), SortOrder: 0, DefaultGroupID: 2},
 		),
 	)
 	assert.Exactly(t, []int64{1, 2}, srv.Websites().IDs())
@@ -331,9 +331,9 @@ func TestService_IsAllowedStoreID(t *testing.T) {
 		{eurSrv, scope.MakeTypeID(124, 1), 4, false, "", nil},
 		{eurSrv, scope.MakeTypeID(124, 0), 4, false, "", nil},
 		{store.MustNewService(cfgmock.NewService(),
-			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: null.BoolFrom(true)}),
+			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: dml.MakeNullBool(true)}),
 			store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2}),
-			store.WithTableStores(&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
+			store.WithTableStores(&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
 		), 0, 2, false, "", errors.IsNotFound},
 	}
 	for i, test := range tests {
@@ -359,15 +359,15 @@ func TestService_DefaultStoreID(t *testing.T) {
 		wantWebsiteID int64
 		wantErrBhf    errors.BehaviourFunc
 	}{
-		{eurSrv, 0, 2, 1, nil},                                  // fall back to default website -> default group -> default store
+		{eurSrv, 0, 2, 1, nil}, // fall back to default website -> default group -> default store
 		{eurSrv, scope.MakeTypeID(scope.Website, 0), 0, 0, nil}, // admin scope
 		{eurSrv, scope.MakeTypeID(scope.Website, 1), 2, 1, nil}, // euro scope, not included ch, because not active, and UK, different group
 		{eurSrv, scope.MakeTypeID(scope.Website, 2), 5, 2, nil}, // oz scope
 		{eurSrv, scope.MakeTypeID(scope.Website, 9999), 0, 0, errors.IsNotFound},
 		{store.MustNewService(cfgmock.NewService(), // default store not active
-			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: null.BoolFrom(true)}),
+			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 1, IsDefault: dml.MakeNullBool(true)}),
 			store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 1}),
-			store.WithTableStores(&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: false}),
+			store.WithTableStores(&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: false}),
 		), scope.MakeTypeID(scope.Website, 1), 0, 0, errors.IsNotValid},
 
 		{eurSrv, scope.MakeTypeID(scope.Group, 0), 0, 0, nil}, // admin scope
@@ -376,12 +376,12 @@ func TestService_DefaultStoreID(t *testing.T) {
 		{eurSrv, scope.MakeTypeID(scope.Group, 3), 5, 2, nil}, // au scope
 		{eurSrv, scope.MakeTypeID(scope.Group, 9999), 0, 0, errors.IsNotFound},
 		{store.MustNewService(cfgmock.NewService(), // default store not active
-			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: null.BoolFrom(true)}),
+			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: dml.MakeNullBool(true)}),
 			store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 1}),
-			store.WithTableStores(&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: false}),
+			store.WithTableStores(&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: false}),
 		), scope.MakeTypeID(scope.Group, 1), 0, 0, errors.IsNotValid},
 		{store.MustNewService(cfgmock.NewService(), // default store not found
-			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: null.BoolFrom(true)}),
+			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: dml.MakeNullBool(true)}),
 			store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 1}),
 		), scope.MakeTypeID(scope.Group, 1), 0, 0, errors.IsNotFound},
 
@@ -391,9 +391,9 @@ func TestService_DefaultStoreID(t *testing.T) {
 		{eurSrv, scope.MakeTypeID(scope.Store, 3), 0, 0, errors.IsNotValid}, // ch store is not active
 
 		{store.MustNewService(cfgmock.NewService(),
-			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: null.BoolFrom(true)}),
+			store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: dml.MakeNullBool(true)}),
 			store.WithTableGroups(&store.TableGroup{GroupID: 1, WebsiteID: 1, Name: "DACH Group", RootCategoryID: 2, DefaultStoreID: 2}),
-			store.WithTableStores(&store.TableStore{StoreID: 1, Code: null.StringFrom("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
+			store.WithTableStores(&store.TableStore{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true}),
 		), 0, 0, 0, errors.IsNotFound},
 	}
 	for i, test := range tests {
@@ -448,6 +448,34 @@ func TestService_StoreIDbyCode(t *testing.T) {
 	}
 }
 
+func TestService_IDbyCode(t *testing.T) {
+	eurSrv := storemock.NewEurozzyService(cfgmock.NewService())
+	tests := []struct {
+		scp        scope.Type
+		code       string
+		wantID     int64
+		wantErrBhf errors.BehaviourFunc
+	}{
+		{scope.Website, "euro", 1, nil},
+		{scope.Website, "oz", 2, nil},
+		{scope.Website, "de", 0, errors.IsNotFound},
+		{scope.Store, "at", 2, nil},
+		{scope.Store, "au", 5, nil},
+		{scope.Store, "xx", 0, errors.IsNotFound},
+		{scope.Group, "dach", 0, errors.IsNotSupported},
+		{scope.Default, "default", 0, errors.IsNotSupported},
+	}
+	for i, test := range tests {
+		haveID, haveErr := eurSrv.IDbyCode(test.scp, test.code)
+		if test.wantErrBhf != nil {
+			assert.True(t, test.wantErrBhf(haveErr), "(%d) %+v", i, haveErr)
+		} else {
+			assert.NoError(t, haveErr, "(%d) %+v", i, haveErr)
+		}
+		assert.Exactly(t, test.wantID, haveID, "Index %d", i)
+	}
+}
+
 func TestService_AllowedStores(t *testing.T) {
 	eurSrv := storemock.NewEurozzyService(cfgmock.NewService())
 	tests := []struct {
@@ -481,10 +509,10 @@ func TestService_AllowedStores(t *testing.T) {
 
 func TestService_HasSingleStore(t *testing.T) {
 	s := store.MustNewService(cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: dml.MakeNullBool(true)}),
 	)
 	s1 := store.MustNewService(cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: dml.MakeNullBool(true)}),
 	)
 	s1.SingleStoreModeEnabled = false
 
@@ -509,7 +537,7 @@ func TestService_IsSingleStoreMode(t *testing.T) {
 	const xPath = `general/single_store_mode/enabled`
 
 	s := store.MustNewService(cfgmock.NewService(),
-		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: null.StringFrom("euro"), Name: null.StringFrom("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: null.BoolFrom(true)}),
+		store.WithTableWebsites(&store.TableWebsite{WebsiteID: 1, Code: dml.MakeNullString("euro"), Name: dml.MakeNullString("Europe"), SortOrder: 0, DefaultGroupID: 12, IsDefault: dml.MakeNullBool(true)}),
 	)
 
 	// no stores and backend not set so true
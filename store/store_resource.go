@@ -15,33 +15,21 @@
 package store
 
 import (
-	"github.com/corestoreio/pkg/storage/csdb"
-	"github.com/corestoreio/pkg/storage/dbr"
+	"github.com/corestoreio/pkg/sql/dml"
 )
 
-func init() {
-	TableCollection = csdb.MustInitTables(TableCollection,
-		csdb.WithTableDMLListeners(TableIndexStore,
-			dbr.MustNewListenerBucket(
-				dbr.Listen{
-					Name:      "admin store on top",
-					EventType: dbr.OnBeforeToSQL,
-					SelectFunc: func(sb *dbr.Select) {
-						sb.OrderBy("CASE WHEN main_table.store_id = 0 THEN 0 ELSE 1 END ASC")
-						sb.OrderBy("main_table.sort_order ASC")
-						sb.OrderBy("main_table.name ASC")
-					},
-				},
-				dbr.Listen{
-					EventType: dbr.OnBeforeToSQL,
-					InsertFunc: func(ib *dbr.Insert) {
-						// todo ... ?
-					},
-				},
-			),
-		),
-	)
-}
+// tableStoreListeners sorts the stores with the admin store on top, followed by
+// sort_order and name.
+var tableStoreListeners = dml.MustNewListenerBucket(
+	dml.Listen{
+		Name:      "admin store on top",
+		EventType: dml.OnBeforeToSQL,
+		ListenSelectFn: func(sb *dml.Select) {
+			sb.OrderBy("CASE WHEN main_table.store_id = 0 THEN 0 ELSE 1 END ASC")
+			sb.OrderBy("main_table.sort_order", "main_table.name")
+		},
+	},
+)
 
 // IsDefault returns true if the current store is the default store.
 func (s TableStore) IsDefault() bool {
@@ -17,10 +17,10 @@ package storemock
 import (
 	"fmt"
 
+	"github.com/corestoreio/errors"
 	"github.com/corestoreio/pkg/config"
+	"github.com/corestoreio/pkg/sql/dml"
 	"github.com/corestoreio/pkg/store"
-	"github.com/corestoreio/pkg/util/null"
-	"github.com/corestoreio/errors"
 )
 
 // NewStoreAU creates a new Store with an attached config.
@@ -28,8 +28,8 @@ import (
 func NewStoreAU(cfg config.Getter) (store.Store, error) {
 	st, err := store.NewStore(
 		cfg,
-		&store.TableStore{StoreID: 5, Code: null.StringFrom("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
-		&store.TableWebsite{WebsiteID: 2, Code: null.StringFrom("oz"), Name: null.StringFrom("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: null.BoolFrom(false)},
+		&store.TableStore{StoreID: 5, Code: dml.MakeNullString("au"), WebsiteID: 2, GroupID: 3, Name: "Australia", SortOrder: 10, IsActive: true},
+		&store.TableWebsite{WebsiteID: 2, Code: dml.MakeNullString("oz"), Name: dml.MakeNullString("OZ"), SortOrder: 20, DefaultGroupID: 3, IsDefault: dml.MakeNullBool(false)},
 		&store.TableGroup{GroupID: 3, WebsiteID: 2, Name: "Australia", RootCategoryID: 2, DefaultStoreID: 5},
 	)
 	return st, errors.Wrap(err, "[storemock] NewStoreAU")
@@ -14,7 +14,7 @@
 
 package store
 
-import "github.com/corestoreio/pkg/storage/csdb"
+import "github.com/corestoreio/pkg/sql/ddl"
 
 // TableCollection handles all tables and its columns. init() in generated Go file will set the value.
-var TableCollection *csdb.Tables
+var TableCollection *ddl.Tables
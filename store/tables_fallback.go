@@ -21,35 +21,132 @@
 package store
 
 import (
-	"database/sql"
+	"context"
 	"sort"
 
-	"github.com/corestoreio/pkg/storage/csdb"
-	"github.com/corestoreio/pkg/storage/dbr"
-	"github.com/corestoreio/pkg/util/null"
 	"github.com/corestoreio/errors"
-	"github.com/corestoreio/log"
+	"github.com/corestoreio/pkg/sql/ddl"
+	"github.com/corestoreio/pkg/sql/dml"
 )
 
-// TableIndex... is the index to a table. These constants are guaranteed
-// to stay the same for all Magento versions. Please access a table via this
-// constant instead of the raw table name. TableIndex iotas must start with 0.
-const (
-	TableIndexStore   = iota // Table: store
-	TableIndexGroup          // Table: store_group
-	TableIndexWebsite        // Table: store_website
-	TableIndexZZZ            // the maximum index, which is not available.
-)
-
-// will be initialized in tables_fallback_test.go
-//
 func init() {
-	TableCollection = csdb.MustInitTables(TableCollection,
-		csdb.WithTable(TableIndexStore, "store"),
-		csdb.WithTable(TableIndexGroup, "store_group"),
-		csdb.WithTable(TableIndexWebsite, "store_website"),
+	TableCollection = ddl.MustNewTables(
+		ddl.WithTable(
+			TableNameStore,
+			&ddl.Column{Field: `store_id`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Key: `PRI`, Extra: `auto_increment`},
+			&ddl.Column{Field: `code`, ColumnType: `varchar(32)`, Null: `YES`, Key: `UNI`},
+			&ddl.Column{Field: `website_id`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Key: `MUL`, Default: dml.MakeNullString(`0`)},
+			&ddl.Column{Field: `group_id`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Key: `MUL`, Default: dml.MakeNullString(`0`)},
+			&ddl.Column{Field: `name`, ColumnType: `varchar(255)`, Null: `NO`},
+			&ddl.Column{Field: `sort_order`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Default: dml.MakeNullString(`0`)},
+			&ddl.Column{Field: `is_active`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Key: `MUL`, Default: dml.MakeNullString(`0`)},
+		),
+		ddl.WithTable(
+			TableNameStoreGroup,
+			&ddl.Column{Field: `group_id`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Key: `PRI`, Extra: `auto_increment`},
+			&ddl.Column{Field: `website_id`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Key: `MUL`, Default: dml.MakeNullString(`0`)},
+			&ddl.Column{Field: `name`, ColumnType: `varchar(255)`, Null: `NO`},
+			&ddl.Column{Field: `root_category_id`, ColumnType: `int(10) unsigned`, Null: `NO`, Default: dml.MakeNullString(`0`)},
+			&ddl.Column{Field: `default_store_id`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Key: `MUL`, Default: dml.MakeNullString(`0`)},
+		),
+		ddl.WithTable(
+			TableNameStoreWebsite,
+			&ddl.Column{Field: `website_id`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Key: `PRI`, Extra: `auto_increment`},
+			&ddl.Column{Field: `code`, ColumnType: `varchar(32)`, Null: `YES`, Key: `UNI`},
+			&ddl.Column{Field: `name`, ColumnType: `varchar(64)`, Null: `YES`},
+			&ddl.Column{Field: `sort_order`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Key: `MUL`, Default: dml.MakeNullString(`0`)},
+			&ddl.Column{Field: `default_group_id`, ColumnType: `smallint(5) unsigned`, Null: `NO`, Key: `MUL`, Default: dml.MakeNullString(`0`)},
+			&ddl.Column{Field: `is_default`, ColumnType: `smallint(5) unsigned`, Null: `YES`, Default: dml.MakeNullString(`0`)},
+		),
+		ddl.WithTableDMLListeners(TableNameStore, tableStoreListeners),
 	)
-	// Don't forget to call TableCollection.ReInit(...) in your code to load the column definitions.
+}
+
+// tableResource provides CRUD for one table to the MySQL database.
+type tableResource struct {
+	db *dml.ConnPool
+	// table contains a shallow copy from the table in TableCollection.
+	table *ddl.Table
+	// stmt contains the prepared SELECT statement, if PrepareSelect has been
+	// called.
+	stmt *dml.Stmt
+}
+
+func newTableResource(db *dml.ConnPool, tableName string) tableResource {
+	t := new(ddl.Table)
+	*t = *(TableCollection.MustTable(tableName)) // shallow copy
+	t.DB = db.DB
+	return tableResource{db: db, table: t}
+}
+
+func (tr *tableResource) prepareSelect(ctx context.Context) (err error) {
+	tr.stmt, err = tr.table.SelectAll().Prepare(ctx)
+	return errors.Wrapf(err, "[store] Prepare SELECT for table %q", tr.table.Name)
+}
+
+func (tr *tableResource) load(ctx context.Context, cm dml.ColumnMapper, args ...interface{}) error {
+	a := tr.db.WithQueryBuilder(tr.table.SelectAll())
+	if tr.stmt != nil {
+		a = tr.stmt.WithArgs()
+	}
+	_, err := a.Load(ctx, cm, args...)
+	return errors.Wrapf(err, "[store] Load table %q", tr.table.Name)
+}
+
+// insert writes all entities with one multi row INSERT and returns the last
+// insert ID, which is the ID of the first inserted row.
+func (tr *tableResource) insert(ctx context.Context, cm dml.ColumnMapper) (int, error) {
+	res, err := tr.table.Insert().WithArgs().Record("", cm).ExecContext(ctx)
+	if err != nil {
+		return 0, errors.Wrapf(err, "[store] Insert into table %q", tr.table.Name)
+	}
+	id, err := res.LastInsertId()
+	return int(id), errors.WithStack(err)
+}
+
+// update updates each entity by its primary key with a prepared statement
+// within one transaction.
+func (tr *tableResource) update(ctx context.Context, entities ...dml.ColumnMapper) (affectedRows int, _ error) {
+	err := tr.db.Transaction(ctx, nil, func(tx *dml.Tx) error {
+		stmt, err := tr.table.UpdateByPK().WithDB(tx.DB).Prepare(ctx)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		defer stmt.Close()
+		for _, e := range entities {
+			res, err := stmt.WithArgs().Record("", e).ExecContext(ctx)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			ra, err := res.RowsAffected()
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			affectedRows += int(ra)
+		}
+		return nil
+	})
+	return affectedRows, errors.Wrapf(err, "[store] Update table %q", tr.table.Name)
+}
+
+// delete deletes all rows by their primary key.
+func (tr *tableResource) delete(ctx context.Context, pkColumn string, ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res, err := tr.db.DeleteFrom(tr.table.Name).Where(dml.Column(pkColumn).In().Int64s(ids...)).WithArgs().ExecContext(ctx)
+	if err != nil {
+		return 0, errors.Wrapf(err, "[store] Delete from table %q", tr.table.Name)
+	}
+	ra, err := res.RowsAffected()
+	return int(ra), errors.WithStack(err)
+}
+
+func (tr *tableResource) Close() error {
+	if tr.stmt != nil {
+		return errors.WithStack(tr.stmt.Close())
+	}
+	return nil
 }
 
 // TableStoresResourcer can load a single item or a collection from a backend
@@ -63,131 +160,51 @@ type TableStoresResourcer interface {
 	Close() error
 }
 
-//// TableStoresMock used to testing. Satisfies interface TableStoresDBer
-//type TableStoresMock struct {
-//	SelectFn func() (TableStoreSlice, error)
-//	InsertFn func(TableStoreSlice) (lastInsertID int, err error)
-//	UpdateFn func(TableStoreSlice) (affectedRows int, err error)
-//	DeleteFn func(TableStoreSlice) (affectedRows int, err error)
-//}
-//
-//func (tsm TableStoresMock) Select() (TableStoreSlice, error) {
-//	return tsm.SelectFn()
-//}
-//
-//func (tsm TableStoresMock) Insert(ts TableStoreSlice) (lastInsertID int, err error) {
-//	return tsm.InsertFn(ts)
-//}
-//
-//func (tsm TableStoresMock) Update(ts TableStoreSlice) (affectedRows int, err error) {
-//	return tsm.UpdateFn(ts)
-//}
-//
-//func (tsm TableStoresMock) Delete(ts TableStoreSlice) (affectedRows int, err error) {
-//	return tsm.DeleteFn(ts)
-//}
-
 // NewTableStoreResource creates a new resource for CRUD operations for table
-// `store`. Default logger: Blackhole.
-func NewTableStoreResource(db dbr.DBer) *tableStoreResource {
-	// accept interfaces but return structs
-	t := new(csdb.Table)
-	*t = *(TableCollection.MustTable(TableIndexStore)) // shallow copy
-	ts := &tableStoreResource{
-		Log:   log.BlackHole{},
-		DB:    db,
-		Table: t,
-	}
-	return ts
-}
-
-// tableStoreResource provides CRUD to the MySQL database
-// Generated via tableToStruct.
-type tableStoreResource struct {
-	Log log.Logger
-	DB  dbr.DBer
-	// Table contains a shallow copy from the table in TableCollection
-	Table *csdb.Table
-	// Listeners custom listeners which get applied after the global listeners
-	// from TableCollection.
-	Listeners dbr.ListenerBucket
-	// SliceCap capacity of the TableStoreSlice. Default 10.
-	SliceCap int
-	stmt     *sql.Stmt
-	// Idea: ResurrectPreparer; handles different queries
-}
-
-func (ts *tableStoreResource) Close() error {
-	if ts.stmt != nil {
-		return ts.stmt.Close()
-	}
-	return nil
+// `store`.
+func NewTableStoreResource(db *dml.ConnPool) *TableStoreResource {
+	return &TableStoreResource{tableResource: newTableResource(db, TableNameStore)}
 }
 
-func (ts *tableStoreResource) PrepareSelect() error {
-	sb := &dbr.Select{
-		Logger:    ts.Log,
-		Preparer:  ts.DB,
-		Columns:   dbr.Quoter.ColumnsWithQualifier(csdb.MainTable, "store_id", "code", "website_id", "group_id", "name", "sort_order", "is_active"),
-		FromTable: dbr.MakeIdentifier(ts.Table.Name, csdb.MainTable),
-	}
-	sb.Listeners.Merge(ts.Table.Listeners.Select) // global listeners
-	sb.Listeners.Merge(ts.Listeners.Select)       // custom listeners
-
-	var err error
-	ts.stmt, err = sb.Prepare()
-	return errors.Wrap(err, "[store] ....todo")
-}
-
-// Select creates a prepared long lived statement.
+// TableStoreResource provides CRUD to the MySQL database table `store`.
 // Generated via tableToStruct.
-func (ts *tableStoreResource) Select(args ...interface{}) (TableStoreSlice, error) {
-	if ts.stmt == nil {
-		// run normal query without prepare
-	}
-
-	rows, err := ts.stmt.Query()
-	if err != nil {
-		return nil, errors.Wrap(err, "[store] TableStores.LoadAll.Rows")
-	}
-	defer rows.Close()
+type TableStoreResource struct {
+	tableResource
+}
 
-	sCap := 10
-	if ts.SliceCap > 0 {
-		sCap = ts.SliceCap
-	}
-	tss := make(TableStoreSlice, 0, sCap)
+// PrepareSelect creates a prepared long lived statement for Select. It must be
+// called before the resource gets used concurrently.
+func (r *TableStoreResource) PrepareSelect() error {
+	return r.prepareSelect(context.Background())
+}
 
-	for rows.Next() {
-		ts := new(TableStore)
-		if err := rows.Scan(&ts.StoreID, &ts.Code, &ts.WebsiteID, &ts.GroupID, &ts.Name, &ts.SortOrder, &ts.IsActive); err != nil {
-			return nil, errors.Wrap(err, "[store] TableStores.LoadAll.Rows.Scan")
-		}
-		tss = append(tss, ts)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "[store] TableStores.LoadAll.Rows.Err")
+// Select loads all stores, the admin store first, ordered by sort_order and
+// name.
+func (r *TableStoreResource) Select(args ...interface{}) (TableStoreSlice, error) {
+	tss := make(TableStoreSlice, 0, 10)
+	if err := r.load(context.Background(), &tss, args...); err != nil {
+		return nil, errors.WithStack(err)
 	}
-
 	return tss, nil
 }
 
-// SQLInsert inserts all records into the database @todo.
-// Generated via tableToStruct.
-func (s *tableStoreResource) Insert(tss TableStoreSlice) (int, error) {
-	return 0, errors.NewNotSupportedf("[store] Missing implementation")
+// Insert inserts all records into the database.
+func (r *TableStoreResource) Insert(tss TableStoreSlice) (int, error) {
+	return r.insert(context.Background(), &tss)
 }
 
-// SQLUpdate updates all record in the database @todo.
-// Generated via tableToStruct.
-func (s *tableStoreResource) Update(tss TableStoreSlice) (int, error) {
-	return 0, errors.NewNotSupportedf("[store] Missing implementation")
+// Update updates all records in the database by their primary key.
+func (r *TableStoreResource) Update(tss TableStoreSlice) (int, error) {
+	ems := make([]dml.ColumnMapper, len(tss))
+	for i, ts := range tss {
+		ems[i] = ts
+	}
+	return r.update(context.Background(), ems...)
 }
 
-// SQLDelete deletes all record from the database @todo.
-// Generated via tableToStruct.
-func (s *tableStoreResource) Delete(tss TableStoreSlice) (int, error) {
-	return 0, errors.NewNotSupportedf("[store] Missing implementation")
+// Delete deletes all records from the database by their primary key.
+func (r *TableStoreResource) Delete(tss TableStoreSlice) (int, error) {
+	return r.delete(context.Background(), "store_id", tss.Extract().StoreID())
 }
 
 // TableStoreSlice represents a collection of TableStore entities
@@ -196,16 +213,75 @@ type TableStoreSlice []*TableStore
 // TableStore represents a type for DB table store
 // Generated via tableToStruct.
 type TableStore struct {
-	DB      dbr.DBer            `json:"omit"`
-	HookDML *dbr.ListenerBucket `json:"omit"`
+	StoreID   int64          `json:",omitempty"` // store_id smallint(5) unsigned NOT NULL PRI  auto_increment
+	Code      dml.NullString `json:",omitempty"` // code varchar(32) NULL UNI
+	WebsiteID int64          `json:",omitempty"` // website_id smallint(5) unsigned NOT NULL MUL DEFAULT '0'
+	GroupID   int64          `json:",omitempty"` // group_id smallint(5) unsigned NOT NULL MUL DEFAULT '0'
+	Name      string         `json:",omitempty"` // name varchar(255) NOT NULL
+	SortOrder int64          `json:",omitempty"` // sort_order smallint(5) unsigned NOT NULL  DEFAULT '0'
+	IsActive  bool           `json:",omitempty"` // is_active smallint(5) unsigned NOT NULL MUL DEFAULT '0'
+}
+
+// MapColumns implements interface dml.ColumnMapper.
+func (e *TableStore) MapColumns(cm *dml.ColumnMap) error {
+	if cm.Mode() == dml.ColumnMapEntityReadAll {
+		return cm.Int64(&e.StoreID).NullString(&e.Code).Int64(&e.WebsiteID).Int64(&e.GroupID).String(&e.Name).Int64(&e.SortOrder).Bool(&e.IsActive).Err()
+	}
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "store_id":
+			cm.Int64(&e.StoreID)
+		case "code":
+			cm.NullString(&e.Code)
+		case "website_id":
+			cm.Int64(&e.WebsiteID)
+		case "group_id":
+			cm.Int64(&e.GroupID)
+		case "name":
+			cm.String(&e.Name)
+		case "sort_order":
+			cm.Int64(&e.SortOrder)
+		case "is_active":
+			cm.Bool(&e.IsActive)
+		default:
+			return errors.NotFound.Newf("[store] TableStore Column %q not found", c)
+		}
+	}
+	return cm.Err()
+}
 
-	StoreID   int64       `db:"store_id" json:",omitempty"`   // store_id smallint(5) unsigned NOT NULL PRI  auto_increment
-	Code      null.String `db:"code" json:",omitempty"`       // code varchar(32) NULL UNI
-	WebsiteID int64       `db:"website_id" json:",omitempty"` // website_id smallint(5) unsigned NOT NULL MUL DEFAULT '0'
-	GroupID   int64       `db:"group_id" json:",omitempty"`   // group_id smallint(5) unsigned NOT NULL MUL DEFAULT '0'
-	Name      string      `db:"name" json:",omitempty"`       // name varchar(255) NOT NULL
-	SortOrder int64       `db:"sort_order" json:",omitempty"` // sort_order smallint(5) unsigned NOT NULL  DEFAULT '0'
-	IsActive  bool        `db:"is_active" json:",omitempty"`  // is_active smallint(5) unsigned NOT NULL MUL DEFAULT '0'
+// MapColumns implements interface dml.ColumnMapper. In the read modes all
+// entities get written as arguments, which creates a multi row INSERT.
+func (s *TableStoreSlice) MapColumns(cm *dml.ColumnMap) error {
+	switch m := cm.Mode(); m {
+	case dml.ColumnMapEntityReadAll, dml.ColumnMapEntityReadSet:
+		for _, e := range *s {
+			if err := e.MapColumns(cm); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case dml.ColumnMapScan:
+		if cm.Count == 0 {
+			*s = (*s)[:0]
+		}
+		e := new(TableStore)
+		if err := e.MapColumns(cm); err != nil {
+			return errors.WithStack(err)
+		}
+		*s = append(*s, e)
+	case dml.ColumnMapCollectionReadSet:
+		for cm.Next() {
+			switch c := cm.Column(); c {
+			case "code":
+				cm.Strings(s.Extract().Code()...)
+			default:
+				return errors.NotFound.Newf("[store] TableStoreSlice Column %q not found", c)
+			}
+		}
+	default:
+		return errors.NotSupported.Newf("[store] Unknown Mode: %q", string(m))
+	}
+	return cm.Err()
 }
 
 // FindByStoreID searches the primary keys and returns a
@@ -440,6 +516,52 @@ type TableGroupsResourcer interface {
 	Delete(TableGroupSlice) (affectedRows int, err error)
 }
 
+// NewTableGroupResource creates a new resource for CRUD operations for table
+// `store_group`.
+func NewTableGroupResource(db *dml.ConnPool) *TableGroupResource {
+	return &TableGroupResource{tableResource: newTableResource(db, TableNameStoreGroup)}
+}
+
+// TableGroupResource provides CRUD to the MySQL database table `store_group`.
+// Generated via tableToStruct.
+type TableGroupResource struct {
+	tableResource
+}
+
+// PrepareSelect creates a prepared long lived statement for Select. It must be
+// called before the resource gets used concurrently.
+func (r *TableGroupResource) PrepareSelect() error {
+	return r.prepareSelect(context.Background())
+}
+
+// Select loads all rows.
+func (r *TableGroupResource) Select() (TableGroupSlice, error) {
+	ts := make(TableGroupSlice, 0, 5)
+	if err := r.load(context.Background(), &ts); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ts, nil
+}
+
+// Insert inserts all records into the database.
+func (r *TableGroupResource) Insert(ts TableGroupSlice) (int, error) {
+	return r.insert(context.Background(), &ts)
+}
+
+// Update updates all records in the database by their primary key.
+func (r *TableGroupResource) Update(ts TableGroupSlice) (int, error) {
+	ems := make([]dml.ColumnMapper, len(ts))
+	for i, t := range ts {
+		ems[i] = t
+	}
+	return r.update(context.Background(), ems...)
+}
+
+// Delete deletes all records from the database by their primary key.
+func (r *TableGroupResource) Delete(ts TableGroupSlice) (int, error) {
+	return r.delete(context.Background(), "group_id", ts.Extract().GroupID())
+}
+
 // TableGroupSlice represents a collection type for DB table store_group
 // Generated via tableToStruct.
 type TableGroupSlice []*TableGroup
@@ -447,37 +569,70 @@ type TableGroupSlice []*TableGroup
 // TableGroup represents a type for DB table store_group
 // Generated via tableToStruct.
 type TableGroup struct {
-	GroupID        int64  `db:"group_id" json:",omitempty"`         // group_id smallint(5) unsigned NOT NULL PRI  auto_increment
-	WebsiteID      int64  `db:"website_id" json:",omitempty"`       // website_id smallint(5) unsigned NOT NULL MUL DEFAULT '0'
-	Name           string `db:"name" json:",omitempty"`             // name varchar(255) NOT NULL
-	RootCategoryID int64  `db:"root_category_id" json:",omitempty"` // root_category_id int(10) unsigned NOT NULL  DEFAULT '0'
-	DefaultStoreID int64  `db:"default_store_id" json:",omitempty"` // default_store_id smallint(5) unsigned NOT NULL MUL DEFAULT '0'
+	GroupID        int64  `json:",omitempty"` // group_id smallint(5) unsigned NOT NULL PRI  auto_increment
+	WebsiteID      int64  `json:",omitempty"` // website_id smallint(5) unsigned NOT NULL MUL DEFAULT '0'
+	Name           string `json:",omitempty"` // name varchar(255) NOT NULL
+	RootCategoryID int64  `json:",omitempty"` // root_category_id int(10) unsigned NOT NULL  DEFAULT '0'
+	DefaultStoreID int64  `json:",omitempty"` // default_store_id smallint(5) unsigned NOT NULL MUL DEFAULT '0'
 }
 
-//
-//// parentSQLSelect fills this slice with data from the database.
-//// Generated via tableToStruct.
-//func (s *TableGroupSlice) parentSQLSelect(dbrSess dbr.SessionRunner, cbs ...dbr.SelectEvent) (int, error) {
-//	return 0, nil // csdb.Load(dbrSess, TableCollection, TableIndexGroup, &(*s), cbs...)
-//}
-//
-//// SQLInsert inserts all records into the database @todo.
-//// Generated via tableToStruct.
-//func (s *TableGroupSlice) SQLInsert(dbrSess dbr.SessionRunner, cbs ...dbr.InsertEvent) (int, error) {
-//	return 0, nil
-//}
-//
-//// SQLUpdate updates all record in the database @todo.
-//// Generated via tableToStruct.
-//func (s *TableGroupSlice) SQLUpdate(dbrSess dbr.SessionRunner, cbs ...dbr.UpdateEvent) (int, error) {
-//	return 0, nil
-//}
-//
-//// SQLDelete deletes all record from the database @todo.
-//// Generated via tableToStruct.
-//func (s *TableGroupSlice) SQLDelete(dbrSess dbr.SessionRunner, cbs ...dbr.DeleteEvent) (int, error) {
-//	return 0, nil
-//}
+// MapColumns implements interface dml.ColumnMapper.
+func (e *TableGroup) MapColumns(cm *dml.ColumnMap) error {
+	if cm.Mode() == dml.ColumnMapEntityReadAll {
+		return cm.Int64(&e.GroupID).Int64(&e.WebsiteID).String(&e.Name).Int64(&e.RootCategoryID).Int64(&e.DefaultStoreID).Err()
+	}
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "group_id":
+			cm.Int64(&e.GroupID)
+		case "website_id":
+			cm.Int64(&e.WebsiteID)
+		case "name":
+			cm.String(&e.Name)
+		case "root_category_id":
+			cm.Int64(&e.RootCategoryID)
+		case "default_store_id":
+			cm.Int64(&e.DefaultStoreID)
+		default:
+			return errors.NotFound.Newf("[store] TableGroup Column %q not found", c)
+		}
+	}
+	return cm.Err()
+}
+
+// MapColumns implements interface dml.ColumnMapper. In the read modes all
+// entities get written as arguments, which creates a multi row INSERT.
+func (s *TableGroupSlice) MapColumns(cm *dml.ColumnMap) error {
+	switch m := cm.Mode(); m {
+	case dml.ColumnMapEntityReadAll, dml.ColumnMapEntityReadSet:
+		for _, e := range *s {
+			if err := e.MapColumns(cm); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case dml.ColumnMapScan:
+		if cm.Count == 0 {
+			*s = (*s)[:0]
+		}
+		e := new(TableGroup)
+		if err := e.MapColumns(cm); err != nil {
+			return errors.WithStack(err)
+		}
+		*s = append(*s, e)
+	case dml.ColumnMapCollectionReadSet:
+		for cm.Next() {
+			switch c := cm.Column(); c {
+			case "name":
+				cm.Strings(s.Extract().Name()...)
+			default:
+				return errors.NotFound.Newf("[store] TableGroupSlice Column %q not found", c)
+			}
+		}
+	default:
+		return errors.NotSupported.Newf("[store] Unknown Mode: %q", string(m))
+	}
+	return cm.Err()
+}
 
 // FindByGroupID searches the primary keys and returns a
 // *TableGroup if found or nil and false.
@@ -672,7 +827,7 @@ func (s TableGroupSlice) Extract() ExtractGroup {
 	}
 }
 
-// TableGroupsResourcer can load a single item or a collection from a backend
+// TableWebsitesResourcer can load a single item or a collection from a backend
 // service aka. MySQL. Each implementation must be thread safe.
 type TableWebsitesResourcer interface {
 	Select() (TableWebsiteSlice, error)
@@ -681,6 +836,52 @@ type TableWebsitesResourcer interface {
 	Delete(TableWebsiteSlice) (affectedRows int, err error)
 }
 
+// NewTableWebsiteResource creates a new resource for CRUD operations for table
+// `store_website`.
+func NewTableWebsiteResource(db *dml.ConnPool) *TableWebsiteResource {
+	return &TableWebsiteResource{tableResource: newTableResource(db, TableNameStoreWebsite)}
+}
+
+// TableWebsiteResource provides CRUD to the MySQL database table `store_website`.
+// Generated via tableToStruct.
+type TableWebsiteResource struct {
+	tableResource
+}
+
+// PrepareSelect creates a prepared long lived statement for Select. It must be
+// called before the resource gets used concurrently.
+func (r *TableWebsiteResource) PrepareSelect() error {
+	return r.prepareSelect(context.Background())
+}
+
+// Select loads all rows.
+func (r *TableWebsiteResource) Select() (TableWebsiteSlice, error) {
+	ts := make(TableWebsiteSlice, 0, 5)
+	if err := r.load(context.Background(), &ts); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ts, nil
+}
+
+// Insert inserts all records into the database.
+func (r *TableWebsiteResource) Insert(ts TableWebsiteSlice) (int, error) {
+	return r.insert(context.Background(), &ts)
+}
+
+// Update updates all records in the database by their primary key.
+func (r *TableWebsiteResource) Update(ts TableWebsiteSlice) (int, error) {
+	ems := make([]dml.ColumnMapper, len(ts))
+	for i, t := range ts {
+		ems[i] = t
+	}
+	return r.update(context.Background(), ems...)
+}
+
+// Delete deletes all records from the database by their primary key.
+func (r *TableWebsiteResource) Delete(ts TableWebsiteSlice) (int, error) {
+	return r.delete(context.Background(), "website_id", ts.Extract().WebsiteID())
+}
+
 // TableWebsiteSlice represents a collection type for DB table store_website
 // Generated via tableToStruct.
 type TableWebsiteSlice []*TableWebsite
@@ -688,37 +889,73 @@ type TableWebsiteSlice []*TableWebsite
 // TableWebsite represents a type for DB table store_website
 // Generated via tableToStruct.
 type TableWebsite struct {
-	WebsiteID      int64       `db:"website_id" json:",omitempty"`       // website_id smallint(5) unsigned NOT NULL PRI  auto_increment
-	Code           null.String `db:"code" json:",omitempty"`             // code varchar(32) NULL UNI
-	Name           null.String `db:"name" json:",omitempty"`             // name varchar(64) NULL
-	SortOrder      int64       `db:"sort_order" json:",omitempty"`       // sort_order smallint(5) unsigned NOT NULL MUL DEFAULT '0'
-	DefaultGroupID int64       `db:"default_group_id" json:",omitempty"` // default_group_id smallint(5) unsigned NOT NULL MUL DEFAULT '0'
-	IsDefault      null.Bool   `db:"is_default" json:",omitempty"`       // is_default smallint(5) unsigned NULL  DEFAULT '0'
-}
-
-//// parentSQLSelect fills this slice with data from the database.
-//// Generated via tableToStruct.
-//func (s *TableWebsiteSlice) parentSQLSelect(dbrSess dbr.SessionRunner, cbs ...dbr.SelectEvent) (int, error) {
-//	return 0, nil // csdb.Load(dbrSess, TableCollection, TableIndexWebsite, &(*s), cbs...)
-//}
-//
-//// SQLInsert inserts all records into the database @todo.
-//// Generated via tableToStruct.
-//func (s *TableWebsiteSlice) SQLInsert(dbrSess dbr.SessionRunner, cbs ...dbr.InsertEvent) (int, error) {
-//	return 0, nil
-//}
-//
-//// SQLUpdate updates all record in the database @todo.
-//// Generated via tableToStruct.
-//func (s *TableWebsiteSlice) SQLUpdate(dbrSess dbr.SessionRunner, cbs ...dbr.UpdateEvent) (int, error) {
-//	return 0, nil
-//}
-//
-//// SQLDelete deletes all record from the database @todo.
-//// Generated via tableToStruct.
-//func (s *TableWebsiteSlice) SQLDelete(dbrSess dbr.SessionRunner, cbs ...dbr.DeleteEvent) (int, error) {
-//	return 0, nil
-//}
+	WebsiteID      int64          `json:",omitempty"` // website_id smallint(5) unsigned NOT NULL PRI  auto_increment
+	Code           dml.NullString `json:",omitempty"` // code varchar(32) NULL UNI
+	Name           dml.NullString `json:",omitempty"` // name varchar(64) NULL
+	SortOrder      int64          `json:",omitempty"` // sort_order smallint(5) unsigned NOT NULL MUL DEFAULT '0'
+	DefaultGroupID int64          `json:",omitempty"` // default_group_id smallint(5) unsigned NOT NULL MUL DEFAULT '0'
+	IsDefault      dml.NullBool   `json:",omitempty"` // is_default smallint(5) unsigned NULL  DEFAULT '0'
+}
+
+// MapColumns implements interface dml.ColumnMapper.
+func (e *TableWebsite) MapColumns(cm *dml.ColumnMap) error {
+	if cm.Mode() == dml.ColumnMapEntityReadAll {
+		return cm.Int64(&e.WebsiteID).NullString(&e.Code).NullString(&e.Name).Int64(&e.SortOrder).Int64(&e.DefaultGroupID).NullBool(&e.IsDefault).Err()
+	}
+	for cm.Next() {
+		switch c := cm.Column(); c {
+		case "website_id":
+			cm.Int64(&e.WebsiteID)
+		case "code":
+			cm.NullString(&e.Code)
+		case "name":
+			cm.NullString(&e.Name)
+		case "sort_order":
+			cm.Int64(&e.SortOrder)
+		case "default_group_id":
+			cm.Int64(&e.DefaultGroupID)
+		case "is_default":
+			cm.NullBool(&e.IsDefault)
+		default:
+			return errors.NotFound.Newf("[store] TableWebsite Column %q not found", c)
+		}
+	}
+	return cm.Err()
+}
+
+// MapColumns implements interface dml.ColumnMapper. In the read modes all
+// entities get written as arguments, which creates a multi row INSERT.
+func (s *TableWebsiteSlice) MapColumns(cm *dml.ColumnMap) error {
+	switch m := cm.Mode(); m {
+	case dml.ColumnMapEntityReadAll, dml.ColumnMapEntityReadSet:
+		for _, e := range *s {
+			if err := e.MapColumns(cm); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	case dml.ColumnMapScan:
+		if cm.Count == 0 {
+			*s = (*s)[:0]
+		}
+		e := new(TableWebsite)
+		if err := e.MapColumns(cm); err != nil {
+			return errors.WithStack(err)
+		}
+		*s = append(*s, e)
+	case dml.ColumnMapCollectionReadSet:
+		for cm.Next() {
+			switch c := cm.Column(); c {
+			case "code":
+				cm.Strings(s.Extract().Code()...)
+			default:
+				return errors.NotFound.Newf("[store] TableWebsiteSlice Column %q not found", c)
+			}
+		}
+	default:
+		return errors.NotSupported.Newf("[store] Unknown Mode: %q", string(m))
+	}
+	return cm.Err()
+}
 
 // FindByWebsiteID searches the primary keys and returns a
 // *TableWebsite if found or nil and false.
@@ -1,4 +1,4 @@
-// Copyright 2015-2016, Cyrill @ Schumacher.fm and the CoreStore contributors
+// Copyright 2015-present, Cyrill @ Schumacher.fm and the CoreStore contributors
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,77 +17,158 @@ package store_test
 import (
 	"testing"
 
-	"github.com/corestoreio/pkg/storage/csdb"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/corestoreio/pkg/sql/dml"
+	"github.com/corestoreio/pkg/sql/dmltest"
 	"github.com/corestoreio/pkg/store"
-	"github.com/corestoreio/pkg/util/cstesting"
-	"github.com/corestoreio/pkg/util/null"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func init() {
-	store.TableCollection = csdb.MustInitTables(store.TableCollection,
-		csdb.WithTable(
-			store.TableIndexStore,
-			"store",
-			&csdb.Column{Field: (`store_id`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (`PRI`), Extra: (`auto_increment`)},
-			&csdb.Column{Field: (`code`), ColumnType: (`varchar(32)`), Null: (`YES`), Key: (`UNI`), Extra: (``)},
-			&csdb.Column{Field: (`website_id`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (`MUL`), Default: null.StringFrom(`0`), Extra: (``)},
-			&csdb.Column{Field: (`group_id`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (`MUL`), Default: null.StringFrom(`0`), Extra: (``)},
-			&csdb.Column{Field: (`name`), ColumnType: (`varchar(255)`), Null: (`NO`), Key: (``), Extra: (``)},
-			&csdb.Column{Field: (`sort_order`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (``), Default: null.StringFrom(`0`), Extra: (``)},
-			&csdb.Column{Field: (`is_active`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (`MUL`), Default: null.StringFrom(`0`), Extra: (``)},
-		),
-		csdb.WithTable(
-			store.TableIndexGroup,
-			"store_group",
-			&csdb.Column{Field: (`group_id`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (`PRI`), Extra: (`auto_increment`)},
-			&csdb.Column{Field: (`website_id`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (`MUL`), Default: null.StringFrom(`0`), Extra: (``)},
-			&csdb.Column{Field: (`name`), ColumnType: (`varchar(255)`), Null: (`NO`), Key: (``), Extra: (``)},
-			&csdb.Column{Field: (`root_category_id`), ColumnType: (`int(10) unsigned`), Null: (`NO`), Key: (``), Default: null.StringFrom(`0`), Extra: (``)},
-			&csdb.Column{Field: (`default_store_id`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (`MUL`), Default: null.StringFrom(`0`), Extra: (``)},
-		),
-		csdb.WithTable(
-			store.TableIndexWebsite,
-			"store_website",
-			&csdb.Column{Field: (`website_id`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (`PRI`), Extra: (`auto_increment`)},
-			&csdb.Column{Field: (`code`), ColumnType: (`varchar(32)`), Null: (`YES`), Key: (`UNI`), Extra: (``)},
-			&csdb.Column{Field: (`name`), ColumnType: (`varchar(64)`), Null: (`YES`), Key: (``), Extra: (``)},
-			&csdb.Column{Field: (`sort_order`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (`MUL`), Default: null.StringFrom(`0`), Extra: (``)},
-			&csdb.Column{Field: (`default_group_id`), ColumnType: (`smallint(5) unsigned`), Null: (`NO`), Key: (`MUL`), Default: null.StringFrom(`0`), Extra: (``)},
-			&csdb.Column{Field: (`is_default`), ColumnType: (`smallint(5) unsigned`), Null: (`YES`), Key: (``), Default: null.StringFrom(`0`), Extra: (``)},
-		),
-	)
-}
+var (
+	_ store.TableStoresResourcer   = (*store.TableStoreResource)(nil)
+	_ store.TableGroupsResourcer   = (*store.TableGroupResource)(nil)
+	_ store.TableWebsitesResourcer = (*store.TableWebsiteResource)(nil)
+)
 
-func TestNewTableStoreResource_Select(t *testing.T) {
+func TestTableStoreResource_Select(t *testing.T) {
+	t.Parallel()
 
-	dbc, dbMock := cstesting.MockDB(t)
-	defer func() {
-		dbMock.ExpectClose()
-		assert.NoError(t, dbc.Close())
-		if err := dbMock.ExpectationsWereMet(); err != nil {
-			t.Error("there were unfulfilled expections", err)
-		}
-	}()
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
 
-	dbMock.ExpectQuery("SELECT (.+) FROM `store`(.+) ORDER BY CASE WHEN(.+)").WillReturnRows(
-		cstesting.MustMockRows(cstesting.WithFile("testdata", "m1_core_store_view.csv")),
+	dbMock.ExpectQuery("SELECT (.+) FROM `store` AS `main_table` ORDER BY CASE WHEN(.+)").WillReturnRows(
+		dmltest.MustMockRows(dmltest.WithFile("testdata", "m2_store.csv")),
 	)
 
-	// store.TableCollection already initialized
+	tss, err := store.NewTableStoreResource(dbc).Select()
+	require.NoError(t, err)
+	assert.Len(t, tss, 18)
+	for _, s := range tss {
+		assert.True(t, len(s.Name) > 1)
+	}
+	assert.Exactly(t, "admin", tss[0].Code.String)
+	assert.True(t, tss[0].IsDefault())
+}
 
-	tsr := store.NewTableStoreResource(dbc.DB)
+func TestTableStoreResource_PrepareSelect(t *testing.T) {
+	t.Parallel()
 
-	tss, err := tsr.Select()
-	assert.NoError(t, err)
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
 
-	if err := dbMock.ExpectationsWereMet(); err != nil {
-		t.Fatalf("%+v", err)
+	prep := dbMock.ExpectPrepare("SELECT (.+) FROM `store` AS `main_table` ORDER BY CASE WHEN(.+)")
+	for i := 0; i < 2; i++ {
+		prep.ExpectQuery().WillReturnRows(
+			dmltest.MustMockRows(dmltest.WithFile("testdata", "m2_store.csv")),
+		)
 	}
-	assert.Len(t, tss, 16)
+	prep.WillBeClosed()
 
-	for _, s := range tss {
-		//t.Logf("%#v", s)
-		assert.True(t, len(s.Name) > 1)
+	tsr := store.NewTableStoreResource(dbc)
+	require.NoError(t, tsr.PrepareSelect())
+	for i := 0; i < 2; i++ {
+		tss, err := tsr.Select()
+		require.NoError(t, err)
+		assert.Len(t, tss, 18)
 	}
+	require.NoError(t, tsr.Close())
+}
+
+func TestTableStoreResource_Insert(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("INSERT INTO `store` (`code`,`website_id`,`group_id`,`name`,`sort_order`,`is_active`) VALUES (?,?,?,?,?,?),(?,?,?,?,?,?)")).
+		WithArgs("de", int64(1), int64(1), "Germany", int64(10), true, "at", int64(1), int64(1), "Österreich", int64(20), false).
+		WillReturnResult(sqlmock.NewResult(21, 2))
+
+	id, err := store.NewTableStoreResource(dbc).Insert(store.TableStoreSlice{
+		{Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
+		{Code: dml.MakeNullString("at"), WebsiteID: 1, GroupID: 1, Name: "Österreich", SortOrder: 20},
+	})
+	require.NoError(t, err)
+	assert.Exactly(t, 21, id)
+}
+
+func TestTableStoreResource_Update(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectBegin()
+	prep := dbMock.ExpectPrepare("UPDATE `store` SET (.+) WHERE \\(`store_id` = \\?\\)")
+	prep.ExpectExec().WithArgs("de", int64(1), int64(1), "Germany", int64(10), true, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.ExpectExec().WithArgs("at", int64(1), int64(1), "Austria", int64(20), true, int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	prep.WillBeClosed()
+	dbMock.ExpectCommit()
+
+	ar, err := store.NewTableStoreResource(dbc).Update(store.TableStoreSlice{
+		{StoreID: 1, Code: dml.MakeNullString("de"), WebsiteID: 1, GroupID: 1, Name: "Germany", SortOrder: 10, IsActive: true},
+		{StoreID: 2, Code: dml.MakeNullString("at"), WebsiteID: 1, GroupID: 1, Name: "Austria", SortOrder: 20, IsActive: true},
+	})
+	require.NoError(t, err)
+	assert.Exactly(t, 2, ar)
+}
+
+func TestTableStoreResource_Delete(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectExec(dmltest.SQLMockQuoteMeta("DELETE FROM `store` WHERE (`store_id` IN (3,4))")).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	tsr := store.NewTableStoreResource(dbc)
+	ar, err := tsr.Delete(store.TableStoreSlice{{StoreID: 3}, {StoreID: 4}})
+	require.NoError(t, err)
+	assert.Exactly(t, 2, ar)
+
+	ar, err = tsr.Delete(nil)
+	require.NoError(t, err)
+	assert.Exactly(t, 0, ar)
+}
+
+func TestTableGroupResource_Select(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectQuery("SELECT (.+) FROM `store_group` AS `main_table`").WillReturnRows(
+		dmltest.MustMockRows(dmltest.WithFile("testdata", "m2_store_group.csv")),
+	)
+
+	tgs, err := store.NewTableGroupResource(dbc).Select()
+	require.NoError(t, err)
+	assert.Len(t, tgs, 9)
+	g, ok := tgs.FindByGroupID(1)
+	require.True(t, ok)
+	assert.Exactly(t, "POS United States", g.Name)
+	assert.Exactly(t, int64(4), g.DefaultStoreID)
+}
+
+func TestTableWebsiteResource_Select(t *testing.T) {
+	t.Parallel()
+
+	dbc, dbMock := dmltest.MockDB(t)
+	defer dmltest.MockClose(t, dbc, dbMock)
+
+	dbMock.ExpectQuery("SELECT (.+) FROM `store_website` AS `main_table`").WillReturnRows(
+		dmltest.MustMockRows(dmltest.WithFile("testdata", "m2_store_website.csv")),
+	)
+
+	tws, err := store.NewTableWebsiteResource(dbc).Select()
+	require.NoError(t, err)
+	assert.Len(t, tws, 6)
+	w, ok := tws.FindByWebsiteID(1)
+	require.True(t, ok)
+	assert.Exactly(t, "es", w.Code.String)
+	assert.False(t, w.IsDefault.Bool)
 }
@@ -17,30 +17,21 @@ package store_test
 import (
 	"testing"
 
-	"github.com/corestoreio/pkg/storage/dbr"
+	"github.com/corestoreio/pkg/sql/dmltest"
 	"github.com/corestoreio/pkg/store"
-	"github.com/corestoreio/pkg/util/cstesting"
-	"github.com/corestoreio/pkg/util/magento"
-	"github.com/corestoreio/log"
 )
 
-// BenchmarkIntegration_TableStoreSlice_Native-4   	   10000	    259183 ns/op	    8501 B/op	     306 allocs/op <= no prepare; Rows()
-// BenchmarkIntegration_TableStoreSlice_Native-4   	   10000	    217399 ns/op	    4704 B/op	     207 allocs/op <= prepared statement
-// with 18 rows
-func BenchmarkIntegration_TableStoreSlice_Native(b *testing.B) {
+// BenchmarkIntegration_TableStoreSlice_Prepared requires a Magento2 database
+// structure.
+func BenchmarkIntegration_TableStoreSlice_Prepared(b *testing.B) {
+	dbc := dmltest.MustConnectDB(b)
+	defer dmltest.Close(b, dbc)
 
-	dbc, mageVersion := cstesting.MustConnectDB()
-	if dbc == nil {
-		b.Skip("Environment DB DSN not found")
+	tsr := store.NewTableStoreResource(dbc)
+	if err := tsr.PrepareSelect(); err != nil {
+		b.Fatalf("%+v", err)
 	}
-	if mageVersion != magento.Version1 {
-		b.Skip("Expecting a Magento1 database structure")
-	}
-	defer dbc.Close()
-
-	tsr := store.NewTableStoreResource(dbc.DB)
-	tsr.Table.Name = "core_store"
-	defer tsr.Close()
+	defer dmltest.Close(b, tsr)
 
 	b.ReportAllocs()
 	b.ResetTimer()
@@ -49,40 +40,8 @@ func BenchmarkIntegration_TableStoreSlice_Native(b *testing.B) {
 		if err != nil {
 			b.Fatalf("%+v", err)
 		}
-		if have, want := len(tss), 18; have != want {
-			b.Errorf("Have: %v Want: %v\n%#v", have, want, tss)
-		}
-	}
-}
-
-// BenchmarkIntegration_TableStoreSlice_Reflection-4   	    5000	    308089 ns/op	   11390 B/op	     434 allocs/op <= without prepare
-func BenchmarkIntegration_TableStoreSlice_Reflection(b *testing.B) {
-	dbc, mageVersion := cstesting.MustConnectDB()
-	if dbc == nil {
-		b.Skip("Environment DB DSN not found")
-	}
-	if mageVersion != magento.Version1 {
-		b.Skip("Expecting a Magento1 database structure")
-	}
-
-	sb := &dbr.Select{
-		Logger:    log.BlackHole{EnableDebug: false, EnableInfo: false},
-		Querier:   dbc.DB,
-		Columns:   []string{"`main_table`.`store_id`", "`main_table`.`code`", "`main_table`.`website_id`", "`main_table`.`group_id`", "`main_table`.`name`", "`main_table`.`sort_order`", "`main_table`.`is_active`"},
-		FromTable: dbr.MakeIdentifier("core_store", "main_table"),
-	}
-
-	b.ReportAllocs()
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-
-		var tss store.TableStoreSlice
-		rows, err := sb.LoadStructs(&tss)
-		if err != nil {
-			b.Fatalf("%+v", err)
-		}
-		if have, want := rows, 18; have != want {
-			b.Errorf("Have: %v Want: %v", have, want)
+		if len(tss) == 0 {
+			b.Fatal("Expecting at least one store")
 		}
 	}
 }
@@ -17,42 +17,34 @@ package store_test
 import (
 	"testing"
 
+	"github.com/corestoreio/errors"
 	"github.com/corestoreio/pkg/store"
-	"github.com/corestoreio/pkg/util/cstesting"
 	"github.com/stretchr/testify/assert"
 )
 
 // These constants are here on purpose hard coded
-func TestGetTable(t *testing.T) {
-	dbc, _ := cstesting.MustConnectDB()
-	if dbc == nil {
-		t.Skip("Environment DB DSN not found")
-	}
-	defer func() { assert.NoError(t, dbc.Close()) }()
-
-	// store.TableCollection initialized with test TestTableGroupSliceLoad()
+func TestTableCollection(t *testing.T) {
+	t.Parallel()
 
 	tests := []struct {
-		ti    int
+		name  string
 		isErr bool
 	}{
-		{ti: store.TableIndexGroup, isErr: false},
-		{ti: store.TableIndexStore, isErr: false},
-		{ti: store.TableIndexWebsite, isErr: false},
-		{ti: store.TableIndexZZZ, isErr: true},
+		{name: "store_group", isErr: false},
+		{name: "store", isErr: false},
+		{name: "store_website", isErr: false},
+		{name: "ZZZ", isErr: true},
 	}
 
 	for _, test := range tests {
-		ts, err := store.TableCollection.Table(test.ti)
-		tn := store.TableCollection.Name(test.ti)
+		ts, err := store.TableCollection.Table(test.name)
 		if test.isErr == false {
 			assert.NoError(t, err)
-			assert.NotNil(t, ts)
-			assert.True(t, len(tn) > 1)
+			assert.Exactly(t, test.name, ts.Name)
+			assert.True(t, ts.Columns.Len() > 1)
 		} else {
-			assert.Error(t, err)
+			assert.True(t, errors.NotFound.Match(err), "%+v", err)
 			assert.Nil(t, ts)
-			assert.Len(t, tn, 0)
 		}
 	}
 }